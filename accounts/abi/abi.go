@@ -19,6 +19,7 @@ package abi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 
@@ -32,6 +33,12 @@ type ABI struct {
 	Constructor Method
 	Methods     map[string]Method
 	Events      map[string]Event
+	Errors      map[string]Error
+
+	// Fallback and Receive are the special unnamed functions introduced in
+	// Solidity v0.6.0, nil if the contract doesn't define them.
+	Fallback *Method
+	Receive  *Method
 }
 
 // JSON returns a parsed ABI interface and error if it failed.
@@ -114,12 +121,13 @@ func (abi ABI) UnpackIntoMap(v map[string]interface{}, name string, data []byte)
 // UnmarshalJSON implements json.Unmarshaler interface
 func (abi *ABI) UnmarshalJSON(data []byte) error {
 	var fields []struct {
-		Type      string
-		Name      string
-		Constant  bool
-		Anonymous bool
-		Inputs    []Argument
-		Outputs   []Argument
+		Type            string
+		Name            string
+		Constant        bool
+		StateMutability string
+		Anonymous       bool
+		Inputs          []Argument
+		Outputs         []Argument
 	}
 
 	if err := json.Unmarshal(data, &fields); err != nil {
@@ -128,6 +136,26 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 
 	abi.Methods = make(map[string]Method)
 	abi.Events = make(map[string]Event)
+	abi.Errors = make(map[string]Error)
+
+	// Reserve the declared names upfront, so that overloads are always suffixed
+	// deterministically and never shadow a function, event or error declared
+	// later on.
+	var (
+		methodNames = make(map[string]bool)
+		eventNames  = make(map[string]bool)
+		errorNames  = make(map[string]bool)
+	)
+	for _, field := range fields {
+		switch field.Type {
+		case "function", "":
+			methodNames[field.Name] = true
+		case "event":
+			eventNames[field.Name] = true
+		case "error":
+			errorNames[field.Name] = true
+		}
+	}
 	for _, field := range fields {
 		switch field.Type {
 		case "constructor":
@@ -136,27 +164,50 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 			}
 		// empty defaults to function according to the abi spec
 		case "function", "":
-			name := field.Name
-			_, ok := abi.Methods[name]
-			for idx := 0; ok; idx++ {
-				name = fmt.Sprintf("%s%d", field.Name, idx)
-				_, ok = abi.Methods[name]
-			}
+			_, exist := abi.Methods[field.Name]
+			name := overloadedName(field.Name, exist, func(name string) bool {
+				_, exist := abi.Methods[name]
+				return exist || methodNames[name]
+			})
 			abi.Methods[name] = Method{
 				Name:    name,
-				Const:   field.Constant,
+				RawName: field.Name,
+				Const:   field.Constant || field.StateMutability == "view" || field.StateMutability == "pure",
 				Inputs:  field.Inputs,
 				Outputs: field.Outputs,
 			}
-		case "event":
-			name := field.Name
-			_, ok := abi.Events[name]
-			for idx := 0; ok; idx++ {
-				name = fmt.Sprintf("%s%d", field.Name, idx)
-				_, ok = abi.Events[name]
+		case "fallback":
+			if abi.Fallback != nil {
+				return errors.New("abi: only a single fallback function is allowed")
+			}
+			abi.Fallback = &Method{Inputs: field.Inputs, Outputs: field.Outputs}
+		case "receive":
+			if abi.Receive != nil {
+				return errors.New("abi: only a single receive function is allowed")
+			}
+			if field.StateMutability != "payable" {
+				return errors.New("abi: receive function must be payable")
+			}
+			abi.Receive = new(Method)
+		case "error":
+			_, exist := abi.Errors[field.Name]
+			name := overloadedName(field.Name, exist, func(name string) bool {
+				_, exist := abi.Errors[name]
+				return exist || errorNames[name]
+			})
+			abi.Errors[name] = Error{
+				Name:   field.Name,
+				Inputs: field.Inputs,
 			}
+		case "event":
+			_, exist := abi.Events[field.Name]
+			name := overloadedName(field.Name, exist, func(name string) bool {
+				_, exist := abi.Events[name]
+				return exist || eventNames[name]
+			})
 			abi.Events[name] = Event{
 				Name:      name,
+				RawName:   field.Name,
 				Anonymous: field.Anonymous,
 				Inputs:    field.Inputs,
 			}
@@ -166,6 +217,20 @@ func (abi *ABI) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// overloadedName returns the name under which a function, event or error should
+// be stored. The first declaration keeps its raw name, every further overload gets
+// the first free numeric suffix (e.g. transfer, transfer0, transfer1).
+func overloadedName(rawName string, overloaded bool, taken func(string) bool) string {
+	if !overloaded {
+		return rawName
+	}
+	for idx := 0; ; idx++ {
+		if name := fmt.Sprintf("%s%d", rawName, idx); !taken(name) {
+			return name
+		}
+	}
+}
+
 // MethodById looks up a method by the 4-byte id
 // returns nil if none found
 func (abi *ABI) MethodById(sigdata []byte) (*Method, error) {
@@ -190,3 +255,17 @@ func (abi *ABI) EventByID(topic common.Hash) (*Event, error) {
 	}
 	return nil, fmt.Errorf("no event with id: %#x", topic.Hex())
 }
+
+// ErrorByID looks up a custom error by its 4-byte selector and returns an error
+// if none is found.
+func (abi *ABI) ErrorByID(sigdata []byte) (*Error, error) {
+	if len(sigdata) < 4 {
+		return nil, fmt.Errorf("data too short (%d bytes) for abi error lookup", len(sigdata))
+	}
+	for _, e := range abi.Errors {
+		if bytes.Equal(e.Id(), sigdata[:4]) {
+			return &e, nil
+		}
+	}
+	return nil, fmt.Errorf("no error with id: %#x", sigdata[:4])
+}
@@ -61,12 +61,12 @@ func TestReader(t *testing.T) {
 	exp := ABI{
 		Methods: map[string]Method{
 			"balance": {
-				"balance", true, nil, nil,
+				Name: "balance", RawName: "balance", Const: true,
 			},
 			"send": {
-				"send", false, []Argument{
+				Name: "send", RawName: "send", Inputs: []Argument{
 					{"amount", Uint256, false},
-				}, nil,
+				},
 			},
 		},
 	}
@@ -175,7 +175,7 @@ func TestTestSlice(t *testing.T) {
 
 func TestMethodSignature(t *testing.T) {
 	String, _ := NewType("string", nil)
-	m := Method{"foo", false, []Argument{{"bar", String, false}, {"baz", String, false}}, nil}
+	m := Method{Name: "foo", Inputs: []Argument{{"bar", String, false}, {"baz", String, false}}}
 	exp := "foo(string,string)"
 	if m.Sig() != exp {
 		t.Error("signature mismatch", exp, "!=", m.Sig())
@@ -187,7 +187,7 @@ func TestMethodSignature(t *testing.T) {
 	}
 
 	uintt, _ := NewType("uint256", nil)
-	m = Method{"foo", false, []Argument{{"bar", uintt, false}}, nil}
+	m = Method{Name: "foo", Inputs: []Argument{{"bar", uintt, false}}}
 	exp = "foo(uint256)"
 	if m.Sig() != exp {
 		t.Error("signature mismatch", exp, "!=", m.Sig())
@@ -206,7 +206,7 @@ func TestMethodSignature(t *testing.T) {
 			{Name: "y", Type: "int256"},
 		}},
 	})
-	m = Method{"foo", false, []Argument{{"s", s, false}, {"bar", String, false}}, nil}
+	m = Method{Name: "foo", Inputs: []Argument{{"s", s, false}, {"bar", String, false}}}
 	exp = "foo((int256,int256[],(int256,int256)[],(int256,int256)[2]),string)"
 	if m.Sig() != exp {
 		t.Error("signature mismatch", exp, "!=", m.Sig())
@@ -1030,3 +1030,91 @@ func TestDoubleDuplicateMethodNames(t *testing.T) {
 		t.Fatalf("Should not have found extra method")
 	}
 }
+
+// TestOverloadedSelectors checks that overloaded methods and events are stored
+// under unique names, but still use their declared names for the selectors.
+func TestOverloadedSelectors(t *testing.T) {
+	abiJSON := `[
+		{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}]},
+		{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"}]},
+		{"type":"function","name":"transfer0","inputs":[]},
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true}]},
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"data","type":"bytes"}]}
+	]`
+	contractAbi, err := JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	methods := map[string]string{
+		"transfer":  "transfer(address,uint256)",
+		"transfer0": "transfer0()",
+		"transfer1": "transfer(address,uint256,bytes)",
+	}
+	if len(contractAbi.Methods) != len(methods) {
+		t.Fatalf("method count mismatch: have %d, want %d", len(contractAbi.Methods), len(methods))
+	}
+	for name, sig := range methods {
+		method, ok := contractAbi.Methods[name]
+		if !ok {
+			t.Fatalf("method %s not found", name)
+		}
+		if method.Sig() != sig {
+			t.Errorf("method %s: signature mismatch: have %s, want %s", name, method.Sig(), sig)
+		}
+		if !bytes.Equal(method.Id(), crypto.Keccak256([]byte(sig))[:4]) {
+			t.Errorf("method %s: selector mismatch", name)
+		}
+	}
+	events := map[string]string{
+		"Transfer":  "Transfer(address)",
+		"Transfer0": "Transfer(address,bytes)",
+	}
+	for name, sig := range events {
+		event, ok := contractAbi.Events[name]
+		if !ok {
+			t.Fatalf("event %s not found", name)
+		}
+		if event.Id() != crypto.Keccak256Hash([]byte(sig)) {
+			t.Errorf("event %s: topic mismatch", name)
+		}
+	}
+	// Packing an overload must use the selector of the declared name
+	packed, err := contractAbi.Pack("transfer1", common.Address{}, big.NewInt(1), []byte{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(packed[:4], crypto.Keccak256([]byte("transfer(address,uint256,bytes)"))[:4]) {
+		t.Errorf("packed selector mismatch: %x", packed[:4])
+	}
+}
+
+func TestSpecialFunctions(t *testing.T) {
+	abiJSON := `[
+		{"type":"fallback","stateMutability":"nonpayable"},
+		{"type":"receive","stateMutability":"payable"},
+		{"type":"function","name":"get","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]}
+	]`
+	contractAbi, err := JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contractAbi.Fallback == nil {
+		t.Error("fallback function not parsed")
+	}
+	if contractAbi.Receive == nil {
+		t.Error("receive function not parsed")
+	}
+	if len(contractAbi.Methods) != 1 || !contractAbi.Methods["get"].Const {
+		t.Errorf("view function not parsed as constant: %v", contractAbi.Methods)
+	}
+	// Invalid special function definitions
+	for _, blob := range []string{
+		`[{"type":"fallback"},{"type":"fallback"}]`,
+		`[{"type":"receive","stateMutability":"payable"},{"type":"receive","stateMutability":"payable"}]`,
+		`[{"type":"receive","stateMutability":"nonpayable"}]`,
+	} {
+		if _, err := JSON(strings.NewReader(blob)); err == nil {
+			t.Errorf("expected error for %s", blob)
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"time"
 
 	"github.com/ccmchain/go-ccmchain"
+	"github.com/ccmchain/go-ccmchain/accounts/abi"
 	"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/common/math"
@@ -42,12 +43,19 @@ import (
 	"github.com/ccmchain/go-ccmchain/rpc"
 )
 
-// This nil assignment ensures compile time that SimulatedBackend implements bind.ContractBackend.
-var _ bind.ContractBackend = (*SimulatedBackend)(nil)
+// These nil assignments ensure at compile time that SimulatedBackend implements
+// bind.ContractBackend and the chain access interfaces of a real node.
+var (
+	_ bind.ContractBackend        = (*SimulatedBackend)(nil)
+	_ ccmchain.ChainReader        = (*SimulatedBackend)(nil)
+	_ ccmchain.ChainStateReader   = (*SimulatedBackend)(nil)
+	_ ccmchain.TransactionReader  = (*SimulatedBackend)(nil)
+	_ ccmchain.PendingStateReader = (*SimulatedBackend)(nil)
+)
 
 var (
-	errBlockNumberUnsupported = errors.New("simulatedBackend cannot access blocks other than the latest block")
-	errGasEstimationFailed    = errors.New("gas required exceeds allowance or always failing transaction")
+	errGasEstimationFailed = errors.New("gas required exceeds allowance or always failing transaction")
+	errPendingBlockDirty   = errors.New("simulatedBackend cannot fork with pending transactions")
 )
 
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
@@ -65,10 +73,11 @@ type SimulatedBackend struct {
 	config *params.ChainConfig
 }
 
-// NewSimulatedBackendWithDatabase creates a new binding backend based on the given database
-// and uses a simulated blockchain for testing purposes.
-func NewSimulatedBackendWithDatabase(database ccmdb.Database, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
-	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, GasLimit: gasLimit, Alloc: alloc}
+// NewSimulatedBackendWithConfig creates a new binding backend based on the given
+// database and chain configuration, using a simulated blockchain for testing
+// purposes. The chain config allows simulating specific hard fork rulesets.
+func NewSimulatedBackendWithConfig(database ccmdb.Database, config *params.ChainConfig, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+	genesis := core.Genesis{Config: config, GasLimit: gasLimit, Alloc: alloc}
 	genesis.MustCommit(database)
 	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ccmash.NewFaker(), vm.Config{}, nil)
 
@@ -78,10 +87,16 @@ func NewSimulatedBackendWithDatabase(database ccmdb.Database, alloc core.Genesis
 		config:     genesis.Config,
 		events:     filters.NewEventSystem(new(event.TypeMux), &filterBackend{database, blockchain}, false),
 	}
-	backend.rollback()
+	backend.rollback(blockchain.CurrentBlock())
 	return backend
 }
 
+// NewSimulatedBackendWithDatabase creates a new binding backend based on the given database
+// and uses a simulated blockchain for testing purposes.
+func NewSimulatedBackendWithDatabase(database ccmdb.Database, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+	return NewSimulatedBackendWithConfig(database, params.AllEthashProtocolChanges, alloc, gasLimit)
+}
+
 // NewSimulatedBackend creates a new binding backend using a simulated blockchain
 // for testing purposes.
 func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
@@ -89,7 +104,7 @@ func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBac
 }
 
 // Commit imports all the pending transactions as a single block and starts a
-// fresh new state.
+// fresh new state on top of it.
 func (b *SimulatedBackend) Commit() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -97,7 +112,7 @@ func (b *SimulatedBackend) Commit() {
 	if _, err := b.blockchain.InsertChain([]*types.Block{b.pendingBlock}); err != nil {
 		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
 	}
-	b.rollback()
+	b.rollback(b.pendingBlock)
 }
 
 // Rollback aborts all pending transactions, reverting to the last committed state.
@@ -105,15 +120,58 @@ func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	b.rollback()
+	b.rollback(b.blockchain.GetBlockByHash(b.pendingBlock.ParentHash()))
 }
 
-func (b *SimulatedBackend) rollback() {
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ccmash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
-	statedb, _ := b.blockchain.State()
+// rollback discards the pending block and starts a fresh empty one on top of
+// the given parent.
+func (b *SimulatedBackend) rollback(parent *types.Block) {
+	blocks, _ := core.GenerateChain(b.config, parent, ccmash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
 
 	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
+	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache())
+}
+
+// Fork creates a side-chain that can be used to simulate reorgs. All future
+// blocks are committed on top of the given parent instead of the current head,
+// the simulated chain reorganising once the side-chain becomes the heavier one.
+// Logs of blocks dropped by the reorg are delivered to log subscribers as removed.
+//
+// Fork fails if there are pending transactions, since they would be lost. The
+// parent must be a block already committed to the chain, the pending block
+// itself cannot be forked from.
+func (b *SimulatedBackend) Fork(ctx context.Context, parent common.Hash) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.pendingBlock.Transactions()) != 0 {
+		return errPendingBlockDirty
+	}
+	block := b.blockchain.GetBlockByHash(parent)
+	if block == nil {
+		return ccmchain.NotFound
+	}
+	b.rollback(block)
+	return nil
+}
+
+// Close terminates the underlying blockchain's update loop.
+func (b *SimulatedBackend) Close() error {
+	b.blockchain.Stop()
+	return nil
+}
+
+// stateByBlockNumber retrieves the state of the canonical block with the given
+// number, or of the current head if the number is nil.
+func (b *SimulatedBackend) stateByBlockNumber(ctx context.Context, blockNumber *big.Int) (*state.StateDB, error) {
+	if blockNumber == nil || blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) == 0 {
+		return b.blockchain.State()
+	}
+	block, err := b.blockByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return b.blockchain.StateAt(block.Root())
 }
 
 // CodeAt returns the code associated with a certain account in the blockchain.
@@ -121,10 +179,10 @@ func (b *SimulatedBackend) CodeAt(ctx context.Context, contract common.Address,
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return nil, errBlockNumberUnsupported
+	statedb, err := b.stateByBlockNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	statedb, _ := b.blockchain.State()
 	return statedb.GetCode(contract), nil
 }
 
@@ -133,10 +191,10 @@ func (b *SimulatedBackend) BalanceAt(ctx context.Context, contract common.Addres
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return nil, errBlockNumberUnsupported
+	statedb, err := b.stateByBlockNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	statedb, _ := b.blockchain.State()
 	return statedb.GetBalance(contract), nil
 }
 
@@ -145,10 +203,10 @@ func (b *SimulatedBackend) NonceAt(ctx context.Context, contract common.Address,
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return 0, errBlockNumberUnsupported
+	statedb, err := b.stateByBlockNumber(ctx, blockNumber)
+	if err != nil {
+		return 0, err
 	}
-	statedb, _ := b.blockchain.State()
 	return statedb.GetNonce(contract), nil
 }
 
@@ -157,17 +215,23 @@ func (b *SimulatedBackend) StorageAt(ctx context.Context, contract common.Addres
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return nil, errBlockNumberUnsupported
+	statedb, err := b.stateByBlockNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	statedb, _ := b.blockchain.State()
 	val := statedb.GetState(contract, key)
 	return val[:], nil
 }
 
 // TransactionReceipt returns the receipt of a transaction.
 func (b *SimulatedBackend) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	receipt, _, _, _ := rawdb.ReadReceipt(b.database, txHash, b.config)
+	if receipt == nil {
+		return nil, ccmchain.NotFound
+	}
 	return receipt, nil
 }
 
@@ -190,6 +254,152 @@ func (b *SimulatedBackend) TransactionByHash(ctx context.Context, txHash common.
 	return nil, false, ccmchain.NotFound
 }
 
+// BlockByHash retrieves a block based on the block hash, including the pending one.
+func (b *SimulatedBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.blockByHash(ctx, hash)
+}
+
+// blockByHash is the lock free version of BlockByHash.
+func (b *SimulatedBackend) blockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	if hash == b.pendingBlock.Hash() {
+		return b.pendingBlock, nil
+	}
+	block := b.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, ccmchain.NotFound
+	}
+	return block, nil
+}
+
+// BlockByNumber retrieves a canonical block from the database based on the block
+// number. If number is nil, the latest known block is returned.
+func (b *SimulatedBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.blockByNumber(ctx, number)
+}
+
+// blockByNumber is the lock free version of BlockByNumber.
+func (b *SimulatedBackend) blockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	if number == nil || number.Cmp(b.blockchain.CurrentBlock().Number()) == 0 {
+		return b.blockchain.CurrentBlock(), nil
+	}
+	if !number.IsUint64() {
+		return nil, ccmchain.NotFound
+	}
+	block := b.blockchain.GetBlockByNumber(number.Uint64())
+	if block == nil {
+		return nil, ccmchain.NotFound
+	}
+	return block, nil
+}
+
+// HeaderByHash returns a block header from the current canonical chain or the
+// pending block.
+func (b *SimulatedBackend) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if hash == b.pendingBlock.Hash() {
+		return b.pendingBlock.Header(), nil
+	}
+	header := b.blockchain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, ccmchain.NotFound
+	}
+	return header, nil
+}
+
+// HeaderByNumber returns a block header from the current canonical chain. If
+// number is nil, the latest known header is returned.
+func (b *SimulatedBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	block, err := b.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return block.Header(), nil
+}
+
+// TransactionCount returns the number of transactions in a given block.
+func (b *SimulatedBackend) TransactionCount(ctx context.Context, blockHash common.Hash) (uint, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	block, err := b.blockByHash(ctx, blockHash)
+	if err != nil {
+		return 0, err
+	}
+	return uint(block.Transactions().Len()), nil
+}
+
+// TransactionInBlock returns the transaction at a given index in a block.
+func (b *SimulatedBackend) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	block, err := b.blockByHash(ctx, blockHash)
+	if err != nil {
+		return nil, err
+	}
+	transactions := block.Transactions()
+	if uint(len(transactions)) <= index {
+		return nil, ccmchain.NotFound
+	}
+	return transactions[index], nil
+}
+
+// SubscribeNewHead returns an event subscription for new chain heads.
+func (b *SimulatedBackend) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ccmchain.Subscription, error) {
+	// Subscribe to new chain heads
+	sink := make(chan *types.Header)
+	sub := b.events.SubscribeNewHeads(sink)
+
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case head := <-sink:
+				select {
+				case ch <- head:
+				case err := <-sub.Err():
+					return err
+				case <-quit:
+					return nil
+				}
+			case err := <-sub.Err():
+				return err
+			case <-quit:
+				return nil
+			}
+		}
+	}), nil
+}
+
+// PendingBalanceAt returns the wei balance of an account in the pending state.
+func (b *SimulatedBackend) PendingBalanceAt(ctx context.Context, account common.Address) (*big.Int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pendingState.GetBalance(account), nil
+}
+
+// PendingStorageAt returns the value of key in the storage of an account in the
+// pending state.
+func (b *SimulatedBackend) PendingStorageAt(ctx context.Context, account common.Address, key common.Hash) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	val := b.pendingState.GetState(account, key)
+	return val[:], nil
+}
+
 // PendingCodeAt returns the code associated with an account in the pending state.
 func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Address) ([]byte, error) {
 	b.mu.Lock()
@@ -198,20 +408,35 @@ func (b *SimulatedBackend) PendingCodeAt(ctx context.Context, contract common.Ad
 	return b.pendingState.GetCode(contract), nil
 }
 
+// PendingTransactionCount returns the number of transactions in the pending block.
+func (b *SimulatedBackend) PendingTransactionCount(ctx context.Context) (uint, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return uint(b.pendingBlock.Transactions().Len()), nil
+}
+
 // CallContract executes a contract call.
 func (b *SimulatedBackend) CallContract(ctx context.Context, call ccmchain.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if blockNumber != nil && blockNumber.Cmp(b.blockchain.CurrentBlock().Number()) != 0 {
-		return nil, errBlockNumberUnsupported
+	block, err := b.blockByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
 	}
-	state, err := b.blockchain.State()
+	statedb, err := b.blockchain.StateAt(block.Root())
 	if err != nil {
 		return nil, err
 	}
-	rval, _, _, err := b.callContract(ctx, call, b.blockchain.CurrentBlock(), state)
-	return rval, err
+	res, err := b.callContract(ctx, call, block, statedb)
+	if err != nil {
+		return nil, err
+	}
+	if res.Err == vm.ErrExecutionReverted {
+		return nil, abi.NewRevertError(res.Revert())
+	}
+	return res.Return(), res.Err
 }
 
 // PendingCallContract executes a contract call on the pending state.
@@ -220,8 +445,14 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ccmchai
 	defer b.mu.Unlock()
 	defer b.pendingState.RevertToSnapshot(b.pendingState.Snapshot())
 
-	rval, _, _, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
-	return rval, err
+	res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+	if err != nil {
+		return nil, err
+	}
+	if res.Err == vm.ErrExecutionReverted {
+		return nil, abi.NewRevertError(res.Revert())
+	}
+	return res.Return(), res.Err
 }
 
 // PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
@@ -259,22 +490,22 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ccmchain.CallMs
 	cap = hi
 
 	// Create a helper to check if a gas allowance results in an executable transaction
-	executable := func(gas uint64) bool {
+	executable := func(gas uint64) (*core.ExecutionResult, bool) {
 		call.Gas = gas
 
 		snapshot := b.pendingState.Snapshot()
-		_, _, failed, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
+		res, err := b.callContract(ctx, call, b.pendingBlock, b.pendingState)
 		b.pendingState.RevertToSnapshot(snapshot)
 
-		if err != nil || failed {
-			return false
+		if err != nil || res.Failed() {
+			return res, false
 		}
-		return true
+		return res, true
 	}
 	// Execute the binary search and hone in on an executable gas limit
 	for lo+1 < hi {
 		mid := (hi + lo) / 2
-		if !executable(mid) {
+		if _, ok := executable(mid); !ok {
 			lo = mid
 		} else {
 			hi = mid
@@ -282,7 +513,10 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ccmchain.CallMs
 	}
 	// Reject the transaction as invalid if it still fails at the highest allowance
 	if hi == cap {
-		if !executable(hi) {
+		if res, ok := executable(hi); !ok {
+			if res != nil && res.Err == vm.ErrExecutionReverted {
+				return 0, abi.NewRevertError(res.Revert())
+			}
 			return 0, errGasEstimationFailed
 		}
 	}
@@ -291,7 +525,7 @@ func (b *SimulatedBackend) EstimateGas(ctx context.Context, call ccmchain.CallMs
 
 // callContract implements common code between normal and pending contract calls.
 // state is modified during execution, make sure to copy it if necessary.
-func (b *SimulatedBackend) callContract(ctx context.Context, call ccmchain.CallMsg, block *types.Block, statedb *state.StateDB) ([]byte, uint64, bool, error) {
+func (b *SimulatedBackend) callContract(ctx context.Context, call ccmchain.CallMsg, block *types.Block, statedb *state.StateDB) (*core.ExecutionResult, error) {
 	// Ensure message is initialized properly.
 	if call.GasPrice == nil {
 		call.GasPrice = big.NewInt(1)
@@ -323,7 +557,7 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	sender, err := types.Sender(types.NewEIP155Signer(b.config.ChainID), tx)
+	sender, err := types.Sender(types.MakeSigner(b.config, b.pendingBlock.Number()), tx)
 	if err != nil {
 		panic(fmt.Errorf("invalid transaction: %v", err))
 	}
@@ -331,18 +565,28 @@ func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transa
 	if tx.Nonce() != nonce {
 		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
 	}
+	b.regenerate(0, tx)
+	return nil
+}
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ccmash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+// regenerate rebuilds the pending block on top of its parent with all the pending
+// transactions followed by the given ones, shifting its timestamp by the given
+// number of seconds on top of any previous time adjustment.
+func (b *SimulatedBackend) regenerate(offset int64, txs ...*types.Transaction) {
+	parent := b.blockchain.GetBlockByHash(b.pendingBlock.ParentHash())
+	offset += int64(b.pendingBlock.Time()) - int64(parent.Time()) - 10
+
+	blocks, _ := core.GenerateChain(b.config, parent, ccmash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
+		block.OffsetTime(offset)
 		for _, tx := range b.pendingBlock.Transactions() {
 			block.AddTxWithChain(b.blockchain, tx)
 		}
-		block.AddTxWithChain(b.blockchain, tx)
+		for _, tx := range txs {
+			block.AddTxWithChain(b.blockchain, tx)
+		}
 	})
-	statedb, _ := b.blockchain.State()
-
 	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
-	return nil
+	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache())
 }
 
 // FilterLogs executes a log filter operation, blocking during execution and
@@ -413,21 +657,14 @@ func (b *SimulatedBackend) SubscribeFilterLogs(ctx context.Context, query ccmcha
 	}), nil
 }
 
-// AdjustTime adds a time shift to the simulated clock.
+// AdjustTime adds a time shift to the simulated clock. The shift is applied to the
+// pending block and retained when further transactions are added to it. Blocks are
+// otherwise spaced 10 seconds apart, making timestamps deterministic.
 func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ccmash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTx(tx)
-		}
-		block.OffsetTime(int64(adjustment.Seconds()))
-	})
-	statedb, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), statedb.Database())
 
+	b.regenerate(int64(adjustment.Seconds()))
 	return nil
 }
 
@@ -511,7 +748,8 @@ func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscr
 	return fb.bc.SubscribeLogsEvent(ch)
 }
 
-func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
+func (fb *filterBackend) BloomStatus() (uint64, uint64)    { return 4096, 0 }
+func (fb *filterBackend) LogIndexStatus() (uint64, uint64) { return 4096, 0 }
 func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.MatcherSession) {
 	panic("not supported")
 }
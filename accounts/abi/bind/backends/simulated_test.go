@@ -18,16 +18,22 @@ package backends_test
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	ccmchain "github.com/ccmchain/go-ccmchain"
+	"github.com/ccmchain/go-ccmchain/accounts/abi"
 	"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
 	"github.com/ccmchain/go-ccmchain/accounts/abi/bind/backends"
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
 	"github.com/ccmchain/go-ccmchain/core/types"
 	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/params"
 )
 
 func TestSimulatedBackend(t *testing.T) {
@@ -80,3 +86,344 @@ func TestSimulatedBackend(t *testing.T) {
 	}
 
 }
+
+// revertABI and revertBin belong to a hand-assembled contract equivalent to
+//
+//	error Insufficient(uint256 available, uint256 required);
+//
+//	function fail() public pure { revert("boom"); }
+//	function assertFail() public pure { assert(false); }
+//	function custom() public pure { revert Insufficient(1, 2); }
+const revertABI = `[
+	{"type":"function","name":"fail","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"function","name":"assertFail","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"function","name":"custom","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"error","name":"Insufficient","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}
+]`
+
+const revertBin = `6101278061000d6000396000f360003560e01c8063a9cc47181461002b57806309ccaefb1461003b578063c231aa301461004b57600080fd5b61006461005b6000396100646000fd5b6100246100bf6000396100246000fd5b6100446100e36000396100446000fd08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000004626f6f6d000000000000000000000000000000000000000000000000000000004e487b710000000000000000000000000000000000000000000000000000000000000001e862080000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002`
+
+func TestSimulatedBackendRevert(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(1000000000000000000)}}, 10000000)
+
+	parsed, err := abi.JSON(strings.NewReader(revertABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	addr, _, contract, err := bind.DeployContract(auth, parsed, common.FromHex(revertBin), sim)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	tests := []struct {
+		mccmod string
+		reason string // reason decoded by the backend
+		bound  string // reason decoded by the bound contract
+	}{
+		{"fail", "boom", "boom"},
+		{"assertFail", "assert(false)", "assert(false)"},
+		{"custom", "", "Insufficient(1, 2)"},
+	}
+	for _, tt := range tests {
+		input, _ := parsed.Pack(tt.mccmod)
+		msg := ccmchain.CallMsg{From: auth.From, To: &addr, Data: input}
+
+		// Check the raw backend calls
+		_, err := sim.CallContract(context.Background(), msg, nil)
+		checkRevert(t, tt.mccmod+" call", err, tt.reason)
+
+		_, err = sim.PendingCallContract(context.Background(), msg)
+		checkRevert(t, tt.mccmod+" pending call", err, tt.reason)
+
+		_, err = sim.EstimateGas(context.Background(), msg)
+		checkRevert(t, tt.mccmod+" estimate", err, tt.reason)
+
+		// Check the bound contract, which also decodes custom errors
+		err = contract.Call(nil, nil, tt.mccmod)
+		checkRevert(t, tt.mccmod+" bound call", err, tt.bound)
+	}
+}
+
+func checkRevert(t *testing.T, name string, err error, reason string) {
+	t.Helper()
+
+	revert, ok := err.(*abi.RevertError)
+	if !ok {
+		t.Fatalf("%s: error mismatch: have %v (%T), want *abi.RevertError", name, err, err)
+	}
+	if revert.Reason != reason {
+		t.Errorf("%s: reason mismatch: have %q, want %q", name, revert.Reason, reason)
+	}
+	if len(revert.Data) < 4 {
+		t.Errorf("%s: missing revert data", name)
+	}
+}
+
+// logBin is a contract which emits an empty LOG0 on every call.
+const logBin = `6006600c60003960066000f360006000a000`
+
+// newLoggingBackend creates a simulated backend with a funded account and a
+// deployed log emitting contract.
+func newLoggingBackend(t *testing.T, config *params.ChainConfig) (*backends.SimulatedBackend, *ecdsa.PrivateKey, common.Address) {
+	key, _ := crypto.GenerateKey()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	sim := backends.NewSimulatedBackendWithConfig(rawdb.NewMemoryDatabase(), config, core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000000000000)}}, 10000000)
+
+	tx, _ := types.SignTx(types.NewContractCreation(0, new(big.Int), 100000, big.NewInt(1), common.FromHex(logBin)), types.HomesteadSigner{}, key)
+	if err := sim.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+	return sim, key, crypto.CreateAddress(addr, 0)
+}
+
+func TestSimulatedBackendChainReader(t *testing.T) {
+	sim, key, contract := newLoggingBackend(t, params.AllEthashProtocolChanges)
+	defer sim.Close()
+
+	ctx := context.Background()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+
+	// Add a transaction to the pending block and check the pending readers
+	tx, _ := types.SignTx(types.NewTransaction(1, contract, big.NewInt(1), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err := sim.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if count, _ := sim.PendingTransactionCount(ctx); count != 1 {
+		t.Errorf("pending transaction count mismatch: have %d, want 1", count)
+	}
+	if balance, _ := sim.PendingBalanceAt(ctx, contract); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("pending balance mismatch: have %v, want 1", balance)
+	}
+	if _, err := sim.TransactionReceipt(ctx, tx.Hash()); err != ccmchain.NotFound {
+		t.Errorf("pending receipt error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	sim.Commit()
+
+	// Retrieve the committed block in all the possible ways
+	block, err := sim.BlockByNumber(ctx, big.NewInt(2))
+	if err != nil {
+		t.Fatalf("failed to retrieve block: %v", err)
+	}
+	if head, _ := sim.BlockByNumber(ctx, nil); head.Hash() != block.Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head.Hash(), block.Hash())
+	}
+	if byHash, _ := sim.BlockByHash(ctx, block.Hash()); byHash == nil || byHash.NumberU64() != 2 {
+		t.Errorf("block by hash mismatch: have %v", byHash)
+	}
+	if header, _ := sim.HeaderByNumber(ctx, big.NewInt(2)); header.Hash() != block.Hash() {
+		t.Errorf("header by number mismatch: have %x, want %x", header.Hash(), block.Hash())
+	}
+	if header, _ := sim.HeaderByHash(ctx, block.Hash()); header.Number.Uint64() != 2 {
+		t.Errorf("header by hash mismatch: have %v, want 2", header.Number)
+	}
+	if _, err := sim.BlockByNumber(ctx, big.NewInt(10)); err != ccmchain.NotFound {
+		t.Errorf("future block error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	if _, err := sim.HeaderByHash(ctx, common.Hash{1}); err != ccmchain.NotFound {
+		t.Errorf("unknown header error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	// Check the transaction accessors
+	if count, _ := sim.TransactionCount(ctx, block.Hash()); count != 1 {
+		t.Errorf("transaction count mismatch: have %d, want 1", count)
+	}
+	if have, _ := sim.TransactionInBlock(ctx, block.Hash(), 0); have == nil || have.Hash() != tx.Hash() {
+		t.Errorf("transaction in block mismatch: have %v, want %x", have, tx.Hash())
+	}
+	if _, err := sim.TransactionInBlock(ctx, block.Hash(), 1); err != ccmchain.NotFound {
+		t.Errorf("out of bounds transaction error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	if receipt, _ := sim.TransactionReceipt(ctx, tx.Hash()); receipt == nil || receipt.BlockHash != block.Hash() {
+		t.Errorf("receipt mismatch: have %v", receipt)
+	}
+	// Check the historical state accessors
+	if balance, _ := sim.BalanceAt(ctx, contract, big.NewInt(1)); balance.Sign() != 0 {
+		t.Errorf("historical balance mismatch: have %v, want 0", balance)
+	}
+	if balance, _ := sim.BalanceAt(ctx, contract, nil); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("current balance mismatch: have %v, want 1", balance)
+	}
+	if code, _ := sim.CodeAt(ctx, contract, big.NewInt(0)); len(code) != 0 {
+		t.Errorf("genesis code mismatch: have %x, want none", code)
+	}
+	if nonce, _ := sim.NonceAt(ctx, sender, big.NewInt(1)); nonce != 1 {
+		t.Errorf("historical nonce mismatch: have %d, want 1", nonce)
+	}
+}
+
+func TestSimulatedBackendAdjustTime(t *testing.T) {
+	sim, key, contract := newLoggingBackend(t, params.AllEthashProtocolChanges)
+	defer sim.Close()
+
+	ctx := context.Background()
+	parent, _ := sim.BlockByNumber(ctx, nil)
+
+	// Adjust the time of the pending block and ensure it survives new transactions
+	if err := sim.AdjustTime(time.Hour); err != nil {
+		t.Fatalf("failed to adjust time: %v", err)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(1, contract, new(big.Int), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err := sim.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	sim.Commit()
+
+	head, _ := sim.BlockByNumber(ctx, nil)
+	if have, want := head.Time(), parent.Time()+10+3600; have != want {
+		t.Errorf("adjusted time mismatch: have %d, want %d", have, want)
+	}
+	// Ensure subsequent blocks are back to the default spacing
+	sim.Commit()
+	next, _ := sim.BlockByNumber(ctx, nil)
+	if have, want := next.Time(), head.Time()+10; have != want {
+		t.Errorf("block time mismatch: have %d, want %d", have, want)
+	}
+}
+
+func TestSimulatedBackendFork(t *testing.T) {
+	sim, key, contract := newLoggingBackend(t, params.AllEthashProtocolChanges)
+	defer sim.Close()
+
+	ctx := context.Background()
+	parent, _ := sim.BlockByNumber(ctx, nil)
+
+	logs := make(chan types.Log, 4)
+	logSub, err := sim.SubscribeFilterLogs(ctx, ccmchain.FilterQuery{Addresses: []common.Address{contract}}, logs)
+	if err != nil {
+		t.Fatalf("failed to subscribe to logs: %v", err)
+	}
+	defer logSub.Unsubscribe()
+
+	heads := make(chan *types.Header, 4)
+	headSub, err := sim.SubscribeNewHead(ctx, heads)
+	if err != nil {
+		t.Fatalf("failed to subscribe to heads: %v", err)
+	}
+	defer headSub.Unsubscribe()
+
+	// Emit a log on the canonical chain
+	tx, _ := types.SignTx(types.NewTransaction(1, contract, new(big.Int), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err := sim.SendTransaction(ctx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	if err := sim.Fork(ctx, parent.Hash()); err == nil {
+		t.Fatalf("forked with pending transactions")
+	}
+	sim.Commit()
+
+	mined, _ := sim.BlockByNumber(ctx, nil)
+	if head := waitHead(t, heads); head.Hash() != mined.Hash() {
+		t.Fatalf("new head mismatch: have %x, want %x", head.Hash(), mined.Hash())
+	}
+	if log := waitLog(t, logs); log.Removed || log.TxHash != tx.Hash() {
+		t.Fatalf("mined log mismatch: %+v", log)
+	}
+	// Fork off the parent and overtake the canonical chain with empty blocks
+	if err := sim.Fork(ctx, parent.Hash()); err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	sim.Commit()
+	sim.Commit()
+
+	head, _ := sim.BlockByNumber(ctx, nil)
+	if head.NumberU64() != mined.NumberU64()+1 || head.Hash() == mined.Hash() {
+		t.Fatalf("side chain not canonical: head #%d %x", head.NumberU64(), head.Hash())
+	}
+	if block, _ := sim.BlockByNumber(ctx, mined.Number()); block.Hash() == mined.Hash() {
+		t.Fatalf("reorged block still canonical")
+	}
+	if log := waitLog(t, logs); !log.Removed || log.TxHash != tx.Hash() {
+		t.Fatalf("removed log mismatch: %+v", log)
+	}
+	if _, err := sim.TransactionReceipt(ctx, tx.Hash()); err != ccmchain.NotFound {
+		t.Fatalf("reorged receipt error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	// Unknown blocks can't be forked from
+	if err := sim.Fork(ctx, common.Hash{1}); err != ccmchain.NotFound {
+		t.Fatalf("unknown parent error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+}
+
+func TestSimulatedBackendForkPending(t *testing.T) {
+	alloc := core.GenesisAlloc{common.Address{1}: {Balance: big.NewInt(1)}}
+
+	sim := backends.NewSimulatedBackend(alloc, 10000000)
+	defer sim.Close()
+
+	// Simulated chains are deterministic, so the block committed by an identical
+	// backend is the pending block of the first one
+	twin := backends.NewSimulatedBackend(alloc, 10000000)
+	defer twin.Close()
+	twin.Commit()
+
+	ctx := context.Background()
+	pending, _ := twin.BlockByNumber(ctx, nil)
+	if block, err := sim.BlockByHash(ctx, pending.Hash()); err != nil || block.NumberU64() != 1 {
+		t.Fatalf("pending block mismatch: have %v, %v", block, err)
+	}
+	// The pending block was never inserted into the chain, so it can't be forked from
+	if err := sim.Fork(ctx, pending.Hash()); err != ccmchain.NotFound {
+		t.Fatalf("pending parent error mismatch: have %v, want %v", err, ccmchain.NotFound)
+	}
+	sim.Commit()
+
+	if head, _ := sim.BlockByNumber(ctx, nil); head.Hash() != pending.Hash() {
+		t.Fatalf("head mismatch after rejected fork: have %x, want %x", head.Hash(), pending.Hash())
+	}
+}
+
+func waitLog(t *testing.T, logs <-chan types.Log) types.Log {
+	t.Helper()
+
+	select {
+	case log := <-logs:
+		return log
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for log")
+	}
+	return types.Log{}
+}
+
+func waitHead(t *testing.T, heads <-chan *types.Header) *types.Header {
+	t.Helper()
+
+	select {
+	case head := <-heads:
+		return head
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for head")
+	}
+	return nil
+}
+
+func TestSimulatedBackendChainConfig(t *testing.T) {
+	// Simulate a chain which never passes Byzantium, so REVERT is not available
+	config := &params.ChainConfig{ChainID: big.NewInt(1337), HomesteadBlock: big.NewInt(0)}
+
+	key, _ := crypto.GenerateKey()
+	auth := bind.NewKeyedTransactor(key)
+	sim := backends.NewSimulatedBackendWithConfig(rawdb.NewMemoryDatabase(), config, core.GenesisAlloc{auth.From: {Balance: big.NewInt(1000000000000000000)}}, 10000000)
+	defer sim.Close()
+
+	if sim.Blockchain().Config() != config {
+		t.Fatalf("chain config not used")
+	}
+	parsed, _ := abi.JSON(strings.NewReader(revertABI))
+	addr, _, _, err := bind.DeployContract(auth, parsed, common.FromHex(revertBin), sim)
+	if err != nil {
+		t.Fatalf("failed to deploy contract: %v", err)
+	}
+	sim.Commit()
+
+	input, _ := parsed.Pack("fail")
+	_, err = sim.CallContract(context.Background(), ccmchain.CallMsg{From: auth.From, To: &addr, Data: input}, nil)
+	if err == nil {
+		t.Fatalf("call succeeded on pre-Constantinople rules")
+	}
+	if _, ok := err.(*abi.RevertError); ok {
+		t.Fatalf("call reverted on pre-Byzantium rules")
+	}
+}
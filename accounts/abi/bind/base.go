@@ -21,10 +21,12 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ccmchain/go-ccmchain"
 	"github.com/ccmchain/go-ccmchain/accounts/abi"
 	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
 	"github.com/ccmchain/go-ccmchain/core/types"
 	"github.com/ccmchain/go-ccmchain/crypto"
 	"github.com/ccmchain/go-ccmchain/event"
@@ -159,11 +161,60 @@ func (c *BoundContract) Call(opts *CallOpts, result interface{}, mccmod string,
 		}
 	}
 	if err != nil {
-		return err
+		return c.unpackRevert(err)
 	}
 	return c.abi.Unpack(result, mccmod, output)
 }
 
+// revertDataError is implemented by the JSON-RPC errors of reverted executions,
+// carrying the hex encoded revert data.
+type revertDataError interface {
+	ErrorCode() int
+	ErrorData() interface{}
+}
+
+// unpackRevert converts the error of a reverted call into an abi.RevertError,
+// decoding its revert data as a standard reason or, failing that, as one of the
+// custom errors declared in the contract ABI. Other errors are returned as is.
+func (c *BoundContract) unpackRevert(err error) error {
+	revert, ok := err.(*abi.RevertError)
+	if !ok {
+		// Not a simulated revert, check for one reported over JSON-RPC
+		rpcErr, ok := err.(revertDataError)
+		if !ok || rpcErr.ErrorCode() != (&abi.RevertError{}).ErrorCode() {
+			return err
+		}
+		data, ok := rpcErr.ErrorData().(string)
+		if !ok {
+			return err
+		}
+		blob, decErr := hexutil.Decode(data)
+		if decErr != nil {
+			return err
+		}
+		revert = abi.NewRevertError(blob)
+	}
+	if revert.Reason != "" {
+		return revert
+	}
+	custom, lookupErr := c.abi.ErrorByID(revert.Data)
+	if lookupErr != nil {
+		return revert
+	}
+	args, unpackErr := custom.Unpack(revert.Data)
+	if unpackErr != nil {
+		return revert
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = fmt.Sprintf("%v", arg)
+	}
+	return &abi.RevertError{
+		Reason: fmt.Sprintf("%s(%s)", custom.Name, strings.Join(values, ", ")),
+		Data:   revert.Data,
+	}
+}
+
 // Transact invokes the (paid) contract mccmod with params as input values.
 func (c *BoundContract) Transact(opts *TransactOpts, mccmod string, params ...interface{}) (*types.Transaction, error) {
 	// Otherwise pack up the parameters and invoke the contract
@@ -174,6 +225,12 @@ func (c *BoundContract) Transact(opts *TransactOpts, mccmod string, params ...in
 	return c.transact(opts, &c.address, input)
 }
 
+// RawTransact initiates a transaction with the given raw calldata as the input.
+// It's usually used to initiate transactions invoking the fallback function.
+func (c *BoundContract) RawTransact(opts *TransactOpts, calldata []byte) (*types.Transaction, error) {
+	return c.transact(opts, &c.address, calldata)
+}
+
 // Transfer initiates a plain transaction to move funds to the contract, calling
 // its default mccmod if one is available.
 func (c *BoundContract) Transfer(opts *TransactOpts) (*types.Transaction, error) {
@@ -74,11 +74,25 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			transacts = make(map[string]*tmplMethod)
 			events    = make(map[string]*tmplEvent)
 			structs   = make(map[string]*tmplStruct)
+
+			// Generated mccmod and event identifiers, used to detect names which
+			// collide after normalization (e.g. foo and _foo)
+			mccmodIdentifiers = make(map[string]string)
+			eventIdentifiers  = make(map[string]string)
 		)
+		if evmABI.Fallback != nil {
+			mccmodIdentifiers[mccmodNormalizer[lang]("fallback")] = "fallback"
+		}
+		if evmABI.Receive != nil {
+			mccmodIdentifiers[mccmodNormalizer[lang]("receive")] = "receive"
+		}
 		for _, original := range evmABI.Methods {
 			// Normalize the mccmod for capital cases and non-anonymous inputs/outputs
 			normalized := original
 			normalized.Name = mccmodNormalizer[lang](original.Name)
+			if err := reserveIdentifier(mccmodIdentifiers, normalized.Name, original.Name); err != nil {
+				return "", err
+			}
 
 			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
 			copy(normalized.Inputs, original.Inputs)
@@ -115,18 +129,21 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			// Normalize the event for capital cases and non-anonymous outputs
 			normalized := original
 			normalized.Name = mccmodNormalizer[lang](original.Name)
-
+			if err := reserveIdentifier(eventIdentifiers, normalized.Name, original.Name); err != nil {
+				return "", err
+			}
 			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
 			copy(normalized.Inputs, original.Inputs)
 			for j, input := range normalized.Inputs {
-				// Indexed fields are input, non-indexed ones are outputs
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+				// Indexed tuples are only available as topic hashes, don't bind them
 				if input.Indexed {
-					if input.Name == "" {
-						normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
-					}
-					if _, exist := structs[input.Type.String()]; input.Type.T == abi.TupleTy && !exist {
-						bindStructType[lang](input.Type, structs)
-					}
+					continue
+				}
+				if _, exist := structs[input.Type.String()]; input.Type.T == abi.TupleTy && !exist {
+					bindStructType[lang](input.Type, structs)
 				}
 			}
 			// Append the event to the accumulator list
@@ -146,6 +163,8 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			Calls:       calls,
 			Transacts:   transacts,
 			Events:      events,
+			Fallback:    specialMethod(evmABI.Fallback),
+			Receive:     specialMethod(evmABI.Receive),
 			Libraries:   make(map[string]string),
 			Structs:     structs,
 		}
@@ -183,13 +202,14 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 	buffer := new(bytes.Buffer)
 
 	funcs := map[string]interface{}{
-		"bindtype":      bindType[lang],
-		"bindtopictype": bindTopicType[lang],
-		"namedtype":     namedType[lang],
-		"formatmccmod":  formatMethod,
-		"formatevent":   formatEvent,
-		"capitalise":    capitalise,
-		"decapitalise":  decapitalise,
+		"bindtype":       bindType[lang],
+		"bindtopictype":  bindTopicType[lang],
+		"bindfiltertype": bindFilterType[lang],
+		"namedtype":      namedType[lang],
+		"formatmccmod":   formatMethod,
+		"formatevent":    formatEvent,
+		"capitalise":     capitalise,
+		"decapitalise":   decapitalise,
 	}
 	tmpl := template.Must(template.New("").Funcs(funcs).Parse(tmplSource[lang]))
 	if err := tmpl.Execute(buffer, data); err != nil {
@@ -337,23 +357,62 @@ var bindTopicType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct)
 }
 
 // bindTopicTypeGo converts a Solidity topic type to a Go one. It is almost the same
-// funcionality as for simple types, but dynamic types get converted to hashes.
+// funcionality as for simple types, but dynamic types, arrays and tuples get
+// converted to hashes.
 func bindTopicTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
-	bound := bindTypeGo(kind, structs)
-	if bound == "string" || bound == "[]byte" {
-		bound = "common.Hash"
+	if hashedTopic(kind) {
+		return "common.Hash"
 	}
-	return bound
+	return bindTypeGo(kind, structs)
 }
 
 // bindTopicTypeJava converts a Solidity topic type to a Java one. It is almost the same
-// funcionality as for simple types, but dynamic types get converted to hashes.
+// funcionality as for simple types, but dynamic types, arrays and tuples get
+// converted to hashes.
 func bindTopicTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
-	bound := bindTypeJava(kind, structs)
-	if bound == "String" || bound == "byte[]" {
-		bound = "Hash"
+	if hashedTopic(kind) {
+		return "Hash"
+	}
+	return bindTypeJava(kind, structs)
+}
+
+// bindFilterType is a set of type binders that convert Solidity types to some
+// supported programming language types usable as event filter arguments.
+var bindFilterType = map[Lang]func(kind abi.Type, structs map[string]*tmplStruct) string{
+	LangGo:   bindFilterTypeGo,
+	LangJava: bindFilterTypeJava,
+}
+
+// bindFilterTypeGo converts a Solidity topic type to a Go filter argument. Strings
+// and byte slices are hashed by the filterer, but arrays and tuples need to be
+// filtered on by their precomputed topic hashes.
+func bindFilterTypeGo(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.StringTy, abi.BytesTy:
+		return bindTypeGo(kind, structs)
+	}
+	return bindTopicTypeGo(kind, structs)
+}
+
+// bindFilterTypeJava converts a Solidity topic type to a Java filter argument. Strings
+// and byte slices are hashed by the filterer, but arrays and tuples need to be
+// filtered on by their precomputed topic hashes.
+func bindFilterTypeJava(kind abi.Type, structs map[string]*tmplStruct) string {
+	switch kind.T {
+	case abi.StringTy, abi.BytesTy:
+		return bindTypeJava(kind, structs)
+	}
+	return bindTopicTypeJava(kind, structs)
+}
+
+// hashedTopic returns whccmer an indexed event field of the given type is stored
+// as the Keccak256 hash of its encoding instead of its value.
+func hashedTopic(kind abi.Type) bool {
+	switch kind.T {
+	case abi.StringTy, abi.BytesTy, abi.ArrayTy, abi.SliceTy, abi.TupleTy:
+		return true
 	}
-	return bound
+	return false
 }
 
 // bindStructType is a set of type binders that convert Solidity tuple types to some supported
@@ -459,6 +518,25 @@ var mccmodNormalizer = map[Lang]func(string) string{
 	LangJava: decapitalise,
 }
 
+// reserveIdentifier records a generated identifier, failing if a different ABI
+// entry already got normalized into the same name.
+func reserveIdentifier(identifiers map[string]string, identifier, name string) error {
+	if original, exist := identifiers[identifier]; exist {
+		return fmt.Errorf("duplicated identifier %q (normalized from %q and %q)", identifier, original, name)
+	}
+	identifiers[identifier] = name
+	return nil
+}
+
+// specialMethod wraps a fallback or receive function for the templates, or
+// returns nil if the contract doesn't define one.
+func specialMethod(mccmod *abi.Method) *tmplMethod {
+	if mccmod == nil {
+		return nil
+	}
+	return &tmplMethod{Original: *mccmod, Normalized: *mccmod}
+}
+
 // capitalise makes a camel-case string which starts with an upper case character.
 func capitalise(input string) string {
 	return abi.ToCamelCase(input)
@@ -541,7 +619,7 @@ func formatMethod(mccmod abi.Method, structs map[string]*tmplStruct) string {
 	if mccmod.Const {
 		constant = "constant "
 	}
-	return fmt.Sprintf("function %v(%v) %sreturns(%v)", mccmod.Name, strings.Join(inputs, ", "), constant, strings.Join(outputs, ", "))
+	return fmt.Sprintf("function %v(%v) %sreturns(%v)", mccmod.RawName, strings.Join(inputs, ", "), constant, strings.Join(outputs, ", "))
 }
 
 // formatEvent transforms raw event representation into a user friendly one.
@@ -554,5 +632,5 @@ func formatEvent(event abi.Event, structs map[string]*tmplStruct) string {
 			inputs[i] = fmt.Sprintf("%v %v", resolveArgName(input, structs), input.Name)
 		}
 	}
-	return fmt.Sprintf("event %v(%v)", event.Name, strings.Join(inputs, ", "))
+	return fmt.Sprintf("event %v(%v)", event.RawName, strings.Join(inputs, ", "))
 }
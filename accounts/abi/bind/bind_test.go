@@ -1255,6 +1255,288 @@ var bindTests = []struct {
 		},
 		[]string{"UseLibrary", "Math"},
 	},
+	// Tests that overloaded methods and events get distinct, deterministic names.
+	// The bytecode is a minimal hand assembled equivalent of the contract source.
+	{
+		`Overload`,
+		`
+		pragma solidity ^0.5.10;
+
+		contract overload {
+			event bar(uint256 i, uint256 j);
+			event bar(uint256 i);
+
+			function foo(uint256 i, uint256 j) public { emit bar(i, j); }
+			function foo(uint256 i) public { emit bar(i); }
+		}
+		`,
+		[]string{`61007e8061000d6000396000f360003560e01c806304bc52f8146100205780632fbebd381461004f57600080fd5b604060046000377fae42e9514233792a47a1e4554624e83fe852228e1503f63cd383e8a431f4f46d60406000a1005b602060046000377f0423a1321222a0a8716c22b92fac42d85a45a612b696a461784d9fa537c81e5c60206000a100`},
+		[]string{`[{"inputs":[{"name":"i","type":"uint256"},{"name":"j","type":"uint256"}],"name":"foo","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[{"name":"i","type":"uint256"}],"name":"foo","outputs":[],"stateMutability":"nonpayable","type":"function"},{"anonymous":false,"inputs":[{"indexed":false,"name":"i","type":"uint256"},{"indexed":false,"name":"j","type":"uint256"}],"name":"bar","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"name":"i","type":"uint256"}],"name":"bar","type":"event"}]`},
+		`
+			"math/big"
+
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind/backends"
+			"github.com/ccmchain/go-ccmchain/core"
+			"github.com/ccmchain/go-ccmchain/crypto"
+		`,
+		`
+			// Initialize test accounts
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}}, 10000000)
+
+			_, _, contract, err := DeployOverload(auth, sim)
+			if err != nil {
+				t.Fatalf("failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			// Both overloads must hit their own selector and fire their own event
+			if _, err := contract.Foo(auth, big.NewInt(1), big.NewInt(2)); err != nil {
+				t.Fatalf("failed to call the two argument overload: %v", err)
+			}
+			if _, err := contract.Foo0(auth, big.NewInt(3)); err != nil {
+				t.Fatalf("failed to call the single argument overload: %v", err)
+			}
+			sim.Commit()
+
+			bar, err := contract.FilterBar(nil)
+			if err != nil {
+				t.Fatalf("failed to filter bar events: %v", err)
+			}
+			defer bar.Close()
+			if !bar.Next() {
+				t.Fatalf("bar event not found: %v", bar.Error())
+			}
+			if bar.Event.I.Cmp(big.NewInt(1)) != 0 || bar.Event.J.Cmp(big.NewInt(2)) != 0 {
+				t.Fatalf("bar event mismatch: have (%v, %v), want (1, 2)", bar.Event.I, bar.Event.J)
+			}
+			if bar.Next() {
+				t.Fatalf("unexpected second bar event")
+			}
+			bar0, err := contract.FilterBar0(nil)
+			if err != nil {
+				t.Fatalf("failed to filter bar0 events: %v", err)
+			}
+			defer bar0.Close()
+			if !bar0.Next() {
+				t.Fatalf("bar0 event not found: %v", bar0.Error())
+			}
+			if bar0.Event.I.Cmp(big.NewInt(3)) != 0 {
+				t.Fatalf("bar0 event mismatch: have %v, want 3", bar0.Event.I)
+			}
+			if bar0.Next() {
+				t.Fatalf("unexpected second bar0 event")
+			}
+		`,
+		nil,
+		nil,
+		nil,
+	},
+	// Tests that the fallback and receive functions can be invoked through the bindings.
+	// The bytecode is a minimal hand assembled equivalent of the contract source.
+	{
+		`NewFallbacks`,
+		`
+		pragma solidity >=0.6.0 <0.7.0;
+
+		contract NewFallbacks {
+			event Fallback(bytes data);
+			fallback() external {
+				emit Fallback(msg.data);
+			}
+
+			event Received(address addr, uint value);
+			receive() external payable {
+				emit Received(msg.sender, msg.value);
+			}
+		}
+		`,
+		[]string{`6100808061000d6000396000f3366100345733600052346020527f88a5966d370b9919b20f3e2c13ff65706f196a4e32cc2c12bf57088f8852587460406000a1005b341561003f57600080fd5b602060005236602052366000604037601f3601601f19166040017f9043988963722edecc2099c75b0af0ff76af14ffca42ed6bce059a20a2a9f986906000a100`},
+		[]string{`[{"anonymous":false,"inputs":[{"indexed":false,"name":"data","type":"bytes"}],"name":"Fallback","type":"event"},{"anonymous":false,"inputs":[{"indexed":false,"name":"addr","type":"address"},{"indexed":false,"name":"value","type":"uint256"}],"name":"Received","type":"event"},{"stateMutability":"nonpayable","type":"fallback"},{"stateMutability":"payable","type":"receive"}]`},
+		`
+			"bytes"
+			"math/big"
+
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind/backends"
+			"github.com/ccmchain/go-ccmchain/core"
+			"github.com/ccmchain/go-ccmchain/crypto"
+		`,
+		`
+			key, _ := crypto.GenerateKey()
+			addr := crypto.PubkeyToAddress(key.PublicKey)
+
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{addr: {Balance: big.NewInt(1000000000)}}, 1000000)
+
+			opts := bind.NewKeyedTransactor(key)
+			_, _, c, err := DeployNewFallbacks(opts, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			// Test receive function
+			opts.Value = big.NewInt(100)
+			if _, err := c.Receive(opts); err != nil {
+				t.Fatalf("Failed to invoke receive function: %v", err)
+			}
+			sim.Commit()
+
+			received, err := c.FilterReceived(nil)
+			if err != nil {
+				t.Fatalf("Failed to filter received events: %v", err)
+			}
+			defer received.Close()
+			if !received.Next() {
+				t.Fatalf("Received event not found: %v", received.Error())
+			}
+			if received.Event.Addr != addr {
+				t.Fatalf("Address mismatch: have %x, want %x", received.Event.Addr, addr)
+			}
+			if received.Event.Value.Cmp(opts.Value) != 0 {
+				t.Fatalf("Value mismatch: have %v, want %v", received.Event.Value, opts.Value)
+			}
+
+			// Test fallback function
+			opts.Value = nil
+			calldata := []byte{0x01, 0x02, 0x03}
+			if _, err := c.Fallback(opts, calldata); err != nil {
+				t.Fatalf("Failed to invoke fallback function: %v", err)
+			}
+			sim.Commit()
+
+			fallback, err := c.FilterFallback(nil)
+			if err != nil {
+				t.Fatalf("Failed to filter fallback events: %v", err)
+			}
+			defer fallback.Close()
+			if !fallback.Next() {
+				t.Fatalf("Fallback event not found: %v", fallback.Error())
+			}
+			if !bytes.Equal(fallback.Event.Data, calldata) {
+				t.Fatalf("Calldata mismatch: have %x, want %x", fallback.Event.Data, calldata)
+			}
+		`,
+		nil,
+		nil,
+		nil,
+	},
+	// Tests that indexed dynamic types, arrays and tuples are surfaced as topic hashes.
+	// The bytecode is a minimal hand assembled equivalent of the contract source.
+	{
+		`IndexedHashes`,
+		`
+		pragma solidity ^0.5.10;
+		pragma experimental ABIEncoderV2;
+
+		contract IndexedHashes {
+			struct Point { uint256 x; uint256 y; }
+
+			event DynamicEvent(string indexed s, bytes indexed b, uint256 v);
+			event ComplexEvent(Point indexed t, uint256[] indexed a);
+
+			function emitEvents() public {
+				uint256[] memory a = new uint256[](2);
+				a[0] = 3;
+				a[1] = 4;
+
+				emit DynamicEvent("hello", hex"deadbeef", 7);
+				emit ComplexEvent(Point(1, 2), a);
+			}
+		}
+		`,
+		[]string{`6100d58061000d6000396000f360076000527fd4fd4e189132273036449fc9e11198c739161b4c0116a9a2dccdfa1c492006f17f1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac87f9e016448a45f45357a24036859a90cda6a34564740c705e335e65a8f234b546960206000a37f2e174c10e159ea99b867ce3205125c24a42d128804e4070ed6fcc8cc98166aa07fe90b7bceb6e7df5418fb78d8ee546e97c83a08bbccc01a0644d599ccd2a7c2e07fd009d5bd057a4382c5e7b3b8ddb00027be10f472cd7ab1eed1d6d22be4302b4c600080a300`},
+		[]string{`[{"anonymous":false,"inputs":[{"indexed":true,"name":"s","type":"string"},{"indexed":true,"name":"b","type":"bytes"},{"indexed":false,"name":"v","type":"uint256"}],"name":"DynamicEvent","type":"event"},{"anonymous":false,"inputs":[{"components":[{"name":"x","type":"uint256"},{"name":"y","type":"uint256"}],"indexed":true,"name":"t","type":"tuple"},{"indexed":true,"name":"a","type":"uint256[]"}],"name":"ComplexEvent","type":"event"},{"inputs":[],"name":"emitEvents","outputs":[],"stateMutability":"nonpayable","type":"function"}]`},
+		`
+			"math/big"
+
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
+			"github.com/ccmchain/go-ccmchain/accounts/abi/bind/backends"
+			"github.com/ccmchain/go-ccmchain/common"
+			"github.com/ccmchain/go-ccmchain/core"
+			"github.com/ccmchain/go-ccmchain/crypto"
+		`,
+		`
+			key, _ := crypto.GenerateKey()
+			auth := bind.NewKeyedTransactor(key)
+			sim := backends.NewSimulatedBackend(core.GenesisAlloc{auth.From: {Balance: big.NewInt(10000000000)}}, 10000000)
+
+			_, _, contract, err := DeployIndexedHashes(auth, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			if _, err := contract.EmitEvents(auth); err != nil {
+				t.Fatalf("Failed to emit events: %v", err)
+			}
+			sim.Commit()
+
+			// Indexed strings and bytes are filtered by value, but only their hashes are retrievable
+			dynamic, err := contract.FilterDynamicEvent(nil, []string{"hello"}, [][]byte{{0xde, 0xad, 0xbe, 0xef}})
+			if err != nil {
+				t.Fatalf("Failed to filter dynamic events: %v", err)
+			}
+			defer dynamic.Close()
+			if !dynamic.Next() {
+				t.Fatalf("Dynamic event not found: %v", dynamic.Error())
+			}
+			if want := crypto.Keccak256Hash([]byte("hello")); dynamic.Event.S != want {
+				t.Fatalf("String topic mismatch: have %x, want %x", dynamic.Event.S, want)
+			}
+			if want := crypto.Keccak256Hash([]byte{0xde, 0xad, 0xbe, 0xef}); dynamic.Event.B != want {
+				t.Fatalf("Bytes topic mismatch: have %x, want %x", dynamic.Event.B, want)
+			}
+			if dynamic.Event.V.Cmp(big.NewInt(7)) != 0 {
+				t.Fatalf("Value mismatch: have %v, want 7", dynamic.Event.V)
+			}
+			missing, err := contract.FilterDynamicEvent(nil, []string{"world"}, nil)
+			if err != nil {
+				t.Fatalf("Failed to filter dynamic events: %v", err)
+			}
+			defer missing.Close()
+			if missing.Next() {
+				t.Fatalf("Dynamic event matched wrong string filter")
+			}
+
+			// Indexed tuples and arrays are filtered by their topic hashes
+			var (
+				tuple = crypto.Keccak256Hash(common.LeftPadBytes([]byte{1}, 32), common.LeftPadBytes([]byte{2}, 32))
+				array = crypto.Keccak256Hash(common.LeftPadBytes([]byte{3}, 32), common.LeftPadBytes([]byte{4}, 32))
+			)
+			complex, err := contract.FilterComplexEvent(nil, []common.Hash{tuple}, []common.Hash{array})
+			if err != nil {
+				t.Fatalf("Failed to filter complex events: %v", err)
+			}
+			defer complex.Close()
+			if !complex.Next() {
+				t.Fatalf("Complex event not found: %v", complex.Error())
+			}
+			if complex.Event.T != tuple {
+				t.Fatalf("Tuple topic mismatch: have %x, want %x", complex.Event.T, tuple)
+			}
+			if complex.Event.A != array {
+				t.Fatalf("Array topic mismatch: have %x, want %x", complex.Event.A, array)
+			}
+		`,
+		nil,
+		nil,
+		nil,
+	},
+}
+
+// Tests that the binder refuses to generate colliding identifiers.
+func TestBindDuplicateIdentifiers(t *testing.T) {
+	abi := `[{"inputs":[],"name":"foo","outputs":[],"stateMutability":"nonpayable","type":"function"},{"inputs":[],"name":"_foo","outputs":[],"stateMutability":"nonpayable","type":"function"}]`
+	if _, err := Bind([]string{"Duplicate"}, []string{abi}, []string{""}, nil, "bindtest", LangGo, nil); err == nil {
+		t.Fatalf("binding succeeded with colliding identifiers")
+	}
+	abi = `[{"anonymous":false,"inputs":[],"name":"bar","type":"event"},{"anonymous":false,"inputs":[],"name":"Bar","type":"event"}]`
+	if _, err := Bind([]string{"Duplicate"}, []string{abi}, []string{""}, nil, "bindtest", LangGo, nil); err == nil {
+		t.Fatalf("binding succeeded with colliding event identifiers")
+	}
 }
 
 // Tests that packages generated by the binder can be successfully compiled and
@@ -27,12 +27,26 @@ import (
 // Event is an event potentially triggered by the EVM's LOG mechanism. The Event
 // holds type information (inputs) about the yielded output. Anonymous events
 // don't get the signature canonical representation as the first LOG topic.
+//
+// Overloaded events are stored under a unique Name (e.g. Transfer0), with
+// RawName holding the declared name used to calculate the signature. Events
+// constructed without a RawName use their Name instead.
 type Event struct {
 	Name      string
+	RawName   string
 	Anonymous bool
 	Inputs    Arguments
 }
 
+// rawName returns the declared name of the event, falling back to its unique
+// name if the event was not parsed from an ABI definition.
+func (e Event) rawName() string {
+	if e.RawName != "" {
+		return e.RawName
+	}
+	return e.Name
+}
+
 func (e Event) String() string {
 	inputs := make([]string, len(e.Inputs))
 	for i, input := range e.Inputs {
@@ -41,7 +55,7 @@ func (e Event) String() string {
 			inputs[i] = fmt.Sprintf("%v indexed %v", input.Type, input.Name)
 		}
 	}
-	return fmt.Sprintf("event %v(%v)", e.Name, strings.Join(inputs, ", "))
+	return fmt.Sprintf("event %v(%v)", e.rawName(), strings.Join(inputs, ", "))
 }
 
 // Id returns the canonical representation of the event's signature used by the
@@ -53,5 +67,5 @@ func (e Event) Id() common.Hash {
 		types[i] = input.Type.String()
 		i++
 	}
-	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ",")))))
+	return common.BytesToHash(crypto.Keccak256([]byte(fmt.Sprintf("%v(%v)", e.rawName(), strings.Join(types, ",")))))
 }
@@ -144,6 +144,20 @@ func TestEventString(t *testing.T) {
 	}
 }
 
+// Tests that events constructed without a raw name use their name for the
+// signature and the string representation.
+func TestEventWithoutRawName(t *testing.T) {
+	uint256, _ := NewType("uint256", nil)
+	event := Event{Name: "Balance", Inputs: Arguments{{Name: "in", Type: uint256}}}
+
+	if id, want := event.Id(), crypto.Keccak256Hash([]byte("Balance(uint256)")); id != want {
+		t.Errorf("expected id to be %x, got %x", want, id)
+	}
+	if str, want := event.String(), "event Balance(uint256 in)"; str != want {
+		t.Errorf("expected string to be %s, got %s", want, str)
+	}
+}
+
 // TestEventMultiValueWithArrayUnpack verifies that array fields will be counted after parsing array.
 func TestEventMultiValueWithArrayUnpack(t *testing.T) {
 	definition := `[{"name": "test", "type": "event", "inputs": [{"indexed": false, "name":"value1", "type":"uint8[2]"},{"indexed": false, "name":"value2", "type":"uint8"}]}]`
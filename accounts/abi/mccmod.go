@@ -31,8 +31,14 @@ import (
 // network. A method such as `Transact` does require a Tx and thus will
 // be flagged `false`.
 // Input specifies the required input parameters for this gives method.
+//
+// Solidity allows overloading methods, in which case Name is the unique name
+// the method is stored under in the ABI (e.g. transfer0) and RawName is the
+// declared name used to calculate the selector. Methods constructed without a
+// RawName use their Name instead.
 type Method struct {
 	Name    string
+	RawName string
 	Const   bool
 	Inputs  Arguments
 	Outputs Arguments
@@ -50,7 +56,16 @@ func (method Method) Sig() string {
 	for i, input := range method.Inputs {
 		types[i] = input.Type.String()
 	}
-	return fmt.Sprintf("%v(%v)", method.Name, strings.Join(types, ","))
+	return fmt.Sprintf("%v(%v)", method.rawName(), strings.Join(types, ","))
+}
+
+// rawName returns the declared name of the method, falling back to its unique
+// name if the method was not parsed from an ABI definition.
+func (method Method) rawName() string {
+	if method.RawName != "" {
+		return method.RawName
+	}
+	return method.Name
 }
 
 func (method Method) String() string {
@@ -69,7 +84,7 @@ func (method Method) String() string {
 	if method.Const {
 		constant = "constant "
 	}
-	return fmt.Sprintf("function %v(%v) %sreturns(%v)", method.Name, strings.Join(inputs, ", "), constant, strings.Join(outputs, ", "))
+	return fmt.Sprintf("function %v(%v) %sreturns(%v)", method.rawName(), strings.Join(inputs, ", "), constant, strings.Join(outputs, ", "))
 }
 
 func (method Method) Id() []byte {
@@ -0,0 +1,148 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/crypto"
+)
+
+var (
+	revertSelector = crypto.Keccak256([]byte("Error(string)"))[:4]
+	panicSelector  = crypto.Keccak256([]byte("Panic(uint256)"))[:4]
+)
+
+// panicReasons map the Solidity panic codes to human readable descriptions.
+var panicReasons = map[uint64]string{
+	0x00: "generic panic",
+	0x01: "assert(false)",
+	0x11: "arithmetic underflow or overflow",
+	0x12: "division or modulo by zero",
+	0x21: "enum overflow",
+	0x22: "invalid encoded storage byte array accessed",
+	0x31: "out-of-bounds array access; popping on an empty array",
+	0x32: "out-of-bounds access of an array or bytesN",
+	0x41: "out of memory",
+	0x51: "uninitialized function",
+}
+
+// UnpackRevert resolves the abi-encoded revert reason. According to the solidity
+// spec, the revert reason is abi-encoded as if it were a call to a function
+// `Error(string)`, while failed assertions and internal errors are encoded as
+// a call to `Panic(uint256)`.
+func UnpackRevert(data []byte) (string, error) {
+	if len(data) < 4 {
+		return "", errors.New("abi: revert data too short")
+	}
+	switch {
+	case bytes.Equal(data[:4], revertSelector):
+		typ, _ := NewType("string", nil)
+		var reason string
+		if err := (Arguments{{Type: typ}}).Unpack(&reason, data[4:]); err != nil {
+			return "", err
+		}
+		return reason, nil
+
+	case bytes.Equal(data[:4], panicSelector):
+		typ, _ := NewType("uint256", nil)
+		var code *big.Int
+		if err := (Arguments{{Type: typ}}).Unpack(&code, data[4:]); err != nil {
+			return "", err
+		}
+		if code.IsUint64() {
+			if reason, ok := panicReasons[code.Uint64()]; ok {
+				return reason, nil
+			}
+		}
+		return fmt.Sprintf("unknown panic code: %#x", code), nil
+	}
+	return "", fmt.Errorf("abi: unknown revert selector %#x", data[:4])
+}
+
+// Error is a custom Solidity error, which contracts may raise through the
+// REVERT opcode with its abi-encoded arguments instead of an `Error(string)`.
+type Error struct {
+	Name   string
+	Inputs Arguments
+}
+
+// Sig returns the error's string signature according to the ABI spec.
+func (e Error) Sig() string {
+	types := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		types[i] = input.Type.String()
+	}
+	return fmt.Sprintf("%v(%v)", e.Name, strings.Join(types, ","))
+}
+
+func (e Error) String() string {
+	inputs := make([]string, len(e.Inputs))
+	for i, input := range e.Inputs {
+		inputs[i] = fmt.Sprintf("%v %v", input.Type, input.Name)
+	}
+	return fmt.Sprintf("error %v(%v)", e.Name, strings.Join(inputs, ", "))
+}
+
+// Id returns the 4 byte selector of the error.
+func (e Error) Id() []byte {
+	return crypto.Keccak256([]byte(e.Sig()))[:4]
+}
+
+// Unpack decodes the arguments of the error from the given revert data.
+func (e Error) Unpack(data []byte) ([]interface{}, error) {
+	if len(data) < 4 {
+		return nil, errors.New("abi: revert data too short")
+	}
+	if !bytes.Equal(data[:4], e.Id()) {
+		return nil, fmt.Errorf("abi: revert data is not a %s error", e.Name)
+	}
+	return e.Inputs.UnpackValues(data[4:])
+}
+
+// RevertError is returned by contract calls and gas estimations aborted by the
+// REVERT opcode. It carries the raw revert data along with the decoded reason.
+type RevertError struct {
+	Reason string // Decoded revert reason, empty if the data couldn't be unpacked
+	Data   []byte // Raw data supplied with the REVERT opcode
+}
+
+// NewRevertError creates a revert error from the data returned by a reverted
+// execution, decoding any `Error(string)` or `Panic(uint256)` reason.
+func NewRevertError(data []byte) *RevertError {
+	reason, _ := UnpackRevert(data)
+	return &RevertError{Reason: reason, Data: common.CopyBytes(data)}
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// ErrorCode returns the JSON-RPC error code of reverted executions.
+func (e *RevertError) ErrorCode() int { return 3 }
+
+// ErrorData returns the hex encoded revert data, attached to JSON-RPC errors.
+func (e *RevertError) ErrorData() interface{} { return hexutil.Encode(e.Data) }
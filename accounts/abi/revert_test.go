@@ -0,0 +1,122 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package abi
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/common"
+)
+
+func TestUnpackRevert(t *testing.T) {
+	var cases = []struct {
+		input     string
+		expect    string
+		expectErr string
+	}{
+		{"", "", "abi: revert data too short"},
+		{"08c379a1", "", "abi: unknown revert selector 0x08c379a1"},
+		{"08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000", "revert reason", ""},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000000", "generic panic", ""},
+		{"4e487b710000000000000000000000000000000000000000000000000000000000000011", "arithmetic underflow or overflow", ""},
+		{"4e487b7100000000000000000000000000000000000000000000000000000000000000ff", "unknown panic code: 0xff", ""},
+	}
+	for index, c := range cases {
+		got, err := UnpackRevert(common.Hex2Bytes(c.input))
+		if c.expectErr != "" {
+			if err == nil {
+				t.Fatalf("case %d: expected error %q, got nil", index, c.expectErr)
+			}
+			if err.Error() != c.expectErr {
+				t.Fatalf("case %d: expected error %q, got %q", index, c.expectErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("case %d: unexpected error: %v", index, err)
+		}
+		if got != c.expect {
+			t.Fatalf("case %d: output mismatch, have %q, want %q", index, got, c.expect)
+		}
+	}
+}
+
+func TestRevertError(t *testing.T) {
+	data := common.Hex2Bytes("08c379a00000000000000000000000000000000000000000000000000000000000000020000000000000000000000000000000000000000000000000000000000000000d72657665727420726561736f6e00000000000000000000000000000000000000")
+	err := NewRevertError(data)
+	if have, want := err.Error(), "execution reverted: revert reason"; have != want {
+		t.Errorf("message mismatch: have %q, want %q", have, want)
+	}
+	if have, want := err.ErrorData(), "0x"+common.Bytes2Hex(data); have != want {
+		t.Errorf("data mismatch: have %v, want %v", have, want)
+	}
+	if have, want := NewRevertError([]byte{0xde, 0xad}).Error(), "execution reverted"; have != want {
+		t.Errorf("undecodable message mismatch: have %q, want %q", have, want)
+	}
+}
+
+const customErrorsJSON = `[
+	{"type":"error","name":"Insufficient","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]},
+	{"type":"error","name":"Insufficient","inputs":[{"name":"available","type":"uint256"}]},
+	{"type":"error","name":"Unauthorized","inputs":[]}
+]`
+
+func TestCustomErrors(t *testing.T) {
+	abi, err := JSON(strings.NewReader(customErrorsJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abi.Errors) != 3 {
+		t.Fatalf("error count mismatch: have %d, want 3", len(abi.Errors))
+	}
+	for _, name := range []string{"Insufficient", "Insufficient0", "Unauthorized"} {
+		if _, ok := abi.Errors[name]; !ok {
+			t.Errorf("missing error %q", name)
+		}
+	}
+	if have, want := abi.Errors["Insufficient0"].Sig(), "Insufficient(uint256)"; have != want {
+		t.Errorf("overloaded signature mismatch: have %q, want %q", have, want)
+	}
+	// Resolve a revert of Insufficient(1, 2) and decode its arguments
+	data := common.Hex2Bytes("e8620800" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002")
+
+	custom, err := abi.ErrorByID(data)
+	if err != nil {
+		t.Fatalf("failed to look up error: %v", err)
+	}
+	if custom.Sig() != "Insufficient(uint256,uint256)" {
+		t.Fatalf("wrong error resolved: %v", custom)
+	}
+	args, err := custom.Unpack(data)
+	if err != nil {
+		t.Fatalf("failed to unpack error: %v", err)
+	}
+	if want := []interface{}{big.NewInt(1), big.NewInt(2)}; !reflect.DeepEqual(args, want) {
+		t.Errorf("arguments mismatch: have %v, want %v", args, want)
+	}
+	if _, err := abi.ErrorByID(common.Hex2Bytes("deadbeef")); err == nil {
+		t.Errorf("expected lookup of unknown selector to fail")
+	}
+	if _, err := abi.Errors["Unauthorized"].Unpack(data); err == nil {
+		t.Errorf("expected unpacking with mismatching selector to fail")
+	}
+}
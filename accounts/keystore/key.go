@@ -53,6 +53,11 @@ type keyStore interface {
 	GetKey(addr common.Address, filename string, auth string) (*Key, error)
 	// Writes and encrypts the key.
 	StoreKey(filename string, k *Key, auth string) error
+	// Writes and encrypts a batch of keys with the given scrypt parameters,
+	// staging all of them before replacing any stored one.
+	StoreKeys(filenames []string, keys []*Key, auth string, scryptN, scryptP int) error
+	// Deletes the key from disk.
+	DeleteKey(filename string) error
 	// Joins filename with the key directory unless it is already absolute.
 	JoinPath(filename string) string
 }
@@ -212,6 +217,70 @@ func writeKeyFile(file string, content []byte) error {
 	return os.Rename(name, file)
 }
 
+// writeKeyFiles writes a batch of key files, all or nothing. All of them are staged
+// in temporary files and verified first, only moving them into place afterwards.
+// Should moving any of them fail, the files already moved are rolled back to their
+// original contents.
+func writeKeyFiles(files []string, contents [][]byte, verify func(file, tmpName string) error) error {
+	tmpNames := make([]string, 0, len(files))
+	defer func() {
+		for _, tmpName := range tmpNames {
+			os.Remove(tmpName)
+		}
+	}()
+	for i, file := range files {
+		tmpName, err := writeTemporaryKeyFile(file, contents[i])
+		if err != nil {
+			return err
+		}
+		tmpNames = append(tmpNames, tmpName)
+		if verify != nil {
+			if err := verify(file, tmpName); err != nil {
+				return err
+			}
+		}
+	}
+	// Back up the files about to be replaced, nil marking the ones not existing yet
+	backups := make([][]byte, len(files))
+	for i, file := range files {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups[i] = blob
+	}
+	for i, tmpName := range tmpNames {
+		if err := os.Rename(tmpName, files[i]); err != nil {
+			tmpNames = tmpNames[i:]
+			if failed := restoreKeyFiles(files[:i], backups[:i]); len(failed) > 0 {
+				return fmt.Errorf("%v, failed to restore %s", err, strings.Join(failed, ", "))
+			}
+			return err
+		}
+	}
+	tmpNames = nil
+	return nil
+}
+
+// restoreKeyFiles rolls back a partially written batch of key files to the given
+// backups, removing the files which didn't exist before. The files which could
+// not be restored are returned.
+func restoreKeyFiles(files []string, backups [][]byte) []string {
+	var failed []string
+	for i, file := range files {
+		var err error
+		if backups[i] == nil {
+			err = os.Remove(file)
+		} else {
+			err = writeKeyFile(file, backups[i])
+		}
+		if err != nil {
+			failed = append(failed, file)
+		}
+	}
+	return failed
+}
+
 // keyFileName implements the naming convention for keyfiles:
 // UTC--<created_at UTC ISO8601>-<address hex>
 func keyFileName(keyAddr common.Address) string {
@@ -26,7 +26,6 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
-	"os"
 	"path/filepath"
 	"reflect"
 	"runtime"
@@ -55,10 +54,10 @@ const KeyStoreScheme = "keystore"
 // Maximum time between wallet refreshes (if filesystem notifications don't work).
 const walletRefreshCycle = 3 * time.Second
 
-// KeyStore manages a key storage directory on disk.
+// KeyStore manages a key storage directory or vault file on disk.
 type KeyStore struct {
 	storage  keyStore                     // Storage backend, might be cleartext or encrypted
-	cache    *accountCache                // In-memory account cache over the filesystem storage
+	cache    accountIndex                 // In-memory account index over the storage backend
 	changes  chan struct{}                // Channel receiving change notifications from the cache
 	unlocked map[common.Address]*unlocked // Currently unlocked account (decrypted private keys)
 
@@ -75,11 +74,27 @@ type unlocked struct {
 	abort chan struct{}
 }
 
+// accountIndex is a live index of the accounts held by a storage backend.
+type accountIndex interface {
+	// accounts returns all the indexed accounts, sorted by URL.
+	accounts() []accounts.Account
+	// hasAddress reports whccmer a key with the given address is indexed.
+	hasAddress(addr common.Address) bool
+	// findAccount resolves the given account into a unique indexed entry.
+	findAccount(a accounts.Account) (accounts.Account, error)
+	// add inserts a newly stored account into the index.
+	add(a accounts.Account)
+	// delete removes a deleted account from the index.
+	delete(a accounts.Account)
+	// close releases any resources held by the index.
+	close()
+}
+
 // NewKeyStore creates a keystore for the given directory.
 func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
 	ks := &KeyStore{storage: &keyStorePassphrase{keydir, scryptN, scryptP, false}}
-	ks.init(keydir)
+	ks.init(newAccountCache(keydir))
 	return ks
 }
 
@@ -88,18 +103,33 @@ func NewKeyStore(keydir string, scryptN, scryptP int) *KeyStore {
 func NewPlaintextKeyStore(keydir string) *KeyStore {
 	keydir, _ = filepath.Abs(keydir)
 	ks := &KeyStore{storage: &keyStorePlain{keydir}}
-	ks.init(keydir)
+	ks.init(newAccountCache(keydir))
+	return ks
+}
+
+// NewVaultKeyStore creates a keystore which keeps all its keys in a single vault
+// file instead of a directory of key files. Each key is encrypted separately with
+// its passphrase, and the vault is replaced atomically whenever it's modified.
+//
+// Only the keys themselves are encrypted: the vault indexes them by file name, and
+// both the names and the addresses within the keys are stored in cleartext, just
+// like in a keystore directory.
+func NewVaultKeyStore(path string, scryptN, scryptP int) *KeyStore {
+	path, _ = filepath.Abs(path)
+	vault := newKeyStoreVault(path, scryptN, scryptP)
+	ks := &KeyStore{storage: vault}
+	ks.init(vault, vault.notify)
 	return ks
 }
 
-func (ks *KeyStore) init(keydir string) {
+func (ks *KeyStore) init(cache accountIndex, changes chan struct{}) {
 	// Lock the mutex since the account cache might call back with events
 	ks.mu.Lock()
 	defer ks.mu.Unlock()
 
 	// Initialize the set of unlocked keys and the account cache
 	ks.unlocked = make(map[common.Address]*unlocked)
-	ks.cache, ks.changes = newAccountCache(keydir)
+	ks.cache, ks.changes = cache, changes
 
 	// TODO: In order for this finalizer to work, there must be no references
 	// to ks. addressCache doesn't keep a reference but unlocked keys do,
@@ -246,7 +276,7 @@ func (ks *KeyStore) Delete(a accounts.Account, passphrase string) error {
 	// The order is crucial here. The key is dropped from the
 	// cache after the file is gone so that a reload happening in
 	// between won't insert it into the cache again.
-	err = os.Remove(a.URL.Path)
+	err = ks.storage.DeleteKey(a.URL.Path)
 	if err == nil {
 		ks.cache.delete(a)
 		ks.refreshWallets()
@@ -369,11 +399,7 @@ func (ks *KeyStore) TimedUnlock(a accounts.Account, passphrase string, timeout t
 
 // Find resolves the given account into a unique entry in the keystore.
 func (ks *KeyStore) Find(a accounts.Account) (accounts.Account, error) {
-	ks.cache.maybeReload()
-	ks.cache.mu.Lock()
-	a, err := ks.cache.find(a)
-	ks.cache.mu.Unlock()
-	return a, err
+	return ks.cache.findAccount(a)
 }
 
 func (ks *KeyStore) getDecryptedKey(a accounts.Account, auth string) (accounts.Account, *Key, error) {
@@ -426,9 +452,12 @@ func (ks *KeyStore) Export(a accounts.Account, passphrase, newPassphrase string)
 		return nil, err
 	}
 	var N, P int
-	if store, ok := ks.storage.(*keyStorePassphrase); ok {
+	switch store := ks.storage.(type) {
+	case *keyStorePassphrase:
 		N, P = store.scryptN, store.scryptP
-	} else {
+	case *keyStoreVault:
+		N, P = store.scryptN, store.scryptP
+	default:
 		N, P = StandardScryptN, StandardScryptP
 	}
 	return EncryptKey(key, newPassphrase, N, P)
@@ -474,6 +503,32 @@ func (ks *KeyStore) Update(a accounts.Account, passphrase, newPassphrase string)
 	return ks.storage.StoreKey(a.URL.Path, key, newPassphrase)
 }
 
+// Reencrypt changes the passphrase of all the accounts in the keystore, encrypting
+// them anew with the given scrypt parameters. All the keys are decrypted before any
+// of them is rewritten, so nothing is modified if any of them can't be unlocked.
+// The rewritten keys are staged before being swapped in, which is atomic for vault
+// keystores and done file by file for key directories, restoring the original
+// files should any of them fail to be replaced.
+func (ks *KeyStore) Reencrypt(passphrase, newPassphrase string, scryptN, scryptP int) error {
+	accs := ks.cache.accounts()
+
+	keys := make([]*Key, 0, len(accs))
+	defer func() {
+		for _, key := range keys {
+			zeroKey(key.PrivateKey)
+		}
+	}()
+	paths := make([]string, len(accs))
+	for i, a := range accs {
+		key, err := ks.storage.GetKey(a.Address, a.URL.Path, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %v", a.URL.Path, err)
+		}
+		paths[i], keys = a.URL.Path, append(keys, key)
+	}
+	return ks.storage.StoreKeys(paths, keys, newPassphrase, scryptN, scryptP)
+}
+
 // ImportPreSaleKey decrypts the given Ccmchain presale wallet and stores
 // a key file in the key directory. The key file is encrypted with the same passphrase.
 func (ks *KeyStore) ImportPreSaleKey(keyJSON []byte, passphrase string) (accounts.Account, error) {
@@ -20,6 +20,7 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -33,10 +34,9 @@ import (
 
 var testSigData = make([]byte, 32)
 
-func TestKeyStore(t *testing.T) {
-	dir, ks := tmpKeyStore(t, true)
-	defer os.RemoveAll(dir)
+func TestKeyStore(t *testing.T) { forEachBackend(t, true, testKeyStore) }
 
+func testKeyStore(t *testing.T, dir string, ks *KeyStore) {
 	a, err := ks.NewAccount("foo")
 	if err != nil {
 		t.Fatal(err)
@@ -44,7 +44,13 @@ func TestKeyStore(t *testing.T) {
 	if !strings.HasPrefix(a.URL.Path, dir) {
 		t.Errorf("account file %s doesn't have dir prefix", a.URL)
 	}
-	stat, err := os.Stat(a.URL.Path)
+	// Vault keys are virtual paths within the vault, check the vault file instead
+	vault, isVault := ks.storage.(*keyStoreVault)
+	file := a.URL.Path
+	if isVault {
+		file = vault.path
+	}
+	stat, err := os.Stat(file)
 	if err != nil {
 		t.Fatalf("account file %s doesn't exist (%v)", a.URL, err)
 	}
@@ -60,7 +66,7 @@ func TestKeyStore(t *testing.T) {
 	if err := ks.Delete(a, "bar"); err != nil {
 		t.Errorf("Delete error: %v", err)
 	}
-	if common.FileExist(a.URL.Path) {
+	if !isVault && common.FileExist(a.URL.Path) {
 		t.Errorf("account file %s should be gone after Delete", a.URL)
 	}
 	if ks.HasAddress(a.Address) {
@@ -68,10 +74,9 @@ func TestKeyStore(t *testing.T) {
 	}
 }
 
-func TestSign(t *testing.T) {
-	dir, ks := tmpKeyStore(t, true)
-	defer os.RemoveAll(dir)
+func TestSign(t *testing.T) { forEachBackend(t, true, testSign) }
 
+func testSign(t *testing.T, dir string, ks *KeyStore) {
 	pass := "" // not used but required by API
 	a1, err := ks.NewAccount(pass)
 	if err != nil {
@@ -85,10 +90,9 @@ func TestSign(t *testing.T) {
 	}
 }
 
-func TestSignWithPassphrase(t *testing.T) {
-	dir, ks := tmpKeyStore(t, true)
-	defer os.RemoveAll(dir)
+func TestSignWithPassphrase(t *testing.T) { forEachBackend(t, true, testSignWithPassphrase) }
 
+func testSignWithPassphrase(t *testing.T, dir string, ks *KeyStore) {
 	pass := "passwd"
 	acc, err := ks.NewAccount(pass)
 	if err != nil {
@@ -113,10 +117,9 @@ func TestSignWithPassphrase(t *testing.T) {
 	}
 }
 
-func TestTimedUnlock(t *testing.T) {
-	dir, ks := tmpKeyStore(t, true)
-	defer os.RemoveAll(dir)
+func TestTimedUnlock(t *testing.T) { forEachBackend(t, true, testTimedUnlock) }
 
+func testTimedUnlock(t *testing.T, dir string, ks *KeyStore) {
 	pass := "foo"
 	a1, err := ks.NewAccount(pass)
 	if err != nil {
@@ -148,10 +151,9 @@ func TestTimedUnlock(t *testing.T) {
 	}
 }
 
-func TestOverrideUnlock(t *testing.T) {
-	dir, ks := tmpKeyStore(t, false)
-	defer os.RemoveAll(dir)
+func TestOverrideUnlock(t *testing.T) { forEachBackend(t, false, testOverrideUnlock) }
 
+func testOverrideUnlock(t *testing.T, dir string, ks *KeyStore) {
 	pass := "foo"
 	a1, err := ks.NewAccount(pass)
 	if err != nil {
@@ -189,10 +191,9 @@ func TestOverrideUnlock(t *testing.T) {
 }
 
 // This test should fail under -race if signing races the expiration goroutine.
-func TestSignRace(t *testing.T) {
-	dir, ks := tmpKeyStore(t, false)
-	defer os.RemoveAll(dir)
+func TestSignRace(t *testing.T) { forEachBackend(t, false, testSignRace) }
 
+func testSignRace(t *testing.T, dir string, ks *KeyStore) {
 	// Create a test account.
 	a1, err := ks.NewAccount("")
 	if err != nil {
@@ -218,10 +219,10 @@ func TestSignRace(t *testing.T) {
 // Tests that the wallet notifier loop starts and stops correctly based on the
 // addition and removal of wallet event subscriptions.
 func TestWalletNotifierLifecycle(t *testing.T) {
-	// Create a temporary kesytore to test with
-	dir, ks := tmpKeyStore(t, false)
-	defer os.RemoveAll(dir)
+	forEachBackend(t, false, testWalletNotifierLifecycle)
+}
 
+func testWalletNotifierLifecycle(t *testing.T, dir string, ks *KeyStore) {
 	// Ensure that the notification updater is not running yet
 	time.Sleep(250 * time.Millisecond)
 	ks.mu.RLock()
@@ -279,10 +280,9 @@ type walletEvent struct {
 
 // Tests that wallet notifications and correctly fired when accounts are added
 // or deleted from the keystore.
-func TestWalletNotifications(t *testing.T) {
-	dir, ks := tmpKeyStore(t, false)
-	defer os.RemoveAll(dir)
+func TestWalletNotifications(t *testing.T) { forEachBackend(t, false, testWalletNotifications) }
 
+func testWalletNotifications(t *testing.T, dir string, ks *KeyStore) {
 	// Subscribe to the wallet feed and collect events.
 	var (
 		events  []walletEvent
@@ -338,6 +338,52 @@ func TestWalletNotifications(t *testing.T) {
 	checkEvents(t, wantEvents, events)
 }
 
+// Tests that re-encrypting the keystore rotates the passphrase of all the keys,
+// and that it doesn't modify anything if any of the keys can't be decrypted.
+func TestReencrypt(t *testing.T) { forEachBackend(t, true, testReencrypt) }
+
+func testReencrypt(t *testing.T, dir string, ks *KeyStore) {
+	var accs []accounts.Account
+	for i := 0; i < 3; i++ {
+		a, err := ks.NewAccount("foo")
+		if err != nil {
+			t.Fatalf("failed to create test account: %v", err)
+		}
+		accs = append(accs, a)
+	}
+	odd, err := ks.NewAccount("odd")
+	if err != nil {
+		t.Fatalf("failed to create test account: %v", err)
+	}
+	// Re-encrypting with a key unlockable by a different passphrase must fail
+	if err := ks.Reencrypt("foo", "bar", veryLightScryptN, veryLightScryptP); err == nil {
+		t.Fatalf("re-encryption succeeded with mismatching passphrases")
+	}
+	for _, a := range accs {
+		if _, err := ks.SignHashWithPassphrase(a, "foo", testSigData); err != nil {
+			t.Fatalf("account %x: old passphrase rejected after failed re-encryption: %v", a.Address, err)
+		}
+	}
+	// Drop the odd key out and re-encrypt the rest
+	if err := ks.Delete(odd, "odd"); err != nil {
+		t.Fatalf("failed to delete test account: %v", err)
+	}
+	if err := ks.Reencrypt("foo", "bar", veryLightScryptN, veryLightScryptP); err != nil {
+		t.Fatalf("failed to re-encrypt keystore: %v", err)
+	}
+	for _, a := range accs {
+		if _, err := ks.SignHashWithPassphrase(a, "foo", testSigData); err == nil {
+			t.Errorf("account %x: old passphrase accepted after re-encryption", a.Address)
+		}
+		if _, err := ks.SignHashWithPassphrase(a, "bar", testSigData); err != nil {
+			t.Errorf("account %x: new passphrase rejected after re-encryption: %v", a.Address, err)
+		}
+	}
+	if have := len(ks.Accounts()); have != len(accs) {
+		t.Errorf("account count mismatch: have %d, want %d", have, len(accs))
+	}
+}
+
 // checkAccounts checks that all known live accounts are present in the wallet list.
 func checkAccounts(t *testing.T, live map[common.Address]accounts.Account, wallets []accounts.Wallet) {
 	if len(live) != len(wallets) {
@@ -385,3 +431,33 @@ func tmpKeyStore(t *testing.T, encrypted bool) (string, *KeyStore) {
 	}
 	return d, newKs(d)
 }
+
+func tmpVaultKeyStore(t *testing.T, encrypted bool) (string, *KeyStore) {
+	d, err := ioutil.TempDir("", "ccm-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d, NewVaultKeyStore(filepath.Join(d, "keys.vault"), veryLightScryptN, veryLightScryptP)
+}
+
+// keyStoreBackends are the key storage backends the keystore tests are run against.
+var keyStoreBackends = []struct {
+	name string
+	new  func(t *testing.T, encrypted bool) (string, *KeyStore)
+}{
+	{"dir", tmpKeyStore},
+	{"vault", tmpVaultKeyStore},
+}
+
+// forEachBackend runs a keystore test against all the key storage backends.
+func forEachBackend(t *testing.T, encrypted bool, test func(t *testing.T, dir string, ks *KeyStore)) {
+	for _, backend := range keyStoreBackends {
+		backend := backend
+		t.Run(backend.name, func(t *testing.T) {
+			dir, ks := backend.new(t, encrypted)
+			defer os.RemoveAll(dir)
+
+			test(t, dir, ks)
+		})
+	}
+}
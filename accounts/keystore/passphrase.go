@@ -129,6 +129,36 @@ func (ks keyStorePassphrase) StoreKey(filename string, key *Key, auth string) er
 	return os.Rename(tmpName, filename)
 }
 
+func (ks keyStorePassphrase) StoreKeys(filenames []string, keys []*Key, auth string, scryptN, scryptP int) error {
+	contents := make([][]byte, len(keys))
+	for i, key := range keys {
+		keyjson, err := EncryptKey(key, auth, scryptN, scryptP)
+		if err != nil {
+			return err
+		}
+		contents[i] = keyjson
+	}
+	var verify func(file, tmpName string) error
+	if !ks.skipKeyFileVerification {
+		// Verify that we can decrypt all the files with the given password.
+		verify = func(file, tmpName string) error {
+			keyjson, err := ioutil.ReadFile(tmpName)
+			if err != nil {
+				return err
+			}
+			if _, err := DecryptKey(keyjson, auth); err != nil {
+				return fmt.Errorf("failed to verify re-encrypted %s: %v", file, err)
+			}
+			return nil
+		}
+	}
+	return writeKeyFiles(filenames, contents, verify)
+}
+
+func (ks keyStorePassphrase) DeleteKey(filename string) error {
+	return os.Remove(filename)
+}
+
 func (ks keyStorePassphrase) JoinPath(filename string) string {
 	if filepath.IsAbs(filename) {
 		return filename
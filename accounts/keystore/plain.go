@@ -53,6 +53,22 @@ func (ks keyStorePlain) StoreKey(filename string, key *Key, auth string) error {
 	return writeKeyFile(filename, content)
 }
 
+func (ks keyStorePlain) StoreKeys(filenames []string, keys []*Key, auth string, scryptN, scryptP int) error {
+	contents := make([][]byte, len(keys))
+	for i, key := range keys {
+		content, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		contents[i] = content
+	}
+	return writeKeyFiles(filenames, contents, nil)
+}
+
+func (ks keyStorePlain) DeleteKey(filename string) error {
+	return os.Remove(filename)
+}
+
 func (ks keyStorePlain) JoinPath(filename string) string {
 	if filepath.IsAbs(filename) {
 		return filename
@@ -100,6 +100,49 @@ func TestKeyStorePassphraseDecryptionFail(t *testing.T) {
 	}
 }
 
+// Tests that a batch of key files failing to be moved into place midway is rolled
+// back, restoring the already replaced files and removing the newly created ones.
+func TestWriteKeyFilesRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gccm-keystore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		existing = filepath.Join(dir, "existing")
+		created  = filepath.Join(dir, "created")
+		failing  = filepath.Join(dir, "failing")
+	)
+	for _, file := range []string{existing, failing} {
+		if err := ioutil.WriteFile(file, []byte("original "+filepath.Base(file)), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Drop the staged file of the last key, failing its move after the others
+	verify := func(file, tmpName string) error {
+		if file == failing {
+			return os.Remove(tmpName)
+		}
+		return nil
+	}
+	files := []string{existing, created, failing}
+	if err := writeKeyFiles(files, [][]byte{[]byte("new"), []byte("new"), []byte("new")}, verify); err == nil {
+		t.Fatalf("failing batch written")
+	}
+	for _, file := range []string{existing, failing} {
+		if blob, err := ioutil.ReadFile(file); err != nil || string(blob) != "original "+filepath.Base(file) {
+			t.Errorf("%s: content mismatch: have %q, %v", filepath.Base(file), blob, err)
+		}
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("created file not removed: %v", err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("leftover files: have %d entries, want 2", len(entries))
+	}
+}
+
 func TestImportPreSaleKey(t *testing.T) {
 	dir, ks := tmpKeyStoreIface(t, true)
 	defer os.RemoveAll(dir)
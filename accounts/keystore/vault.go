@@ -0,0 +1,317 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/accounts"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/log"
+)
+
+// vaultVersion is the version of the vault file format.
+const vaultVersion = 1
+
+// errVaultKeyNotFound is returned if a key requested from a vault is missing.
+var errVaultKeyNotFound = errors.New("key not found in vault")
+
+// vaultJSON is the on-disk format of a key vault.
+type vaultJSON struct {
+	Version int                        `json:"version"`
+	Keys    map[string]json.RawMessage `json:"keys"` // Encrypted keys, by key file name
+}
+
+// keyStoreVault is a storage backend keeping all the keys in a single vault file,
+// each of them encrypted separately in the Web3 Secret Storage format. The vault
+// is written into a temporary file first, which then atomically replaces it.
+//
+// The vault also indexes the accounts it holds. Their URLs are virtual paths
+// within the vault file, named according to the key file naming convention.
+type keyStoreVault struct {
+	*accountCache // In-memory index of the accounts in the vault
+
+	path    string // Location of the vault file
+	scryptN int
+	scryptP int
+
+	lock    sync.Mutex // Serializes the vault file accesses
+	modTime time.Time  // Modification time of the last loaded vault file
+	size    int64      // Size of the last loaded vault file
+}
+
+// newKeyStoreVault creates a vault backed storage for the given vault file and
+// loads the accounts already contained within.
+func newKeyStoreVault(path string, scryptN, scryptP int) *keyStoreVault {
+	vault := &keyStoreVault{
+		accountCache: &accountCache{
+			keydir: path,
+			byAddr: make(map[common.Address][]accounts.Account),
+			notify: make(chan struct{}, 1),
+		},
+		path:    path,
+		scryptN: scryptN,
+		scryptP: scryptP,
+	}
+	vault.reload()
+	return vault
+}
+
+func (v *keyStoreVault) GetKey(addr common.Address, filename, auth string) (*Key, error) {
+	name, err := v.keyName(filename)
+	if err != nil {
+		return nil, err
+	}
+	v.lock.Lock()
+	vault, err := v.read()
+	v.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	keyjson, ok := vault.Keys[name]
+	if !ok {
+		return nil, errVaultKeyNotFound
+	}
+	key, err := DecryptKey(keyjson, auth)
+	if err != nil {
+		return nil, err
+	}
+	// Make sure we're really operating on the requested key (no swap attacks)
+	if key.Address != addr {
+		return nil, fmt.Errorf("key content mismatch: have account %x, want %x", key.Address, addr)
+	}
+	return key, nil
+}
+
+func (v *keyStoreVault) StoreKey(filename string, key *Key, auth string) error {
+	return v.StoreKeys([]string{filename}, []*Key{key}, auth, v.scryptN, v.scryptP)
+}
+
+func (v *keyStoreVault) StoreKeys(filenames []string, keys []*Key, auth string, scryptN, scryptP int) error {
+	names := make([]string, len(filenames))
+	for i, filename := range filenames {
+		name, err := v.keyName(filename)
+		if err != nil {
+			return err
+		}
+		names[i] = name
+	}
+	contents := make([]json.RawMessage, len(keys))
+	for i, key := range keys {
+		keyjson, err := EncryptKey(key, auth, scryptN, scryptP)
+		if err != nil {
+			return err
+		}
+		contents[i] = keyjson
+	}
+	return v.update(func(keys map[string]json.RawMessage) error {
+		for i, name := range names {
+			keys[name] = contents[i]
+		}
+		return nil
+	})
+}
+
+func (v *keyStoreVault) DeleteKey(filename string) error {
+	name, err := v.keyName(filename)
+	if err != nil {
+		return err
+	}
+	return v.update(func(keys map[string]json.RawMessage) error {
+		if _, ok := keys[name]; !ok {
+			return errVaultKeyNotFound
+		}
+		delete(keys, name)
+		return nil
+	})
+}
+
+func (v *keyStoreVault) JoinPath(filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(v.path, filename)
+}
+
+// keyName converts a virtual key path into the name of the key within the vault.
+func (v *keyStoreVault) keyName(filename string) (string, error) {
+	if filepath.Dir(filename) != v.path {
+		return "", fmt.Errorf("key %s is not in vault %s", filename, v.path)
+	}
+	return filepath.Base(filename), nil
+}
+
+// read loads the contents of the vault file. A missing vault is considered empty.
+//
+// The mccmod assumes that the vault lock is held!
+func (v *keyStoreVault) read() (*vaultJSON, error) {
+	vault := &vaultJSON{Version: vaultVersion, Keys: make(map[string]json.RawMessage)}
+
+	blob, err := ioutil.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		return vault, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, vault); err != nil {
+		return nil, fmt.Errorf("invalid vault %s: %v", v.path, err)
+	}
+	if vault.Version != vaultVersion {
+		return nil, fmt.Errorf("unsupported vault version %d", vault.Version)
+	}
+	if vault.Keys == nil {
+		vault.Keys = make(map[string]json.RawMessage)
+	}
+	return vault, nil
+}
+
+// update applies a modification to the keys in the vault and atomically replaces
+// the vault file with the result. The account index is updated in the same go,
+// so the change is visible even if the file's size and modification time remain
+// the same.
+func (v *keyStoreVault) update(modify func(keys map[string]json.RawMessage) error) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	vault, err := v.read()
+	if err != nil {
+		return err
+	}
+	if err := modify(vault.Keys); err != nil {
+		return err
+	}
+	blob, err := json.Marshal(vault)
+	if err != nil {
+		return err
+	}
+	if err := writeKeyFile(v.path, blob); err != nil {
+		return err
+	}
+	v.modTime, v.size = v.stat()
+	v.index(vault)
+	return nil
+}
+
+// reload re-reads the vault file if it was changed by someone else since it was
+// last loaded, and updates the account index accordingly.
+func (v *keyStoreVault) reload() {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	modTime, size := v.stat()
+	if modTime.Equal(v.modTime) && size == v.size {
+		return
+	}
+	vault, err := v.read()
+	if err != nil {
+		log.Debug("Failed to reload keystore vault", "path", v.path, "err", err)
+		return
+	}
+	v.modTime, v.size = modTime, size
+	v.index(vault)
+}
+
+// stat returns the modification time and size of the vault file, or zero values
+// if the vault doesn't exist.
+func (v *keyStoreVault) stat() (time.Time, int64) {
+	stat, err := os.Stat(v.path)
+	if err != nil {
+		return time.Time{}, 0
+	}
+	return stat.ModTime(), stat.Size()
+}
+
+// index rebuilds the account index from the vault contents and notifies the
+// listeners of the change.
+//
+// The mccmod assumes that the vault lock is held!
+func (v *keyStoreVault) index(vault *vaultJSON) {
+	var key struct {
+		Address string `json:"address"`
+	}
+	all := make(accountsByURL, 0, len(vault.Keys))
+	for name, keyjson := range vault.Keys {
+		key.Address = ""
+		err := json.Unmarshal(keyjson, &key)
+		addr := common.HexToAddress(key.Address)
+		switch {
+		case err != nil:
+			log.Debug("Failed to decode vault key", "name", name, "err", err)
+		case (addr == common.Address{}):
+			log.Debug("Failed to decode vault key", "name", name, "err", "missing or zero address")
+		default:
+			all = append(all, accounts.Account{
+				Address: addr,
+				URL:     accounts.URL{Scheme: KeyStoreScheme, Path: filepath.Join(v.path, name)},
+			})
+		}
+	}
+	sort.Sort(all)
+
+	v.accountCache.mu.Lock()
+	v.all = all
+	v.byAddr = make(map[common.Address][]accounts.Account)
+	for _, a := range all {
+		v.byAddr[a.Address] = append(v.byAddr[a.Address], a)
+	}
+	select {
+	case v.notify <- struct{}{}:
+	default:
+	}
+	v.accountCache.mu.Unlock()
+}
+
+func (v *keyStoreVault) accounts() []accounts.Account {
+	v.reload()
+	v.accountCache.mu.Lock()
+	defer v.accountCache.mu.Unlock()
+	cpy := make([]accounts.Account, len(v.all))
+	copy(cpy, v.all)
+	return cpy
+}
+
+func (v *keyStoreVault) hasAddress(addr common.Address) bool {
+	v.reload()
+	v.accountCache.mu.Lock()
+	defer v.accountCache.mu.Unlock()
+	return len(v.byAddr[addr]) > 0
+}
+
+func (v *keyStoreVault) findAccount(a accounts.Account) (accounts.Account, error) {
+	v.reload()
+	v.accountCache.mu.Lock()
+	defer v.accountCache.mu.Unlock()
+	return v.find(a)
+}
+
+func (v *keyStoreVault) close() {
+	v.accountCache.mu.Lock()
+	defer v.accountCache.mu.Unlock()
+	if v.notify != nil {
+		close(v.notify)
+		v.notify = nil
+	}
+}
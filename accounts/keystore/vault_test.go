@@ -0,0 +1,148 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package keystore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Tests that the vault stores all keys in a single file, and that changes made
+// to it through one keystore are picked up by others sharing the same vault.
+func TestVaultSharedFile(t *testing.T) {
+	dir, ks1 := tmpVaultKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keys.vault")
+	ks2 := NewVaultKeyStore(path, veryLightScryptN, veryLightScryptP)
+
+	a1, err := ks1.NewAccount("foo")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	a2, err := ks1.NewAccount("bar")
+	if err != nil {
+		t.Fatalf("failed to create account: %v", err)
+	}
+	// Make sure the vault contains both keys and nothing else is in the directory
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to list keystore directory: %v", err)
+	}
+	if len(files) != 1 || files[0].Name() != "keys.vault" {
+		t.Fatalf("keystore directory contents mismatch: have %v, want [keys.vault]", files)
+	}
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read vault: %v", err)
+	}
+	var vault vaultJSON
+	if err := json.Unmarshal(blob, &vault); err != nil {
+		t.Fatalf("failed to decode vault: %v", err)
+	}
+	if vault.Version != vaultVersion || len(vault.Keys) != 2 {
+		t.Fatalf("vault mismatch: have version %d with %d keys, want version %d with 2 keys", vault.Version, len(vault.Keys), vaultVersion)
+	}
+	// Ensure the other keystore sees and can use the new accounts
+	if !ks2.HasAddress(a1.Address) || !ks2.HasAddress(a2.Address) {
+		t.Fatalf("shared vault accounts missing: have %v", ks2.Accounts())
+	}
+	if _, err := ks2.SignHashWithPassphrase(a2, "bar", testSigData); err != nil {
+		t.Fatalf("failed to sign with shared vault account: %v", err)
+	}
+	// Delete an account via the second keystore and ensure the first notices. The
+	// sleep ensures the vault modification time changes on coarse filesystems.
+	time.Sleep(10 * time.Millisecond)
+	if err := ks2.Delete(a1, "foo"); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	if ks1.HasAddress(a1.Address) {
+		t.Fatalf("deleted account still present in shared vault")
+	}
+}
+
+// Tests that vault keys can't be accessed via paths outside the vault.
+func TestVaultKeyPath(t *testing.T) {
+	dir, ks := tmpVaultKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	vault := ks.storage.(*keyStoreVault)
+	key, a, err := storeNewKey(vault, rand.Reader, "foo")
+	if err != nil {
+		t.Fatalf("failed to store key: %v", err)
+	}
+	if _, err := vault.GetKey(key.Address, a.URL.Path, "foo"); err != nil {
+		t.Fatalf("failed to retrieve key: %v", err)
+	}
+	outside := filepath.Join(dir, filepath.Base(a.URL.Path))
+	if _, err := vault.GetKey(key.Address, outside, "foo"); err == nil {
+		t.Fatalf("retrieved vault key via path outside the vault")
+	}
+	if err := vault.DeleteKey(outside); err == nil {
+		t.Fatalf("deleted vault key via path outside the vault")
+	}
+	if err := vault.DeleteKey(a.URL.Path); err != nil {
+		t.Fatalf("failed to delete key: %v", err)
+	}
+	if err := vault.DeleteKey(a.URL.Path); err != errVaultKeyNotFound {
+		t.Fatalf("deleting missing key error mismatch: have %v, want %v", err, errVaultKeyNotFound)
+	}
+}
+
+// Tests that the account index follows the modifications made through the vault
+// even if they leave the vault file's size and modification time unchanged.
+func TestVaultIndexSameSizeRewrite(t *testing.T) {
+	dir, ks := tmpVaultKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	vault := ks.storage.(*keyStoreVault)
+	_, a, err := storeNewKey(vault, rand.Reader, "foo")
+	if err != nil {
+		t.Fatalf("failed to store key: %v", err)
+	}
+	if accs := vault.accounts(); len(accs) != 1 || accs[0].Address != a.Address {
+		t.Fatalf("account index mismatch: have %v, want %x", accs, a.Address)
+	}
+	stat, err := os.Stat(vault.path)
+	if err != nil {
+		t.Fatalf("failed to stat vault: %v", err)
+	}
+	// Replace the key with a same sized one, resetting the modification time to
+	// emulate a rewrite within the timestamp granularity of the filesystem
+	key, err := newKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if err := vault.StoreKey(a.URL.Path, key, "foo"); err != nil {
+		t.Fatalf("failed to replace key: %v", err)
+	}
+	if err := os.Chtimes(vault.path, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("failed to reset vault modification time: %v", err)
+	}
+	if restat, _ := os.Stat(vault.path); restat.Size() != stat.Size() {
+		t.Fatalf("vault size changed: have %d, want %d", restat.Size(), stat.Size())
+	}
+	accs := vault.accounts()
+	if len(accs) != 1 || accs[0].Address != key.Address {
+		t.Fatalf("account index mismatch: have %v, want %x", accs, key.Address)
+	}
+}
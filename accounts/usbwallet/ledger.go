@@ -48,13 +48,18 @@ type ledgerParam1 byte
 type ledgerParam2 byte
 
 const (
-	ledgerOpRetrieveAddress  ledgerOpcode = 0x02 // Returns the public key and Ccmchain address for a given BIP 32 path
-	ledgerOpSignTransaction  ledgerOpcode = 0x04 // Signs an Ccmchain transaction after having the user validate the parameters
-	ledgerOpGetConfiguration ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpRetrieveAddress     ledgerOpcode = 0x02 // Returns the public key and Ccmchain address for a given BIP 32 path
+	ledgerOpSignTransaction     ledgerOpcode = 0x04 // Signs an Ccmchain transaction after having the user validate the parameters
+	ledgerOpGetConfiguration    ledgerOpcode = 0x06 // Returns specific wallet application configuration
+	ledgerOpSignPersonalMessage ledgerOpcode = 0x08 // Signs a personal message after having the user validate it
+	ledgerOpSignTypedMessage    ledgerOpcode = 0x0c // Signs an EIP-712 message given its domain and message hashes
 
 	ledgerP1DirectlyFetchAddress    ledgerParam1 = 0x00 // Return address directly from the wallet
 	ledgerP1InitTransactionData     ledgerParam1 = 0x00 // First transaction data block for signing
 	ledgerP1ContTransactionData     ledgerParam1 = 0x80 // Subsequent transaction data block for signing
+	ledgerP1InitMessageData         ledgerParam1 = 0x00 // First personal message data block for signing
+	ledgerP1ContMessageData         ledgerParam1 = 0x80 // Subsequent personal message data block for signing
+	ledgerP1SignTypedMessageHashes  ledgerParam1 = 0x00 // Typed message is signed based on its precomputed hashes
 	ledgerP2DiscardAddressChainCode ledgerParam2 = 0x00 // Do not return the chain code along with the address
 )
 
@@ -67,6 +72,11 @@ var errLedgerReplyInvalidHeader = errors.New("ledger: invalid reply header")
 // when a response does arrive, but it does not contain the expected data.
 var errLedgerInvalidVersionReply = errors.New("ledger: invalid version reply")
 
+// ledgerTextPrefix is the prefix the Ledger Ethereum app hashes personal messages
+// with (INS 0x08). It differs from the Ccmchain scheme used by accounts.TextHash,
+// so the device can't sign messages prefixed that way.
+const ledgerTextPrefix = "\x19Ethereum Signed Message:\n"
+
 // ledgerDriver implements the communication with a Ledger hardware wallet.
 type ledgerDriver struct {
 	device  io.ReadWriter // USB device connection to communicate through
@@ -161,13 +171,61 @@ func (w *ledgerDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 		return common.Address{}, nil, accounts.ErrWalletClosed
 	}
 	// Ensure the wallet is capable of signing the given transaction
-	if chainID != nil && w.version[0] <= 1 && w.version[1] <= 0 && w.version[2] <= 2 {
+	if chainID != nil && !w.versionAtLeast(1, 0, 3) {
 		return common.Address{}, nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing this transaction, please update to v1.0.3 at least", w.version[0], w.version[1], w.version[2])
 	}
 	// All infos gathered and metadata checks out, request signing
 	return w.ledgerSign(path, tx, chainID)
 }
 
+// SignText implements usbwallet.driver, sending the message to the Ledger and
+// waiting for the user to confirm or deny signing it as a personal message.
+func (w *ledgerDriver) SignText(path accounts.DerivationPath, text []byte) ([]byte, error) {
+	// If the Ccmchain app doesn't run, abort
+	if w.offline() {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is capable of signing personal messages
+	if !w.versionAtLeast(1, 0, 8) {
+		return nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support signing personal messages, please update to v1.0.8 at least", w.version[0], w.version[1], w.version[2])
+	}
+	// All infos gathered and metadata checks out, request signing
+	return w.ledgerSignPersonalMessage(path, text)
+}
+
+// TextPrefix implements usbwallet.driver, returning the prefix the Ledger Ethereum
+// app hashes personal messages with.
+func (w *ledgerDriver) TextPrefix() string {
+	return ledgerTextPrefix
+}
+
+// SignTypedMessage implements usbwallet.driver, sending the EIP-712 domain and
+// message hashes to the Ledger and waiting for the user to confirm or deny them.
+func (w *ledgerDriver) SignTypedMessage(path accounts.DerivationPath, domainHash []byte, messageHash []byte) ([]byte, error) {
+	// If the Ccmchain app doesn't run, abort
+	if w.offline() {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Ensure the wallet is capable of signing typed messages
+	if !w.versionAtLeast(1, 5, 0) {
+		return nil, fmt.Errorf("Ledger v%d.%d.%d doesn't support EIP-712 signing, please update to v1.5.0 at least", w.version[0], w.version[1], w.version[2])
+	}
+	// All infos gathered and metadata checks out, request signing
+	return w.ledgerSignTypedMessage(path, domainHash, messageHash)
+}
+
+// versionAtLeast returns whccmer the Ccmchain app running on the Ledger is of
+// the given version or newer.
+func (w *ledgerDriver) versionAtLeast(major, minor, patch byte) bool {
+	if w.version[0] != major {
+		return w.version[0] > major
+	}
+	if w.version[1] != minor {
+		return w.version[1] > minor
+	}
+	return w.version[2] >= patch
+}
+
 // ledgerVersion retrieves the current version of the Ccmchain wallet app running
 // on the Ledger wallet.
 //
@@ -365,6 +423,137 @@ func (w *ledgerDriver) ledgerSign(derivationPath []uint32, tx *types.Transaction
 	return sender, signed, nil
 }
 
+// ledgerSignPersonalMessage sends the message to the Ledger wallet, and waits for
+// the user to confirm or deny signing it. The wallet prefixes the message itself
+// with ledgerTextPrefix and its decimal length before hashing and signing it.
+//
+// The personal message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 08  | 00: first message data block
+//               80: subsequent message data block
+//                  | 00 | variable | variable
+//
+// Where the input for the first message block (first 255 bytes) is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Message length (big endian)                      | 4 bytes
+//   Message chunk                                    | arbitrary
+//
+// And the input for subsequent message blocks (first 255 bytes) are:
+//
+//   Description   | Length
+//   --------------+----------
+//   Message chunk | arbitrary
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignPersonalMessage(derivationPath []uint32, text []byte) ([]byte, error) {
+	// Flatten the derivation path and the message length into the Ledger request
+	path := make([]byte, 1+4*len(derivationPath)+4)
+	path[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(path[1+4*i:], component)
+	}
+	binary.BigEndian.PutUint32(path[1+4*len(derivationPath):], uint32(len(text)))
+	payload := append(path, text...)
+
+	// Send the request and wait for the response
+	var (
+		op    = ledgerP1InitMessageData
+		reply []byte
+		err   error
+	)
+	for len(payload) > 0 {
+		// Calculate the size of the next data chunk
+		chunk := 255
+		if chunk > len(payload) {
+			chunk = len(payload)
+		}
+		// Send the chunk over, ensuring it's processed correctly
+		reply, err = w.ledgerExchange(ledgerOpSignPersonalMessage, op, 0, payload[:chunk])
+		if err != nil {
+			return nil, err
+		}
+		// Shift the payload and ensure subsequent chunks are marked as such
+		payload = payload[chunk:]
+		op = ledgerP1ContMessageData
+	}
+	return ledgerSignature(reply)
+}
+
+// ledgerSignTypedMessage sends the EIP-712 domain and message hashes to the Ledger
+// wallet, and waits for the user to confirm or deny signing them.
+//
+// The typed message signing protocol is defined as follows:
+//
+//   CLA | INS | P1 | P2 | Lc  | Le
+//   ----+-----+----+----+-----+---
+//    E0 | 0C  | 00 | 00 | variable | variable
+//
+// Where the input is:
+//
+//   Description                                      | Length
+//   -------------------------------------------------+----------
+//   Number of BIP 32 derivations to perform (max 10) | 1 byte
+//   First derivation index (big endian)              | 4 bytes
+//   ...                                              | 4 bytes
+//   Last derivation index (big endian)               | 4 bytes
+//   Domain separator hash                            | 32 bytes
+//   Message hash                                     | 32 bytes
+//
+// And the output data is:
+//
+//   Description | Length
+//   ------------+---------
+//   signature V | 1 byte
+//   signature R | 32 bytes
+//   signature S | 32 bytes
+func (w *ledgerDriver) ledgerSignTypedMessage(derivationPath []uint32, domainHash []byte, messageHash []byte) ([]byte, error) {
+	if len(domainHash) != 32 || len(messageHash) != 32 {
+		return nil, errors.New("invalid typed message hashes")
+	}
+	// Flatten the derivation path and the hashes into the Ledger request
+	payload := make([]byte, 1+4*len(derivationPath), 1+4*len(derivationPath)+64)
+	payload[0] = byte(len(derivationPath))
+	for i, component := range derivationPath {
+		binary.BigEndian.PutUint32(payload[1+4*i:], component)
+	}
+	payload = append(payload, domainHash...)
+	payload = append(payload, messageHash...)
+
+	// Send the request and wait for the response
+	reply, err := w.ledgerExchange(ledgerOpSignTypedMessage, ledgerP1SignTypedMessageHashes, 0, payload)
+	if err != nil {
+		return nil, err
+	}
+	return ledgerSignature(reply)
+}
+
+// ledgerSignature converts a [V || R || S] signature replied by the Ledger into
+// the [R || S || V] format used by Ccmchain, with V being 0 or 1.
+func ledgerSignature(reply []byte) ([]byte, error) {
+	if len(reply) != 65 {
+		return nil, errors.New("reply lacks signature")
+	}
+	signature := append(reply[1:], reply[0])
+	if signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	return signature, nil
+}
+
 // ledgerExchange performs a data exchange with the Ledger wallet, sending it a
 // message and retrieving the response.
 //
@@ -0,0 +1,358 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package usbwallet
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/accounts"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/log"
+)
+
+// ledgerAPDU is a single command received by the fake Ledger.
+type ledgerAPDU struct {
+	ins  ledgerOpcode
+	p1   ledgerParam1
+	p2   ledgerParam2
+	data []byte
+}
+
+// fakeLedger is a USB transport emulating a Ledger running the Ccmchain app. It
+// reassembles the HID reports written into APDUs, answers them and streams the
+// replies back as HID reports.
+type fakeLedger struct {
+	key     *ecdsa.PrivateKey
+	version [3]byte
+
+	reports [][]byte     // HID reports written by the driver
+	apdus   []ledgerAPDU // APDUs reassembled from the reports
+	pending []byte       // Partially received APDU
+	size    int          // Total size of the partially received APDU
+	replies []byte       // HID reports queued for reading
+
+	message []byte // Personal message being streamed
+	total   int    // Total length of the personal message
+}
+
+func newFakeLedger(t *testing.T) *fakeLedger {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return &fakeLedger{key: key, version: [3]byte{1, 5, 0}}
+}
+
+func (l *fakeLedger) Close() error { return nil }
+
+func (l *fakeLedger) Write(b []byte) (int, error) {
+	if len(b) > 64 {
+		return 0, fmt.Errorf("oversized HID report: %d bytes", len(b))
+	}
+	if len(b) < 5 || b[0] != 0x01 || b[1] != 0x01 || b[2] != 0x05 {
+		return 0, errors.New("invalid HID report header")
+	}
+	l.reports = append(l.reports, append([]byte{}, b...))
+
+	// Reassemble the APDU, verifying the sequence numbers
+	seq := int(binary.BigEndian.Uint16(b[3:5]))
+	if seq == 0 {
+		l.size = int(binary.BigEndian.Uint16(b[5:7]))
+		l.pending = append([]byte{}, b[7:]...)
+	} else {
+		if want := (len(l.pending) + 2) / 59; seq != want {
+			return 0, fmt.Errorf("sequence mismatch: have %d, want %d", seq, want)
+		}
+		l.pending = append(l.pending, b[5:]...)
+	}
+	if len(l.pending) < l.size {
+		return len(b), nil
+	}
+	apdu := l.pending[:l.size]
+	if apdu[0] != 0xe0 || int(apdu[4]) != len(apdu)-5 {
+		return 0, errors.New("invalid APDU header")
+	}
+	cmd := ledgerAPDU{ins: ledgerOpcode(apdu[1]), p1: ledgerParam1(apdu[2]), p2: ledgerParam2(apdu[3]), data: apdu[5:]}
+	l.apdus = append(l.apdus, cmd)
+
+	reply, err := l.handle(cmd)
+	if err != nil {
+		return 0, err
+	}
+	l.queue(append(reply, 0x90, 0x00))
+	return len(b), nil
+}
+
+func (l *fakeLedger) Read(b []byte) (int, error) {
+	if len(l.replies) == 0 {
+		return 0, errors.New("no reply queued")
+	}
+	n := copy(b, l.replies)
+	l.replies = l.replies[n:]
+	return n, nil
+}
+
+// queue splits a reply into 64 byte HID reports.
+func (l *fakeLedger) queue(reply []byte) {
+	payload := make([]byte, 2, 2+len(reply))
+	binary.BigEndian.PutUint16(payload, uint16(len(reply)))
+	payload = append(payload, reply...)
+
+	for seq := 0; len(payload) > 0; seq++ {
+		report := make([]byte, 64)
+		copy(report, []byte{0x01, 0x01, 0x05})
+		binary.BigEndian.PutUint16(report[3:], uint16(seq))
+		payload = payload[copy(report[5:], payload):]
+		l.replies = append(l.replies, report...)
+	}
+}
+
+// handle executes a single APDU, returning the reply data.
+func (l *fakeLedger) handle(cmd ledgerAPDU) ([]byte, error) {
+	switch cmd.ins {
+	case ledgerOpGetConfiguration:
+		return append([]byte{0x01}, l.version[:]...), nil
+
+	case ledgerOpSignPersonalMessage:
+		data := cmd.data
+		if cmd.p1 == ledgerP1InitMessageData {
+			rest, err := skipPath(data)
+			if err != nil {
+				return nil, err
+			}
+			l.total = int(binary.BigEndian.Uint32(rest))
+			l.message, data = nil, rest[4:]
+		} else if cmd.p1 != ledgerP1ContMessageData {
+			return nil, fmt.Errorf("invalid P1: %#x", cmd.p1)
+		}
+		l.message = append(l.message, data...)
+		if len(l.message) < l.total {
+			return nil, nil
+		}
+		// The Ledger Ethereum app hashes with the Ethereum prefix, not the Ccmchain one
+		prefixed := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(l.message), l.message)
+		return l.sign(crypto.Keccak256([]byte(prefixed)))
+
+	case ledgerOpSignTypedMessage:
+		rest, err := skipPath(cmd.data)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) != 64 {
+			return nil, fmt.Errorf("invalid typed message hashes length: %d", len(rest))
+		}
+		return l.sign(crypto.Keccak256([]byte{0x19, 0x01}, rest))
+
+	case 0xff:
+		// Test opcode echoing the request
+		return cmd.data, nil
+	}
+	return nil, fmt.Errorf("unknown opcode: %#x", cmd.ins)
+}
+
+// sign signs the hash, returning the signature in the Ledger's [V || R || S] format.
+func (l *fakeLedger) sign(hash []byte) ([]byte, error) {
+	sig, err := crypto.Sign(hash, l.key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{sig[64] + 27}, sig[:64]...), nil
+}
+
+// skipPath validates the derivation path prefix of a request and returns the
+// data following it.
+func skipPath(data []byte) ([]byte, error) {
+	if len(data) < 1 || len(data) < 1+4*int(data[0]) {
+		return nil, errors.New("invalid derivation path")
+	}
+	if int(data[0]) != len(accounts.DefaultBaseDerivationPath) {
+		return nil, fmt.Errorf("derivation path length mismatch: %d", data[0])
+	}
+	return data[1+4*int(data[0]):], nil
+}
+
+// newTestLedgerWallet creates an opened wallet backed by a fake Ledger, which has
+// the fake device's account pinned.
+func newTestLedgerWallet(t *testing.T) (*wallet, *fakeLedger, accounts.Account) {
+	device := newFakeLedger(t)
+	driver := &ledgerDriver{device: device, version: device.version, log: log.Root()}
+
+	account := accounts.Account{Address: crypto.PubkeyToAddress(device.key.PublicKey)}
+	w := &wallet{
+		hub:       new(Hub),
+		driver:    driver,
+		device:    device,
+		paths:     map[common.Address]accounts.DerivationPath{account.Address: accounts.DefaultBaseDerivationPath},
+		commsLock: make(chan struct{}, 1),
+		log:       log.Root(),
+	}
+	w.commsLock <- struct{}{}
+	return w, device, account
+}
+
+// Tests that the Ledger transport splits long APDUs and replies correctly into HID
+// reports.
+func TestLedgerExchangeFraming(t *testing.T) {
+	device := newFakeLedger(t)
+	driver := &ledgerDriver{device: device, log: log.Root()}
+
+	data := bytes.Repeat([]byte{0xaa, 0xbb, 0xcc}, 85)
+	reply, err := driver.ledgerExchange(0xff, 0x12, 0x34, data)
+	if err != nil {
+		t.Fatalf("exchange failed: %v", err)
+	}
+	if !bytes.Equal(reply, data) {
+		t.Errorf("reply mismatch: have %x, want %x", reply, data)
+	}
+	// The 2 byte length and 260 byte APDU need 5 reports of 59 byte payloads
+	if len(device.reports) != 5 {
+		t.Errorf("report count mismatch: have %d, want 5", len(device.reports))
+	}
+	if len(device.apdus) != 1 {
+		t.Fatalf("APDU count mismatch: have %d, want 1", len(device.apdus))
+	}
+	if cmd := device.apdus[0]; cmd.p1 != 0x12 || cmd.p2 != 0x34 || !bytes.Equal(cmd.data, data) {
+		t.Errorf("APDU mismatch: have %+v", cmd)
+	}
+}
+
+// Tests that long personal messages are streamed to the Ledger in multiple APDUs
+// and the returned signature is converted to the Ccmchain format.
+func TestLedgerSignText(t *testing.T) {
+	w, device, account := newTestLedgerWallet(t)
+
+	text := []byte(strings.Repeat("Ccmchain on a Ledger. ", 30))
+	data := []byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(text), text))
+
+	sig, err := w.SignData(account, accounts.MimetypeTextPlain, data)
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	if len(sig) != 65 || sig[64] > 1 {
+		t.Fatalf("invalid signature: %x", sig)
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != account.Address {
+		t.Fatalf("signature doesn't recover to the account: %v", err)
+	}
+	// The 21 byte path, 4 byte length and 660 byte message need 3 chunks
+	if len(device.apdus) != 3 {
+		t.Fatalf("APDU count mismatch: have %d, want 3", len(device.apdus))
+	}
+	for i, cmd := range device.apdus {
+		want := ledgerP1ContMessageData
+		if i == 0 {
+			want = ledgerP1InitMessageData
+		}
+		if cmd.ins != ledgerOpSignPersonalMessage || cmd.p1 != want {
+			t.Errorf("APDU %d: header mismatch: have ins %#x p1 %#x, want ins %#x p1 %#x", i, cmd.ins, cmd.p1, ledgerOpSignPersonalMessage, want)
+		}
+		if i < 2 && len(cmd.data) != 255 {
+			t.Errorf("APDU %d: chunk size mismatch: have %d, want 255", i, len(cmd.data))
+		}
+	}
+	if !bytes.Equal(device.message, text) {
+		t.Errorf("streamed message mismatch")
+	}
+	// The device can't sign with the Ccmchain prefix, so it must not be asked to
+	if _, err := w.SignText(account, text); !isTextPrefixError(err, ledgerTextPrefix) {
+		t.Errorf("text signing error mismatch: have %v, want prefix error", err)
+	}
+	_, msg := accounts.TextAndHash(text)
+	if _, err := w.SignData(account, accounts.MimetypeTextPlain, []byte(msg)); !isTextPrefixError(err, ledgerTextPrefix) {
+		t.Errorf("text data signing error mismatch: have %v, want prefix error", err)
+	}
+	if len(device.apdus) != 3 {
+		t.Errorf("unsupported messages sent to the device: %d APDUs", len(device.apdus)-3)
+	}
+}
+
+// Tests that signing personal messages on Trezor wallets, which can't sign them,
+// fails without contacting the device.
+func TestTrezorSignText(t *testing.T) {
+	w := &wallet{driver: new(trezorDriver)}
+
+	text := []byte("hello")
+	if _, err := w.SignText(accounts.Account{}, text); err != errTextUnsupported {
+		t.Errorf("text signing error mismatch: have %v, want %v", err, errTextUnsupported)
+	}
+	_, msg := accounts.TextAndHash(text)
+	if _, err := w.SignData(accounts.Account{}, accounts.MimetypeTextPlain, []byte(msg)); err != errTextUnsupported {
+		t.Errorf("text data signing error mismatch: have %v, want %v", err, errTextUnsupported)
+	}
+}
+
+// isTextPrefixError reports whccmer err rejects a personal message because the
+// device signs with the given prefix.
+func isTextPrefixError(err error, prefix string) bool {
+	perr, ok := err.(*textPrefixError)
+	return ok && perr.prefix == prefix
+}
+
+// Tests that EIP-712 typed data is signed by sending the domain and message hashes.
+func TestLedgerSignTypedData(t *testing.T) {
+	w, device, account := newTestLedgerWallet(t)
+
+	domain := crypto.Keccak256([]byte("domain"))
+	message := crypto.Keccak256([]byte("message"))
+	data := append(append([]byte{0x19, 0x01}, domain...), message...)
+
+	sig, err := w.SignData(account, accounts.MimetypeTypedData, data)
+	if err != nil {
+		t.Fatalf("failed to sign typed data: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if err != nil || crypto.PubkeyToAddress(*pubkey) != account.Address {
+		t.Fatalf("signature doesn't recover to the account: %v", err)
+	}
+	if len(device.apdus) != 1 {
+		t.Fatalf("APDU count mismatch: have %d, want 1", len(device.apdus))
+	}
+	if cmd := device.apdus[0]; cmd.ins != ledgerOpSignTypedMessage || !bytes.HasSuffix(cmd.data, data[2:]) {
+		t.Errorf("APDU mismatch: have %+v", cmd)
+	}
+	// Old firmware and unsupported formats must be rejected
+	w.driver.(*ledgerDriver).version = [3]byte{1, 4, 9}
+	if _, err := w.SignData(account, accounts.MimetypeTypedData, data); err == nil {
+		t.Errorf("typed data signed by outdated firmware")
+	}
+	if _, err := w.SignData(account, accounts.MimetypeClique, data); err != accounts.ErrNotSupported {
+		t.Errorf("unsupported data error mismatch: have %v, want %v", err, accounts.ErrNotSupported)
+	}
+}
+
+func TestUnprefixText(t *testing.T) {
+	for _, text := range []string{"", "hello", "12 apples", "0", strings.Repeat("9", 120)} {
+		_, msg := accounts.TextAndHash([]byte(text))
+		have, ok := unprefixText(textPrefix, []byte(msg))
+		if !ok || string(have) != text {
+			t.Errorf("text %q: have %q, %v", text, have, ok)
+		}
+	}
+	for _, data := range []string{"hello", "\x19Ccmchain Signed Message:\n", "\x19Ccmchain Signed Message:\n4abc", "\x19Ethereum Signed Message:\n5hello"} {
+		if _, ok := unprefixText(textPrefix, []byte(data)); ok {
+			t.Errorf("data %q: unexpectedly unprefixed", data)
+		}
+	}
+}
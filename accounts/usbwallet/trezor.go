@@ -185,6 +185,24 @@ func (w *trezorDriver) SignTx(path accounts.DerivationPath, tx *types.Transactio
 	return w.trezorSign(path, tx, chainID)
 }
 
+// SignText implements usbwallet.driver, however signing personal messages is
+// not yet supported for Trezor wallets.
+func (w *trezorDriver) SignText(path accounts.DerivationPath, text []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
+// TextPrefix implements usbwallet.driver, however signing personal messages is
+// not yet supported for Trezor wallets, so there's no prefix.
+func (w *trezorDriver) TextPrefix() string {
+	return ""
+}
+
+// SignTypedMessage implements usbwallet.driver, however EIP-712 signing is not
+// supported for Trezor wallets.
+func (w *trezorDriver) SignTypedMessage(path accounts.DerivationPath, domainHash []byte, messageHash []byte) ([]byte, error) {
+	return nil, accounts.ErrNotSupported
+}
+
 // trezorDerive sends a derivation request to the Trezor device and returns the
 // Ccmchain address located on that path.
 func (w *trezorDriver) trezorDerive(derivationPath []uint32) (common.Address, error) {
@@ -18,10 +18,13 @@
 package usbwallet
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"strconv"
 	"sync"
 	"time"
 
@@ -37,6 +40,24 @@ import (
 // Maximum time between wallet health checks to detect USB unplugs.
 const heartbeatCycle = time.Second
 
+// textPrefix is the personal message prefix used by accounts.TextHash.
+const textPrefix = "\x19Ccmchain Signed Message:\n"
+
+// errTextUnsupported is returned when signing a personal message on a device that
+// can't sign personal messages at all.
+var errTextUnsupported = errors.New("usbwallet: personal messages not supported by the device")
+
+// textPrefixError is returned when signing a personal message on a device hashing
+// it with a different prefix than accounts.TextHash, as the signature wouldn't
+// verify against the hash the caller expects.
+type textPrefixError struct {
+	prefix string // Prefix the device hashes personal messages with
+}
+
+func (e *textPrefixError) Error() string {
+	return fmt.Sprintf("usbwallet: device signs personal messages with prefix %q instead of %q, sign text/plain data carrying the device prefix instead", e.prefix, textPrefix)
+}
+
 // Minimum time to wait between self derivation attempts, even it the user is
 // requesting accounts like crazy.
 const selfDeriveThrottling = time.Second
@@ -67,6 +88,20 @@ type driver interface {
 	// SignTx sends the transaction to the USB device and waits for the user to confirm
 	// or deny the transaction.
 	SignTx(path accounts.DerivationPath, tx *types.Transaction, chainID *big.Int) (common.Address, *types.Transaction, error)
+
+	// SignText sends the message to the USB device and waits for the user to confirm
+	// or deny signing it as a personal message. The device prefixes the message
+	// itself, using the prefix returned by TextPrefix.
+	SignText(path accounts.DerivationPath, text []byte) ([]byte, error)
+
+	// TextPrefix returns the prefix the USB device hashes personal messages with,
+	// followed by the decimal message length and the message. An empty prefix means
+	// personal messages are not supported.
+	TextPrefix() string
+
+	// SignTypedMessage sends the EIP-712 domain separator and message hashes to the
+	// USB device and waits for the user to confirm or deny signing them.
+	SignTypedMessage(path accounts.DerivationPath, domainHash []byte, messageHash []byte) ([]byte, error)
 }
 
 // wallet represents the common functionality shared by all USB hardware
@@ -518,8 +553,29 @@ func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error)
 	return nil, accounts.ErrNotSupported
 }
 
-// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed
+// SignData signs keccak256(data). The mimetype parameter describes the type of data
+// being signed. Hardware wallets can't sign arbitrary hashes, so only EIP-712 typed
+// data and personal messages are supported, which the device can display.
 func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	switch mimeType {
+	case accounts.MimetypeTypedData:
+		// Typed data is "\x19\x01" || domainSeparator || hashStruct(message)
+		if len(data) == 66 && data[0] == 0x19 && data[1] == 0x01 {
+			return w.signWithDevice(account, crypto.Keccak256(data), func(path accounts.DerivationPath) ([]byte, error) {
+				return w.driver.SignTypedMessage(path, data[2:34], data[34:66])
+			})
+		}
+	case accounts.MimetypeTextPlain:
+		// Personal messages arrive already prefixed, the device needs the original
+		prefix := w.driver.TextPrefix()
+		if text, ok := unprefixText(prefix, data); ok && prefix != "" {
+			return w.signText(account, prefix, text)
+		}
+		// Explain why messages prefixed for accounts.TextHash can't be signed
+		if _, ok := unprefixText(textPrefix, data); ok {
+			return nil, w.textError()
+		}
+	}
 	return w.signHash(account, crypto.Keccak256(data))
 }
 
@@ -530,8 +586,103 @@ func (w *wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mi
 	return w.SignData(account, mimeType, data)
 }
 
+// SignText implements accounts.Wallet, sending the message over to the hardware
+// wallet to request a confirmation from the user. It returns either the signature
+// or a failure if the user denied signing the message.
+//
+// Hardware wallets prefix the message themselves, so only devices hashing it as
+// accounts.TextHash does are supported, which currently none of them do:
+//   - The Ledger Ethereum app uses the Ethereum prefix instead, so its personal
+//     messages can only be signed through SignData, passing text/plain data that
+//     carries the Ethereum prefix. Signing the message otherwise (including data
+//     carrying the Ccmchain prefix, as sent by clef and ccm_sign) fails with an
+//     error naming the device prefix.
+//   - Trezor wallets can't sign personal messages at all.
 func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
-	return w.signHash(account, accounts.TextHash(text))
+	if w.driver.TextPrefix() != textPrefix {
+		return nil, w.textError()
+	}
+	return w.signText(account, textPrefix, text)
+}
+
+// textError returns the error explaining why the device can't sign a personal
+// message hashed as accounts.TextHash does.
+func (w *wallet) textError() error {
+	if prefix := w.driver.TextPrefix(); prefix != "" {
+		return &textPrefixError{prefix: prefix}
+	}
+	return errTextUnsupported
+}
+
+// signText requests the device to sign a personal message, verifying the result
+// against the message hashed with the device's prefix.
+func (w *wallet) signText(account accounts.Account, prefix string, text []byte) ([]byte, error) {
+	hash := crypto.Keccak256([]byte(prefix+strconv.Itoa(len(text))), text)
+	return w.signWithDevice(account, hash, func(path accounts.DerivationPath) ([]byte, error) {
+		return w.driver.SignText(path, text)
+	})
+}
+
+// signWithDevice requests a signature from the hardware wallet through the given
+// callback, verifying that it was made by the requested account over the given
+// hash to avoid hardware fault surprises.
+func (w *wallet) signWithDevice(account accounts.Account, hash []byte, sign func(path accounts.DerivationPath) ([]byte, error)) ([]byte, error) {
+	w.stateLock.RLock() // Comms have own mutex, this is for the state fields
+	defer w.stateLock.RUnlock()
+
+	// If the wallet is closed, abort
+	if w.device == nil {
+		return nil, accounts.ErrWalletClosed
+	}
+	// Make sure the requested account is contained within
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	// All infos gathered and metadata checks out, request signing
+	<-w.commsLock
+	defer func() { w.commsLock <- struct{}{} }()
+
+	// Ensure the device isn't screwed with while user confirmation is pending
+	// TODO(karalabe): remove if hotplug lands on Windows
+	w.hub.commsLock.Lock()
+	w.hub.commsPend++
+	w.hub.commsLock.Unlock()
+
+	defer func() {
+		w.hub.commsLock.Lock()
+		w.hub.commsPend--
+		w.hub.commsLock.Unlock()
+	}()
+	signature, err := sign(path)
+	if err != nil {
+		return nil, err
+	}
+	pubkey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return nil, err
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != account.Address {
+		return nil, fmt.Errorf("signer mismatch: expected %s, got %s", account.Address.Hex(), signer.Hex())
+	}
+	return signature, nil
+}
+
+// unprefixText extracts the original message from a personal message prefixed
+// with the given prefix and the message length, as done by accounts.TextAndHash.
+func unprefixText(prefix string, data []byte) ([]byte, bool) {
+	if prefix == "" || !bytes.HasPrefix(data, []byte(prefix)) {
+		return nil, false
+	}
+	rest := data[len(prefix):]
+
+	// The message length is not delimited, try all digit prefixes
+	for i := 1; i <= len(rest) && rest[i-1] >= '0' && rest[i-1] <= '9'; i++ {
+		if text := rest[i:]; strconv.Itoa(len(text)) == string(rest[:i]) {
+			return text, true
+		}
+	}
+	return nil, false
 }
 
 // SignTx implements accounts.Wallet. It sends the transaction over to the Ledger
@@ -580,11 +731,11 @@ func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID
 	return signed, nil
 }
 
-// SignHashWithPassphrase implements accounts.Wallet, however signing arbitrary
-// data is not supported for Ledger wallets, so this mccmod will always return
-// an error.
+// SignTextWithPassphrase implements accounts.Wallet, attempting to sign the given
+// text with the given account using passphrase as extra authentication.
+// Since USB wallets don't rely on passphrases, these are silently ignored.
 func (w *wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
-	return w.SignText(account, accounts.TextHash(text))
+	return w.SignText(account, text)
 }
 
 // SignTxWithPassphrase implements accounts.Wallet, attempting to sign the given
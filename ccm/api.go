@@ -288,6 +288,9 @@ func (api *PublicDebugAPI) DumpBlock(blockNr rpc.BlockNumber) (state.Dump, error
 // the private debugging endpoint.
 type PrivateDebugAPI struct {
 	ccm *Ccmchain
+
+	// Testing hooks
+	traceDispatchHook func(blocks int, memory uint64, state uint64) // Method to call upon dispatching a block for chain tracing
 }
 
 // NewPrivateDebugAPI creates a new API definition for the full node-related
@@ -20,12 +20,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ccmchain/go-ccmchain/common"
@@ -62,6 +64,14 @@ type TraceConfig struct {
 	Reexec  *uint64
 }
 
+// TraceChainConfig holds extra parameters to the chain trace function.
+type TraceChainConfig struct {
+	*TraceConfig
+	Threads *int            // Number of blocks to trace concurrently (defaults to the CPU count)
+	Memory  *uint64         // Memory allowance (bytes) of the in-flight blocks, their states and traces (defaults to unlimited)
+	Resume  *hexutil.Uint64 // Last block already received by the client, tracing continues after it
+}
+
 // StdTraceConfig holds extra parameters to standard-json trace functions.
 type StdTraceConfig struct {
 	*vm.LogConfig
@@ -82,6 +92,7 @@ type blockTraceTask struct {
 	block   *types.Block     // Block to trace the transactions from
 	rootref common.Hash      // Trie root reference held for this task
 	results []*txTraceResult // Trace results procudes by the task
+	memory  uint64           // Memory reserved by the task until its results are streamed
 }
 
 // blockTraceResult represets the results of tracing a single block when an entire
@@ -101,7 +112,13 @@ type txTraceTask struct {
 
 // TraceChain returns the structured logs created during the execution of EVM
 // between two blocks (excluding start) and returns them as a JSON object.
-func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceConfig) (*rpc.Subscription, error) {
+//
+// The blocks are traced concurrently, but streamed back in order, one result per
+// block. Memory usage is bounded by the number of blocks in flight and by the
+// optionally requested memory allowance. An interrupted trace can be resumed by
+// repeating the request with the last received block number set as the resume
+// point, in which case the state is regenerated without tracing up to it.
+func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.BlockNumber, config *TraceChainConfig) (*rpc.Subscription, error) {
 	// Fetch the block interval that we want to trace
 	var from, to *types.Block
 
@@ -137,16 +154,29 @@ func (api *PrivateDebugAPI) TraceChain(ctx context.Context, start, end rpc.Block
 // traceChain configures a new tracer according to the provided configuration, and
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requested tracer.
-func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Block, config *TraceConfig) (*rpc.Subscription, error) {
+func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Block, config *TraceChainConfig) (*rpc.Subscription, error) {
 	// Tracing a chain is a **long** operation, only do with subscriptions
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	var traceConfig *TraceConfig
+	if config != nil {
+		traceConfig = config.TraceConfig
+	}
+	// Blocks up to the origin are only used to regenerate state, skip any that the
+	// client already received if we're resuming an interrupted trace
+	origin := start.NumberU64()
+	if config != nil && config.Resume != nil {
+		resume := uint64(*config.Resume)
+		if resume < origin || resume >= end.NumberU64() {
+			return nil, fmt.Errorf("resume block #%d outside of traced range (#%d, #%d)", resume, origin, end.NumberU64())
+		}
+		origin = resume
+	}
 	sub := notifier.CreateSubscription()
 
 	// Ensure we have a valid starting state before doing any work
-	origin := start.NumberU64()
 	database := state.NewDatabaseWithCache(api.ccm.ChainDb(), 16) // Chain tracing will probably start at genesis
 
 	if number := start.NumberU64(); number > 0 {
@@ -159,8 +189,8 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 	if err != nil {
 		// If the starting state is missing, allow some number of blocks to be reexecuted
 		reexec := defaultTraceReexec
-		if config != nil && config.Reexec != nil {
-			reexec = *config.Reexec
+		if traceConfig != nil && traceConfig.Reexec != nil {
+			reexec = *traceConfig.Reexec
 		}
 		// Find the most recent block that has the state available
 		for i := uint64(0); i < reexec; i++ {
@@ -186,13 +216,28 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 	blocks := int(end.NumberU64() - origin)
 
 	threads := runtime.NumCPU()
+	if config != nil && config.Threads != nil && *config.Threads > 0 {
+		threads = *config.Threads
+	}
 	if threads > blocks {
 		threads = blocks
 	}
+	// Cap the memory used by the in-flight blocks: every block reserves its own size
+	// and the trie nodes pinned by its state copy when handed to the tracers, and
+	// the size of its traces as they are produced, releasing them only when its
+	// results are streamed to the user, so out of order results can't accumulate
+	// either.
+	allowance := uint64(0)
+	if config != nil && config.Memory != nil {
+		allowance = *config.Memory
+	}
 	var (
-		pend    = new(sync.WaitGroup)
-		tasks   = make(chan *blockTraceTask, threads)
-		results = make(chan *blockTraceTask, threads)
+		reserved uint64 // Memory reserved by the in-flight blocks (atomic)
+		pend     = new(sync.WaitGroup)
+		tasks    = make(chan *blockTraceTask, threads)
+		results  = make(chan *blockTraceTask, threads)
+		inflight = make(chan struct{}, 2*threads) // Blocks traced but not yet streamed to the user
+		streamed = make(chan struct{}, 1)         // Notification that an in-flight block was streamed
 	)
 	for th := 0; th < threads; th++ {
 		pend.Add(1)
@@ -208,16 +253,31 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 					msg, _ := tx.AsMessage(signer)
 					vmctx := core.NewEVMContext(msg, task.block.Header(), api.ccm.blockchain, nil)
 
-					res, err := api.traceTx(ctx, msg, vmctx, task.statedb, config)
+					// The subscription context is cancelled once the call returns, so
+					// trace with a fresh one instead, bounded by the tracer timeout
+					res, err := api.traceTx(context.Background(), msg, vmctx, task.statedb, traceConfig)
+					if err != nil {
+						task.results[i] = &txTraceResult{Error: err.Error()}
+						log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
+						break
+					}
+					// Keep the encoded trace only, so its memory can be accounted for
+					blob, err := json.Marshal(res)
 					if err != nil {
 						task.results[i] = &txTraceResult{Error: err.Error()}
 						log.Warn("Tracing failed", "hash", tx.Hash(), "block", task.block.NumberU64(), "err", err)
 						break
 					}
+					task.memory += uint64(len(blob))
+					atomic.AddUint64(&reserved, uint64(len(blob)))
+
 					// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
 					task.statedb.Finalise(api.ccm.blockchain.Config().IsEIP158(task.block.Number()))
-					task.results[i] = &txTraceResult{Result: res}
+					task.results[i] = &txTraceResult{Result: json.RawMessage(blob)}
 				}
+				// Release the state, only the results are needed from here on
+				task.statedb = nil
+
 				// Stream the result back to the user or abort on teardown
 				select {
 				case results <- task:
@@ -237,6 +297,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 			traced uint64
 			failed error
 			proot  common.Hash
+			pinned uint64 // Trie memory added by the last commit, pinned by the next state copy
 		)
 		// Ensure everything is properly cleaned up on any exit path
 		defer func() {
@@ -265,7 +326,7 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 			if time.Since(logged) > 8*time.Second {
 				if number > origin {
 					nodes, imgs := database.TrieDB().Size()
-					log.Info("Tracing chain segment", "start", origin, "end", end.NumberU64(), "current", number, "transactions", traced, "elapsed", time.Since(begin), "memory", nodes+imgs)
+					log.Info("Tracing chain segment", "start", origin, "end", end.NumberU64(), "current", number, "transactions", traced, "elapsed", time.Since(begin), "memory", nodes+imgs, "inflight", common.StorageSize(atomic.LoadUint64(&reserved)))
 				} else {
 					log.Info("Preparing state for chain trace", "block", number, "start", origin, "elapsed", time.Since(begin))
 				}
@@ -279,10 +340,29 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 			}
 			// Send the block over to the concurrent tracers (if not in the fast-forward phase)
 			if number > origin {
+				// Wait until the reserved memory drops below the allowance. Nothing is
+				// reserved without blocks in flight, so tracing can't deadlock.
+				for allowance > 0 && atomic.LoadUint64(&reserved) >= allowance {
+					select {
+					case <-streamed:
+					case <-notifier.Closed():
+						return
+					}
+				}
+				select {
+				case inflight <- struct{}{}:
+				case <-notifier.Closed():
+					return
+				}
 				txs := block.Transactions()
 
+				task := &blockTraceTask{statedb: statedb.Copy(), block: block, rootref: proot, results: make([]*txTraceResult, len(txs)), memory: uint64(block.Size()) + pinned}
+				atomic.AddUint64(&reserved, task.memory)
+				if api.traceDispatchHook != nil {
+					api.traceDispatchHook(len(inflight), atomic.LoadUint64(&reserved), pinned)
+				}
 				select {
-				case tasks <- &blockTraceTask{statedb: statedb.Copy(), block: block, rootref: proot, results: make([]*txTraceResult, len(txs))}:
+				case tasks <- task:
 				case <-notifier.Closed():
 					return
 				}
@@ -294,12 +374,18 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 				failed = err
 				break
 			}
-			// Finalize the state so any modifications are written to the trie
+			// Finalize the state so any modifications are written to the trie, tracking
+			// the trie memory it adds, which stays referenced by the next state copy
+			nodes, imgs := database.TrieDB().Size()
 			root, err := statedb.Commit(api.ccm.blockchain.Config().IsEIP158(block.Number()))
 			if err != nil {
 				failed = err
 				break
 			}
+			pinned = 0
+			if cnodes, cimgs := database.TrieDB().Size(); cnodes+cimgs > nodes+imgs {
+				pinned = uint64(cnodes + cimgs - nodes - imgs)
+			}
 			if err := statedb.Reset(root); err != nil {
 				failed = err
 				break
@@ -322,28 +408,35 @@ func (api *PrivateDebugAPI) traceChain(ctx context.Context, start, end *types.Bl
 	// Keep reading the trace results and stream the to the user
 	go func() {
 		var (
-			done = make(map[uint64]*blockTraceResult)
+			done = make(map[uint64]*blockTraceTask)
 			next = origin + 1
 		)
 		for res := range results {
 			// Queue up next received result
-			result := &blockTraceResult{
-				Block:  hexutil.Uint64(res.block.NumberU64()),
-				Hash:   res.block.Hash(),
-				Traces: res.results,
-			}
-			done[uint64(result.Block)] = result
+			done[res.block.NumberU64()] = res
 
 			// Dereference any paret tries held in memory by this task
 			database.TrieDB().Dereference(res.rootref)
 
 			// Stream completed traces to the user, aborting on the first error
-			for result, ok := done[next]; ok; result, ok = done[next] {
-				if len(result.Traces) > 0 || next == end.NumberU64() {
-					notifier.Notify(sub.ID, result)
+			for task, ok := done[next]; ok; task, ok = done[next] {
+				if len(task.results) > 0 || next == end.NumberU64() {
+					notifier.Notify(sub.ID, &blockTraceResult{
+						Block:  hexutil.Uint64(task.block.NumberU64()),
+						Hash:   task.block.Hash(),
+						Traces: task.results,
+					})
 				}
 				delete(done, next)
 				next++
+
+				// Release the block's memory and wake the feeder if it's waiting
+				atomic.AddUint64(&reserved, ^(task.memory - 1))
+				<-inflight
+				select {
+				case streamed <- struct{}{}:
+				default:
+				}
 			}
 		}
 	}()
@@ -502,7 +595,7 @@ func (api *PrivateDebugAPI) traceBlock(ctx context.Context, block *types.Block,
 		vmctx := core.NewEVMContext(msg, block.Header(), api.ccm.blockchain, nil)
 
 		vmenv := vm.NewEVM(vmctx, statedb, api.ccm.blockchain.Config(), vm.Config{})
-		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
 			failed = err
 			break
 		}
@@ -596,7 +689,7 @@ func (api *PrivateDebugAPI) standardTraceBlockToFile(ctx context.Context, block
 		}
 		// Execute the transaction and flush any traces to disk
 		vmenv := vm.NewEVM(vmctx, statedb, api.ccm.blockchain.Config(), vmConf)
-		_, _, _, err = core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
+		_, err = core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas()))
 		if writer != nil {
 			writer.Flush()
 		}
@@ -737,15 +830,21 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 				return nil, err
 			}
 		}
-		// Constuct the JavaScript tracer to execute with
-		if tracer, err = tracers.New(*config.Tracer); err != nil {
-			return nil, err
+		// Construct the native tracer if one exists by that name, or the JavaScript
+		// tracer to execute with otherwise
+		traced, ok := tracers.NewNative(*config.Tracer)
+		if !ok {
+			if traced, err = tracers.New(*config.Tracer); err != nil {
+				return nil, err
+			}
 		}
+		tracer = traced
+
 		// Handle timeouts and RPC cancellations
 		deadlineCtx, cancel := context.WithTimeout(ctx, timeout)
 		go func() {
 			<-deadlineCtx.Done()
-			tracer.(*tracers.Tracer).Stop(errors.New("execution timeout"))
+			traced.Stop(errors.New("execution timeout"))
 		}()
 		defer cancel()
 
@@ -758,7 +857,7 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	// Run the transaction with tracing enabled.
 	vmenv := vm.NewEVM(vmctx, statedb, api.ccm.blockchain.Config(), vm.Config{Debug: true, Tracer: tracer})
 
-	ret, gas, failed, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
+	result, err := core.ApplyMessage(vmenv, message, new(core.GasPool).AddGas(message.Gas()))
 	if err != nil {
 		return nil, fmt.Errorf("tracing failed: %v", err)
 	}
@@ -766,13 +865,13 @@ func (api *PrivateDebugAPI) traceTx(ctx context.Context, message core.Message, v
 	switch tracer := tracer.(type) {
 	case *vm.StructLogger:
 		return &ccmapi.ExecutionResult{
-			Gas:         gas,
-			Failed:      failed,
-			ReturnValue: fmt.Sprintf("%x", ret),
+			Gas:         result.UsedGas,
+			Failed:      result.Failed(),
+			ReturnValue: fmt.Sprintf("%x", result.ReturnData),
 			StructLogs:  ccmapi.FormatLogs(tracer.StructLogs()),
 		}, nil
 
-	case *tracers.Tracer:
+	case tracers.ResultTracer:
 		return tracer.GetResult()
 
 	default:
@@ -812,7 +911,7 @@ func (api *PrivateDebugAPI) computeTxEnv(blockHash common.Hash, txIndex int, ree
 		}
 		// Not yet the searched for transaction, execute on top of the current state
 		vmenv := vm.NewEVM(context, statedb, api.ccm.blockchain.Config(), vm.Config{})
-		if _, _, _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
 			return nil, vm.Context{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
 		}
 		// Ensure any modifications are committed to the state
@@ -0,0 +1,274 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ccm
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rpc"
+)
+
+// newTestTraceBackend creates a chain of the given length with a value transfer
+// in every block not divisible by three, and a debug API client to trace it.
+func newTestTraceBackend(t *testing.T, blocks int) (*rpc.Client, *PrivateDebugAPI, []*types.Block) {
+	return newTestTraceBackendWithCalls(t, blocks, common.Address{0x01}, nil, params.TxGas)
+}
+
+// newTestTraceBackendWithCalls creates a chain of the given length with a call to
+// the given account in every block not divisible by three, deploying the given
+// code to it, and a debug API client to trace the chain.
+func newTestTraceBackendWithCalls(t *testing.T, blocks int, callee common.Address, code []byte, gas uint64) (*rpc.Client, *PrivateDebugAPI, []*types.Block) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		engine = ccmash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				testBank: {Balance: big.NewInt(1000000000000000)},
+				callee:   {Balance: new(big.Int), Code: code},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+		signer  = types.HomesteadSigner{}
+	)
+	chain, _ := core.GenerateChain(gspec.Config, genesis, engine, db, blocks, func(i int, gen *core.BlockGen) {
+		if (i+1)%3 == 0 {
+			return
+		}
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), callee, big.NewInt(1000), gas, nil, nil), signer, testBankKey)
+		if err != nil {
+			t.Fatalf("failed to sign transaction: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	api := NewPrivateDebugAPI(&Ccmchain{blockchain: blockchain, chainDb: db})
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("debug", api); err != nil {
+		t.Fatalf("failed to register debug API: %v", err)
+	}
+	return rpc.DialInProc(server), api, chain
+}
+
+// traceTestChain traces the given block range via a subscription, returning the
+// streamed results once the end block arrives.
+func traceTestChain(t *testing.T, client *rpc.Client, start, end uint64, config *TraceChainConfig) []*blockTraceResult {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	results := make(chan *blockTraceResult)
+	sub, err := client.Subscribe(ctx, "debug", results, "traceChain", hexutil.Uint64(start), hexutil.Uint64(end), config)
+	if err != nil {
+		t.Fatalf("failed to subscribe to chain trace: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var traced []*blockTraceResult
+	for {
+		select {
+		case res := <-results:
+			traced = append(traced, res)
+			if uint64(res.Block) == end {
+				return traced
+			}
+		case err := <-sub.Err():
+			t.Fatalf("chain trace failed: %v", err)
+		case <-ctx.Done():
+			t.Fatalf("chain trace timed out after %d results", len(traced))
+		}
+	}
+}
+
+// checkTraceResults verifies that all the blocks with transactions in the chain
+// segment (start, end] were traced, and that the results arrived in order.
+func checkTraceResults(t *testing.T, chain []*types.Block, start, end uint64, traced []*blockTraceResult) {
+	var want []*types.Block
+	for _, block := range chain[start:end] {
+		if len(block.Transactions()) > 0 || block.NumberU64() == end {
+			want = append(want, block)
+		}
+	}
+	if len(traced) != len(want) {
+		t.Fatalf("traced block count mismatch: have %d, want %d", len(traced), len(want))
+	}
+	for i, res := range traced {
+		if uint64(res.Block) != want[i].NumberU64() || res.Hash != want[i].Hash() {
+			t.Fatalf("result %d: block mismatch: have #%d [%x], want #%d [%x]", i, res.Block, res.Hash, want[i].NumberU64(), want[i].Hash())
+		}
+		if len(res.Traces) != len(want[i].Transactions()) {
+			t.Fatalf("result %d: trace count mismatch: have %d, want %d", i, len(res.Traces), len(want[i].Transactions()))
+		}
+		for j, trace := range res.Traces {
+			if trace.Error != "" {
+				t.Errorf("result %d, trace %d: tracing failed: %v", i, j, trace.Error)
+			}
+		}
+	}
+}
+
+// Tests that tracing a chain streams all the blocks back in order, and that the
+// number of blocks in flight is bounded by the thread count and by the memory
+// allowance, even when it only permits a single block to be in flight.
+func TestTraceChain(t *testing.T) {
+	client, api, chain := newTestTraceBackend(t, 64)
+	defer client.Close()
+
+	var (
+		lock     sync.Mutex
+		inflight int
+	)
+	api.traceDispatchHook = func(blocks int, memory uint64, state uint64) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if blocks > inflight {
+			inflight = blocks
+		}
+	}
+	tracer, tiny, large := "callTracer", uint64(1), uint64(1024*1024)
+	tests := []struct {
+		threads int
+		memory  *uint64
+		limit   int
+	}{
+		{threads: 1, limit: 2},
+		{threads: 4, limit: 8},
+		{threads: 1, memory: &tiny, limit: 1},
+		{threads: 4, memory: &tiny, limit: 1},
+		{threads: 4, memory: &large, limit: 8},
+	}
+	for i, tt := range tests {
+		lock.Lock()
+		inflight = 0
+		lock.Unlock()
+
+		threads := tt.threads
+		config := &TraceChainConfig{
+			TraceConfig: &TraceConfig{Tracer: &tracer},
+			Threads:     &threads,
+			Memory:      tt.memory,
+		}
+		traced := traceTestChain(t, client, 0, 64, config)
+		checkTraceResults(t, chain, 0, 64, traced)
+
+		lock.Lock()
+		if inflight == 0 || inflight > tt.limit {
+			t.Errorf("test %d: in-flight blocks out of bounds: have %d, want 1..%d", i, inflight, tt.limit)
+		}
+		lock.Unlock()
+	}
+}
+
+// Tests that the memory allowance of a chain trace accounts for the trie nodes
+// pinned by the state copies of the in-flight blocks, not just for the blocks and
+// their traces.
+func TestTraceChainStateMemory(t *testing.T) {
+	// Deploy a contract writing 64 new storage slots on every call:
+	//   for i := 64; i > 0; i-- { sstore(number*64 + i-1, number) }
+	code := common.FromHex("0x60405b6001900380436040020143905580600257" + "00")
+	client, api, chain := newTestTraceBackendWithCalls(t, 32, common.Address{0xcc}, code, 2000000)
+	defer client.Close()
+
+	// Allow for more in-flight blocks and traces than the thread count permits,
+	// leaving only the state copies to limit the blocks in flight
+	var blocks uint64
+	for _, block := range chain {
+		if size := uint64(block.Size()); size > blocks {
+			blocks = size
+		}
+	}
+	allowance := 8 * (blocks + 64)
+
+	var (
+		lock     sync.Mutex
+		inflight int
+		pinned   uint64
+	)
+	api.traceDispatchHook = func(blocks int, memory uint64, state uint64) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		if blocks > inflight {
+			inflight = blocks
+		}
+		if state > pinned {
+			pinned = state
+		}
+	}
+	tracer, threads := "4byteTracer", 4
+	config := &TraceChainConfig{
+		TraceConfig: &TraceConfig{Tracer: &tracer},
+		Threads:     &threads,
+		Memory:      &allowance,
+	}
+	traced := traceTestChain(t, client, 0, 32, config)
+	checkTraceResults(t, chain, 0, 32, traced)
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if pinned < allowance {
+		t.Fatalf("state copies not charged enough: have %d, want at least %d", pinned, allowance)
+	}
+	// A state copy exhausts the allowance, so at most one more block may be traced
+	// alongside the one holding it
+	if inflight == 0 || inflight > 2 {
+		t.Errorf("in-flight blocks out of bounds: have %d, want 1..2", inflight)
+	}
+}
+
+// Tests that an interrupted chain trace can be resumed, continuing after the last
+// block received by the client.
+func TestTraceChainResume(t *testing.T) {
+	client, _, chain := newTestTraceBackend(t, 64)
+	defer client.Close()
+
+	memory, resume := uint64(1), hexutil.Uint64(31)
+	config := &TraceChainConfig{Memory: &memory, Resume: &resume}
+
+	traced := traceTestChain(t, client, 0, 64, config)
+	checkTraceResults(t, chain, 31, 64, traced)
+
+	// Resuming outside of the traced range should be rejected
+	for _, resume := range []hexutil.Uint64{64, 65} {
+		config.Resume = &resume
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if _, err := client.Subscribe(ctx, "debug", make(chan *blockTraceResult), "traceChain", hexutil.Uint64(0), hexutil.Uint64(64), config); err == nil {
+			t.Errorf("resume #%d: expected error, got none", resume)
+		}
+		cancel()
+	}
+}
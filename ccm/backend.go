@@ -86,6 +86,7 @@ type Ccmchain struct {
 
 	bloomRequests chan chan *bloombits.Retrieval // Channel receiving bloom data retrieval requests
 	bloomIndexer  *core.ChainIndexer             // Bloom indexer operating during block imports
+	logIndexer    *core.ChainIndexer             // Address/topic log indexer operating during block imports (optional)
 
 	APIBackend *EthAPIBackend
 
@@ -184,6 +185,7 @@ func New(ctx *node.ServiceContext, config *Config) (*Ccmchain, error) {
 			TrieDirtyLimit:      config.TrieDirtyCache,
 			TrieDirtyDisabled:   config.NoPruning,
 			TrieTimeLimit:       config.TrieTimeout,
+			SnapshotLimit:       config.SnapshotCache,
 		}
 	)
 	ccm.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, ccm.engine, vmConfig, ccm.shouldPreserve)
@@ -197,6 +199,10 @@ func New(ctx *node.ServiceContext, config *Config) (*Ccmchain, error) {
 		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
 	}
 	ccm.bloomIndexer.Start(ccm.blockchain)
+	if config.LogIndex {
+		ccm.logIndexer = NewLogIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+		ccm.logIndexer.Start(ccm.blockchain)
+	}
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = ctx.ResolvePath(config.TxPool.Journal)
@@ -509,9 +515,11 @@ func (s *Ccmchain) ArchiveMode() bool                  { return s.config.NoPruni
 // network protocols to start.
 func (s *Ccmchain) Protocols() []p2p.Protocol {
 	protos := make([]p2p.Protocol, len(ProtocolVersions))
+	filter := newEnrFilter(s.protocolManager.forkFilter)
 	for i, vsn := range ProtocolVersions {
 		protos[i] = s.protocolManager.makeProtocol(vsn)
 		protos[i].Attributes = []enr.Entry{s.currentEthEntry()}
+		protos[i].DialFilter = filter
 	}
 	if s.lesServer != nil {
 		protos = append(protos, s.lesServer.Protocols()...)
@@ -550,6 +558,9 @@ func (s *Ccmchain) Start(srvr *p2p.Server) error {
 // Ccmchain protocol.
 func (s *Ccmchain) Stop() error {
 	s.bloomIndexer.Close()
+	if s.logIndexer != nil {
+		s.logIndexer.Close()
+	}
 	s.blockchain.Stop()
 	s.engine.Close()
 	s.protocolManager.Stop()
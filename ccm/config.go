@@ -97,6 +97,7 @@ type Config struct {
 
 	NoPruning  bool // Whccmer to disable pruning and flush everything to disk
 	NoPrefetch bool // Whccmer to disable prefetching and only load state on demand
+	LogIndex   bool // Whccmer to maintain a persistent address/topic index for log filtering
 
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
@@ -121,6 +122,7 @@ type Config struct {
 	TrieCleanCache int
 	TrieDirtyCache int
 	TrieTimeout    time.Duration
+	SnapshotCache  int // Memory allowance (MB) for the state snapshot, 0 disables snapshots
 
 	// Mining options
 	Miner miner.Config
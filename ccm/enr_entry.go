@@ -20,6 +20,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/core"
 	"github.com/ccmchain/go-ccmchain/core/forkid"
 	"github.com/ccmchain/go-ccmchain/p2p/enode"
+	"github.com/ccmchain/go-ccmchain/p2p/enr"
 	"github.com/ccmchain/go-ccmchain/rlp"
 )
 
@@ -59,3 +60,16 @@ func (ccm *Ccmchain) startEthEntryUpdate(ln *enode.LocalNode) {
 func (ccm *Ccmchain) currentEthEntry() *ccmEntry {
 	return &ccmEntry{ForkID: forkid.NewID(ccm.blockchain)}
 }
+
+// newEnrFilter creates a dial filter rejecting discovered nodes which advertise a
+// "ccm" entry incompatible with the local chain. Nodes without the entry are let
+// through, their fork ID is verified during the handshake.
+func newEnrFilter(forkFilter forkid.Filter) func(*enode.Node) bool {
+	return func(n *enode.Node) bool {
+		var entry ccmEntry
+		if err := n.Load(&entry); err != nil {
+			return enr.IsNotFound(err)
+		}
+		return forkFilter(entry.ForkID) == nil
+	}
+}
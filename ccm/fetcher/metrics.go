@@ -40,4 +40,11 @@ var (
 	headerFilterOutMeter = metrics.NewRegisteredMeter("ccm/fetcher/filter/headers/out", nil)
 	bodyFilterInMeter    = metrics.NewRegisteredMeter("ccm/fetcher/filter/bodies/in", nil)
 	bodyFilterOutMeter   = metrics.NewRegisteredMeter("ccm/fetcher/filter/bodies/out", nil)
+
+	txAnnounceInMeter     = metrics.NewRegisteredMeter("ccm/fetcher/tx/announces/in", nil)
+	txAnnounceDOSMeter    = metrics.NewRegisteredMeter("ccm/fetcher/tx/announces/dos", nil)
+	txBroadcastInMeter    = metrics.NewRegisteredMeter("ccm/fetcher/tx/broadcasts/in", nil)
+	txRequestOutMeter     = metrics.NewRegisteredMeter("ccm/fetcher/tx/requests/out", nil)
+	txRequestTimeoutMeter = metrics.NewRegisteredMeter("ccm/fetcher/tx/requests/timeout", nil)
+	txReplyInMeter        = metrics.NewRegisteredMeter("ccm/fetcher/tx/replies/in", nil)
 )
@@ -0,0 +1,357 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/log"
+)
+
+const (
+	txArriveTimeout = 500 * time.Millisecond // Time allowance before an announced transaction is explicitly requested
+	txGatherSlack   = 100 * time.Millisecond // Interval used to collate almost-expired announces with fetches
+	txFetchTimeout  = 5 * time.Second        // Maximum allotted time to return an explicitly requested transaction
+	txAnnounceLimit = 4096                   // Maximum number of unique transactions a peer may have announced
+	txFetchLimit    = 256                    // Maximum number of transactions to request from a peer at once
+)
+
+// txHasFn is a callback type for checking whccmer a transaction is already known.
+type txHasFn func(common.Hash) bool
+
+// txAddFn is a callback type for injecting a batch of transactions into the pool.
+type txAddFn func([]*types.Transaction) []error
+
+// txRequesterFn is a callback type for sending a transaction retrieval request.
+type txRequesterFn func(peer string, hashes []common.Hash) error
+
+// txAnnounce is the hash notification of the availability of a batch of new
+// transactions in the network.
+type txAnnounce struct {
+	origin string        // Identifier of the peer originating the notification
+	hashes []common.Hash // Hashes of the transactions being announced
+}
+
+// txDelivery is the notification of a batch of transactions having arrived,
+// either broadcast or as a reply to a retrieval request.
+type txDelivery struct {
+	origin string        // Identifier of the peer delivering the transactions
+	hashes []common.Hash // Hashes of the transactions delivered
+	direct bool          // Whccmer the delivery is a reply to a retrieval request
+}
+
+// txRequest is a transaction retrieval request in flight to a remote peer.
+type txRequest struct {
+	hashes []common.Hash // Hashes of the transactions requested
+	time   time.Time     // Timestamp of the request
+}
+
+// TxFetcher is responsible for accumulating transaction announcements from
+// various peers and scheduling them for retrieval. Announced transactions are
+// only requested if they were not broadcast in full within a short period, and
+// every transaction is only requested from a single peer at a time.
+type TxFetcher struct {
+	// Various event channels
+	notify  chan *txAnnounce
+	cleanup chan *txDelivery
+	drop    chan string
+	quit    chan struct{}
+
+	// Announce states
+	announces map[string]map[common.Hash]struct{} // Per peer set of announced but not yet arrived transactions
+	announced map[common.Hash]map[string]struct{} // Set of peers announcing each transaction, alternate sources
+	waiting   map[common.Hash]time.Time           // Announced transactions, waiting for a broadcast before fetching
+	fetching  map[common.Hash]string              // Announced transactions, currently fetching from a peer
+	requests  map[string]*txRequest               // Retrieval requests in flight, at most one per peer
+
+	// Callbacks
+	hasTx    txHasFn       // Checks whccmer a transaction is already known to the pool
+	addTxs   txAddFn       // Injects a batch of transactions into the pool
+	fetchTxs txRequesterFn // Requests a batch of transactions from a peer
+
+	// Testing hooks
+	fetchingHook func(string, []common.Hash) // Method to call upon starting a transaction retrieval
+}
+
+// NewTxFetcher creates a transaction fetcher to retrieve announced transactions
+// based on hash announcements.
+func NewTxFetcher(hasTx txHasFn, addTxs txAddFn, fetchTxs txRequesterFn) *TxFetcher {
+	return &TxFetcher{
+		notify:    make(chan *txAnnounce),
+		cleanup:   make(chan *txDelivery),
+		drop:      make(chan string),
+		quit:      make(chan struct{}),
+		announces: make(map[string]map[common.Hash]struct{}),
+		announced: make(map[common.Hash]map[string]struct{}),
+		waiting:   make(map[common.Hash]time.Time),
+		fetching:  make(map[common.Hash]string),
+		requests:  make(map[string]*txRequest),
+		hasTx:     hasTx,
+		addTxs:    addTxs,
+		fetchTxs:  fetchTxs,
+	}
+}
+
+// Start boots up the announcement based transaction retriever, accepting and
+// processing hash notifications and transaction deliveries until termination
+// requested.
+func (f *TxFetcher) Start() {
+	go f.loop()
+}
+
+// Stop terminates the announcement based transaction retriever, canceling all
+// pending operations.
+func (f *TxFetcher) Stop() {
+	close(f.quit)
+}
+
+// Notify announces the fetcher of the potential availability of a batch of new
+// transactions in the network.
+func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+	txAnnounceInMeter.Mark(int64(len(hashes)))
+
+	select {
+	case f.notify <- &txAnnounce{origin: peer, hashes: hashes}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Enqueue injects a batch of received transactions into the pool, and marks
+// them as arrived in the fetcher. Direct deliveries are replies to retrieval
+// requests, any transaction requested but missing from them is deemed to be
+// unavailable at the peer.
+func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool) error {
+	if direct {
+		txReplyInMeter.Mark(int64(len(txs)))
+	} else {
+		txBroadcastInMeter.Mark(int64(len(txs)))
+	}
+	// Import the transactions, irrelevant of the pool accepting them or not they
+	// have arrived and must not be fetched again
+	if len(txs) > 0 {
+		f.addTxs(txs)
+	}
+
+	hashes := make([]common.Hash, len(txs))
+	for i, tx := range txs {
+		hashes[i] = tx.Hash()
+	}
+	select {
+	case f.cleanup <- &txDelivery{origin: peer, hashes: hashes, direct: direct}:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// Drop removes all the announcements of a peer, rescheduling any transaction
+// being fetched from it to alternate sources.
+func (f *TxFetcher) Drop(peer string) error {
+	select {
+	case f.drop <- peer:
+		return nil
+	case <-f.quit:
+		return errTerminated
+	}
+}
+
+// loop is the main fetcher loop, checking and processing various notification
+// events.
+func (f *TxFetcher) loop() {
+	timer := time.NewTimer(0)
+	<-timer.C // clear out the channel
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-f.quit:
+			// TxFetcher terminating, abort all operations
+			return
+
+		case notification := <-f.notify:
+			// A batch of transactions was announced, make sure it's not DOS-ing us
+			if _, ok := f.announces[notification.origin]; !ok {
+				f.announces[notification.origin] = make(map[common.Hash]struct{})
+			}
+			announces := f.announces[notification.origin]
+
+			for i, hash := range notification.hashes {
+				if _, ok := announces[hash]; ok {
+					continue
+				}
+				if len(announces) >= txAnnounceLimit {
+					log.Debug("Peer exceeded outstanding transaction announces", "peer", notification.origin, "limit", txAnnounceLimit)
+					txAnnounceDOSMeter.Mark(int64(len(notification.hashes) - i))
+					break
+				}
+				// Skip transactions we already have, schedule the rest for retrieval
+				if f.hasTx(hash) {
+					continue
+				}
+				announces[hash] = struct{}{}
+				if _, ok := f.announced[hash]; !ok {
+					f.announced[hash] = make(map[string]struct{})
+					f.waiting[hash] = time.Now()
+				}
+				f.announced[hash][notification.origin] = struct{}{}
+			}
+			if len(announces) == 0 {
+				delete(f.announces, notification.origin)
+			}
+
+		case delivery := <-f.cleanup:
+			// A batch of transactions arrived, stop tracking them
+			for _, hash := range delivery.hashes {
+				for peer := range f.announced[hash] {
+					f.forget(peer, hash)
+				}
+			}
+			// If it's a reply to our request, the peer doesn't have the missing ones
+			if req := f.requests[delivery.origin]; delivery.direct && req != nil {
+				f.expire(delivery.origin, req)
+			}
+
+		case peer := <-f.drop:
+			// A peer disconnected, reschedule anything it was about to deliver
+			if req := f.requests[peer]; req != nil {
+				f.expire(peer, req)
+			}
+			for hash := range f.announces[peer] {
+				f.forget(peer, hash)
+			}
+
+		case <-timer.C:
+			// At least one announcement arrive or retrieval timeout expired, check all
+			now := time.Now()
+			for hash, arrived := range f.waiting {
+				if now.Sub(arrived) > txArriveTimeout-txGatherSlack {
+					delete(f.waiting, hash)
+				}
+			}
+			for peer, req := range f.requests {
+				if now.Sub(req.time) > txFetchTimeout {
+					log.Debug("Transaction retrieval timed out", "peer", peer, "count", len(req.hashes))
+					txRequestTimeoutMeter.Mark(int64(len(req.hashes)))
+					f.expire(peer, req)
+				}
+			}
+		}
+		f.schedule()
+		f.reschedule(timer)
+	}
+}
+
+// forget removes a transaction announcement of a peer, dropping the transaction
+// from the fetcher altogether if there are no more peers announcing it.
+func (f *TxFetcher) forget(peer string, hash common.Hash) {
+	if announces := f.announces[peer]; announces != nil {
+		delete(announces, hash)
+		if len(announces) == 0 {
+			delete(f.announces, peer)
+		}
+	}
+	if announced := f.announced[hash]; announced != nil {
+		delete(announced, peer)
+		if len(announced) == 0 {
+			delete(f.announced, hash)
+			delete(f.waiting, hash)
+		}
+	}
+	if f.fetching[hash] == peer {
+		delete(f.fetching, hash)
+	}
+}
+
+// expire terminates a retrieval request of a peer, forgetting the announcements
+// of any transaction still outstanding, so they are fetched from alternate peers.
+func (f *TxFetcher) expire(peer string, req *txRequest) {
+	delete(f.requests, peer)
+	for _, hash := range req.hashes {
+		if f.fetching[hash] == peer {
+			f.forget(peer, hash)
+		}
+	}
+}
+
+// schedule requests all the transactions ready for retrieval from idle peers,
+// making sure every transaction is requested from at most one peer.
+func (f *TxFetcher) schedule() {
+	for peer, announces := range f.announces {
+		if f.requests[peer] != nil {
+			continue
+		}
+		var hashes []common.Hash
+		for hash := range announces {
+			if _, ok := f.waiting[hash]; ok {
+				continue
+			}
+			if _, ok := f.fetching[hash]; ok {
+				continue
+			}
+			f.fetching[hash] = peer
+			if hashes = append(hashes, hash); len(hashes) >= txFetchLimit {
+				break
+			}
+		}
+		if len(hashes) == 0 {
+			continue
+		}
+		f.requests[peer] = &txRequest{hashes: hashes, time: time.Now()}
+		if f.fetchingHook != nil {
+			f.fetchingHook(peer, hashes)
+		}
+		txRequestOutMeter.Mark(int64(len(hashes)))
+
+		go func(peer string, hashes []common.Hash) {
+			if err := f.fetchTxs(peer, hashes); err != nil {
+				log.Debug("Failed to request transactions", "peer", peer, "err", err)
+			}
+		}(peer, hashes)
+	}
+}
+
+// reschedule resets the specified timer to the next announcement arrival or
+// retrieval timeout.
+func (f *TxFetcher) reschedule(timer *time.Timer) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	var (
+		earliest time.Time
+		pending  bool
+	)
+	for _, arrived := range f.waiting {
+		if deadline := arrived.Add(txArriveTimeout); !pending || deadline.Before(earliest) {
+			earliest, pending = deadline, true
+		}
+	}
+	for _, req := range f.requests {
+		if deadline := req.time.Add(txFetchTimeout); !pending || deadline.Before(earliest) {
+			earliest, pending = deadline, true
+		}
+	}
+	if pending {
+		timer.Reset(time.Until(earliest))
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package fetcher
+
+import (
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/types"
+)
+
+// txFetcherTester is a test simulator for mocking out a local transaction pool.
+type txFetcherTester struct {
+	fetcher *TxFetcher
+
+	pool map[common.Hash]*types.Transaction // Transactions known to the pool
+	lock sync.RWMutex
+
+	requests chan txFetchRequest // Retrieval requests issued by the fetcher
+}
+
+// txFetchRequest is a retrieval request issued by the fetcher to a peer.
+type txFetchRequest struct {
+	peer   string
+	hashes []common.Hash
+}
+
+// newTxTester creates a new transaction fetcher test mocker.
+func newTxTester() *txFetcherTester {
+	tester := &txFetcherTester{
+		pool:     make(map[common.Hash]*types.Transaction),
+		requests: make(chan txFetchRequest, 16),
+	}
+	tester.fetcher = NewTxFetcher(tester.hasTx, tester.addTxs, tester.fetchTxs)
+	tester.fetcher.Start()
+	return tester
+}
+
+// hasTx checks whccmer a transaction is known to the simulated pool.
+func (f *txFetcherTester) hasTx(hash common.Hash) bool {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+
+	_, ok := f.pool[hash]
+	return ok
+}
+
+// addTxs injects a batch of transactions into the simulated pool.
+func (f *txFetcherTester) addTxs(txs []*types.Transaction) []error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, tx := range txs {
+		f.pool[tx.Hash()] = tx
+	}
+	return make([]error, len(txs))
+}
+
+// fetchTxs records a retrieval request issued by the fetcher.
+func (f *txFetcherTester) fetchTxs(peer string, hashes []common.Hash) error {
+	f.requests <- txFetchRequest{peer: peer, hashes: hashes}
+	return nil
+}
+
+// makeTxs creates a batch of distinct test transactions.
+func makeTxs(n int) []*types.Transaction {
+	txs := make([]*types.Transaction, n)
+	for i := range txs {
+		txs[i] = types.NewTransaction(uint64(i), common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	}
+	return txs
+}
+
+// verifyTxRequest checks that a retrieval request is issued to the given peer
+// for exactly the given transactions, or to any peer if none was specified.
+func verifyTxRequest(t *testing.T, requests chan txFetchRequest, peer string, txs ...*types.Transaction) string {
+	select {
+	case req := <-requests:
+		if peer != "" && req.peer != peer {
+			t.Fatalf("request peer mismatch: have %s, want %s", req.peer, peer)
+		}
+		want := make(map[common.Hash]bool)
+		for _, tx := range txs {
+			want[tx.Hash()] = true
+		}
+		if len(req.hashes) != len(want) {
+			t.Fatalf("requested hash count mismatch: have %d, want %d", len(req.hashes), len(want))
+		}
+		for _, hash := range req.hashes {
+			if !want[hash] {
+				t.Fatalf("unexpected hash requested: %x", hash)
+			}
+		}
+		return req.peer
+	case <-time.After(txArriveTimeout + time.Second):
+		t.Fatalf("retrieval request timeout")
+	}
+	return ""
+}
+
+// verifyNoTxRequest checks that no retrieval request is issued for a while.
+func verifyNoTxRequest(t *testing.T, requests chan txFetchRequest, wait time.Duration) {
+	select {
+	case req := <-requests:
+		t.Fatalf("unexpected request to %s: %x", req.peer, req.hashes)
+	case <-time.After(wait):
+	}
+}
+
+// Tests that announced transactions are only requested after the arrival
+// timeout elapses, and only once even if announced by multiple peers.
+func TestTxSequentialAnnouncements(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(3)
+	hashes := []common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}
+
+	start := time.Now()
+	tester.fetcher.Notify("A", hashes)
+	tester.fetcher.Notify("B", hashes)
+
+	peer := verifyTxRequest(t, tester.requests, "", txs...)
+	if elapsed := time.Since(start); elapsed < txArriveTimeout-txGatherSlack {
+		t.Fatalf("transactions requested too early: %v", elapsed)
+	}
+	verifyNoTxRequest(t, tester.requests, 200*time.Millisecond)
+
+	tester.fetcher.Enqueue(peer, txs, true)
+	verifyNoTxRequest(t, tester.requests, txArriveTimeout)
+
+	for _, tx := range txs {
+		if !tester.hasTx(tx.Hash()) {
+			t.Fatalf("transaction %x not imported", tx.Hash())
+		}
+	}
+}
+
+// Tests that announced transactions broadcast in full before the arrival timeout
+// are not requested at all.
+func TestTxBroadcastCancelsRetrieval(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(2)
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash(), txs[1].Hash()})
+	tester.fetcher.Enqueue("B", txs[:1], false)
+
+	verifyTxRequest(t, tester.requests, "A", txs[1])
+}
+
+// Tests that already known transactions are not scheduled for retrieval.
+func TestTxKnownAnnouncements(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(2)
+	tester.addTxs(txs[:1])
+	tester.fetcher.Notify("A", []common.Hash{txs[0].Hash(), txs[1].Hash()})
+
+	verifyTxRequest(t, tester.requests, "A", txs[1])
+}
+
+// Tests that transactions missing from a retrieval reply, or pending at a dropped
+// peer, are rescheduled to alternate peers.
+func TestTxRetrievalFailover(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	txs := makeTxs(2)
+	hashes := []common.Hash{txs[0].Hash(), txs[1].Hash()}
+	for _, peer := range []string{"A", "B", "C"} {
+		tester.fetcher.Notify(peer, hashes)
+	}
+	// Deliver only one of the transactions, the other must be requested elsewhere
+	first := verifyTxRequest(t, tester.requests, "", txs...)
+	tester.fetcher.Enqueue(first, txs[:1], true)
+
+	second := verifyTxRequest(t, tester.requests, "", txs[1])
+	if second == first {
+		t.Fatalf("transaction re-requested from peer %s", first)
+	}
+	// Drop the second peer too, the last one must be asked
+	tester.fetcher.Drop(second)
+
+	third := verifyTxRequest(t, tester.requests, "", txs[1])
+	if third == first || third == second {
+		t.Fatalf("transaction re-requested from peer %s", third)
+	}
+}
+
+// Tests that a peer cannot have more than a limited number of transaction
+// announces outstanding.
+func TestTxAnnounceDOSProtection(t *testing.T) {
+	tester := newTxTester()
+	defer tester.fetcher.Stop()
+
+	hashes := make([]common.Hash, txAnnounceLimit+100)
+	for i := range hashes {
+		hashes[i] = common.BigToHash(big.NewInt(int64(i + 1)))
+	}
+	tester.fetcher.Notify("A", hashes)
+
+	var requested int
+	for requested < txAnnounceLimit {
+		select {
+		case req := <-tester.requests:
+			requested += len(req.hashes)
+			if len(req.hashes) > txFetchLimit {
+				t.Fatalf("request size mismatch: have %d, want at most %d", len(req.hashes), txFetchLimit)
+			}
+			// Reply with nothing, so the next batch is scheduled
+			tester.fetcher.Enqueue("A", nil, true)
+
+		case <-time.After(txArriveTimeout + time.Second):
+			t.Fatalf("retrieval stalled at %d transactions", requested)
+		}
+	}
+	verifyNoTxRequest(t, tester.requests, 200*time.Millisecond)
+	if requested != txAnnounceLimit {
+		t.Fatalf("requested transaction count mismatch: have %d, want %d", requested, txAnnounceLimit)
+	}
+}
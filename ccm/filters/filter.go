@@ -20,10 +20,12 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"sort"
 
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/core"
 	"github.com/ccmchain/go-ccmchain/core/bloombits"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
 	"github.com/ccmchain/go-ccmchain/core/types"
 	"github.com/ccmchain/go-ccmchain/ccmdb"
 	"github.com/ccmchain/go-ccmchain/event"
@@ -44,6 +46,7 @@ type Backend interface {
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
 
 	BloomStatus() (uint64, uint64)
+	LogIndexStatus() (uint64, uint64)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
@@ -142,18 +145,35 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	if f.end == -1 {
 		end = head
 	}
-	// Gather all indexed logs, and finish with non indexed ones
+	// Gather all the logs available in the log index first (if enabled and the
+	// filter has criteria to look up), continue with the bloom indexed ones and
+	// finish with non indexed ones
 	var (
-		logs []*types.Log
-		err  error
+		logs  []*types.Log
+		found []*types.Log
+		err   error
 	)
+	if size, sections := f.backend.LogIndexStatus(); f.logIndexable() {
+		if indexed := sections * size; indexed > uint64(f.begin) {
+			if indexed > end {
+				found, err = f.logIndexLogs(ctx, size, end)
+			} else {
+				found, err = f.logIndexLogs(ctx, size, indexed-1)
+			}
+			logs = append(logs, found...)
+			if err != nil {
+				return logs, err
+			}
+		}
+	}
 	size, sections := f.backend.BloomStatus()
 	if indexed := sections * size; indexed > uint64(f.begin) {
 		if indexed > end {
-			logs, err = f.indexedLogs(ctx, end)
+			found, err = f.indexedLogs(ctx, end)
 		} else {
-			logs, err = f.indexedLogs(ctx, indexed-1)
+			found, err = f.indexedLogs(ctx, indexed-1)
 		}
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
@@ -163,6 +183,109 @@ func (f *Filter) Logs(ctx context.Context) ([]*types.Log, error) {
 	return logs, err
 }
 
+// logIndexable returns whccmer the filter has any address or topic criteria that
+// can be looked up in the log index. Wildcard filters match all logs, so those
+// can't be accelerated.
+func (f *Filter) logIndexable() bool {
+	if len(f.addresses) > 0 {
+		return true
+	}
+	for _, sub := range f.topics {
+		if len(sub) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// logIndexLogs returns the logs matching the filter criteria based on the local
+// persistent address and topic log index.
+func (f *Filter) logIndexLogs(ctx context.Context, size, end uint64) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	for section := uint64(f.begin) / size; section <= end/size; section++ {
+		// Abort if the context was cancelled
+		select {
+		case <-ctx.Done():
+			return logs, ctx.Err()
+		default:
+		}
+		// Retrieve the suggested blocks and pull any truly matching logs
+		head := rawdb.ReadCanonicalHash(f.db, (section+1)*size-1)
+		for _, number := range f.logIndexMatches(section, head) {
+			if number < uint64(f.begin) || number > end {
+				continue
+			}
+			f.begin = int64(number) + 1
+
+			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if header == nil || err != nil {
+				return logs, err
+			}
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+		}
+	}
+	f.begin = int64(end) + 1
+	return logs, nil
+}
+
+// logIndexMatches returns the numbers of the blocks within a log index section
+// which contain a log that includes both one of the filtered addresses as well
+// as one of the topics from each of the filtered topic positions. The position
+// of the topics is not indexed, so the results need to be filtered further.
+func (f *Filter) logIndexMatches(section uint64, head common.Hash) []uint64 {
+	// Positions of the logs matching all the criteria evaluated so far, grouped
+	// by block number. A nil map means no criteria have been evaluated yet.
+	var matches map[uint64]map[uint]struct{}
+
+	intersect := func(items [][]byte) {
+		found := make(map[uint64]map[uint]struct{})
+		for _, item := range items {
+			for _, entry := range rawdb.ReadLogIndex(f.db, section, head, item) {
+				for _, index := range entry.Logs {
+					if matches != nil {
+						if _, ok := matches[entry.Number][index]; !ok {
+							continue
+						}
+					}
+					if found[entry.Number] == nil {
+						found[entry.Number] = make(map[uint]struct{})
+					}
+					found[entry.Number][index] = struct{}{}
+				}
+			}
+		}
+		matches = found
+	}
+	if len(f.addresses) > 0 {
+		items := make([][]byte, len(f.addresses))
+		for i, address := range f.addresses {
+			items[i] = address.Bytes()
+		}
+		intersect(items)
+	}
+	for _, sub := range f.topics {
+		if len(sub) == 0 {
+			continue // empty rule set == wildcard
+		}
+		items := make([][]byte, len(sub))
+		for i, topic := range sub {
+			items[i] = topic.Bytes()
+		}
+		intersect(items)
+	}
+	numbers := make([]uint64, 0, len(matches))
+	for number := range matches {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers
+}
+
 // indexedLogs returns the logs matching the filter criteria based on the bloom
 // bits indexed available locally or via the network.
 func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
@@ -27,6 +27,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
 	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/forkid"
 	"github.com/ccmchain/go-ccmchain/core/rawdb"
 	"github.com/ccmchain/go-ccmchain/core/state"
 	"github.com/ccmchain/go-ccmchain/core/types"
@@ -35,12 +36,15 @@ import (
 	"github.com/ccmchain/go-ccmchain/ccm/downloader"
 	"github.com/ccmchain/go-ccmchain/event"
 	"github.com/ccmchain/go-ccmchain/p2p"
+	"github.com/ccmchain/go-ccmchain/p2p/enode"
+	"github.com/ccmchain/go-ccmchain/p2p/enr"
 	"github.com/ccmchain/go-ccmchain/params"
 )
 
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders62(t *testing.T) { testGetBlockHeaders(t, 62) }
 func TestGetBlockHeaders63(t *testing.T) { testGetBlockHeaders(t, 63) }
+func TestGetBlockHeaders64(t *testing.T) { testGetBlockHeaders(t, 64) }
 
 func testGetBlockHeaders(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxHashFetch+15, nil, nil)
@@ -200,6 +204,7 @@ func testGetBlockHeaders(t *testing.T, protocol int) {
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies62(t *testing.T) { testGetBlockBodies(t, 62) }
 func TestGetBlockBodies63(t *testing.T) { testGetBlockBodies(t, 63) }
+func TestGetBlockBodies64(t *testing.T) { testGetBlockBodies(t, 64) }
 
 func testGetBlockBodies(t *testing.T, protocol int) {
 	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, downloader.MaxBlockFetch+15, nil, nil)
@@ -271,6 +276,7 @@ func testGetBlockBodies(t *testing.T, protocol int) {
 
 // Tests that the node state database can be retrieved based on hashes.
 func TestGetNodeData63(t *testing.T) { testGetNodeData(t, 63) }
+func TestGetNodeData64(t *testing.T) { testGetNodeData(t, 64) }
 
 func testGetNodeData(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -367,6 +373,7 @@ func testGetNodeData(t *testing.T, protocol int) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetReceipt63(t *testing.T) { testGetReceipt(t, 63) }
+func TestGetReceipt64(t *testing.T) { testGetReceipt(t, 64) }
 
 func testGetReceipt(t *testing.T, protocol int) {
 	// Define three accounts to simulate transactions with
@@ -632,3 +639,324 @@ outer:
 		t.Errorf("block broadcast to %d peers, expected %d", receivedCount, broadcastExpected)
 	}
 }
+
+// Tests that transactions are propagated in full to all legacy peers, but only
+// to a square root subset of ccm65 peers, the rest receiving announcements.
+func TestBroadcastTransactionsMixedPeers(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var peers []*testPeer
+	for i := 0; i < 9; i++ {
+		version := ccm65
+		if i < 3 {
+			version = ccm63
+		}
+		peer, _ := newTestPeer(fmt.Sprintf("peer %d", i), version, pm, true)
+		defer peer.close()
+		peers = append(peers, peer)
+	}
+	for pm.peers.Len() < len(peers) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tx := newTestTransaction(testAccount, 0, 0)
+	pm.BroadcastTxs(types.Transactions{tx})
+
+	type result struct {
+		peer *testPeer
+		code uint64
+		err  error
+	}
+	resCh := make(chan result, len(peers))
+	for _, peer := range peers {
+		go func(p *testPeer) {
+			msg, err := p.app.ReadMsg()
+			if err != nil {
+				resCh <- result{peer: p, err: err}
+				return
+			}
+			switch msg.Code {
+			case TxMsg:
+				var txs []*types.Transaction
+				if err = msg.Decode(&txs); err == nil && (len(txs) != 1 || txs[0].Hash() != tx.Hash()) {
+					err = fmt.Errorf("transactions mismatch: have %v, want [%x]", txs, tx.Hash())
+				}
+			case NewPooledTransactionHashesMsg:
+				var hashes []common.Hash
+				if err = msg.Decode(&hashes); err == nil && (len(hashes) != 1 || hashes[0] != tx.Hash()) {
+					err = fmt.Errorf("announcement mismatch: have %x, want [%x]", hashes, tx.Hash())
+				}
+			}
+			resCh <- result{peer: p, code: msg.Code, err: err}
+		}(peer)
+	}
+	var full, announced int
+	for range peers {
+		select {
+		case res := <-resCh:
+			if res.err != nil {
+				t.Fatalf("%s: %v", res.peer.Name(), res.err)
+			}
+			switch {
+			case res.code == TxMsg:
+				full++
+			case res.code == NewPooledTransactionHashesMsg && res.peer.version >= ccm65:
+				announced++
+			default:
+				t.Fatalf("%s (ccm%d): unexpected message code %d", res.peer.Name(), res.peer.version, res.code)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("transaction propagated to %d peers, expected %d", full+announced, len(peers))
+		}
+	}
+	if direct := int(math.Sqrt(float64(len(peers)))); full < 3 || full > 3+direct {
+		t.Errorf("transaction broadcast to %d peers, expected between %d and %d", full, 3, 3+direct)
+	}
+	if announced != len(peers)-full {
+		t.Errorf("transaction announced to %d peers, expected %d", announced, len(peers)-full)
+	}
+}
+
+// Tests that announced transactions are retrieved from ccm65 peers, each only
+// from a single peer at a time, falling back to alternate peers if the first
+// does not deliver, while legacy peers keep broadcasting in full.
+func TestTransactionFetchingMixedPeers(t *testing.T) {
+	txAdded := make(chan []*types.Transaction, 16)
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, txAdded)
+	pm.acceptTxs = 1 // mark synced to accept transactions
+	defer pm.Stop()
+
+	legacy, _ := newTestPeer("legacy", ccm63, pm, true)
+	defer legacy.close()
+
+	var peers []*testPeer
+	for i := 0; i < 2; i++ {
+		peer, _ := newTestPeer(fmt.Sprintf("peer %d", i), ccm65, pm, true)
+		defer peer.close()
+		peers = append(peers, peer)
+	}
+	for pm.peers.Len() < len(peers)+1 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// Announce two transactions from both ccm65 peers, but broadcast one of them
+	// in full from the legacy peer before the announcements expire
+	var (
+		fetched   = newTestTransaction(testAccount, 0, 0)
+		broadcast = newTestTransaction(testAccount, 1, 0)
+	)
+	for _, peer := range peers {
+		if err := p2p.Send(peer.app, NewPooledTransactionHashesMsg, []common.Hash{fetched.Hash(), broadcast.Hash()}); err != nil {
+			t.Fatalf("%s: failed to announce transactions: %v", peer.Name(), err)
+		}
+	}
+	if err := p2p.Send(legacy.app, TxMsg, []*types.Transaction{broadcast}); err != nil {
+		t.Fatalf("failed to broadcast transaction: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != broadcast.Hash() {
+			t.Fatalf("broadcast transaction mismatch: have %v, want [%x]", added, broadcast.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("broadcast transaction not added")
+	}
+	// Wait for the fetcher to request the remaining transaction, only from one peer
+	reqCh := make(chan *testPeer, len(peers))
+	errCh := make(chan error, len(peers))
+	for _, peer := range peers {
+		go func(p *testPeer) {
+			for {
+				if err := p2p.ExpectMsg(p.app, GetPooledTransactionsMsg, []common.Hash{fetched.Hash()}); err != nil {
+					errCh <- fmt.Errorf("%s: %v", p.Name(), err)
+					return
+				}
+				reqCh <- p
+			}
+		}(peer)
+	}
+	var first *testPeer
+	select {
+	case first = <-reqCh:
+	case err := <-errCh:
+		t.Fatalf("unexpected retrieval request: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatalf("transaction retrieval not requested")
+	}
+	select {
+	case p := <-reqCh:
+		t.Fatalf("%s: duplicate retrieval request", p.Name())
+	case err := <-errCh:
+		t.Fatalf("unexpected retrieval request: %v", err)
+	case <-time.After(200 * time.Millisecond):
+	}
+	// Reply without the transaction, ensure it's requested from the other peer
+	if err := p2p.Send(first.app, PooledTransactionsMsg, []*types.Transaction{}); err != nil {
+		t.Fatalf("failed to send empty reply: %v", err)
+	}
+	var second *testPeer
+	select {
+	case second = <-reqCh:
+		if second == first {
+			t.Fatalf("%s: retrieval re-requested from unavailable peer", first.Name())
+		}
+	case err := <-errCh:
+		t.Fatalf("unexpected retrieval request: %v", err)
+	case <-time.After(time.Second):
+		t.Fatalf("transaction retrieval not rescheduled")
+	}
+	if err := p2p.Send(second.app, PooledTransactionsMsg, []*types.Transaction{fetched}); err != nil {
+		t.Fatalf("failed to send reply: %v", err)
+	}
+	select {
+	case added := <-txAdded:
+		if len(added) != 1 || added[0].Hash() != fetched.Hash() {
+			t.Fatalf("fetched transaction mismatch: have %v, want [%x]", added, fetched.Hash())
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("fetched transaction not added")
+	}
+}
+
+// Tests that ccm65 peers can retrieve transactions from the local pool, receiving
+// only the ones known.
+func TestGetPooledTransactions(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	var (
+		known   = newTestTransaction(testAccount, 0, 0)
+		unknown = newTestTransaction(testAccount, 1, 0)
+	)
+	pm.txpool.AddRemotes([]*types.Transaction{known})
+
+	peer, _ := newTestPeer("peer", ccm65, pm, true)
+	defer peer.close()
+
+	// The pool content is announced to the peer on connect, drain it first
+	if err := p2p.ExpectMsg(peer.app, NewPooledTransactionHashesMsg, []common.Hash{known.Hash()}); err != nil {
+		t.Fatalf("initial announcement mismatch: %v", err)
+	}
+	if err := p2p.Send(peer.app, GetPooledTransactionsMsg, []common.Hash{unknown.Hash(), known.Hash()}); err != nil {
+		t.Fatalf("failed to request transactions: %v", err)
+	}
+	if err := p2p.ExpectMsg(peer.app, PooledTransactionsMsg, []*types.Transaction{known}); err != nil {
+		t.Fatalf("pooled transactions mismatch: %v", err)
+	}
+}
+
+// Tests that peers on diverging fork schedules can connect while their chains
+// are compatible, but get rejected, both during the handshake and when dialing
+// from discovery, once the chains split.
+func TestForkIDSplit(t *testing.T) {
+	var (
+		engine = ccmash.NewFaker()
+
+		configNoFork  = &params.ChainConfig{HomesteadBlock: big.NewInt(1)}
+		configProFork = &params.ChainConfig{
+			HomesteadBlock: big.NewInt(1),
+			EIP150Block:    big.NewInt(2),
+			EIP155Block:    big.NewInt(2),
+			EIP158Block:    big.NewInt(2),
+			ByzantiumBlock: big.NewInt(3),
+		}
+		dbNoFork  = rawdb.NewMemoryDatabase()
+		dbProFork = rawdb.NewMemoryDatabase()
+
+		gspecNoFork  = &core.Genesis{Config: configNoFork}
+		gspecProFork = &core.Genesis{Config: configProFork}
+
+		genesisNoFork  = gspecNoFork.MustCommit(dbNoFork)
+		genesisProFork = gspecProFork.MustCommit(dbProFork)
+
+		chainNoFork, _  = core.NewBlockChain(dbNoFork, nil, configNoFork, engine, vm.Config{}, nil)
+		chainProFork, _ = core.NewBlockChain(dbProFork, nil, configProFork, engine, vm.Config{}, nil)
+
+		blocksNoFork, _  = core.GenerateChain(configNoFork, genesisNoFork, engine, dbNoFork, 2, nil)
+		blocksProFork, _ = core.GenerateChain(configProFork, genesisProFork, engine, dbProFork, 2, nil)
+
+		ccmNoFork, _  = NewProtocolManager(configNoFork, nil, downloader.FullSync, 1, new(event.TypeMux), new(testTxPool), engine, chainNoFork, dbNoFork, 1, nil)
+		ccmProFork, _ = NewProtocolManager(configProFork, nil, downloader.FullSync, 1, new(event.TypeMux), new(testTxPool), engine, chainProFork, dbProFork, 1, nil)
+	)
+	ccmNoFork.Start(1000)
+	ccmProFork.Start(1000)
+	defer ccmNoFork.Stop()
+	defer ccmProFork.Stop()
+
+	// connect runs the handshake between the two nodes, returning the first error
+	// or nil if the peers stayed connected.
+	connect := func() error {
+		p2pNoFork, p2pProFork := p2p.MsgPipe()
+		defer p2pNoFork.Close()
+		defer p2pProFork.Close()
+
+		peerNoFork := newPeer(64, p2p.NewPeer(enode.ID{1}, "", nil), p2pNoFork)
+		peerProFork := newPeer(64, p2p.NewPeer(enode.ID{2}, "", nil), p2pProFork)
+
+		errc := make(chan error, 2)
+		go func() { errc <- ccmNoFork.handle(peerProFork) }()
+		go func() { errc <- ccmProFork.handle(peerNoFork) }()
+
+		select {
+		case err := <-errc:
+			return err
+		case <-time.After(250 * time.Millisecond):
+			return nil
+		}
+	}
+	// dialable checks whccmer the nodes accept each other's discovery records.
+	dialable := func() bool {
+		noFork := newEnrFilter(ccmNoFork.forkFilter)(newEnrTestNode(t, &ccmEntry{ForkID: forkid.NewID(chainProFork)}))
+		proFork := newEnrFilter(ccmProFork.forkFilter)(newEnrTestNode(t, &ccmEntry{ForkID: forkid.NewID(chainNoFork)}))
+		return noFork && proFork
+	}
+	// Both nodes should allow the other to connect (same genesis, next fork is the same)
+	if err := connect(); err != nil {
+		t.Fatalf("frontier nofork <-> profork failed: %v", err)
+	}
+	if !dialable() {
+		t.Fatalf("frontier nofork <-> profork records rejected")
+	}
+	// Progress into Homestead. Fork's match, so we don't care what the future holds
+	chainNoFork.InsertChain(blocksNoFork[:1])
+	chainProFork.InsertChain(blocksProFork[:1])
+
+	if err := connect(); err != nil {
+		t.Fatalf("homestead nofork <-> profork failed: %v", err)
+	}
+	if !dialable() {
+		t.Fatalf("homestead nofork <-> profork records rejected")
+	}
+	// Progress into Spurious. Forks mismatch, signalling differing chains, reject
+	chainNoFork.InsertChain(blocksNoFork[1:2])
+	chainProFork.InsertChain(blocksProFork[1:2])
+
+	err := connect()
+	if want := errResp(ErrForkIDRejected, "%v", forkid.ErrLocalIncompatibleOrStale); err == nil || err.Error() != want.Error() {
+		t.Fatalf("fork ID rejection error mismatch: have %v, want %v", err, want)
+	}
+	if dialable() {
+		t.Fatalf("split nofork <-> profork records accepted")
+	}
+	// Nodes not advertising any fork ID are left for the handshake to check
+	if !newEnrFilter(ccmNoFork.forkFilter)(newEnrTestNode(t, nil)) {
+		t.Fatalf("record without ccm entry rejected")
+	}
+}
+
+// newEnrTestNode creates a signed node record with an optional ccm entry.
+func newEnrTestNode(t *testing.T, entry *ccmEntry) *enode.Node {
+	key, _ := crypto.GenerateKey()
+
+	var r enr.Record
+	if entry != nil {
+		r.Set(entry)
+	}
+	if err := enode.SignV4(&r, key); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ccm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/types"
+)
+
+const (
+	// logIndexThrottling is the time to wait between processing two consecutive
+	// log index sections. It's useful during backfills to prevent disk overload.
+	logIndexThrottling = 100 * time.Millisecond
+)
+
+// LogIndexer implements a core.ChainIndexer, building up a persistent index of
+// the log positions containing each address and topic, permitting the retrieval
+// of matching logs without scanning the receipts of bloom false positives.
+type LogIndexer struct {
+	size    uint64                           // section size to generate the log index for
+	db      ccmdb.Database                   // database instance to write index data and metadata into
+	items   map[string][]rawdb.LogIndexEntry // log positions of the addresses and topics in the section
+	section uint64                           // Section is the section number being processed currently
+	head    common.Hash                      // Head is the hash of the last header processed
+}
+
+// NewLogIndexer returns a chain indexer that generates the address and topic log
+// index for the canonical chain for fast logs filtering.
+func NewLogIndexer(db ccmdb.Database, size, confirms uint64) *core.ChainIndexer {
+	backend := &LogIndexer{
+		db:   db,
+		size: size,
+	}
+	table := rawdb.NewTable(db, string(rawdb.LogIndexPrefix))
+
+	return core.NewChainIndexer(db, table, backend, size, confirms, logIndexThrottling, "logindex")
+}
+
+// Reset implements core.ChainIndexerBackend, starting a new log index section.
+// Any index data left over from a previous run of the same section (i.e. one that
+// was rolled back by a reorg) is deleted.
+func (b *LogIndexer) Reset(ctx context.Context, section uint64, lastSectionHead common.Hash) error {
+	rawdb.DeleteLogIndexSection(b.db, section)
+
+	b.items, b.section, b.head = make(map[string][]rawdb.LogIndexEntry), section, common.Hash{}
+	return nil
+}
+
+// Process implements core.ChainIndexerBackend, adding the logs of a new header
+// into the index.
+func (b *LogIndexer) Process(ctx context.Context, header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+
+	receipts := rawdb.ReadRawReceipts(b.db, hash, number)
+	if receipts == nil && header.Bloom != (types.Bloom{}) {
+		return fmt.Errorf("receipts of block #%d [%x…] not found", number, hash[:4])
+	}
+	var index uint
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			b.add(log.Address.Bytes(), number, index)
+			for _, topic := range log.Topics {
+				b.add(topic.Bytes(), number, index)
+			}
+			index++
+		}
+	}
+	b.head = hash
+	return nil
+}
+
+// add appends a log position to the index of the given address or topic.
+func (b *LogIndexer) add(item []byte, number uint64, index uint) {
+	entries := b.items[string(item)]
+	if n := len(entries); n > 0 && entries[n-1].Number == number {
+		// Same block as the last occurrence, skip if the same log also repeated
+		// (i.e. the topic is duplicated within the log)
+		if logs := entries[n-1].Logs; logs[len(logs)-1] != index {
+			entries[n-1].Logs = append(logs, index)
+		}
+		return
+	}
+	b.items[string(item)] = append(entries, rawdb.LogIndexEntry{Number: number, Logs: []uint{index}})
+}
+
+// Commit implements core.ChainIndexerBackend, finalizing the log index section
+// and writing it out into the database.
+func (b *LogIndexer) Commit() error {
+	batch := b.db.NewBatch()
+	for item, entries := range b.items {
+		rawdb.WriteLogIndex(batch, b.section, b.head, []byte(item), entries)
+		if batch.ValueSize() > ccmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package ccm
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccm/filters"
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/bloombits"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/params"
+)
+
+var (
+	// logEmitterCode is a contract emitting a LOG2 with the first two words of the
+	// call data as topics.
+	logEmitterCode = hexutil.MustDecode("0x602035600035600060006000a200")
+
+	// logEmitters are the addresses of the log emitting test contracts.
+	logEmitters = []common.Address{{0xe1}, {0xe2}, {0xe3}}
+
+	// logTopics are the topics emitted by the test contracts.
+	logTopics = []common.Hash{{0x01}, {0x02}, {0x03}, {0x04}}
+)
+
+// noLogIndexBackend is an API backend with the log index disabled, forcing the
+// filters onto the bloombits path.
+type noLogIndexBackend struct {
+	*EthAPIBackend
+}
+
+func (b *noLogIndexBackend) LogIndexStatus() (uint64, uint64) {
+	return params.BloomBitsBlocks, 0
+}
+
+// makeLogChain generates a chain of the given length on top of parent, calling
+// random log emitting contracts in some of the blocks.
+func makeLogChain(t *testing.T, db ccmdb.Database, parent *types.Block, n int, coinbase common.Address, seed int64) []*types.Block {
+	rand := rand.New(rand.NewSource(seed))
+
+	blocks, _ := core.GenerateChain(params.TestChainConfig, parent, ccmash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(coinbase)
+		if rand.Intn(32) != 0 {
+			return
+		}
+		for j := rand.Intn(3); j >= 0; j-- {
+			data := append(logTopics[rand.Intn(len(logTopics))].Bytes(), logTopics[rand.Intn(len(logTopics))].Bytes()...)
+			tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testBank), logEmitters[rand.Intn(len(logEmitters))], new(big.Int), 50000, nil, data), types.HomesteadSigner{}, testBankKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	return blocks
+}
+
+// waitLogIndex waits until both the bloombits and the log indexers processed the
+// requested sections with the given head.
+func waitLogIndex(t *testing.T, indexers []*core.ChainIndexer, sections uint64, head common.Hash) {
+	for _, indexer := range indexers {
+		for start := time.Now(); ; time.Sleep(10 * time.Millisecond) {
+			if have, _, hash := indexer.Sections(); have == sections && hash == head {
+				break
+			}
+			if time.Since(start) > 30*time.Second {
+				t.Fatalf("timeout waiting for %d indexed sections", sections)
+			}
+		}
+	}
+}
+
+// checkLogIndex verifies that filtering via the log index returns the exact same
+// results as filtering via bloombits, and that all of them are canonical.
+func checkLogIndex(t *testing.T, backend *EthAPIBackend) {
+	tests := []struct {
+		begin, end int64
+		addresses  []common.Address
+		topics     [][]common.Hash
+	}{
+		{0, -1, logEmitters[:1], nil},
+		{0, -1, logEmitters[:2], [][]common.Hash{{logTopics[0]}}},
+		{0, -1, nil, [][]common.Hash{{logTopics[1], logTopics[2]}}},
+		{0, -1, nil, [][]common.Hash{{}, {logTopics[3]}}},
+		{0, -1, logEmitters[2:], [][]common.Hash{{logTopics[0]}, {logTopics[1]}}},
+		{0, -1, []common.Address{{0xff}}, nil},
+		{5000, 8000, logEmitters, [][]common.Hash{{logTopics[2]}, {logTopics[2], logTopics[3]}}},
+		{4096, 4096, logEmitters, nil},
+	}
+	var found int
+	for i, tt := range tests {
+		want, err := filters.NewRangeFilter(&noLogIndexBackend{backend}, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter via bloombits: %v", i, err)
+		}
+		have, err := filters.NewRangeFilter(backend, tt.begin, tt.end, tt.addresses, tt.topics).Logs(context.Background())
+		if err != nil {
+			t.Fatalf("test %d: failed to filter via log index: %v", i, err)
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Fatalf("test %d: log mismatch: have %d logs, want %d", i, len(have), len(want))
+		}
+		for j, log := range have {
+			if hash := rawdb.ReadCanonicalHash(backend.ccm.chainDb, log.BlockNumber); hash != log.BlockHash {
+				t.Fatalf("test %d, log %d: non canonical block #%d [%x…]", i, j, log.BlockNumber, log.BlockHash[:4])
+			}
+		}
+		found += len(have)
+	}
+	if found == 0 {
+		t.Fatalf("no logs matched any of the filters")
+	}
+}
+
+// Tests that the log index produces the same filtering results as the bloombits,
+// both for the originally indexed chain as well as after a reorg rolled back and
+// reindexed a section.
+func TestLogIndex(t *testing.T) {
+	var (
+		db     = rawdb.NewMemoryDatabase()
+		gendb  = rawdb.NewMemoryDatabase()
+		engine = ccmash.NewFaker()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{testBank: {Balance: big.NewInt(params.Ccmchain)}},
+		}
+	)
+	for _, emitter := range logEmitters {
+		gspec.Alloc[emitter] = core.GenesisAccount{Code: logEmitterCode, Balance: new(big.Int)}
+	}
+	genesis := gspec.MustCommit(gendb)
+	gspec.MustCommit(db)
+
+	// Generate a chain spanning two full sections and a fork replacing the second
+	blocks := 2*params.BloomBitsBlocks + params.BloomConfirms
+	chain := makeLogChain(t, gendb, genesis, int(blocks), common.Address{}, 1)
+	fork := makeLogChain(t, gendb, chain[5999], int(blocks)-6000+100, common.Address{0xff}, 2)
+
+	blockchain, err := core.NewBlockChain(db, nil, gspec.Config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	ccm := &Ccmchain{
+		chainDb:       db,
+		blockchain:    blockchain,
+		shutdownChan:  make(chan bool),
+		bloomRequests: make(chan chan *bloombits.Retrieval),
+		bloomIndexer:  NewBloomIndexer(db, params.BloomBitsBlocks, params.BloomConfirms),
+		logIndexer:    NewLogIndexer(db, params.BloomBitsBlocks, params.BloomConfirms),
+	}
+	defer close(ccm.shutdownChan)
+	ccm.startBloomHandlers(params.BloomBitsBlocks)
+
+	ccm.bloomIndexer.Start(blockchain)
+	defer ccm.bloomIndexer.Close()
+	ccm.logIndexer.Start(blockchain)
+	defer ccm.logIndexer.Close()
+
+	backend := &EthAPIBackend{ccm: ccm}
+	indexers := []*core.ChainIndexer{ccm.bloomIndexer, ccm.logIndexer}
+
+	// Import the original chain and compare the filter results
+	if _, err := blockchain.InsertChain(chain); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	head := chain[2*params.BloomBitsBlocks-2].Hash() // chain[0] is block #1
+	waitLogIndex(t, indexers, 2, head)
+	checkLogIndex(t, backend)
+
+	// Reorg the second section and ensure it's reindexed without stale data
+	if _, err := blockchain.InsertChain(fork); err != nil {
+		t.Fatalf("failed to import fork: %v", err)
+	}
+	waitLogIndex(t, indexers, 2, fork[2*params.BloomBitsBlocks-6000-2].Hash())
+	checkLogIndex(t, backend)
+
+	for _, emitter := range logEmitters {
+		if entries := rawdb.ReadLogIndex(db, 1, head, emitter.Bytes()); entries != nil {
+			t.Errorf("stale log index entries for %x in reorged section: %v", emitter, entries)
+		}
+	}
+}
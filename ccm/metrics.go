@@ -26,6 +26,10 @@ var (
 	propTxnInTrafficMeter     = metrics.NewRegisteredMeter("ccm/prop/txns/in/traffic", nil)
 	propTxnOutPacketsMeter    = metrics.NewRegisteredMeter("ccm/prop/txns/out/packets", nil)
 	propTxnOutTrafficMeter    = metrics.NewRegisteredMeter("ccm/prop/txns/out/traffic", nil)
+	propTxHashInPacketsMeter  = metrics.NewRegisteredMeter("ccm/prop/txhashes/in/packets", nil)
+	propTxHashInTrafficMeter  = metrics.NewRegisteredMeter("ccm/prop/txhashes/in/traffic", nil)
+	propTxHashOutPacketsMeter = metrics.NewRegisteredMeter("ccm/prop/txhashes/out/packets", nil)
+	propTxHashOutTrafficMeter = metrics.NewRegisteredMeter("ccm/prop/txhashes/out/traffic", nil)
 	propHashInPacketsMeter    = metrics.NewRegisteredMeter("ccm/prop/hashes/in/packets", nil)
 	propHashInTrafficMeter    = metrics.NewRegisteredMeter("ccm/prop/hashes/in/traffic", nil)
 	propHashOutPacketsMeter   = metrics.NewRegisteredMeter("ccm/prop/hashes/out/packets", nil)
@@ -50,6 +54,10 @@ var (
 	reqReceiptInTrafficMeter  = metrics.NewRegisteredMeter("ccm/req/receipts/in/traffic", nil)
 	reqReceiptOutPacketsMeter = metrics.NewRegisteredMeter("ccm/req/receipts/out/packets", nil)
 	reqReceiptOutTrafficMeter = metrics.NewRegisteredMeter("ccm/req/receipts/out/traffic", nil)
+	reqTxnInPacketsMeter      = metrics.NewRegisteredMeter("ccm/req/txns/in/packets", nil)
+	reqTxnInTrafficMeter      = metrics.NewRegisteredMeter("ccm/req/txns/in/traffic", nil)
+	reqTxnOutPacketsMeter     = metrics.NewRegisteredMeter("ccm/req/txns/out/packets", nil)
+	reqTxnOutTrafficMeter     = metrics.NewRegisteredMeter("ccm/req/txns/out/traffic", nil)
 	miscInPacketsMeter        = metrics.NewRegisteredMeter("ccm/misc/in/packets", nil)
 	miscInTrafficMeter        = metrics.NewRegisteredMeter("ccm/misc/in/traffic", nil)
 	miscOutPacketsMeter       = metrics.NewRegisteredMeter("ccm/misc/out/packets", nil)
@@ -103,6 +111,11 @@ func (rw *meteredMsgReadWriter) ReadMsg() (p2p.Msg, error) {
 		packets, traffic = propBlockInPacketsMeter, propBlockInTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnInPacketsMeter, propTxnInTrafficMeter
+
+	case rw.version >= ccm65 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxHashInPacketsMeter, propTxHashInTrafficMeter
+	case rw.version >= ccm65 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = reqTxnInPacketsMeter, reqTxnInTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
@@ -130,6 +143,11 @@ func (rw *meteredMsgReadWriter) WriteMsg(msg p2p.Msg) error {
 		packets, traffic = propBlockOutPacketsMeter, propBlockOutTrafficMeter
 	case msg.Code == TxMsg:
 		packets, traffic = propTxnOutPacketsMeter, propTxnOutTrafficMeter
+
+	case rw.version >= ccm65 && msg.Code == NewPooledTransactionHashesMsg:
+		packets, traffic = propTxHashOutPacketsMeter, propTxHashOutTrafficMeter
+	case rw.version >= ccm65 && msg.Code == PooledTransactionsMsg:
+		packets, traffic = reqTxnOutPacketsMeter, reqTxnOutTrafficMeter
 	}
 	packets.Mark(1)
 	traffic.Mark(int64(msg.Size))
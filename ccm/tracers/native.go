@@ -0,0 +1,151 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+)
+
+// ResultTracer is a vm.Tracer which assembles a JSON result out of the traced
+// execution and which can be interrupted. It is implemented both by the
+// JavaScript tracer and by the native Go ones.
+type ResultTracer interface {
+	vm.Tracer
+
+	// GetResult returns the JSON encoded result of the trace, or any error that
+	// occurred (or interrupted) while tracing.
+	GetResult() (json.RawMessage, error)
+
+	// Stop terminates execution of the tracer at the first opportune moment.
+	Stop(err error)
+}
+
+// natives contains the native Go reimplementations of the built in JavaScript
+// tracers by name. They produce identical output to their JavaScript siblings,
+// at a fraction of the execution cost.
+var natives = map[string]func() ResultTracer{
+	"callTracer":     newCallTracer,
+	"prestateTracer": newPrestateTracer,
+	"4byteTracer":    newFourByteTracer,
+	"noopTracer":     newNoopTracer,
+}
+
+// NewNative creates the native Go tracer registered under the given name. The
+// returned flag is false if there is no native implementation with that name,
+// in which case the JavaScript tracer should be used instead.
+func NewNative(name string) (ResultTracer, bool) {
+	if constructor, ok := natives[name]; ok {
+		return constructor(), true
+	}
+	return nil, false
+}
+
+// interruptible implements the interruption semantics of the JavaScript tracer
+// for the native ones: once stopped, no further steps are traced and the reason
+// is returned instead of the trace result.
+type interruptible struct {
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+	err       error  // Error, if tracing was interrupted
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *interruptible) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// interrupted returns whccmer tracing was interrupted, recording the reason as
+// the tracing error the first time it's noticed.
+func (t *interruptible) interrupted() bool {
+	if t.err != nil {
+		return true
+	}
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		t.err = t.reason
+		return true
+	}
+	return false
+}
+
+// peekStack returns the nth-from-the-top element of the stack, with the same
+// out of bound semantics as the JavaScript stack wrapper.
+func peekStack(stack *vm.Stack, n int) *big.Int {
+	return (&stackWrapper{stack: stack}).peek(n)
+}
+
+// sliceMemory returns the requested range of memory, with the same out of bound
+// semantics as the JavaScript memory wrapper.
+func sliceMemory(memory *vm.Memory, begin, end int64) []byte {
+	if end < begin {
+		return nil
+	}
+	return common.CopyBytes((&memoryWrapper{memory: memory}).slice(begin, end))
+}
+
+// encodeResult serializes a native tracer result exactly the way the JavaScript
+// engine would, i.e. compacted and without escaping HTML characters.
+func encodeResult(result interface{}) (json.RawMessage, error) {
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(result); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'})), nil
+}
+
+// noopTracer is the native Go version of noop_tracer.js.
+type noopTracer struct {
+	interruptible
+}
+
+func newNoopTracer() ResultTracer {
+	return new(noopTracer)
+}
+
+func (t *noopTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	return nil
+}
+
+func (t *noopTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	t.interrupted()
+	return nil
+}
+
+func (t *noopTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+func (t *noopTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+func (t *noopTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	return json.RawMessage(`{}`), nil
+}
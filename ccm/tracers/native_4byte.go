@@ -0,0 +1,119 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+)
+
+// fourByteTracer is the native Go version of 4byte_tracer.js, aggregating the
+// 4byte method identifiers (and call data sizes) of all the calls made.
+type fourByteTracer struct {
+	interruptible
+
+	keys  []string       // Identifiers in the order they were first found
+	ids   map[string]int // Number of occurrences of each identifier
+	input []byte         // Call data of the outer transaction
+}
+
+func newFourByteTracer() ResultTracer {
+	return &fourByteTracer{ids: make(map[string]int)}
+}
+
+// store saves the given identifier and data size.
+func (t *fourByteTracer) store(id []byte, size int64) {
+	key := fmt.Sprintf("%s-%d", hexutil.Encode(id), size)
+	if _, ok := t.ids[key]; !ok {
+		t.keys = append(t.keys, key)
+	}
+	t.ids[key]++
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *fourByteTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.input = common.CopyBytes(input)
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *fourByteTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.interrupted() {
+		return nil
+	}
+	// Skip any opcodes that are not internal calls, finding the input offset
+	var inOffIdx int
+	switch op {
+	case vm.CALL, vm.CALLCODE:
+		inOffIdx = 3 // gas, addr, val, memin, meminsz, memout, memoutsz
+	case vm.DELEGATECALL, vm.STATICCALL:
+		inOffIdx = 2 // gas, addr, memin, meminsz, memout, memoutsz
+	default:
+		return nil
+	}
+	// Skip any pre-compile invocations, those are just fancy opcodes
+	if _, ok := vm.PrecompiledContractsByzantium[common.BigToAddress(peekStack(stack, 1))]; ok {
+		return nil
+	}
+	// Gather internal call details
+	if inSz := int64(peekStack(stack, inOffIdx+1).Uint64()); inSz >= 4 {
+		inOff := int64(peekStack(stack, inOffIdx).Uint64())
+		t.store(sliceMemory(memory, inOff, inOff+4), inSz-4)
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *fourByteTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *fourByteTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the JSON encoded identifier counts, or the reason of
+// interruption.
+func (t *fourByteTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	// Save the outer calldata also
+	if len(t.input) >= 4 {
+		t.store(t.input[:4], int64(len(t.input)-4))
+	}
+	// Serialize the identifiers in the order they were found
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, key := range t.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, `"%s":%d`, key, t.ids[key])
+	}
+	buf.WriteByte('}')
+	return json.RawMessage(buf.Bytes()), nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/crypto"
+)
+
+// errNoStateAccessed is returned by the prestate tracer if the transaction did
+// not execute any code, so the state was never available to the tracer.
+var errNoStateAccessed = errors.New("no state accessed by the transaction")
+
+// prestateAccount is the pre-transaction state of a single account.
+type prestateAccount struct {
+	Balance *hexutil.Big     `json:"balance"`
+	Nonce   uint64           `json:"nonce"`
+	Code    hexutil.Bytes    `json:"code"`
+	Storage *prestateStorage `json:"storage"`
+}
+
+// prestateStorage is the accessed storage of an account, serialized in access
+// order (same as JavaScript objects).
+type prestateStorage struct {
+	keys  []common.Hash
+	slots map[common.Hash]common.Hash
+}
+
+// MarshalJSON implements json.Marshaler, retaining the access order of the slots.
+func (s *prestateStorage) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, key := range s.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, `"%s":"%s"`, key.Hex(), s.slots[key].Hex())
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// prestate is the genesis being assembled by the prestate tracer, serialized in
+// access order (same as JavaScript objects).
+type prestate struct {
+	addrs    []common.Address
+	accounts map[common.Address]*prestateAccount
+}
+
+// MarshalJSON implements json.Marshaler, retaining the access order of the accounts.
+func (p *prestate) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('{')
+	for i, addr := range p.addrs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		blob, err := encodeResult(p.accounts[addr])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(buf, `"%s":%s`, hexutil.Encode(addr[:]), blob)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// prestateTracer is the native Go version of prestate_tracer.js, reassembling
+// the pre-transaction state of all the accounts and storage slots accessed.
+type prestateTracer struct {
+	interruptible
+
+	prestate *prestate  // Genesis being assembled, nil until the first step
+	db       vm.StateDB // State database of the last traced step
+
+	create bool
+	from   common.Address
+	to     common.Address
+	value  *big.Int
+}
+
+func newPrestateTracer() ResultTracer {
+	return new(prestateTracer)
+}
+
+// lookupAccount injects the specified account into the prestate.
+func (t *prestateTracer) lookupAccount(addr common.Address) {
+	if _, ok := t.prestate.accounts[addr]; ok {
+		return
+	}
+	t.prestate.addrs = append(t.prestate.addrs, addr)
+	t.prestate.accounts[addr] = &prestateAccount{
+		Balance: (*hexutil.Big)(new(big.Int).Set(t.db.GetBalance(addr))),
+		Nonce:   t.db.GetNonce(addr),
+		Code:    common.CopyBytes(t.db.GetCode(addr)),
+		Storage: &prestateStorage{slots: make(map[common.Hash]common.Hash)},
+	}
+}
+
+// lookupStorage injects the specified storage entry of the given account into
+// the prestate.
+func (t *prestateTracer) lookupStorage(addr common.Address, key common.Hash) {
+	t.lookupAccount(addr)
+
+	storage := t.prestate.accounts[addr].Storage
+	if _, ok := storage.slots[key]; ok {
+		return
+	}
+	storage.keys = append(storage.keys, key)
+	storage.slots[key] = t.db.GetState(addr, key)
+}
+
+// CaptureStart implements the Tracer interface to initialize the tracing operation.
+func (t *prestateTracer) CaptureStart(from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) error {
+	t.create = create
+	t.from = from
+	t.to = to
+	t.value = new(big.Int)
+	if value != nil {
+		t.value.Set(value)
+	}
+	return nil
+}
+
+// CaptureState implements the Tracer interface to trace a single step of VM execution.
+func (t *prestateTracer) CaptureState(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	if t.interrupted() {
+		return nil
+	}
+	t.db = env.StateDB
+
+	// Add the current account if we just started tracing. Balance will potentially
+	// be wrong here, since this will include the value sent along with the message.
+	// We fix that in GetResult.
+	if t.prestate == nil {
+		t.prestate = &prestate{accounts: make(map[common.Address]*prestateAccount)}
+		t.lookupAccount(contract.Address())
+	}
+	// Whenever new state is accessed, add it to the prestate
+	switch op {
+	case vm.EXTCODECOPY, vm.EXTCODESIZE, vm.BALANCE:
+		t.lookupAccount(common.BigToAddress(peekStack(stack, 0)))
+
+	case vm.CREATE:
+		from := contract.Address()
+		t.lookupAccount(crypto.CreateAddress(from, env.StateDB.GetNonce(from)))
+
+	case vm.CREATE2:
+		offset := int64(peekStack(stack, 1).Uint64())
+		size := int64(peekStack(stack, 2).Uint64())
+		salt := common.BigToHash(peekStack(stack, 3))
+
+		code := sliceMemory(memory, offset, offset+size)
+		t.lookupAccount(crypto.CreateAddress2(contract.Address(), salt, crypto.Keccak256(code)))
+
+	case vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL:
+		t.lookupAccount(common.BigToAddress(peekStack(stack, 1)))
+
+	case vm.SSTORE, vm.SLOAD:
+		t.lookupStorage(contract.Address(), common.BigToHash(peekStack(stack, 0)))
+	}
+	return nil
+}
+
+// CaptureFault implements the Tracer interface to trace an execution fault
+// while running an opcode.
+func (t *prestateTracer) CaptureFault(env *vm.EVM, pc uint64, op vm.OpCode, gas, cost uint64, memory *vm.Memory, stack *vm.Stack, contract *vm.Contract, depth int, err error) error {
+	return nil
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *prestateTracer) CaptureEnd(output []byte, gasUsed uint64, d time.Duration, err error) error {
+	return nil
+}
+
+// GetResult returns the JSON encoded prestate, or the reason of interruption.
+func (t *prestateTracer) GetResult() (json.RawMessage, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	if t.prestate == nil {
+		return nil, errNoStateAccessed
+	}
+	// At this point, we need to deduct the 'value' from the outer transaction,
+	// and move it back to the origin
+	t.lookupAccount(t.from)
+	t.lookupAccount(t.to)
+
+	fromBal := new(big.Int).Set((*big.Int)(t.prestate.accounts[t.from].Balance))
+	toBal := new(big.Int).Set((*big.Int)(t.prestate.accounts[t.to].Balance))
+
+	t.prestate.accounts[t.to].Balance = (*hexutil.Big)(toBal.Sub(toBal, t.value))
+	t.prestate.accounts[t.from].Balance = (*hexutil.Big)(fromBal.Add(fromBal, t.value))
+
+	// Decrement the caller's nonce, and remove empty create targets
+	t.prestate.accounts[t.from].Nonce--
+	if t.create {
+		// We can blindly delete the contract prestate, as any existing state would
+		// have caused the transaction to be rejected as invalid in the first place.
+		delete(t.prestate.accounts, t.to)
+		for i, addr := range t.prestate.addrs {
+			if addr == t.to {
+				t.prestate.addrs = append(t.prestate.addrs[:i], t.prestate.addrs[i+1:]...)
+				break
+			}
+		}
+	}
+	return encodeResult(t.prestate)
+}
@@ -17,13 +17,16 @@
 package tracers
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"crypto/rand"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"path/filepath"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -168,39 +171,115 @@ func TestPrestateTracerCreate2(t *testing.T) {
 		Code:    []byte{},
 		Balance: big.NewInt(500000000000000),
 	}
-	statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc)
+	for _, native := range []bool{false, true} {
+		statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc)
 
-	// Create the tracer, the EVM environment and run it
-	tracer, err := New("prestateTracer")
+		// Create the tracer, the EVM environment and run it
+		tracer, err := newTestTracer("prestateTracer", native)
+		if err != nil {
+			t.Fatalf("failed to create prestate tracer (native: %v): %v", native, err)
+		}
+		evm := vm.NewEVM(context, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+
+		msg, err := tx.AsMessage(signer)
+		if err != nil {
+			t.Fatalf("failed to prepare transaction for tracing: %v", err)
+		}
+		st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
+		if _, err = st.TransitionDb(); err != nil {
+			t.Fatalf("failed to execute transaction: %v", err)
+		}
+		// Retrieve the trace result and compare against the etalon
+		res, err := tracer.GetResult()
+		if err != nil {
+			t.Fatalf("failed to retrieve trace result (native: %v): %v", native, err)
+		}
+		ret := make(map[string]interface{})
+		if err := json.Unmarshal(res, &ret); err != nil {
+			t.Fatalf("failed to unmarshal trace result (native: %v): %v", native, err)
+		}
+		if _, has := ret["0x60f3f640a8508fc6a86d45df051962668e1e8ac7"]; !has {
+			t.Fatalf("Expected 0x60f3f640a8508fc6a86d45df051962668e1e8ac7 in result (native: %v)", native)
+		}
+	}
+}
+
+// traceTimeRE matches the execution time reported by the call tracers, which
+// naturally differs between runs.
+var traceTimeRE = regexp.MustCompile(`"time":"[^"]*",?`)
+
+// newTestTracer creates either the JavaScript or the native tracer by name.
+func newTestTracer(name string, native bool) (ResultTracer, error) {
+	if native {
+		if tracer, ok := NewNative(name); ok {
+			return tracer, nil
+		}
+		return nil, fmt.Errorf("no native tracer named %q", name)
+	}
+	return New(name)
+}
+
+// loadTracerTest reads a tracer test case from disk, assembling the transaction
+// and the EVM context needed to replay it.
+func loadTracerTest(t *testing.T, file string) (*callTracerTest, *types.Transaction, types.Signer, vm.Context) {
+	blob, err := ioutil.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("failed to read testcase: %v", err)
+	}
+	test := new(callTracerTest)
+	if err := json.Unmarshal(blob, test); err != nil {
+		t.Fatalf("failed to parse testcase: %v", err)
+	}
+	// Configure a blockchain with the given prestate
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
+		t.Fatalf("failed to parse testcase input: %v", err)
+	}
+	signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
+	origin, _ := signer.Sender(tx)
+
+	context := vm.Context{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Origin:      origin,
+		Coinbase:    test.Context.Miner,
+		BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
+		Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
+		Difficulty:  (*big.Int)(test.Context.Difficulty),
+		GasLimit:    uint64(test.Context.GasLimit),
+		GasPrice:    tx.GasPrice(),
+	}
+	return test, tx, signer, context
+}
+
+// runTracerTest replays the transaction of a tracer test case on top of its
+// prestate through either the JavaScript or the native tracer with the given
+// name, returning the trace result.
+func runTracerTest(t *testing.T, test *callTracerTest, tx *types.Transaction, signer types.Signer, context vm.Context, name string, native bool) json.RawMessage {
+	tracer, err := newTestTracer(name, native)
 	if err != nil {
-		t.Fatalf("failed to create call tracer: %v", err)
+		t.Fatalf("failed to create %s (native: %v): %v", name, native, err)
 	}
-	evm := vm.NewEVM(context, statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+	statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc)
+	evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
 
 	msg, err := tx.AsMessage(signer)
 	if err != nil {
 		t.Fatalf("failed to prepare transaction for tracing: %v", err)
 	}
 	st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
-	if _, _, _, err = st.TransitionDb(); err != nil {
+	if _, err = st.TransitionDb(); err != nil {
 		t.Fatalf("failed to execute transaction: %v", err)
 	}
-	// Retrieve the trace result and compare against the etalon
 	res, err := tracer.GetResult()
 	if err != nil {
-		t.Fatalf("failed to retrieve trace result: %v", err)
-	}
-	ret := make(map[string]interface{})
-	if err := json.Unmarshal(res, &ret); err != nil {
-		t.Fatalf("failed to unmarshal trace result: %v", err)
-	}
-	if _, has := ret["0x60f3f640a8508fc6a86d45df051962668e1e8ac7"]; !has {
-		t.Fatalf("Expected 0x60f3f640a8508fc6a86d45df051962668e1e8ac7 in result")
+		t.Fatalf("failed to retrieve %s result (native: %v): %v", name, native, err)
 	}
+	return res
 }
 
 // Iterates over all the input-output datasets in the tracer test harness and
-// runs the JavaScript tracers against them.
+// runs both the JavaScript and the native tracers against them.
 func TestCallTracer(t *testing.T) {
 	files, err := ioutil.ReadDir("testdata")
 	if err != nil {
@@ -214,64 +293,57 @@ func TestCallTracer(t *testing.T) {
 		t.Run(camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
 			t.Parallel()
 
-			// Call tracer test found, read if from disk
-			blob, err := ioutil.ReadFile(filepath.Join("testdata", file.Name()))
-			if err != nil {
-				t.Fatalf("failed to read testcase: %v", err)
-			}
-			test := new(callTracerTest)
-			if err := json.Unmarshal(blob, test); err != nil {
-				t.Fatalf("failed to parse testcase: %v", err)
-			}
-			// Configure a blockchain with the given prestate
-			tx := new(types.Transaction)
-			if err := rlp.DecodeBytes(common.FromHex(test.Input), tx); err != nil {
-				t.Fatalf("failed to parse testcase input: %v", err)
-			}
-			signer := types.MakeSigner(test.Genesis.Config, new(big.Int).SetUint64(uint64(test.Context.Number)))
-			origin, _ := signer.Sender(tx)
-
-			context := vm.Context{
-				CanTransfer: core.CanTransfer,
-				Transfer:    core.Transfer,
-				Origin:      origin,
-				Coinbase:    test.Context.Miner,
-				BlockNumber: new(big.Int).SetUint64(uint64(test.Context.Number)),
-				Time:        new(big.Int).SetUint64(uint64(test.Context.Time)),
-				Difficulty:  (*big.Int)(test.Context.Difficulty),
-				GasLimit:    uint64(test.Context.GasLimit),
-				GasPrice:    tx.GasPrice(),
-			}
-			statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), test.Genesis.Alloc)
+			// Run the transaction through both the JavaScript and the native tracer
+			test, tx, signer, context := loadTracerTest(t, file.Name())
 
-			// Create the tracer, the EVM environment and run it
-			tracer, err := New("callTracer")
-			if err != nil {
-				t.Fatalf("failed to create call tracer: %v", err)
-			}
-			evm := vm.NewEVM(context, statedb, test.Genesis.Config, vm.Config{Debug: true, Tracer: tracer})
+			var results []json.RawMessage
+			for _, native := range []bool{false, true} {
+				res := runTracerTest(t, test, tx, signer, context, "callTracer", native)
 
-			msg, err := tx.AsMessage(signer)
-			if err != nil {
-				t.Fatalf("failed to prepare transaction for tracing: %v", err)
-			}
-			st := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas()))
-			if _, _, _, err = st.TransitionDb(); err != nil {
-				t.Fatalf("failed to execute transaction: %v", err)
+				// Compare the trace result against the etalon
+				ret := new(callTrace)
+				if err := json.Unmarshal(res, ret); err != nil {
+					t.Fatalf("failed to unmarshal trace result (native: %v): %v", native, err)
+				}
+				if !reflect.DeepEqual(ret, test.Result) {
+					t.Fatalf("trace mismatch (native: %v): \nhave %+v\nwant %+v", native, ret, test.Result)
+				}
+				results = append(results, res)
 			}
-			// Retrieve the trace result and compare against the etalon
-			res, err := tracer.GetResult()
-			if err != nil {
-				t.Fatalf("failed to retrieve trace result: %v", err)
-			}
-			ret := new(callTrace)
-			if err := json.Unmarshal(res, ret); err != nil {
-				t.Fatalf("failed to unmarshal trace result: %v", err)
+			// Ensure the two tracers produce the exact same output, apart from timing
+			js, native := traceTimeRE.ReplaceAll(results[0], nil), traceTimeRE.ReplaceAll(results[1], nil)
+			if !bytes.Equal(js, native) {
+				t.Fatalf("native output mismatch: \nhave %s\nwant %s", native, js)
 			}
+		})
+	}
+}
 
-			if !reflect.DeepEqual(ret, test.Result) {
-				t.Fatalf("trace mismatch: \nhave %+v\nwant %+v", ret, test.Result)
+// Iterates over all the datasets in the tracer test harness and ensures that the
+// native prestate and 4byte tracers produce byte for byte the same output as the
+// JavaScript tracers they replace.
+func TestNativeTracers(t *testing.T) {
+	files, err := ioutil.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("failed to retrieve tracer test suite: %v", err)
+	}
+	for _, name := range []string{"prestateTracer", "4byteTracer"} {
+		for _, file := range files {
+			if !strings.HasPrefix(file.Name(), "call_tracer_") {
+				continue
 			}
-		})
+			name, file := name, file // capture range variables
+			t.Run(name+"/"+camel(strings.TrimSuffix(strings.TrimPrefix(file.Name(), "call_tracer_"), ".json")), func(t *testing.T) {
+				t.Parallel()
+
+				test, tx, signer, context := loadTracerTest(t, file.Name())
+
+				js := runTracerTest(t, test, tx, signer, context, name, false)
+				native := runTracerTest(t, test, tx, signer, context, name, true)
+				if !bytes.Equal(js, native) {
+					t.Fatalf("native output mismatch: \nhave %s\nwant %s", native, js)
+				}
+			})
+		}
 	}
 }
@@ -462,6 +462,9 @@ func (ec *Client) PendingTransactionCount(ctx context.Context) (uint, error) {
 // blockNumber selects the block height at which the call runs. It can be nil, in which
 // case the code is taken from the latest known block. Note that state from very old
 // blocks might not be available.
+//
+// If the call is reverted, the returned error implements rpc.DataError, carrying the
+// hex encoded revert data.
 func (ec *Client) CallContract(ctx context.Context, msg ccmchain.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	var hex hexutil.Bytes
 	err := ec.c.CallContext(ctx, &hex, "ccm_call", toCallArg(msg), toBlockNumArg(blockNumber))
@@ -22,10 +22,13 @@ import (
 	"fmt"
 	"math/big"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ccmchain/go-ccmchain"
+	"github.com/ccmchain/go-ccmchain/accounts/abi"
+	"github.com/ccmchain/go-ccmchain/accounts/abi/bind"
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
 	"github.com/ccmchain/go-ccmchain/core"
@@ -35,6 +38,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/ccm"
 	"github.com/ccmchain/go-ccmchain/node"
 	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rpc"
 )
 
 // Verify that Client implements the ccmchain interfaces.
@@ -166,8 +170,27 @@ var (
 	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
 	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
 	testBalance = big.NewInt(2e10)
+
+	testRevertAddr  = common.Address{0xee} // Contract with the revertABI interface
+	testInvalidAddr = common.Address{0xfe} // Contract executing an invalid opcode
 )
 
+// revertABI and revertCode belong to a hand-assembled contract equivalent to
+//
+//	error Insufficient(uint256 available, uint256 required);
+//
+//	function fail() public pure { revert("boom"); }
+//	function assertFail() public pure { assert(false); }
+//	function custom() public pure { revert Insufficient(1, 2); }
+const revertABI = `[
+	{"type":"function","name":"fail","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"function","name":"assertFail","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"function","name":"custom","inputs":[],"outputs":[],"stateMutability":"pure"},
+	{"type":"error","name":"Insufficient","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]}
+]`
+
+const revertCode = `60003560e01c8063a9cc47181461002b57806309ccaefb1461003b578063c231aa301461004b57600080fd5b61006461005b6000396100646000fd5b6100246100bf6000396100246000fd5b6100446100e36000396100446000fd08c379a000000000000000000000000000000000000000000000000000000000000000200000000000000000000000000000000000000000000000000000000000000004626f6f6d000000000000000000000000000000000000000000000000000000004e487b710000000000000000000000000000000000000000000000000000000000000001e862080000000000000000000000000000000000000000000000000000000000000000010000000000000000000000000000000000000000000000000000000000000002`
+
 func newTestBackend(t *testing.T) (*node.Node, []*types.Block) {
 	// Generate test chain.
 	genesis, blocks := generateTestChain()
@@ -196,8 +219,12 @@ func generateTestChain() (*core.Genesis, []*types.Block) {
 	db := rawdb.NewMemoryDatabase()
 	config := params.AllEthashProtocolChanges
 	genesis := &core.Genesis{
-		Config:    config,
-		Alloc:     core.GenesisAlloc{testAddr: {Balance: testBalance}},
+		Config: config,
+		Alloc: core.GenesisAlloc{
+			testAddr:        {Balance: testBalance},
+			testRevertAddr:  {Balance: new(big.Int), Code: common.FromHex(revertCode)},
+			testInvalidAddr: {Balance: new(big.Int), Code: []byte{0xfe}},
+		},
 		ExtraData: []byte("test genesis"),
 		Timestamp: 9000,
 	}
@@ -335,3 +362,48 @@ func TestChainID(t *testing.T) {
 		t.Fatalf("ChainID returned wrong number: %+v", id)
 	}
 }
+
+// Tests that reverted calls report their revert data, decoded by bound contracts
+// into reasons and custom errors, while other execution failures return empty
+// data without an error.
+func TestCallContractFailures(t *testing.T) {
+	backend, _ := newTestBackend(t)
+	client, _ := backend.Attach()
+	defer backend.Stop()
+	defer client.Close()
+	ec := NewClient(client)
+
+	res, err := ec.CallContract(context.Background(), ccmchain.CallMsg{From: testAddr, To: &testInvalidAddr}, nil)
+	if err != nil || len(res) != 0 {
+		t.Fatalf("failed call mismatch: have %x, %v, want empty result without error", res, err)
+	}
+	parsed, err := abi.JSON(strings.NewReader(revertABI))
+	if err != nil {
+		t.Fatalf("failed to parse abi: %v", err)
+	}
+	contract := bind.NewBoundContract(testRevertAddr, parsed, ec, ec, ec)
+
+	tests := []struct {
+		mccmod string
+		reason string
+	}{
+		{"fail", "boom"},
+		{"assertFail", "assert(false)"},
+		{"custom", "Insufficient(1, 2)"},
+	}
+	for _, tt := range tests {
+		input, _ := parsed.Pack(tt.mccmod)
+		_, err := ec.CallContract(context.Background(), ccmchain.CallMsg{From: testAddr, To: &testRevertAddr, Data: input}, nil)
+		if de, ok := err.(rpc.DataError); !ok {
+			t.Errorf("%s: error mismatch: have %v (%T), want rpc.DataError", tt.mccmod, err, err)
+		} else if data, ok := de.ErrorData().(string); !ok || !strings.HasPrefix(data, "0x") || len(data) < 10 {
+			t.Errorf("%s: revert data mismatch: have %v", tt.mccmod, de.ErrorData())
+		}
+		err = contract.Call(nil, nil, tt.mccmod)
+		if revert, ok := err.(*abi.RevertError); !ok {
+			t.Errorf("%s: bound call error mismatch: have %v (%T), want *abi.RevertError", tt.mccmod, err, err)
+		} else if revert.Reason != tt.reason {
+			t.Errorf("%s: bound call reason mismatch: have %q, want %q", tt.mccmod, revert.Reason, tt.reason)
+		}
+	}
+}
@@ -40,6 +40,7 @@ func init() {
 		commandGenerate,
 		commandInspect,
 		commandChangePassphrase,
+		commandRotate,
 		commandSignMessage,
 		commandVerifyMessage,
 	}
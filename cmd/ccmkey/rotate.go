@@ -0,0 +1,99 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ccmchain/go-ccmchain/accounts/keystore"
+	"github.com/ccmchain/go-ccmchain/cmd/utils"
+	"gopkg.in/urfave/cli.v1"
+)
+
+type outputRotate struct {
+	Addresses []string
+}
+
+var lightKDFFlag = cli.BoolFlag{
+	Name:  "lightkdf",
+	Usage: "re-encrypt with less secure, but faster key derivation parameters",
+}
+
+var commandRotate = cli.Command{
+	Name:      "rotate",
+	Usage:     "re-encrypt all keys of a keystore",
+	ArgsUsage: "<keydir|vault>",
+	Description: `
+Re-encrypt all the keys of a keystore directory or vault file with a new passphrase
+and the standard (or with --lightkdf the light) key derivation parameters.
+
+All keys must be encrypted with the same passphrase. Every key is decrypted before
+any of them is rewritten, so nothing is changed if any of them can't be decrypted.
+`,
+	Flags: []cli.Flag{
+		passphraseFlag,
+		newPassphraseFlag,
+		lightKDFFlag,
+		jsonFlag,
+	},
+	Action: func(ctx *cli.Context) error {
+		path := ctx.Args().First()
+		if path == "" {
+			utils.Fatalf("No keystore directory or vault file specified")
+		}
+		stat, err := os.Stat(path)
+		if err != nil {
+			utils.Fatalf("Failed to open keystore at '%s': %v", path, err)
+		}
+		scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+		if ctx.Bool(lightKDFFlag.Name) {
+			scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+		}
+		// Open the keystore, a regular file being a vault
+		var ks *keystore.KeyStore
+		if stat.IsDir() {
+			ks = keystore.NewKeyStore(path, scryptN, scryptP)
+		} else {
+			ks = keystore.NewVaultKeyStore(path, scryptN, scryptP)
+		}
+		accounts := ks.Accounts()
+		if len(accounts) == 0 {
+			utils.Fatalf("No keys found in '%s'", path)
+		}
+		// Re-encrypt all the keys with the new passphrase
+		passphrase := getPassphrase(ctx)
+		newPhrase := getNewPassphrase(ctx)
+
+		if err := ks.Reencrypt(passphrase, newPhrase, scryptN, scryptP); err != nil {
+			utils.Fatalf("Error re-encrypting keys: %v", err)
+		}
+		// Output some information.
+		var out outputRotate
+		for _, account := range accounts {
+			out.Addresses = append(out.Addresses, account.Address.Hex())
+		}
+		if ctx.Bool(jsonFlag.Name) {
+			mustPrintJSON(out)
+		} else {
+			for _, address := range out.Addresses {
+				fmt.Println("Re-encrypted:", address)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotate(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "ccmkey-test")
+	if err != nil {
+		t.Fatal("Can't create temporary directory:", err)
+	}
+	defer os.RemoveAll(tmpdir)
+
+	keyfile := filepath.Join(tmpdir, "the-keyfile")
+
+	// Create the key.
+	generate := runEthkey(t, "generate", keyfile)
+	generate.Expect(`
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Repeat passphrase: {{.InputLine "foobar"}}
+`)
+	_, matches := generate.ExpectRegexp(`Address: (0x[0-9a-fA-F]{40})\n`)
+	address := matches[1]
+	generate.ExpectExit()
+
+	// Rotating with a wrong passphrase must fail.
+	rotate := runEthkey(t, "rotate", "--lightkdf", tmpdir)
+	rotate.Expect(`
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "wrong"}}
+Please provide a new passphrase
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+`)
+	rotate.ExpectRegexp(`Fatal: Error re-encrypting keys: .*could not decrypt key with given passphrase`)
+	rotate.ExpectExit()
+
+	// Rotate the keystore to the new passphrase.
+	rotate = runEthkey(t, "rotate", "--lightkdf", tmpdir)
+	rotate.Expect(`
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Please provide a new passphrase
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+Re-encrypted: ` + address + `
+`)
+	rotate.ExpectExit()
+
+	// Ensure the key can be used with the new passphrase.
+	sign := runEthkey(t, "signmessage", keyfile, "test message")
+	sign.Expect(`
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar2"}}
+`)
+	sign.ExpectRegexp(`Signature: ([0-9a-f]+)\n`)
+	sign.ExpectExit()
+}
@@ -68,6 +68,21 @@ func getPassphrase(ctx *cli.Context) string {
 	return promptPassphrase(false)
 }
 
+// getNewPassphrase obtains a new passphrase given by the user. It first checks
+// the --newpasswordfile command line flag and ultimately prompts the user for a
+// passphrase with confirmation.
+func getNewPassphrase(ctx *cli.Context) string {
+	fmt.Println("Please provide a new passphrase")
+	if passFile := ctx.String(newPassphraseFlag.Name); passFile != "" {
+		content, err := ioutil.ReadFile(passFile)
+		if err != nil {
+			utils.Fatalf("Failed to read new passphrase file '%s': %v", passFile, err)
+		}
+		return strings.TrimRight(string(content), "\r\n")
+	}
+	return promptPassphrase(true)
+}
+
 // signHash is a helper function that calculates a hash for the given message
 // that can be safely used to calculate a signature from.
 //
@@ -116,7 +116,7 @@ var (
 	}
 	ruleFlag = cli.StringFlag{
 		Name:  "rules",
-		Usage: "Path to the rule file to auto-authorize requests with",
+		Usage: "Path to the rule file to auto-authorize requests with (JavaScript, or a JSON/YAML policy if ending in .json, .yaml or .yml)",
 	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
@@ -456,13 +456,25 @@ func signer(c *cli.Context) error {
 				if storedShasum != foundShaSum {
 					log.Warn("Rule hash not attested, disabling", "hash", foundShaSum, "attested", storedShasum)
 				} else {
-					// Initialize rules
-					ruleEngine, err := rules.NewRuleEvaluator(ui, jsStorage)
-					if err != nil {
-						utils.Fatalf(err.Error())
+					// Initialize rules, JSON and YAML files hold declarative policies
+					switch filepath.Ext(ruleFile) {
+					case ".json", ".yaml", ".yml":
+						ruleEngine, err := rules.NewPolicyEvaluator(ui, db, jsStorage)
+						if err != nil {
+							utils.Fatalf(err.Error())
+						}
+						if err := ruleEngine.Init(ruleJS); err != nil {
+							utils.Fatalf("Could not load policy: %v", err)
+						}
+						ui = ruleEngine
+					default:
+						ruleEngine, err := rules.NewRuleEvaluator(ui, jsStorage)
+						if err != nil {
+							utils.Fatalf(err.Error())
+						}
+						ruleEngine.Init(string(ruleJS))
+						ui = ruleEngine
 					}
-					ruleEngine.Init(string(ruleJS))
-					ui = ruleEngine
 					log.Info("Rule engine configured", "file", c.String(ruleFlag.Name))
 				}
 			}
@@ -530,7 +542,7 @@ func signer(c *cli.Context) error {
 			ipcapiURL = filepath.Join(configDir, "clef.ipc")
 		}
 
-		listener, _, err := rpc.StartIPCEndpoint(ipcapiURL, rpcAPI)
+		listener, _, err := rpc.StartIPCEndpoint(ipcapiURL, rpcAPI, rpc.Limits{}, false)
 		if err != nil {
 			utils.Fatalf("Could not start IPC api: %v", err)
 		}
@@ -0,0 +1,151 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package ccmtest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/forkid"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rlp"
+)
+
+// Chain is the blockchain the test suite checks the remote node against.
+// blocks[0] is the genesis block.
+type Chain struct {
+	genesis     core.Genesis
+	blocks      []*types.Block
+	chainConfig *params.ChainConfig
+}
+
+// loadChain takes the given chain.rlp file, and decodes and returns
+// the blocks from the file.
+func loadChain(chainfile string, genesisfile string) (*Chain, error) {
+	gblob, err := ioutil.ReadFile(genesisfile)
+	if err != nil {
+		return nil, err
+	}
+	var gen core.Genesis
+	if err := json.Unmarshal(gblob, &gen); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	if gen.Config == nil {
+		return nil, fmt.Errorf("genesis file has no chain configuration")
+	}
+	gblock := gen.ToBlock(nil)
+
+	fh, err := os.Open(chainfile)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(chainfile, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
+	}
+	stream := rlp.NewStream(reader, 0)
+	blocks := []*types.Block{gblock}
+	for i := 0; ; i++ {
+		var b types.Block
+		if err := stream.Decode(&b); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("at block index %d: %v", i, err)
+		}
+		if b.NumberU64() == 0 {
+			continue // skip the genesis if the export contains it
+		}
+		if b.ParentHash() != blocks[len(blocks)-1].Hash() {
+			return nil, fmt.Errorf("block %d (index %d) is not a child of the previous block", b.NumberU64(), i)
+		}
+		blocks = append(blocks, &b)
+	}
+	return &Chain{genesis: gen, blocks: blocks, chainConfig: gen.Config}, nil
+}
+
+// Len returns the number of blocks in the chain, including the genesis.
+func (c *Chain) Len() int {
+	return len(c.blocks)
+}
+
+// Head returns the last block of the chain.
+func (c *Chain) Head() *types.Block {
+	return c.blocks[len(c.blocks)-1]
+}
+
+// TD calculates the total difficulty of the chain up to and including the
+// block at the given height.
+func (c *Chain) TD(height int) *big.Int {
+	sum := new(big.Int)
+	for _, block := range c.blocks[:height+1] {
+		sum.Add(sum, block.Difficulty())
+	}
+	return sum
+}
+
+// ForkID returns the fork ID of the chain with its head at the given height.
+func (c *Chain) ForkID(height int) forkid.ID {
+	return forkid.NewIDFromConfig(c.chainConfig, c.blocks[0].Hash(), uint64(height))
+}
+
+// indexOf returns the height of the block with the given hash, or -1 if the
+// block isn't part of the chain.
+func (c *Chain) indexOf(hash common.Hash) int {
+	for i, block := range c.blocks {
+		if block.Hash() == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetHeaders answers a header query against the chain, limited to the first
+// n blocks. It mirrors the node-side query handling.
+func (c *Chain) GetHeaders(req GetBlockHeaders, n int) ([]*types.Header, error) {
+	if req.Amount < 1 {
+		return nil, fmt.Errorf("no block headers requested")
+	}
+	origin := int(req.Origin.Number)
+	if req.Origin.Hash != (common.Hash{}) {
+		if origin = c.indexOf(req.Origin.Hash); origin < 0 {
+			return nil, fmt.Errorf("unknown origin %x", req.Origin.Hash)
+		}
+	}
+	var headers []*types.Header
+	for i := origin; i >= 0 && i < n && uint64(len(headers)) < req.Amount; {
+		headers = append(headers, c.blocks[i].Header())
+		if req.Reverse {
+			i -= int(req.Skip) + 1
+		} else {
+			i += int(req.Skip) + 1
+		}
+	}
+	return headers, nil
+}
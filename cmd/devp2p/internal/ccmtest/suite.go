@@ -0,0 +1,370 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ccmtest implements a conformance test suite for the ccm wire protocol.
+package ccmtest
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/utesting"
+	"github.com/ccmchain/go-ccmchain/p2p"
+	"github.com/ccmchain/go-ccmchain/p2p/enode"
+	"github.com/ccmchain/go-ccmchain/params"
+)
+
+// timeout is the maximum time the suite waits for any expected message.
+var timeout = 20 * time.Second
+
+// maxRequestItems is the number of blocks covered by each bulk data request.
+const maxRequestItems = 8
+
+// Suite represents a structure used to test the ccm protocol of a node.
+type Suite struct {
+	Dest *enode.Node
+
+	chain *Chain
+}
+
+// NewSuite creates a test suite for the node at dest. The chain file holds the
+// blocks the node is expected to have, optionally followed by blocks it hasn't
+// seen yet, which are used to test block propagation.
+func NewSuite(dest *enode.Node, chainfile string, genesisfile string) (*Suite, error) {
+	chain, err := loadChain(chainfile, genesisfile)
+	if err != nil {
+		return nil, err
+	}
+	return &Suite{Dest: dest, chain: chain}, nil
+}
+
+// AllTests returns all test cases of the suite, in execution order. Note that
+// TestBroadcastBlock advances the remote chain and thus has to run last but one.
+func (s *Suite) AllTests() []utesting.Test {
+	return []utesting.Test{
+		{Name: "Status", Fn: s.TestStatus},
+		{Name: "GetBlockHeaders", Fn: s.TestGetBlockHeaders},
+		{Name: "GetBlockBodies", Fn: s.TestGetBlockBodies},
+		{Name: "GetReceipts", Fn: s.TestGetReceipts},
+		{Name: "GetNodeData", Fn: s.TestGetNodeData},
+		{Name: "BroadcastBlock", Fn: s.TestBroadcastBlock},
+		{Name: "BroadcastTransaction", Fn: s.TestBroadcastTransaction},
+	}
+}
+
+// TestStatus attempts to connect to the given node and exchange
+// a status message with it.
+func (s *Suite) TestStatus(t *utesting.T) {
+	conn, status := s.setup(t)
+	defer conn.Close()
+
+	t.Logf("remote head: %x (height %d)", status.Head, s.chain.indexOf(status.Head))
+}
+
+// TestGetBlockHeaders tests whccmer the given node can respond to
+// a GetBlockHeaders request correctly.
+func (s *Suite) TestGetBlockHeaders(t *utesting.T) {
+	conn, status := s.setup(t)
+	defer conn.Close()
+
+	head := s.chain.indexOf(status.Head)
+	requests := []GetBlockHeaders{
+		{Origin: HashOrNumber{Number: 0}, Amount: maxRequestItems},
+		{Origin: HashOrNumber{Hash: status.Head}, Amount: maxRequestItems, Reverse: true},
+		{Origin: HashOrNumber{Number: 1}, Amount: maxRequestItems / 2, Skip: 1},
+	}
+	for _, req := range requests {
+		want, err := s.chain.GetHeaders(req, head+1)
+		if err != nil {
+			t.Fatalf("invalid request %+v: %v", req, err)
+		}
+		if err := conn.Write(&req); err != nil {
+			t.Fatalf("could not write to connection: %v", err)
+		}
+		switch msg := conn.ReadAndServe(timeout).(type) {
+		case *BlockHeaders:
+			if len(*msg) != len(want) {
+				t.Fatalf("wrong number of headers for %+v: have %d, want %d", req, len(*msg), len(want))
+			}
+			for i, header := range *msg {
+				if header.Hash() != want[i].Hash() {
+					t.Fatalf("wrong header %d for %+v: have %x, want %x", i, req, header.Hash(), want[i].Hash())
+				}
+			}
+		default:
+			t.Fatalf("unexpected response to %+v: %s", req, pretty(msg))
+		}
+	}
+}
+
+// TestGetBlockBodies tests whccmer the given node can respond to
+// a GetBlockBodies request and that the response is accurate.
+func (s *Suite) TestGetBlockBodies(t *utesting.T) {
+	conn, status := s.setup(t)
+	defer conn.Close()
+
+	blocks := s.requestBlocks(status)
+	req := make(GetBlockBodies, len(blocks))
+	for i, block := range blocks {
+		req[i] = block.Hash()
+	}
+	if err := conn.Write(req); err != nil {
+		t.Fatalf("could not write to connection: %v", err)
+	}
+	switch msg := conn.ReadAndServe(timeout).(type) {
+	case *BlockBodies:
+		if len(*msg) != len(blocks) {
+			t.Fatalf("wrong number of bodies: have %d, want %d", len(*msg), len(blocks))
+		}
+		for i, body := range *msg {
+			header := blocks[i].Header()
+			if hash := types.DeriveSha(types.Transactions(body.Transactions)); hash != header.TxHash {
+				t.Errorf("wrong transactions in body of block %d: have root %x, want %x", header.Number, hash, header.TxHash)
+			}
+			if hash := types.CalcUncleHash(body.Uncles); hash != header.UncleHash {
+				t.Errorf("wrong uncles in body of block %d: have hash %x, want %x", header.Number, hash, header.UncleHash)
+			}
+		}
+	default:
+		t.Fatalf("unexpected response: %s", pretty(msg))
+	}
+}
+
+// TestGetReceipts tests whccmer the given node can respond to a
+// GetReceipts request with receipts matching the block headers.
+func (s *Suite) TestGetReceipts(t *utesting.T) {
+	conn, status := s.setup(t)
+	defer conn.Close()
+
+	blocks := s.requestBlocks(status)
+	req := make(GetReceipts, len(blocks))
+	for i, block := range blocks {
+		req[i] = block.Hash()
+	}
+	if err := conn.Write(req); err != nil {
+		t.Fatalf("could not write to connection: %v", err)
+	}
+	switch msg := conn.ReadAndServe(timeout).(type) {
+	case *Receipts:
+		if len(*msg) != len(blocks) {
+			t.Fatalf("wrong number of receipt lists: have %d, want %d", len(*msg), len(blocks))
+		}
+		for i, receipts := range *msg {
+			header := blocks[i].Header()
+			if hash := types.DeriveSha(types.Receipts(receipts)); hash != header.ReceiptHash {
+				t.Errorf("wrong receipts for block %d: have root %x, want %x", header.Number, hash, header.ReceiptHash)
+			}
+		}
+	default:
+		t.Fatalf("unexpected response: %s", pretty(msg))
+	}
+}
+
+// TestGetNodeData tests whccmer the given node can serve the state root of
+// its head block.
+func (s *Suite) TestGetNodeData(t *utesting.T) {
+	conn, status := s.setup(t)
+	defer conn.Close()
+
+	root := s.chain.blocks[s.chain.indexOf(status.Head)].Root()
+	if err := conn.Write(GetNodeData{root}); err != nil {
+		t.Fatalf("could not write to connection: %v", err)
+	}
+	switch msg := conn.ReadAndServe(timeout).(type) {
+	case *NodeData:
+		if len(*msg) != 1 {
+			t.Fatalf("wrong number of trie nodes: have %d, want 1", len(*msg))
+		}
+		if hash := crypto.Keccak256Hash((*msg)[0]); hash != root {
+			t.Fatalf("wrong trie node: have hash %x, want %x", hash, root)
+		}
+	default:
+		t.Fatalf("unexpected response: %s", pretty(msg))
+	}
+}
+
+// TestBroadcastBlock tests whccmer a block announced by one peer is imported
+// by the node and propagated to another peer.
+func (s *Suite) TestBroadcastBlock(t *utesting.T) {
+	sendConn, status := s.setup(t)
+	defer sendConn.Close()
+	recvConn, _ := s.setup(t)
+	defer recvConn.Close()
+
+	head := s.chain.indexOf(status.Head)
+	if head+1 >= s.chain.Len() {
+		t.Fatalf("chain file has no block beyond the remote head (height %d)", head)
+	}
+	block := s.chain.blocks[head+1]
+	if err := sendConn.Write(NewBlock{Block: block, TD: s.chain.TD(head + 1)}); err != nil {
+		t.Fatalf("could not write to connection: %v", err)
+	}
+	for {
+		switch msg := recvConn.ReadAndServe(timeout).(type) {
+		case *NewBlock:
+			if msg.Block.Hash() != block.Hash() {
+				t.Fatalf("wrong block propagated: have %x, want %x", msg.Block.Hash(), block.Hash())
+			}
+			return
+		case *NewBlockHashes:
+			for _, announce := range *msg {
+				if announce.Hash == block.Hash() && announce.Number == block.NumberU64() {
+					return
+				}
+			}
+			t.Fatalf("wrong block announced: %s", pretty(msg))
+		case *Transactions:
+			// Ignore transactions the node may be propagating meanwhile.
+		default:
+			t.Fatalf("unexpected message: %s", pretty(msg))
+		}
+	}
+}
+
+// TestBroadcastTransaction tests whccmer a transaction sent by one peer is
+// accepted into the node's pool and propagated to another peer. The
+// transaction is signed with the first genesis account that has a secret key.
+func (s *Suite) TestBroadcastTransaction(t *utesting.T) {
+	sendConn, status := s.setup(t)
+	defer sendConn.Close()
+	recvConn, _ := s.setup(t)
+	defer recvConn.Close()
+
+	tx, err := s.makeTransaction(s.chain.indexOf(status.Head))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sendConn.Write(Transactions{tx}); err != nil {
+		t.Fatalf("could not write to connection: %v", err)
+	}
+	for {
+		switch msg := recvConn.ReadAndServe(timeout).(type) {
+		case *Transactions:
+			for _, recv := range *msg {
+				if recv.Hash() == tx.Hash() {
+					return
+				}
+			}
+		case *NewBlock, *NewBlockHashes:
+			// Ignore block propagation from earlier tests.
+		default:
+			t.Fatalf("unexpected message: %s", pretty(msg))
+		}
+	}
+}
+
+// setup dials the node and performs the devp2p and ccm handshakes. The test
+// fails if either of them doesn't succeed.
+func (s *Suite) setup(t *utesting.T) (*Conn, *Status) {
+	conn, err := s.dial()
+	if err != nil {
+		t.Fatalf("could not dial: %v", err)
+	}
+	if _, err := conn.handshake(timeout); err != nil {
+		conn.Close()
+		t.Fatalf("handshake failed: %v", err)
+	}
+	status, err := conn.statusExchange(s.chain, timeout)
+	if err != nil {
+		conn.Close()
+		t.Fatalf("status exchange failed: %v", err)
+	}
+	return conn, status
+}
+
+// dial attempts to dial the given node and perform the RLPx encryption
+// handshake with it.
+func (s *Suite) dial() (*Conn, error) {
+	var conn Conn
+
+	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", s.Dest.IP(), s.Dest.TCP()))
+	if err != nil {
+		return nil, err
+	}
+	conn.fd = fd
+	conn.RLPXConn = p2p.NewRLPXConn(fd)
+	if conn.ourKey, err = crypto.GenerateKey(); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if _, err := conn.Handshake(conn.ourKey, s.Dest.Pubkey()); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// requestBlocks returns the blocks queried by the bulk data tests, starting
+// at the first block after genesis.
+func (s *Suite) requestBlocks(status *Status) []*types.Block {
+	end := s.chain.indexOf(status.Head) + 1
+	if end > maxRequestItems+1 {
+		end = maxRequestItems + 1
+	}
+	return s.chain.blocks[1:end]
+}
+
+// makeTransaction creates a value transfer from a genesis account with a known
+// secret key, using the next nonce of that account at the given height.
+func (s *Suite) makeTransaction(head int) (*types.Transaction, error) {
+	var (
+		key  *ecdsa.PrivateKey
+		from common.Address
+	)
+	for addr, account := range s.chain.genesis.Alloc {
+		if len(account.PrivateKey) == 0 {
+			continue
+		}
+		k, err := crypto.ToECDSA(account.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret key of genesis account %x: %v", addr, err)
+		}
+		key, from = k, addr
+		break
+	}
+	if key == nil {
+		return nil, fmt.Errorf("genesis has no account with a secret key")
+	}
+	nonce := s.chain.genesis.Alloc[from].Nonce
+	for _, block := range s.chain.blocks[1 : head+1] {
+		signer := types.MakeSigner(s.chain.chainConfig, block.Number())
+		for _, tx := range block.Transactions() {
+			if sender, err := types.Sender(signer, tx); err == nil && sender == from {
+				nonce++
+			}
+		}
+	}
+	var (
+		signer   = types.MakeSigner(s.chain.chainConfig, big.NewInt(int64(head+1)))
+		gasPrice = big.NewInt(params.GWei)
+		tx       = types.NewTransaction(nonce, from, big.NewInt(1), params.TxGas, gasPrice, nil)
+	)
+	return types.SignTx(tx, signer, key)
+}
+
+// pretty formats a message for test failure output.
+func pretty(msg Message) string {
+	if err, ok := msg.(*Error); ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("%T %+v", msg, msg)
+}
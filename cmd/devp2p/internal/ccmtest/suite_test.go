@@ -0,0 +1,162 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package ccmtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccm"
+	"github.com/ccmchain/go-ccmchain/ccm/downloader"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/utesting"
+	"github.com/ccmchain/go-ccmchain/node"
+	"github.com/ccmchain/go-ccmchain/p2p"
+	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rlp"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = new(big.Int).Mul(big.NewInt(1000), big.NewInt(params.Ccmchain))
+)
+
+func TestCcmSuite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ccmtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	genesis, blocks := generateTestChain()
+	chainfile, genesisfile := writeTestChain(t, dir, genesis, blocks)
+
+	// Start a node which knows all but the last block, which is
+	// used to test block propagation.
+	n, err := runNode(genesis, blocks[:len(blocks)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.Stop()
+
+	suite, err := NewSuite(n.Server().Self(), chainfile, genesisfile)
+	if err != nil {
+		t.Fatalf("could not create test suite: %v", err)
+	}
+	timeout = 5 * time.Second
+	for _, result := range utesting.RunTests(suite.AllTests(), nil) {
+		if result.Failed {
+			t.Errorf("test %q failed:\n%s", result.Name, result.Output)
+		}
+	}
+}
+
+// generateTestChain creates a genesis and a chain of blocks on top of it. Some
+// of the blocks contain transactions so there are receipts to check.
+func generateTestChain() (*core.Genesis, []*types.Block) {
+	db := rawdb.NewMemoryDatabase()
+	config := params.AllEthashProtocolChanges
+	genesis := &core.Genesis{
+		Config:     config,
+		Alloc:      core.GenesisAlloc{testAddr: {Balance: testBalance, PrivateKey: crypto.FromECDSA(testKey)}},
+		ExtraData:  []byte("test genesis"),
+		Difficulty: big.NewInt(131072),
+		Timestamp:  9000,
+	}
+	signer := types.MakeSigner(config, common.Big0)
+	generate := func(i int, g *core.BlockGen) {
+		g.OffsetTime(5)
+		g.SetExtra([]byte("test"))
+		if i%2 == 0 {
+			tx, _ := types.SignTx(types.NewTransaction(g.TxNonce(testAddr), common.Address{0x01}, big.NewInt(1), params.TxGas, big.NewInt(params.GWei), nil), signer, testKey)
+			g.AddTx(tx)
+		}
+	}
+	gblock := genesis.ToBlock(db)
+	blocks, _ := core.GenerateChain(config, gblock, ccmash.NewFaker(), db, 10, generate)
+	return genesis, append([]*types.Block{gblock}, blocks...)
+}
+
+// writeTestChain stores the genesis specification and the RLP encoded chain in
+// the given directory.
+func writeTestChain(t *testing.T, dir string, genesis *core.Genesis, blocks []*types.Block) (string, string) {
+	chainfile := filepath.Join(dir, "chain.rlp")
+	fh, err := os.Create(chainfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fh.Close()
+	for _, block := range blocks[1:] {
+		if err := rlp.Encode(fh, block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	genesisfile := filepath.Join(dir, "genesis.json")
+	blob, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(genesisfile, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return chainfile, genesisfile
+}
+
+// runNode starts an in-process node with the given chain imported.
+func runNode(genesis *core.Genesis, blocks []*types.Block) (*node.Node, error) {
+	n, err := node.New(&node.Config{
+		P2P: p2p.Config{
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			NoDial:      true,
+			MaxPeers:    10,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var ccmservice *ccm.Ccmchain
+	n.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		config := &ccm.Config{
+			Genesis:   genesis,
+			NetworkId: genesis.Config.ChainID.Uint64(),
+			SyncMode:  downloader.FullSync,
+		}
+		config.Ethash.PowMode = ccmash.ModeFake
+		ccmservice, err = ccm.New(ctx, config)
+		return ccmservice, err
+	})
+	if err := n.Start(); err != nil {
+		return nil, err
+	}
+	if _, err := ccmservice.BlockChain().InsertChain(blocks[1:]); err != nil {
+		n.Stop()
+		return nil, err
+	}
+	return n, nil
+}
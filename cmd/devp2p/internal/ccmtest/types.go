@@ -0,0 +1,376 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package ccmtest
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"reflect"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/forkid"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/p2p"
+	"github.com/ccmchain/go-ccmchain/rlp"
+)
+
+// Message is implemented by all the wire messages understood by the test suite.
+type Message interface {
+	Code() int
+}
+
+// Error is returned by Conn.Read when a message couldn't be received or decoded.
+// It implements Message so read failures can be reported like any other
+// unexpected message.
+type Error struct {
+	err error
+}
+
+func (e *Error) Unwrap() error  { return e.err }
+func (e *Error) Error() string  { return e.err.Error() }
+func (e *Error) Code() int      { return -1 }
+func (e *Error) String() string { return e.Error() }
+
+func errorf(format string, args ...interface{}) *Error {
+	return &Error{fmt.Errorf(format, args...)}
+}
+
+// Hello is the RLP structure of the protocol handshake.
+type Hello struct {
+	Version    uint64
+	Name       string
+	Caps       []p2p.Cap
+	ListenPort uint64
+	ID         []byte // secp256k1 public key
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+func (h Hello) Code() int { return 0x00 }
+
+// Disconnect is the RLP structure for a disconnect message.
+type Disconnect struct {
+	Reason p2p.DiscReason
+}
+
+func (d Disconnect) Code() int { return 0x01 }
+
+// Ping is the devp2p keepalive request.
+type Ping struct{}
+
+func (p Ping) Code() int { return 0x02 }
+
+// Pong is the reply to Ping.
+type Pong struct{}
+
+func (p Pong) Code() int { return 0x03 }
+
+// baseProtocolLength is the number of message codes reserved by devp2p. The
+// ccm protocol messages are offset by this value on the wire.
+const baseProtocolLength = 16
+
+// Status is the network packet for the status message for ccm/64 and later.
+type Status struct {
+	ProtocolVersion uint32
+	NetworkID       uint64
+	TD              *big.Int
+	Head            common.Hash
+	Genesis         common.Hash
+	ForkID          forkid.ID
+}
+
+func (s Status) Code() int { return baseProtocolLength + 0x00 }
+
+// NewBlockHashes is the network packet for the block announcements.
+type NewBlockHashes []struct {
+	Hash   common.Hash // Hash of one particular block being announced
+	Number uint64      // Number of one particular block being announced
+}
+
+func (nbh NewBlockHashes) Code() int { return baseProtocolLength + 0x01 }
+
+// Transactions is the network packet for transaction propagation.
+type Transactions []*types.Transaction
+
+func (t Transactions) Code() int { return baseProtocolLength + 0x02 }
+
+// GetBlockHeaders represents a block header query.
+type GetBlockHeaders struct {
+	Origin  HashOrNumber // Block from which to retrieve headers
+	Amount  uint64       // Maximum number of headers to retrieve
+	Skip    uint64       // Blocks to skip between consecutive headers
+	Reverse bool         // Query direction (false = rising towards latest, true = falling towards genesis)
+}
+
+func (g GetBlockHeaders) Code() int { return baseProtocolLength + 0x03 }
+
+// HashOrNumber is a combined field for specifying an origin block.
+type HashOrNumber struct {
+	Hash   common.Hash // Block hash from which to retrieve headers (excludes Number)
+	Number uint64      // Block hash from which to retrieve headers (excludes Hash)
+}
+
+// EncodeRLP is a specialized encoder for HashOrNumber to encode only one of the
+// two contained union fields.
+func (hn *HashOrNumber) EncodeRLP(w io.Writer) error {
+	if hn.Hash == (common.Hash{}) {
+		return rlp.Encode(w, hn.Number)
+	}
+	if hn.Number != 0 {
+		return fmt.Errorf("both origin hash (%x) and number (%d) provided", hn.Hash, hn.Number)
+	}
+	return rlp.Encode(w, hn.Hash)
+}
+
+// DecodeRLP is a specialized decoder for HashOrNumber to decode the contents
+// into either a block hash or a block number.
+func (hn *HashOrNumber) DecodeRLP(s *rlp.Stream) error {
+	_, size, _ := s.Kind()
+	origin, err := s.Raw()
+	if err == nil {
+		switch {
+		case size == 32:
+			err = rlp.DecodeBytes(origin, &hn.Hash)
+		case size <= 8:
+			err = rlp.DecodeBytes(origin, &hn.Number)
+		default:
+			err = fmt.Errorf("invalid input size %d for origin", size)
+		}
+	}
+	return err
+}
+
+// BlockHeaders is the network packet for block header distribution.
+type BlockHeaders []*types.Header
+
+func (bh BlockHeaders) Code() int { return baseProtocolLength + 0x04 }
+
+// GetBlockBodies represents a GetBlockBodies request.
+type GetBlockBodies []common.Hash
+
+func (gbb GetBlockBodies) Code() int { return baseProtocolLength + 0x05 }
+
+// BlockBodies is the network packet for block content distribution.
+type BlockBodies []*types.Body
+
+func (bb BlockBodies) Code() int { return baseProtocolLength + 0x06 }
+
+// NewBlock is the network packet for the block propagation message.
+type NewBlock struct {
+	Block *types.Block
+	TD    *big.Int
+}
+
+func (nb NewBlock) Code() int { return baseProtocolLength + 0x07 }
+
+// GetNodeData represents a state trie node request.
+type GetNodeData []common.Hash
+
+func (gnd GetNodeData) Code() int { return baseProtocolLength + 0x0d }
+
+// NodeData is the network packet for state trie node distribution.
+type NodeData [][]byte
+
+func (nd NodeData) Code() int { return baseProtocolLength + 0x0e }
+
+// GetReceipts represents a block receipts request.
+type GetReceipts []common.Hash
+
+func (gr GetReceipts) Code() int { return baseProtocolLength + 0x0f }
+
+// Receipts is the network packet for block receipts distribution.
+type Receipts [][]*types.Receipt
+
+func (r Receipts) Code() int { return baseProtocolLength + 0x10 }
+
+// Conn represents an individual connection with a peer.
+type Conn struct {
+	*p2p.RLPXConn
+	fd         net.Conn
+	ourKey     *ecdsa.PrivateKey
+	ccmVersion uint
+}
+
+// Read reads the next message from the connection and decodes it. Failures are
+// returned as *Error.
+func (c *Conn) Read() Message {
+	raw, err := c.ReadMsg()
+	if err != nil {
+		return errorf("could not read from connection: %v", err)
+	}
+	defer raw.Discard()
+
+	var msg Message
+	switch int(raw.Code) {
+	case (Hello{}).Code():
+		msg = new(Hello)
+	case (Disconnect{}).Code():
+		msg = new(Disconnect)
+	case (Ping{}).Code():
+		msg = new(Ping)
+	case (Pong{}).Code():
+		msg = new(Pong)
+	case (Status{}).Code():
+		msg = new(Status)
+	case (NewBlockHashes{}).Code():
+		msg = new(NewBlockHashes)
+	case (Transactions{}).Code():
+		msg = new(Transactions)
+	case (GetBlockHeaders{}).Code():
+		msg = new(GetBlockHeaders)
+	case (BlockHeaders{}).Code():
+		msg = new(BlockHeaders)
+	case (GetBlockBodies{}).Code():
+		msg = new(GetBlockBodies)
+	case (BlockBodies{}).Code():
+		msg = new(BlockBodies)
+	case (NewBlock{}).Code():
+		msg = new(NewBlock)
+	case (GetNodeData{}).Code():
+		msg = new(GetNodeData)
+	case (NodeData{}).Code():
+		msg = new(NodeData)
+	case (GetReceipts{}).Code():
+		msg = new(GetReceipts)
+	case (Receipts{}).Code():
+		msg = new(Receipts)
+	default:
+		return errorf("invalid message code: %d", raw.Code)
+	}
+	// Ping and Pong carry an empty list, there's nothing to decode.
+	switch msg.(type) {
+	case *Ping, *Pong:
+		return msg
+	}
+	if err := raw.Decode(msg); err != nil {
+		return errorf("could not rlp decode message %T: %v", msg, err)
+	}
+	return msg
+}
+
+// ReadAndServe reads the next non-keepalive message, answering any pings the
+// remote sends while waiting. The whole operation is bounded by timeout.
+func (c *Conn) ReadAndServe(timeout time.Duration) Message {
+	start := time.Now()
+	for time.Since(start) < timeout {
+		c.fd.SetReadDeadline(start.Add(timeout))
+		switch msg := c.Read().(type) {
+		case *Ping:
+			c.Write(Pong{})
+		default:
+			return msg
+		}
+	}
+	return errorf("no message received within %v", timeout)
+}
+
+// Write encodes msg and sends it over the connection.
+func (c *Conn) Write(msg Message) error {
+	var payload interface{} = msg
+	switch msg.(type) {
+	case Ping, *Ping, Pong, *Pong:
+		payload = []interface{}{}
+	}
+	return p2p.Send(c, uint64(msg.Code()), payload)
+}
+
+// handshake performs the devp2p protocol handshake, advertising the ccm
+// capabilities understood by the suite.
+func (c *Conn) handshake(timeout time.Duration) (*Hello, error) {
+	pub := crypto.FromECDSAPub(&c.ourKey.PublicKey)[1:]
+	ourHello := &Hello{
+		Version: 5,
+		Caps:    []p2p.Cap{{Name: "ccm", Version: 64}},
+		ID:      pub,
+	}
+	if err := c.Write(ourHello); err != nil {
+		return nil, errorf("could not write to connection: %v", err)
+	}
+	c.fd.SetReadDeadline(time.Now().Add(timeout))
+	switch msg := c.Read().(type) {
+	case *Hello:
+		// Enable snappy only if the remote speaks p2p/5 as well.
+		if msg.Version >= 5 {
+			c.SetSnappy(true)
+		}
+		c.ccmVersion = negotiateCcmVersion(ourHello.Caps, msg.Caps)
+		if c.ccmVersion == 0 {
+			return nil, errorf("no common ccm protocol version, remote has %v", msg.Caps)
+		}
+		return msg, nil
+	default:
+		return nil, errorf("bad handshake: %#v", msg)
+	}
+}
+
+// negotiateCcmVersion returns the highest ccm version supported by both sides.
+func negotiateCcmVersion(ours, theirs []p2p.Cap) uint {
+	var highest uint
+	for _, capability := range theirs {
+		if capability.Name != "ccm" {
+			continue
+		}
+		for _, our := range ours {
+			if our == capability && our.Version > highest {
+				highest = our.Version
+			}
+		}
+	}
+	return highest
+}
+
+// statusExchange performs the ccm status handshake. The remote status is
+// validated against the chain, and a status mirroring the remote's head is
+// sent back so the node doesn't attempt to sync from us.
+func (c *Conn) statusExchange(chain *Chain, timeout time.Duration) (*Status, error) {
+	msg := c.ReadAndServe(timeout)
+	status, ok := msg.(*Status)
+	if !ok {
+		return nil, errorf("bad status message: %#v", msg)
+	}
+	if status.ProtocolVersion != uint32(c.ccmVersion) {
+		return nil, errorf("wrong protocol version: have %d, want %d", status.ProtocolVersion, c.ccmVersion)
+	}
+	if status.Genesis != chain.blocks[0].Hash() {
+		return nil, errorf("wrong genesis: have %x, want %x", status.Genesis, chain.blocks[0].Hash())
+	}
+	head := chain.indexOf(status.Head)
+	if head < 0 {
+		return nil, errorf("remote head %x not in chain", status.Head)
+	}
+	if want := chain.ForkID(head); !reflect.DeepEqual(status.ForkID, want) {
+		return nil, errorf("wrong fork id: have %v, want %v", status.ForkID, want)
+	}
+	ourStatus := &Status{
+		ProtocolVersion: status.ProtocolVersion,
+		NetworkID:       status.NetworkID,
+		TD:              chain.TD(head),
+		Head:            status.Head,
+		Genesis:         status.Genesis,
+		ForkID:          status.ForkID,
+	}
+	if err := c.Write(ourStatus); err != nil {
+		return nil, errorf("could not write to connection: %v", err)
+	}
+	return status, nil
+}
@@ -57,6 +57,9 @@ func init() {
 	app.Commands = []cli.Command{
 		enrdumpCommand,
 		discv4Command,
+		dnsCommand,
+		rlpxCommand,
+		ccmtestCommand,
 	}
 }
 
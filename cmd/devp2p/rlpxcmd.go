@@ -0,0 +1,112 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of go-ccmchain.
+//
+// go-ccmchain is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ccmchain is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ccmchain. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/ccmchain/go-ccmchain/cmd/devp2p/internal/ccmtest"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/utesting"
+	"github.com/ccmchain/go-ccmchain/p2p"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	rlpxCommand = cli.Command{
+		Name:  "rlpx",
+		Usage: "RLPx Commands",
+		Subcommands: []cli.Command{
+			rlpxPingCommand,
+		},
+	}
+	rlpxPingCommand = cli.Command{
+		Name:   "ping",
+		Usage:  "Perform a RLPx handshake",
+		Action: rlpxPing,
+	}
+	ccmtestCommand = cli.Command{
+		Name:      "ccmtest",
+		Usage:     "Runs ccm protocol conformance tests against a node",
+		ArgsUsage: "<node> <chain.rlp> <genesis.json>",
+		Action:    runCcmTest,
+	}
+)
+
+func rlpxPing(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("missing node as command-line argument")
+	}
+	n, err := parseNode(ctx.Args()[0])
+	if err != nil {
+		return err
+	}
+	fd, err := net.Dial("tcp", fmt.Sprintf("%v:%d", n.IP(), n.TCP()))
+	if err != nil {
+		return err
+	}
+	conn := p2p.NewRLPXConn(fd)
+	defer conn.Close()
+
+	ourKey, _ := crypto.GenerateKey()
+	if _, err := conn.Handshake(ourKey, n.Pubkey()); err != nil {
+		return err
+	}
+	msg, err := conn.ReadMsg()
+	if err != nil {
+		return err
+	}
+	switch int(msg.Code) {
+	case (ccmtest.Hello{}).Code():
+		var h ccmtest.Hello
+		if err := msg.Decode(&h); err != nil {
+			return fmt.Errorf("invalid handshake: %v", err)
+		}
+		fmt.Printf("%+v\n", h)
+	case (ccmtest.Disconnect{}).Code():
+		var d ccmtest.Disconnect
+		if err := msg.Decode(&d); err != nil {
+			return fmt.Errorf("invalid disconnect message: %v", err)
+		}
+		return fmt.Errorf("received disconnect message: %v", d.Reason)
+	default:
+		return fmt.Errorf("invalid message code %d, expected handshake (code zero)", msg.Code)
+	}
+	return nil
+}
+
+func runCcmTest(ctx *cli.Context) error {
+	if ctx.NArg() != 3 {
+		return fmt.Errorf("need node, chain file and genesis file as arguments")
+	}
+	n, err := parseNode(ctx.Args()[0])
+	if err != nil {
+		return err
+	}
+	suite, err := ccmtest.NewSuite(n, ctx.Args()[1], ctx.Args()[2])
+	if err != nil {
+		return err
+	}
+	results := utesting.RunTests(suite.AllTests(), os.Stdout)
+	if fails := utesting.CountFailures(results); fails > 0 {
+		return fmt.Errorf("%v of %v tests passed", len(results)-fails, len(results))
+	}
+	fmt.Printf("all tests passed\n")
+	return nil
+}
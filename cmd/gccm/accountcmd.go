@@ -52,6 +52,7 @@ passwordfile as argument containing the wallet password in plaintext.`,
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
 				},
@@ -97,6 +98,7 @@ Make sure you backup your keys regularly.`,
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
 				},
 				Description: `
 Print a short summary of all accounts`,
@@ -108,6 +110,7 @@ Print a short summary of all accounts`,
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
 				},
@@ -134,6 +137,7 @@ password to file or expose in any other way.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
 					utils.LightKDFFlag,
 				},
 				Description: `
@@ -153,6 +157,34 @@ For non-interactive use the passphrase can be specified with the --password flag
 
 Since only one password can be given, only format update can be performed,
 changing your password is only possible interactively.
+`,
+			},
+			{
+				Name:   "reencrypt",
+				Usage:  "Re-encrypt all accounts with a new password",
+				Action: utils.MigrateFlags(accountReencrypt),
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
+					utils.PasswordFileFlag,
+					utils.LightKDFFlag,
+				},
+				Description: `
+    gccm account reencrypt
+
+Re-encrypts all the accounts in the keystore with a new password, using the
+current key derivation parameters (see --lightkdf).
+
+All accounts must be unlockable with the same current password. Every key is
+decrypted before any of them is rewritten, so if any key can't be decrypted,
+nothing is changed.
+
+This same command can therefore be used to migrate all accounts to new key
+derivation parameters, by giving the same password twice.
+
+For non-interactive use the passwords can be specified with the --password flag,
+with the current password on the first line and the new one on the second.
 `,
 			},
 			{
@@ -162,6 +194,7 @@ changing your password is only possible interactively.
 				Flags: []cli.Flag{
 					utils.DataDirFlag,
 					utils.KeyStoreDirFlag,
+					utils.KeyStoreVaultFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
 				},
@@ -307,8 +340,12 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
-	account, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
-
+	var account accounts.Account
+	if vault := cfg.Node.KeyStoreVault; vault != "" {
+		account, err = keystore.NewVaultKeyStore(vault, scryptN, scryptP).NewAccount(password)
+	} else {
+		account, err = keystore.StoreKey(keydir, password, scryptN, scryptP)
+	}
 	if err != nil {
 		utils.Fatalf("Failed to create account: %v", err)
 	}
@@ -341,6 +378,30 @@ func accountUpdate(ctx *cli.Context) error {
 	return nil
 }
 
+// accountReencrypt re-encrypts all the accounts in the keystore with a new
+// pass-phrase and the configured key derivation parameters.
+func accountReencrypt(ctx *cli.Context) error {
+	stack, cfg := makeConfigNode(ctx)
+	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+
+	scryptN, scryptP, _, err := cfg.Node.AccountConfig()
+	if err != nil {
+		utils.Fatalf("Failed to read configuration: %v", err)
+	}
+	if len(ks.Accounts()) == 0 {
+		utils.Fatalf("No accounts to re-encrypt")
+	}
+	passwords := utils.MakePasswordList(ctx)
+	oldPassword := getPassPhrase("Please enter the current password of the accounts.", false, 0, passwords)
+	newPassword := getPassPhrase("Please give a new password. Do not forget this password.", true, 1, passwords)
+
+	if err := ks.Reencrypt(oldPassword, newPassword, scryptN, scryptP); err != nil {
+		utils.Fatalf("Could not re-encrypt the accounts: %v", err)
+	}
+	fmt.Printf("Re-encrypted %d accounts\n", len(ks.Accounts()))
+	return nil
+}
+
 func importWallet(ctx *cli.Context) error {
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
@@ -88,6 +88,51 @@ Path of the secret key file: .*UTC--.+--[0-9a-f]{40}
 `)
 }
 
+func TestAccountVault(t *testing.T) {
+	datadir := tmpdir(t)
+	vault := filepath.Join(datadir, "vault.json")
+
+	gccm := runGccm(t, "account", "new", "--datadir", datadir, "--keystore.vault", vault, "--lightkdf")
+	gccm.Expect(`
+Your new account is locked with a password. Please give a password. Do not forget this password.
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Repeat passphrase: {{.InputLine "foobar"}}
+
+Your new key was generated
+`)
+	_, matches := gccm.ExpectRegexp(`
+Public address of the key:   0x[0-9a-fA-F]{40}
+Path of the secret key file: (.*vault\.json.UTC--.+--[0-9a-f]{40})
+
+- You can share your public address with anyone. Others need it to interact with you.
+- You must NEVER share the secret key with anyone! The key controls access to your funds!
+- You must BACKUP your key file! Without the key, it's impossible to access account funds!
+- You must REMEMBER your password! Without the password, it's impossible to decrypt the key!
+`)
+	gccm.ExpectExit()
+
+	// The key must be kept in the vault, not in the keystore directory
+	if files, _ := ioutil.ReadDir(filepath.Join(datadir, "keystore")); len(files) != 0 {
+		t.Errorf("expected no key files in keystore directory, found %d files", len(files))
+	}
+	if len(matches) < 2 {
+		t.Fatalf("key path not reported")
+	}
+	path := matches[1]
+	address := path[len(path)-40:]
+
+	gccm = runGccm(t, "account", "list", "--datadir", datadir, "--keystore.vault", vault)
+	gccm.Expect(`
+Account #0: {` + address + `} keystore://` + path + `
+`)
+	gccm.ExpectExit()
+
+	// Without the vault flag the account must not be listed
+	gccm = runGccm(t, "account", "list", "--datadir", datadir)
+	gccm.ExpectExit()
+}
+
 func TestAccountNewBadRepeat(t *testing.T) {
 	gccm := runGccm(t, "account", "new", "--lightkdf")
 	defer gccm.ExpectExit()
@@ -116,6 +161,36 @@ Repeat passphrase: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountReencrypt(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	gccm := runGccm(t, "account", "reencrypt", "--datadir", datadir, "--lightkdf")
+	defer gccm.ExpectExit()
+	gccm.Expect(`
+Please enter the current password of the accounts.
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Please give a new password. Do not forget this password.
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+Re-encrypted 3 accounts
+`)
+}
+
+func TestAccountReencryptWrongPassword(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	gccm := runGccm(t, "account", "reencrypt", "--datadir", datadir, "--lightkdf")
+	defer gccm.ExpectExit()
+	gccm.Expect(`
+Please enter the current password of the accounts.
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "wrong"}}
+Please give a new password. Do not forget this password.
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+`)
+	gccm.ExpectRegexp(`Fatal: Could not re-encrypt the accounts: failed to decrypt .*: could not decrypt key with given passphrase`)
+}
+
 func TestWalletImport(t *testing.T) {
 	gccm := runGccm(t, "wallet", "import", "--lightkdf", "testdata/guswallet.json")
 	defer gccm.ExpectExit()
@@ -32,10 +32,13 @@ import (
 	"github.com/ccmchain/go-ccmchain/core"
 	"github.com/ccmchain/go-ccmchain/core/rawdb"
 	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/core/state/pruner"
 	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/ccm"
 	"github.com/ccmchain/go-ccmchain/ccm/downloader"
 	"github.com/ccmchain/go-ccmchain/event"
 	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/params"
 	"github.com/ccmchain/go-ccmchain/trie"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -188,6 +191,61 @@ Use "ccmchain dump 0" to dump the genesis block.`,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 	}
+	logIndexCommand = cli.Command{
+		Action:    utils.MigrateFlags(backfillLogIndex),
+		Name:      "backfill-logindex",
+		Usage:     "Build the persistent log index for the already imported chain",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.AncientFlag,
+			utils.CacheFlag,
+			utils.TestnetFlag,
+			utils.RinkebyFlag,
+			utils.GoerliFlag,
+			utils.SyncModeFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+gccm backfill-logindex
+will index the addresses and topics of the logs in all the confirmed sections of
+the local chain, which would otherwise only be done in the background by a node
+running with --logindex. Sections already indexed are skipped.`,
+	}
+	snapshotCommand = cli.Command{
+		Name:     "snapshot",
+		Usage:    "A set of commands based on the state snapshot",
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The snapshot commands operate on the state of an offline node.`,
+		Subcommands: []cli.Command{
+			{
+				Name:      "prune-state",
+				Usage:     "Prune stale state data, retaining only the recent states",
+				ArgsUsage: " ",
+				Action:    utils.MigrateFlags(pruneState),
+				Category:  "BLOCKCHAIN COMMANDS",
+				Flags: []cli.Flag{
+					utils.DataDirFlag,
+					utils.AncientFlag,
+					utils.TestnetFlag,
+					utils.RinkebyFlag,
+					utils.GoerliFlag,
+					utils.PruneRetainFlag,
+					utils.PruneBloomSizeFlag,
+				},
+				Description: `
+gccm snapshot prune-state
+will delete all the trie nodes and contract codes that are not reachable from
+the state of the latest --prune.retain canonical blocks (those which are still
+present in the database) or from the genesis state.
+
+The retained state is tracked in a bloom filter of --prune.bloomsize megabytes,
+a bigger filter retaining fewer stale entries. The node must be stopped while
+pruning and the command refuses to run if the head state is missing.`,
+			},
+		},
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -552,6 +610,74 @@ func inspect(ctx *cli.Context) error {
 	return rawdb.InspectDatabase(chainDb)
 }
 
+// backfillLogIndex builds the address/topic log index for all the confirmed
+// sections of the local chain.
+func backfillLogIndex(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chain, chainDb := utils.MakeChain(ctx, stack)
+	defer chainDb.Close()
+	defer chain.Stop()
+
+	// Figure out how many sections the chain can be indexed up to
+	var (
+		head     = chain.CurrentBlock().NumberU64()
+		sections uint64
+	)
+	if head+1 >= params.BloomConfirms {
+		sections = (head + 1 - params.BloomConfirms) / params.BloomBitsBlocks
+	}
+	indexer := ccm.NewLogIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms)
+	defer indexer.Close()
+
+	start := time.Now()
+	indexer.Start(chain)
+
+	// Wait until all the sections are indexed, bailing out if progress stalls
+	var (
+		last     uint64
+		progress = time.Now()
+	)
+	for {
+		done, _, _ := indexer.Sections()
+		if done >= sections {
+			break
+		}
+		if done != last {
+			last, progress = done, time.Now()
+			log.Info("Backfilling log index", "sections", done, "total", sections, "elapsed", common.PrettyDuration(time.Since(start)))
+		}
+		if time.Since(progress) > time.Minute {
+			utils.Fatalf("Log index backfill stalled at section %d/%d", done, sections)
+		}
+		time.Sleep(time.Second)
+	}
+	log.Info("Log index backfill successful", "sections", sections, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// pruneState deletes all the stale state data from the chain database, keeping
+// only the state of the recent canonical blocks and the genesis.
+func pruneState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	chainDb := utils.MakeChainDatabase(ctx, stack)
+	defer chainDb.Close()
+
+	pruner, err := pruner.NewPruner(chainDb, ctx.Uint64(utils.PruneBloomSizeFlag.Name))
+	if err != nil {
+		utils.Fatalf("Failed to create state pruner: %v", err)
+	}
+	start := time.Now()
+	if err := pruner.Prune(ctx.Uint64(utils.PruneRetainFlag.Name)); err != nil {
+		utils.Fatalf("Failed to prune state: %v", err)
+	}
+	log.Info("State pruning successful", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -44,6 +44,7 @@ type alccmGenesisSpec struct {
 		EIP158ForkBlock         hexutil.Uint64         `json:"EIP158ForkBlock"`
 		ByzantiumForkBlock      hexutil.Uint64         `json:"byzantiumForkBlock"`
 		ConstantinopleForkBlock hexutil.Uint64         `json:"constantinopleForkBlock"`
+		IstanbulForkBlock       hexutil.Uint64         `json:"istanbulForkBlock"`
 		MinGasLimit             hexutil.Uint64         `json:"minGasLimit"`
 		MaxGasLimit             hexutil.Uint64         `json:"maxGasLimit"`
 		TieBreakingGas          bool                   `json:"tieBreakingGas"`
@@ -120,6 +121,10 @@ func newAlccmGenesisSpec(network string, genesis *core.Genesis) (*alccmGenesisSp
 	if num := genesis.Config.ConstantinopleBlock; num != nil {
 		spec.setConstantinople(num)
 	}
+	// Istanbul
+	if num := genesis.Config.IstanbulBlock; num != nil {
+		spec.setIstanbul(num)
+	}
 
 	spec.Params.NetworkID = (hexutil.Uint64)(genesis.Config.ChainID.Uint64())
 	spec.Params.ChainID = (hexutil.Uint64)(genesis.Config.ChainID.Uint64())
@@ -167,6 +172,10 @@ func newAlccmGenesisSpec(network string, genesis *core.Genesis) (*alccmGenesisSp
 		spec.setPrecompile(8, &alccmGenesisSpecBuiltin{Name: "alt_bn128_pairing_product",
 			StartingBlock: (hexutil.Uint64)(genesis.Config.ByzantiumBlock.Uint64())})
 	}
+	if genesis.Config.IstanbulBlock != nil {
+		spec.setPrecompile(9, &alccmGenesisSpecBuiltin{Name: "blake2_compression",
+			StartingBlock: (hexutil.Uint64)(genesis.Config.IstanbulBlock.Uint64())})
+	}
 	return spec, nil
 }
 
@@ -204,6 +213,10 @@ func (spec *alccmGenesisSpec) setConstantinople(num *big.Int) {
 	spec.Params.ConstantinopleForkBlock = hexutil.Uint64(num.Uint64())
 }
 
+func (spec *alccmGenesisSpec) setIstanbul(num *big.Int) {
+	spec.Params.IstanbulForkBlock = hexutil.Uint64(num.Uint64())
+}
+
 // parityChainSpec is the chain specification format used by Parity.
 type parityChainSpec struct {
 	Name    string `json:"name"`
@@ -246,6 +259,10 @@ type parityChainSpec struct {
 		EIP1052Transition        hexutil.Uint64       `json:"eip1052Transition"`
 		EIP1283Transition        hexutil.Uint64       `json:"eip1283Transition"`
 		EIP1283DisableTransition hexutil.Uint64       `json:"eip1283DisableTransition"`
+		EIP1344Transition        hexutil.Uint64       `json:"eip1344Transition"`
+		EIP1884Transition        hexutil.Uint64       `json:"eip1884Transition"`
+		EIP2028Transition        hexutil.Uint64       `json:"eip2028Transition"`
+		EIP2200Transition        hexutil.Uint64       `json:"eip2200Transition"`
 	} `json:"params"`
 
 	Genesis struct {
@@ -278,17 +295,20 @@ type parityChainSpecAccount struct {
 
 // parityChainSpecBuiltin is the precompiled contract definition.
 type parityChainSpecBuiltin struct {
-	Name       string                  `json:"name,omitempty"`
-	ActivateAt math2.HexOrDecimal64    `json:"activate_at,omitempty"`
-	Pricing    *parityChainSpecPricing `json:"pricing,omitempty"`
+	Name              string                  `json:"name,omitempty"`
+	ActivateAt        math2.HexOrDecimal64    `json:"activate_at,omitempty"`
+	Pricing           *parityChainSpecPricing `json:"pricing,omitempty"`
+	EIP1108Transition math2.HexOrDecimal64    `json:"eip1108_transition,omitempty"`
 }
 
 // parityChainSpecPricing represents the different pricing models that builtin
 // contracts might advertise using.
 type parityChainSpecPricing struct {
-	Linear       *parityChainSpecLinearPricing       `json:"linear,omitempty"`
-	ModExp       *parityChainSpecModExpPricing       `json:"modexp,omitempty"`
-	AltBnPairing *parityChainSpecAltBnPairingPricing `json:"alt_bn128_pairing,omitempty"`
+	Linear              *parityChainSpecLinearPricing              `json:"linear,omitempty"`
+	ModExp              *parityChainSpecModExpPricing              `json:"modexp,omitempty"`
+	AltBnPairing        *parityChainSpecAltBnPairingPricing        `json:"alt_bn128_pairing,omitempty"`
+	AltBnConstOperation *parityChainSpecAltBnConstOperationPricing `json:"alt_bn128_const_operations,omitempty"`
+	Blake2F             *parityChainSpecBlakePricing               `json:"blake2_f,omitempty"`
 }
 
 type parityChainSpecLinearPricing struct {
@@ -300,9 +320,20 @@ type parityChainSpecModExpPricing struct {
 	Divisor uint64 `json:"divisor"`
 }
 
+type parityChainSpecAltBnConstOperationPricing struct {
+	Price                  uint64 `json:"price"`
+	EIP1108TransitionPrice uint64 `json:"eip1108_transition_price,omitempty"`
+}
+
 type parityChainSpecAltBnPairingPricing struct {
-	Base uint64 `json:"base"`
-	Pair uint64 `json:"pair"`
+	Base                  uint64 `json:"base"`
+	Pair                  uint64 `json:"pair"`
+	EIP1108TransitionBase uint64 `json:"eip1108_transition_base,omitempty"`
+	EIP1108TransitionPair uint64 `json:"eip1108_transition_pair,omitempty"`
+}
+
+type parityChainSpecBlakePricing struct {
+	GasPerRound uint64 `json:"gas_per_round"`
 }
 
 // newParityChainSpec converts a go-ccmchain genesis block into a Parity specific
@@ -352,6 +383,10 @@ func newParityChainSpec(network string, genesis *core.Genesis, bootnodes []strin
 	if num := genesis.Config.PetersburgBlock; num != nil {
 		spec.setConstantinopleFix(num)
 	}
+	// Istanbul
+	if num := genesis.Config.IstanbulBlock; num != nil {
+		spec.setIstanbul(num)
+	}
 
 	spec.Params.MaximumExtraDataSize = (hexutil.Uint64)(params.MaximumExtraDataSize)
 	spec.Params.MinGasLimit = (hexutil.Uint64)(params.MinGasLimit)
@@ -412,6 +447,37 @@ func newParityChainSpec(network string, genesis *core.Genesis, bootnodes []strin
 			Name: "alt_bn128_pairing", ActivateAt: blnum, Pricing: &parityChainSpecPricing{AltBnPairing: &parityChainSpecAltBnPairingPricing{Base: 100000, Pair: 80000}},
 		})
 	}
+	// Istanbul reprices the bn256 precompiles (EIP-1108) and adds blake2 F (EIP-152)
+	if genesis.Config.IstanbulBlock != nil {
+		if genesis.Config.ByzantiumBlock == nil {
+			return nil, errors.New("invalid genesis, istanbul fork is enabled while byzantium is not")
+		}
+		istnum := math2.HexOrDecimal64(genesis.Config.IstanbulBlock.Uint64())
+
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{6}))].Builtin.Pricing = &parityChainSpecPricing{
+			AltBnConstOperation: &parityChainSpecAltBnConstOperationPricing{Price: params.Bn256AddGasByzantium, EIP1108TransitionPrice: params.Bn256AddGasIstanbul},
+		}
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{6}))].Builtin.EIP1108Transition = istnum
+
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{7}))].Builtin.Pricing = &parityChainSpecPricing{
+			AltBnConstOperation: &parityChainSpecAltBnConstOperationPricing{Price: params.Bn256ScalarMulGasByzantium, EIP1108TransitionPrice: params.Bn256ScalarMulGasIstanbul},
+		}
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{7}))].Builtin.EIP1108Transition = istnum
+
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{8}))].Builtin.Pricing = &parityChainSpecPricing{
+			AltBnPairing: &parityChainSpecAltBnPairingPricing{
+				Base:                  params.Bn256PairingBaseGasByzantium,
+				Pair:                  params.Bn256PairingPerPointGasByzantium,
+				EIP1108TransitionBase: params.Bn256PairingBaseGasIstanbul,
+				EIP1108TransitionPair: params.Bn256PairingPerPointGasIstanbul,
+			},
+		}
+		spec.Accounts[common.UnprefixedAddress(common.BytesToAddress([]byte{8}))].Builtin.EIP1108Transition = istnum
+
+		spec.setPrecompile(9, &parityChainSpecBuiltin{
+			Name: "blake2_f", ActivateAt: istnum, Pricing: &parityChainSpecPricing{Blake2F: &parityChainSpecBlakePricing{GasPerRound: params.Blake2FGasPerRound}},
+		})
+	}
 	return spec, nil
 }
 
@@ -451,6 +517,14 @@ func (spec *parityChainSpec) setConstantinopleFix(num *big.Int) {
 	spec.Params.EIP1283DisableTransition = hexutil.Uint64(num.Uint64())
 }
 
+func (spec *parityChainSpec) setIstanbul(num *big.Int) {
+	n := hexutil.Uint64(num.Uint64())
+	spec.Params.EIP1344Transition = n
+	spec.Params.EIP1884Transition = n
+	spec.Params.EIP2028Transition = n
+	spec.Params.EIP2200Transition = n
+}
+
 // pyCcmchainGenesisSpec represents the genesis specification format used by the
 // Python Ccmchain implementation.
 type pyCcmchainGenesisSpec struct {
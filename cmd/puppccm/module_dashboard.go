@@ -633,6 +633,7 @@ func deployDashboard(client *sshClient, network string, conf *config, config *da
 		"Byzantium":         conf.Genesis.Config.ByzantiumBlock,
 		"Constantinople":    conf.Genesis.Config.ConstantinopleBlock,
 		"ConstantinopleFix": conf.Genesis.Config.PetersburgBlock,
+		"Istanbul":          conf.Genesis.Config.IstanbulBlock,
 	})
 	files[filepath.Join(workdir, "index.html")] = indexfile.Bytes()
 
@@ -49,6 +49,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/les"
 	"github.com/ccmchain/go-ccmchain/log"
 	"github.com/ccmchain/go-ccmchain/metrics"
+	"github.com/ccmchain/go-ccmchain/metrics/exp"
 	"github.com/ccmchain/go-ccmchain/metrics/influxdb"
 	"github.com/ccmchain/go-ccmchain/miner"
 	"github.com/ccmchain/go-ccmchain/node"
@@ -58,7 +59,6 @@ import (
 	"github.com/ccmchain/go-ccmchain/p2p/nat"
 	"github.com/ccmchain/go-ccmchain/p2p/netutil"
 	"github.com/ccmchain/go-ccmchain/params"
-	"github.com/ccmchain/go-ccmchain/rpc"
 	whisper "github.com/ccmchain/go-ccmchain/whisper/whisperv6"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	cli "gopkg.in/urfave/cli.v1"
@@ -127,6 +127,10 @@ var (
 		Name:  "keystore",
 		Usage: "Directory for the keystore (default = inside the datadir)",
 	}
+	KeyStoreVaultFlag = cli.StringFlag{
+		Name:  "keystore.vault",
+		Usage: "Single file vault to keep all the keys in, instead of the keystore directory",
+	}
 	NoUSBFlag = cli.BoolFlag{
 		Name:  "nousb",
 		Usage: "Disables monitoring for and managing USB hardware wallets",
@@ -201,6 +205,10 @@ var (
 		Usage: `Blockchain garbage collection mode ("full", "archive")`,
 		Value: "full",
 	}
+	LogIndexFlag = cli.BoolFlag{
+		Name:  "logindex",
+		Usage: "Maintain a persistent address/topic log index for accelerated log filtering",
+	}
 	LightKDFFlag = cli.BoolFlag{
 		Name:  "lightkdf",
 		Usage: "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -358,6 +366,21 @@ var (
 		Usage: "Maximum amount of time non-executable transaction are queued",
 		Value: ccm.DefaultConfig.TxPool.Lifetime,
 	}
+	TxPoolTxSizeLimitFlag = cli.Uint64Flag{
+		Name:  "txpool.txsizelimit",
+		Usage: "Maximum size in bytes of a single transaction accepted into the pool",
+		Value: ccm.DefaultConfig.TxPool.TxSizeLimit,
+	}
+	TxPoolAccountBytesFlag = cli.Uint64Flag{
+		Name:  "txpool.accountbytes",
+		Usage: "Maximum total size in bytes of the non-local transactions pooled per account (0 = unlimited)",
+		Value: ccm.DefaultConfig.TxPool.AccountBytes,
+	}
+	TxPoolPriceHalflifeFlag = cli.DurationFlag{
+		Name:  "txpool.pricehalflife",
+		Usage: "Time after which the eviction priority of a pooled transaction halves (0 = rank by gas price only)",
+		Value: ccm.DefaultConfig.TxPool.PriceHalflife,
+	}
 	// Performance tuning settings
 	CacheFlag = cli.IntFlag{
 		Name:  "cache",
@@ -383,6 +406,25 @@ var (
 		Name:  "cache.noprefetch",
 		Usage: "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
 	}
+	CacheSnapshotFlag = cli.IntFlag{
+		Name:  "cache.snapshot",
+		Usage: "Percentage of cache memory allowance to use for snapshot caching (default = 10%, requires --snapshot)",
+		Value: 10,
+	}
+	SnapshotFlag = cli.BoolFlag{
+		Name:  "snapshot",
+		Usage: "Enables the flat state snapshot for accelerated account and storage reads",
+	}
+	PruneRetainFlag = cli.Uint64Flag{
+		Name:  "prune.retain",
+		Usage: "Number of recent block states to retain when pruning (the genesis state is always retained)",
+		Value: 128,
+	}
+	PruneBloomSizeFlag = cli.Uint64Flag{
+		Name:  "prune.bloomsize",
+		Usage: "Megabytes of memory allocated to the bloom filter tracking the retained state while pruning",
+		Value: 2048,
+	}
 	// Miner settings
 	MiningEnabledFlag = cli.BoolFlag{
 		Name:  "mine",
@@ -482,6 +524,27 @@ var (
 		Name:  "rpc.gascap",
 		Usage: "Sets a cap on gas that can be used in ccm_call/estimateGas",
 	}
+	RPCBatchLimitFlag = cli.IntFlag{
+		Name:  "rpc.batchlimit",
+		Usage: "Maximum number of requests in a batch (0 = unlimited)",
+	}
+	RPCResponseLimitFlag = cli.IntFlag{
+		Name:  "rpc.responselimit",
+		Usage: "Maximum number of bytes returned for a request or batch (0 = unlimited)",
+	}
+	RPCTimeoutFlag = cli.DurationFlag{
+		Name:  "rpc.timeout",
+		Usage: "Maximum execution time of RPC method calls (0 = unlimited)",
+	}
+	RPCMethodTimeoutsFlag = cli.StringFlag{
+		Name:  "rpc.methodtimeouts",
+		Usage: "Comma separated list of per-method execution timeouts (e.g. ccm_getLogs=10s,ccm_call=5s)",
+		Value: "",
+	}
+	RPCAccessLogFlag = cli.BoolFlag{
+		Name:  "rpc.accesslog",
+		Usage: "Log every served RPC method call with its duration, caller and error code",
+	}
 	// Logging and debug settings
 	EthStatsURLFlag = cli.StringFlag{
 		Name:  "ccmstats",
@@ -581,6 +644,44 @@ var (
 		Usage: "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
 		Value: strings.Join(node.DefaultConfig.GraphQLVirtualHosts, ","),
 	}
+	GraphQLTracingFlag = cli.BoolFlag{
+		Name:  "graphql.tracing",
+		Usage: "Enable transaction tracing via GraphQL (native tracers only)",
+	}
+	AuthRPCEnabledFlag = cli.BoolFlag{
+		Name:  "authrpc",
+		Usage: "Enable the JWT authenticated HTTP and WS-RPC server",
+	}
+	AuthRPCListenAddrFlag = cli.StringFlag{
+		Name:  "authrpc.addr",
+		Usage: "Authenticated RPC server listening interface",
+		Value: node.DefaultAuthHost,
+	}
+	AuthRPCPortFlag = cli.IntFlag{
+		Name:  "authrpc.port",
+		Usage: "Authenticated RPC server listening port",
+		Value: node.DefaultAuthPort,
+	}
+	AuthRPCApiFlag = cli.StringFlag{
+		Name:  "authrpc.api",
+		Usage: "API's offered over the authenticated RPC interface",
+		Value: "",
+	}
+	AuthRPCJWTSecretFlag = cli.StringFlag{
+		Name:  "authrpc.jwtsecret",
+		Usage: "Path to the hex encoded secret the authentication tokens are signed with (generated if missing)",
+		Value: "",
+	}
+	AuthRPCTLSCertFlag = cli.StringFlag{
+		Name:  "authrpc.tlscert",
+		Usage: "PEM encoded TLS certificate to serve the authenticated RPC server with",
+		Value: "",
+	}
+	AuthRPCTLSKeyFlag = cli.StringFlag{
+		Name:  "authrpc.tlskey",
+		Usage: "PEM encoded TLS private key to serve the authenticated RPC server with",
+		Value: "",
+	}
 	ExecFlag = cli.StringFlag{
 		Name:  "exec",
 		Usage: "Execute JavaScript statement",
@@ -642,6 +743,10 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS discovery trees to find peers in (works with --nodiscover)",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -693,6 +798,20 @@ var (
 		Name:  "metrics.expensive",
 		Usage: "Enable expensive metrics collection and reporting",
 	}
+	// MetricsHTTPFlag defines the endpoint for a stand-alone metrics HTTP endpoint.
+	// Since the pprof service enables sensitive/vulnerable behavior, this allows a user
+	// to enable a public-OK metrics endpoint without having to worry about ALSO exposing
+	// other profiling behavior or information.
+	MetricsHTTPFlag = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Enable stand-alone metrics HTTP server listening interface",
+		Value: "",
+	}
+	MetricsPortFlag = cli.IntFlag{
+		Name:  "metrics.port",
+		Usage: "Metrics HTTP server listening port",
+		Value: 6061,
+	}
 	MetricsEnableInfluxDBFlag = cli.BoolFlag{
 		Name:  "metrics.influxdb",
 		Usage: "Enable metrics export/push to an external InfluxDB database",
@@ -926,6 +1045,67 @@ func setGraphQL(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(GraphQLVirtualHostsFlag.Name) {
 		cfg.GraphQLVirtualHosts = splitAndTrim(ctx.GlobalString(GraphQLVirtualHostsFlag.Name))
 	}
+	if ctx.GlobalIsSet(GraphQLTracingFlag.Name) {
+		cfg.GraphQLTracing = ctx.GlobalBool(GraphQLTracingFlag.Name)
+	}
+}
+
+// setRPCLimits configures the resource limits and the access log of the RPC
+// servers from the set command line flags.
+func setRPCLimits(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalIsSet(RPCBatchLimitFlag.Name) {
+		cfg.RPCLimits.BatchItems = ctx.GlobalInt(RPCBatchLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCResponseLimitFlag.Name) {
+		cfg.RPCLimits.ResponseSize = ctx.GlobalInt(RPCResponseLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCTimeoutFlag.Name) {
+		cfg.RPCLimits.ExecutionTimeout = ctx.GlobalDuration(RPCTimeoutFlag.Name)
+	}
+	if ctx.GlobalIsSet(RPCMethodTimeoutsFlag.Name) {
+		timeouts := make(map[string]time.Duration)
+		for _, entry := range splitAndTrim(ctx.GlobalString(RPCMethodTimeoutsFlag.Name)) {
+			parts := strings.SplitN(entry, "=", 2)
+			if len(parts) != 2 {
+				Fatalf("Invalid RPC method timeout %q, want <method>=<duration>", entry)
+			}
+			timeout, err := time.ParseDuration(parts[1])
+			if err != nil {
+				Fatalf("Invalid RPC method timeout %q: %v", entry, err)
+			}
+			timeouts[parts[0]] = timeout
+		}
+		cfg.RPCLimits.MethodTimeouts = timeouts
+	}
+	if ctx.GlobalIsSet(RPCAccessLogFlag.Name) {
+		cfg.RPCAccessLog = ctx.GlobalBool(RPCAccessLogFlag.Name)
+	}
+}
+
+// setAuthRPC creates the authenticated RPC listener interface string from the
+// set command line flags, returning empty if the endpoint is disabled.
+func setAuthRPC(ctx *cli.Context, cfg *node.Config) {
+	if ctx.GlobalBool(AuthRPCEnabledFlag.Name) && cfg.AuthHost == "" {
+		cfg.AuthHost = "127.0.0.1"
+		if ctx.GlobalIsSet(AuthRPCListenAddrFlag.Name) {
+			cfg.AuthHost = ctx.GlobalString(AuthRPCListenAddrFlag.Name)
+		}
+	}
+	if ctx.GlobalIsSet(AuthRPCPortFlag.Name) {
+		cfg.AuthPort = ctx.GlobalInt(AuthRPCPortFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCApiFlag.Name) {
+		cfg.AuthModules = splitAndTrim(ctx.GlobalString(AuthRPCApiFlag.Name))
+	}
+	if ctx.GlobalIsSet(AuthRPCJWTSecretFlag.Name) {
+		cfg.JWTSecret = ctx.GlobalString(AuthRPCJWTSecretFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCTLSCertFlag.Name) {
+		cfg.AuthTLSCert = ctx.GlobalString(AuthRPCTLSCertFlag.Name)
+	}
+	if ctx.GlobalIsSet(AuthRPCTLSKeyFlag.Name) {
+		cfg.AuthTLSKey = ctx.GlobalString(AuthRPCTLSKeyFlag.Name)
+	}
 }
 
 // setWS creates the WebSocket RPC listener interface string from the set
@@ -1130,6 +1310,15 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.DiscoveryV5 = true
 	}
 
+	if ctx.GlobalIsSet(DNSDiscoveryFlag.Name) {
+		cfg.DiscoveryURLs = nil
+		for _, url := range splitAndTrim(ctx.GlobalString(DNSDiscoveryFlag.Name)) {
+			if url != "" {
+				cfg.DiscoveryURLs = append(cfg.DiscoveryURLs, url)
+			}
+		}
+	}
+
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
 		if err != nil {
@@ -1144,6 +1333,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DiscoveryURLs = nil
 	}
 }
 
@@ -1153,7 +1343,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
 	setGraphQL(ctx, cfg)
+	setAuthRPC(ctx, cfg)
 	setWS(ctx, cfg)
+	setRPCLimits(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	setDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
@@ -1165,6 +1357,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(KeyStoreVaultFlag.Name) {
+		cfg.KeyStoreVault = ctx.GlobalString(KeyStoreVaultFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
@@ -1261,6 +1456,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.GlobalIsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.GlobalDuration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.GlobalIsSet(TxPoolTxSizeLimitFlag.Name) {
+		cfg.TxSizeLimit = ctx.GlobalUint64(TxPoolTxSizeLimitFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolAccountBytesFlag.Name) {
+		cfg.AccountBytes = ctx.GlobalUint64(TxPoolAccountBytesFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxPoolPriceHalflifeFlag.Name) {
+		cfg.PriceHalflife = ctx.GlobalDuration(TxPoolPriceHalflifeFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *ccm.Config) {
@@ -1433,12 +1637,19 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ccm.Config) {
 	cfg.NoPruning = ctx.GlobalString(GCModeFlag.Name) == "archive"
 	cfg.NoPrefetch = ctx.GlobalBool(CacheNoPrefetchFlag.Name)
 
+	if ctx.GlobalIsSet(LogIndexFlag.Name) {
+		cfg.LogIndex = ctx.GlobalBool(LogIndexFlag.Name)
+	}
+
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheTrieFlag.Name) / 100
 	}
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cfg.TrieDirtyCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalBool(SnapshotFlag.Name) {
+		cfg.SnapshotCache = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
+	}
 	if ctx.GlobalIsSet(DocRootFlag.Name) {
 		cfg.DocRoot = ctx.GlobalString(DocRootFlag.Name)
 	}
@@ -1568,17 +1779,17 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 }
 
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
-func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts) {
+func RegisterGraphQLService(stack *node.Node, endpoint string, cors, vhosts, wsOrigins []string, tracing bool) {
 	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
 		// Try to construct the GraphQL service backed by a full node
 		var ccmServ *ccm.Ccmchain
 		if err := ctx.Service(&ccmServ); err == nil {
-			return graphql.New(ccmServ.APIBackend, endpoint, cors, vhosts, timeouts)
+			return graphql.New(ctx, ccmServ.APIBackend, false, endpoint, cors, vhosts, wsOrigins, tracing)
 		}
 		// Try to construct the GraphQL service backed by a light node
 		var lesServ *les.LightCcmchain
 		if err := ctx.Service(&lesServ); err == nil {
-			return graphql.New(lesServ.ApiBackend, endpoint, cors, vhosts, timeouts)
+			return graphql.New(ctx, lesServ.ApiBackend, true, endpoint, cors, vhosts, wsOrigins, tracing)
 		}
 		// Well, this should not have happened, bail out
 		return nil, errors.New("no Ccmchain service")
@@ -1605,6 +1816,11 @@ func SetupMetrics(ctx *cli.Context) {
 
 			go influxdb.InfluxDBWithTags(metrics.DefaultRegistry, 10*time.Second, endpoint, database, username, password, "gccm.", tagsMap)
 		}
+		if ctx.GlobalString(MetricsHTTPFlag.Name) != "" {
+			address := fmt.Sprintf("%s:%d", ctx.GlobalString(MetricsHTTPFlag.Name), ctx.GlobalInt(MetricsPortFlag.Name))
+			log.Info("Enabling stand-alone metrics HTTP endpoint", "address", address)
+			exp.Setup(address)
+		}
 	}
 }
 
@@ -1697,6 +1913,9 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	if ctx.GlobalIsSet(CacheFlag.Name) || ctx.GlobalIsSet(CacheGCFlag.Name) {
 		cache.TrieDirtyLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheGCFlag.Name) / 100
 	}
+	if ctx.GlobalBool(SnapshotFlag.Name) {
+		cache.SnapshotLimit = ctx.GlobalInt(CacheFlag.Name) * ctx.GlobalInt(CacheSnapshotFlag.Name) / 100
+	}
 	vmcfg := vm.Config{EnablePreimageRecording: ctx.GlobalBool(VMEnableDebugFlag.Name)}
 	chain, err = core.NewBlockChain(chainDb, cache, config, engine, vmcfg, nil)
 	if err != nil {
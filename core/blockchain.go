@@ -33,6 +33,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/consensus"
 	"github.com/ccmchain/go-ccmchain/core/rawdb"
 	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/core/state/snapshot"
 	"github.com/ccmchain/go-ccmchain/core/types"
 	"github.com/ccmchain/go-ccmchain/core/vm"
 	"github.com/ccmchain/go-ccmchain/ccmdb"
@@ -111,6 +112,7 @@ type CacheConfig struct {
 	TrieDirtyLimit      int           // Memory limit (MB) at which to start flushing dirty trie nodes to disk
 	TrieDirtyDisabled   bool          // Whccmer to disable trie write caching and GC altogccmer (archive node)
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
+	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory (0 = snapshots disabled)
 }
 
 // BlockChain represents the canonical chain given a database with a genesis
@@ -151,6 +153,7 @@ type BlockChain struct {
 	currentFastBlock atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
+	snaps         *snapshot.Tree // Snapshot tree for fast trie leaf access (nil if disabled)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
 	bodyRLPCache  *lru.Cache     // Cache for the most recent block bodies in RLP encoded format
 	receiptsCache *lru.Cache     // Cache for the most recent receipts per block
@@ -229,6 +232,10 @@ func NewBlockChain(db ccmdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
 	}
+	// Load any existing snapshot, regenerating it if loading failed
+	if bc.cacheConfig.SnapshotLimit > 0 {
+		bc.snaps = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, bc.CurrentBlock().Root())
+	}
 	// The first thing the node will do is reconstruct the verification data for
 	// the head block (ccmash cache or clique voting snapshot). Might as well do
 	// it in advance.
@@ -442,7 +449,16 @@ func (bc *BlockChain) SetHead(head uint64) error {
 	bc.blockCache.Purge()
 	bc.futureBlocks.Purge()
 
-	return bc.loadLastState()
+	if err := bc.loadLastState(); err != nil {
+		return err
+	}
+	// If the rewound head is not covered by the snapshot tree, regenerate it
+	if bc.snaps != nil {
+		if root := bc.CurrentBlock().Root(); bc.snaps.Snapshot(root) == nil {
+			bc.snaps.Rebuild(root)
+		}
+	}
+	return nil
 }
 
 // FastSyncCommitHead sets the current head block to the one defined by the hash
@@ -500,7 +516,7 @@ func (bc *BlockChain) State() (*state.StateDB, error) {
 
 // StateAt returns a new mutable state based on a particular point in time.
 func (bc *BlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
-	return state.New(root, bc.stateCache)
+	return state.NewWithSnapshot(root, bc.stateCache, bc.snaps)
 }
 
 // StateCache returns the caching database underpinning the blockchain instance.
@@ -508,6 +524,12 @@ func (bc *BlockChain) StateCache() state.Database {
 	return bc.stateCache
 }
 
+// Snapshots returns the state snapshot tree of the blockchain, or nil if state
+// snapshots are disabled.
+func (bc *BlockChain) Snapshots() *snapshot.Tree {
+	return bc.snaps
+}
+
 // Reset purges the entire blockchain, restoring it to its genesis state.
 func (bc *BlockChain) Reset() error {
 	return bc.ResetWithGenesisBlock(bc.genesisBlock)
@@ -806,6 +828,15 @@ func (bc *BlockChain) Stop() {
 
 	bc.wg.Wait()
 
+	// Flatten all the snapshot diff layers into the persistent disk layer. The
+	// head state is always written out below, so the snapshot can be reloaded
+	// on the next startup without regeneration.
+	if bc.snaps != nil {
+		if err := bc.snaps.Cap(bc.CurrentBlock().Root(), 0); err != nil {
+			log.Error("Failed to flatten state snapshot", "err", err)
+		}
+		bc.snaps.Stop()
+	}
 	// Ensure the state of a recent block is also stored to disk before exiting.
 	// We're writing three different states to catch different restart scenarios:
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
@@ -1376,6 +1407,17 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	// Set new head.
 	if status == CanonStatTy {
 		bc.insert(block)
+
+		// Flatten the snapshot layers that went out of the in-memory trie window,
+		// the bottom diff layer being the last state trie still available
+		if bc.snaps != nil {
+			if bc.snaps.Snapshot(root) == nil {
+				log.Warn("State snapshot missing for new head, regenerating", "number", block.Number(), "hash", block.Hash(), "root", root)
+				bc.snaps.Rebuild(root)
+			} else if err := bc.snaps.Cap(root, TriesInMemory-1); err != nil {
+				log.Warn("Failed to cap snapshot tree", "root", root, "layers", TriesInMemory-1, "err", err)
+			}
+		}
 	}
 	bc.futureBlocks.Remove(block.Hash())
 	return status, nil
@@ -1590,7 +1632,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, []
 		if parent == nil {
 			parent = bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
 		}
-		statedb, err := state.New(parent.Root, bc.stateCache)
+		statedb, err := state.NewWithSnapshot(parent.Root, bc.stateCache, bc.snaps)
 		if err != nil {
 			return it.index, events, coalescedLogs, err
 		}
@@ -1960,6 +2002,16 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 		rawdb.DeleteCanonicalHash(batch, i)
 	}
 	batch.Write()
+
+	// Ensure the state snapshot follows the new canonical chain. If the fork point
+	// was deeper than the persistent snapshot layer, the side chain could not be
+	// layered on top and the snapshot needs to be regenerated for the new head.
+	if bc.snaps != nil {
+		if root := newBlock.Root(); bc.snaps.Snapshot(root) == nil {
+			log.Warn("State snapshot missing after reorg, regenerating", "number", newBlock.Number(), "hash", newBlock.Hash(), "root", root)
+			bc.snaps.Rebuild(root)
+		}
+	}
 	// If any logs need to be fired, do it now. In theory we could avoid creating
 	// this goroutine if there are no events to fire, but realistcally that only
 	// ever happens if we're reorging empty blocks, which will only happen on idle
@@ -0,0 +1,413 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/core/state/snapshot"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rlp"
+	"github.com/ccmchain/go-ccmchain/trie"
+)
+
+var (
+	snapTestKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	snapTestAddress = crypto.PubkeyToAddress(snapTestKey.PublicKey)
+
+	// snapTestStorer stores the block number into slot 0 and into the slot
+	// keyed by the block number itself.
+	snapTestStorer = common.HexToAddress("0xaaaa")
+
+	// snapTestDestructor self-destructs when called, wiping its storage.
+	snapTestDestructor = common.HexToAddress("0xbbbb")
+
+	// snapTestWriter stores the second word of the call data into the slot
+	// keyed by the first word.
+	snapTestWriter = common.HexToAddress("0xcccc")
+)
+
+// newSnapshotTestChain creates a chain generator with a genesis containing a
+// funded account and the two test contracts. The blocks call the storer in
+// every block, destruct the destructor at block 10 and resurrect its address as
+// a plain account at block 20.
+func newSnapshotTestChain(t *testing.T, n int, seed byte) (*Genesis, []*types.Block) {
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			snapTestAddress: {Balance: big.NewInt(1000000000000000000)},
+			snapTestStorer: {
+				Code:    common.FromHex("0x434355436000550000"),
+				Balance: big.NewInt(0),
+			},
+			snapTestDestructor: {
+				Code:    common.FromHex("0x33ff"),
+				Storage: map[common.Hash]common.Hash{common.HexToHash("0x01"): common.HexToHash("0x01")},
+				Balance: big.NewInt(1),
+			},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	signer := types.HomesteadSigner{}
+	blocks, _ := GenerateChain(gspec.Config, genesis, ccmash.NewFaker(), db, n, func(i int, block *BlockGen) {
+		block.SetCoinbase(common.Address{seed})
+
+		send := func(to common.Address, value int64, gas uint64) {
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(snapTestAddress), to, big.NewInt(value), gas, new(big.Int), nil), signer, snapTestKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			block.AddTx(tx)
+		}
+		send(common.Address{seed, byte(i)}, 1, 21000)
+		send(snapTestStorer, 0, 100000)
+
+		switch i {
+		case 10:
+			send(snapTestDestructor, 0, 100000)
+		case 20:
+			send(snapTestDestructor, 1, 21000)
+		}
+	})
+	return gspec, blocks
+}
+
+// checkSnapshotConsistency iterates the entire state trie of the given root and
+// ensures that the snapshot layer of the same root returns exactly the same data.
+func checkSnapshotConsistency(t *testing.T, chain *BlockChain, root common.Hash) {
+	snap := chain.Snapshots().Snapshot(root)
+	if snap == nil {
+		t.Fatalf("snapshot missing for root %x", root)
+	}
+	accTrie, err := chain.StateCache().OpenTrie(root)
+	if err != nil {
+		t.Fatalf("failed to open account trie: %v", err)
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		var acc state.Account
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			t.Fatalf("failed to decode account: %v", err)
+		}
+		hash := common.BytesToHash(accIt.Key)
+		blob, err := snap.AccountRLP(hash)
+		if err != nil {
+			t.Fatalf("account %x: snapshot retrieval failed: %v", hash, err)
+		}
+		if want := snapshot.AccountRLP(acc.Nonce, acc.Balance, acc.Root, acc.CodeHash); !bytes.Equal(blob, want) {
+			t.Fatalf("account %x: snapshot mismatch: have %x, want %x", hash, blob, want)
+		}
+		stTrie, err := chain.StateCache().OpenStorageTrie(hash, acc.Root)
+		if err != nil {
+			t.Fatalf("account %x: failed to open storage trie: %v", hash, err)
+		}
+		stIt := trie.NewIterator(stTrie.NodeIterator(nil))
+		for stIt.Next() {
+			blob, err := snap.Storage(hash, common.BytesToHash(stIt.Key))
+			if err != nil {
+				t.Fatalf("slot %x:%x: snapshot retrieval failed: %v", hash, stIt.Key, err)
+			}
+			if !bytes.Equal(blob, stIt.Value) {
+				t.Fatalf("slot %x:%x: snapshot mismatch: have %x, want %x", hash, stIt.Key, blob, stIt.Value)
+			}
+		}
+	}
+	// The storage of the destructed contract must be gone from the snapshot too
+	trieState, err := state.New(root, chain.StateCache())
+	if err != nil {
+		t.Fatalf("failed to open trie backed state: %v", err)
+	}
+	if trieState.GetState(snapTestDestructor, common.HexToHash("0x01")) == (common.Hash{}) {
+		destructed := crypto.Keccak256Hash(snapTestDestructor[:])
+		if blob, err := snap.Storage(destructed, crypto.Keccak256Hash(common.HexToHash("0x01").Bytes())); err != nil || len(blob) != 0 {
+			t.Fatalf("destructed storage accessible: have %x, err %v", blob, err)
+		}
+	}
+}
+
+// checkSnapshotState ensures that the state read through the snapshot matches
+// the state read through the tries.
+func checkSnapshotState(t *testing.T, chain *BlockChain, root common.Hash, accounts ...common.Address) {
+	snapState, err := chain.StateAt(root)
+	if err != nil {
+		t.Fatalf("failed to open snapshot backed state: %v", err)
+	}
+	trieState, err := state.New(root, chain.StateCache())
+	if err != nil {
+		t.Fatalf("failed to open trie backed state: %v", err)
+	}
+	for _, addr := range accounts {
+		if have, want := snapState.Exist(addr), trieState.Exist(addr); have != want {
+			t.Errorf("account %x: existence mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := snapState.GetBalance(addr), trieState.GetBalance(addr); have.Cmp(want) != 0 {
+			t.Errorf("account %x: balance mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := snapState.GetNonce(addr), trieState.GetNonce(addr); have != want {
+			t.Errorf("account %x: nonce mismatch: have %v, want %v", addr, have, want)
+		}
+		if have, want := snapState.GetCodeHash(addr), trieState.GetCodeHash(addr); have != want {
+			t.Errorf("account %x: code hash mismatch: have %x, want %x", addr, have, want)
+		}
+		for _, slot := range []common.Hash{{}, common.HexToHash("0x01"), common.HexToHash("0x20")} {
+			if have, want := snapState.GetState(addr, slot), trieState.GetState(addr, slot); have != want {
+				t.Errorf("account %x, slot %x: value mismatch: have %x, want %x", addr, slot, have, want)
+			}
+		}
+	}
+}
+
+// newSnapshotChain creates a blockchain on top of the given database with state
+// snapshots enabled.
+func newSnapshotChain(t *testing.T, db ccmdb.Database, gspec *Genesis) *BlockChain {
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		SnapshotLimit:  16,
+	}
+	chain, err := NewBlockChain(db, config, gspec.Config, ccmash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	chain.Snapshots().WaitGeneration()
+	return chain
+}
+
+// Tests that the state snapshot is kept in sync with the state tries while
+// importing a chain long enough to flatten diff layers into the disk layer, and
+// that it's persisted and reloaded across restarts.
+func TestSnapshotImport(t *testing.T) {
+	gspec, blocks := newSnapshotTestChain(t, TriesInMemory+32, 1)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain := newSnapshotChain(t, db, gspec)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	accounts := []common.Address{snapTestAddress, snapTestStorer, snapTestDestructor, {1, 0}, {1, 5}, {2, 0}}
+	for _, block := range blocks[len(blocks)-TriesInMemory:] {
+		checkSnapshotState(t, chain, block.Root(), accounts...)
+	}
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+
+	// Restart the chain and ensure the snapshot is reloaded without data loss
+	chain.Stop()
+	if root := rawdb.ReadSnapshotRoot(db); root != blocks[len(blocks)-1].Root() {
+		t.Fatalf("persisted snapshot root mismatch: have %x, want %x", root, blocks[len(blocks)-1].Root())
+	}
+	chain = newSnapshotChain(t, db, gspec)
+	defer chain.Stop()
+
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+	checkSnapshotState(t, chain, chain.CurrentBlock().Root(), accounts...)
+}
+
+// Tests that a state snapshot is generated in the background for a chain that
+// was imported without snapshots enabled.
+func TestSnapshotGeneration(t *testing.T) {
+	gspec, blocks := newSnapshotTestChain(t, 32, 1)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := NewBlockChain(db, nil, gspec.Config, ccmash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	chain.Stop()
+
+	chain = newSnapshotChain(t, db, gspec)
+	defer chain.Stop()
+
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+	checkSnapshotState(t, chain, chain.CurrentBlock().Root(), snapTestAddress, snapTestStorer, snapTestDestructor)
+}
+
+// Tests that the state snapshot follows the canonical chain across reorgs, both
+// shallow ones served from the diff layers and deep ones requiring the snapshot
+// to be regenerated.
+func TestSnapshotReorg(t *testing.T) {
+	gspec, blocks := newSnapshotTestChain(t, TriesInMemory+32, 1)
+	_, forks := newSnapshotTestChain(t, TriesInMemory+40, 2)
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain := newSnapshotChain(t, db, gspec)
+	defer chain.Stop()
+
+	// Insert a short side chain first, then the canonical one on top, so that
+	// the side chain becomes unreachable from the new disk layer
+	if _, err := chain.InsertChain(forks[:8]); err != nil {
+		t.Fatalf("failed to insert fork chain: %v", err)
+	}
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+
+	// Reorg to the longer competing chain which forks below the disk layer
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert competing chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != forks[len(forks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, forks[len(forks)-1].Hash())
+	}
+	chain.Snapshots().WaitGeneration()
+
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+	checkSnapshotState(t, chain, chain.CurrentBlock().Root(), snapTestAddress, snapTestStorer, snapTestDestructor, common.Address{1, 0}, common.Address{2, 0})
+}
+
+// Tests that the state snapshot matches the state tries across randomly built
+// chains, randomly reorged onto a competing chain. The seeds are fixed so that
+// any failure is reproducible.
+func TestSnapshotRandomChains(t *testing.T) {
+	for seed := int64(1); seed <= 4; seed++ {
+		seed := seed
+		t.Run(fmt.Sprintf("seed-%d", seed), func(t *testing.T) {
+			testSnapshotRandomChain(t, seed)
+		})
+	}
+}
+
+func testSnapshotRandomChain(t *testing.T, seed int64) {
+	rng := rand.New(rand.NewSource(seed))
+
+	// Assemble the pool of accounts and storage slots the random transactions
+	// will be touching
+	accounts := []common.Address{snapTestAddress, snapTestWriter, snapTestDestructor}
+	for i := 0; i < 16; i++ {
+		accounts = append(accounts, common.Address{0xcc, byte(i)})
+	}
+	var slots []common.Hash
+	for i := 0; i <= 0x20; i++ {
+		slots = append(slots, common.BigToHash(big.NewInt(int64(i))))
+	}
+	gspec := &Genesis{
+		Config: params.TestChainConfig,
+		Alloc: GenesisAlloc{
+			snapTestAddress: {Balance: big.NewInt(1000000000000000000)},
+			snapTestWriter: {
+				Code:    common.FromHex("0x6020356000355500"),
+				Balance: big.NewInt(0),
+			},
+			snapTestDestructor: {
+				Code:    common.FromHex("0x33ff"),
+				Storage: map[common.Hash]common.Hash{common.HexToHash("0x01"): common.HexToHash("0x01")},
+				Balance: big.NewInt(1),
+			},
+		},
+	}
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+
+	// generate creates a random chain segment on top of the given parent, sending
+	// value around the account pool and writing or clearing random slots
+	signer := types.HomesteadSigner{}
+	generate := func(parent *types.Block, n int, extra []byte) []*types.Block {
+		blocks, _ := GenerateChain(gspec.Config, parent, ccmash.NewFaker(), gendb, n, func(i int, block *BlockGen) {
+			block.SetCoinbase(accounts[rng.Intn(len(accounts))])
+			block.SetExtra(extra)
+
+			for txs := rng.Intn(5); txs > 0; txs-- {
+				var (
+					to    = accounts[rng.Intn(len(accounts))]
+					value = big.NewInt(rng.Int63n(1000))
+					gas   = uint64(21000)
+					data  []byte
+				)
+				if rng.Intn(2) == 0 {
+					var word common.Hash
+					if rng.Intn(3) > 0 {
+						word = common.BigToHash(big.NewInt(rng.Int63()))
+					}
+					to, value, gas = snapTestWriter, new(big.Int), 100000
+					data = append(slots[rng.Intn(len(slots))].Bytes(), word.Bytes()...)
+				} else if to == snapTestDestructor {
+					gas = 100000
+				}
+				tx, err := types.SignTx(types.NewTransaction(block.TxNonce(snapTestAddress), to, value, gas, new(big.Int), data), signer, snapTestKey)
+				if err != nil {
+					t.Fatalf("failed to sign transaction: %v", err)
+				}
+				block.AddTx(tx)
+			}
+		})
+		return blocks
+	}
+	blocks := generate(genesis, TriesInMemory/2+rng.Intn(TriesInMemory), nil)
+
+	// Pick a random fork point and generate a longer competing chain from it
+	var (
+		fork   = rng.Intn(len(blocks))
+		parent = genesis
+	)
+	if fork > 0 {
+		parent = blocks[fork-1]
+	}
+	forks := generate(parent, len(blocks)-fork+1+rng.Intn(8), []byte("fork"))
+
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain := newSnapshotChain(t, db, gspec)
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+	for i := 0; i < 8; i++ {
+		block := blocks[len(blocks)-1-rng.Intn(len(blocks))%TriesInMemory]
+		checkSnapshotState(t, chain, block.Root(), accounts...)
+	}
+	// Reorg onto the competing chain and ensure the snapshot follows it
+	if _, err := chain.InsertChain(forks); err != nil {
+		t.Fatalf("failed to insert competing chain: %v", err)
+	}
+	if head := chain.CurrentBlock().Hash(); head != forks[len(forks)-1].Hash() {
+		t.Fatalf("head mismatch: have %x, want %x", head, forks[len(forks)-1].Hash())
+	}
+	chain.Snapshots().WaitGeneration()
+
+	checkSnapshotConsistency(t, chain, chain.CurrentBlock().Root())
+	checkSnapshotState(t, chain, chain.CurrentBlock().Root(), accounts...)
+}
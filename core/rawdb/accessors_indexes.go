@@ -129,3 +129,53 @@ func WriteBloomBits(db ccmdb.KeyValueWriter, bit uint, section uint64, head comm
 		log.Crit("Failed to store bloom bits", "err", err)
 	}
 }
+
+// ReadLogIndex retrieves the positions of the logs within the given section that
+// contain the specified address or topic.
+func ReadLogIndex(db ccmdb.KeyValueReader, section uint64, head common.Hash, item []byte) []LogIndexEntry {
+	data, _ := db.Get(logIndexKey(section, head, item))
+	if len(data) == 0 {
+		return nil
+	}
+	var entries []LogIndexEntry
+	if err := rlp.DecodeBytes(data, &entries); err != nil {
+		log.Error("Invalid log index RLP", "section", section, "head", head, "item", common.Bytes2Hex(item), "err", err)
+		return nil
+	}
+	return entries
+}
+
+// WriteLogIndex stores the positions of the logs within the given section that
+// contain the specified address or topic.
+func WriteLogIndex(db ccmdb.KeyValueWriter, section uint64, head common.Hash, item []byte, entries []LogIndexEntry) {
+	data, err := rlp.EncodeToBytes(entries)
+	if err != nil {
+		log.Crit("Failed to RLP encode log index", "err", err)
+	}
+	if err := db.Put(logIndexKey(section, head, item), data); err != nil {
+		log.Crit("Failed to store log index", "err", err)
+	}
+}
+
+// DeleteLogIndexSection removes all the log index entries belonging to the given
+// section, regardless of the section head they were generated for.
+func DeleteLogIndexSection(db ccmdb.Database, section uint64) {
+	it := db.NewIteratorWithPrefix(logIndexSectionKey(section))
+	defer it.Release()
+
+	batch := db.NewBatch()
+	for it.Next() {
+		if err := batch.Delete(it.Key()); err != nil {
+			log.Crit("Failed to delete log index", "err", err)
+		}
+		if batch.ValueSize() > ccmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to delete log index", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to delete log index", "err", err)
+	}
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"math/big"
+	"reflect"
 	"testing"
 
 	"github.com/ccmchain/go-ccmchain/common"
@@ -106,3 +107,41 @@ func TestLookupStorage(t *testing.T) {
 		})
 	}
 }
+
+// Tests that log index entries can be stored, retrieved and dropped per section.
+func TestLogIndexStorage(t *testing.T) {
+	db := NewMemoryDatabase()
+
+	var (
+		addr  = common.Address{0x01}
+		topic = common.Hash{0x02}
+		head1 = common.Hash{0x03}
+		head2 = common.Hash{0x04}
+	)
+	entries := []LogIndexEntry{{Number: 1, Logs: []uint{0, 2}}, {Number: 7, Logs: []uint{1}}}
+
+	WriteLogIndex(db, 0, head1, addr.Bytes(), entries)
+	WriteLogIndex(db, 0, head2, topic.Bytes(), entries[:1])
+	WriteLogIndex(db, 1, head1, addr.Bytes(), entries[1:])
+
+	if have := ReadLogIndex(db, 0, head1, addr.Bytes()); !reflect.DeepEqual(have, entries) {
+		t.Fatalf("address entries mismatch: have %v, want %v", have, entries)
+	}
+	if have := ReadLogIndex(db, 0, head2, topic.Bytes()); !reflect.DeepEqual(have, entries[:1]) {
+		t.Fatalf("topic entries mismatch: have %v, want %v", have, entries[:1])
+	}
+	if have := ReadLogIndex(db, 0, head2, addr.Bytes()); have != nil {
+		t.Fatalf("entries found for different section head: %v", have)
+	}
+	// Drop the first section and ensure only that is gone
+	DeleteLogIndexSection(db, 0)
+	if have := ReadLogIndex(db, 0, head1, addr.Bytes()); have != nil {
+		t.Fatalf("deleted address entries returned: %v", have)
+	}
+	if have := ReadLogIndex(db, 0, head2, topic.Bytes()); have != nil {
+		t.Fatalf("deleted topic entries returned: %v", have)
+	}
+	if have := ReadLogIndex(db, 1, head1, addr.Bytes()); !reflect.DeepEqual(have, entries[1:]) {
+		t.Fatalf("unrelated section entries mismatch: have %v, want %v", have, entries[1:])
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/steakknife/bloomfilter"
+)
+
+// stateBloomHasher is a wrapper around a byte blob to satisfy the interface API
+// requirements of the bloom library used. It's used to convert a trie hash or
+// contract code hash into a 64 bit mini hash.
+type stateBloomHasher []byte
+
+func (f stateBloomHasher) Write(p []byte) (n int, err error) { panic("not implemented") }
+func (f stateBloomHasher) Sum(b []byte) []byte               { panic("not implemented") }
+func (f stateBloomHasher) Reset()                            { panic("not implemented") }
+func (f stateBloomHasher) BlockSize() int                    { panic("not implemented") }
+func (f stateBloomHasher) Size() int                         { return 8 }
+func (f stateBloomHasher) Sum64() uint64                     { return binary.BigEndian.Uint64(f) }
+
+// stateBloom is a bloom filter used during the state pruning to track all the
+// trie nodes and contract codes reachable from the retained state roots. Any
+// hash not contained in it is surely stale and can be deleted.
+//
+// False positives are harmless: the affected stale entries are simply retained
+// until the next pruning run.
+type stateBloom struct {
+	bloom *bloomfilter.Filter
+}
+
+// newStateBloom creates a brand new state bloom of the given size (in megabytes).
+// The bloom is hard coded to use 4 filters.
+func newStateBloom(memory uint64) (*stateBloom, error) {
+	bloom, err := bloomfilter.New(memory*1024*1024*8, 4)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Allocated state bloom", "size", common.StorageSize(memory*1024*1024))
+	return &stateBloom{bloom: bloom}, nil
+}
+
+// Put marks the given trie node or contract code hash as reachable.
+func (b *stateBloom) Put(hash []byte) {
+	b.bloom.Add(stateBloomHasher(hash))
+}
+
+// Contain returns whccmer the given hash might have been marked reachable. It
+// only returns false if the hash was surely never added.
+func (b *stateBloom) Contain(hash []byte) bool {
+	return b.bloom.Contains(stateBloomHasher(hash))
+}
+
+// errorRate calculates the probability of a random containment test returning a
+// false positive (same formula as the fast sync bloom, the library's is wrong).
+func (b *stateBloom) errorRate() float64 {
+	k := float64(b.bloom.K())
+	n := float64(b.bloom.N())
+	m := float64(b.bloom.M())
+
+	return math.Pow(1.0-math.Exp((-k)*(n+0.5)/(m-1)), k)
+}
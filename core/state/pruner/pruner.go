@@ -0,0 +1,233 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package pruner implements offline pruning of stale state data.
+package pruner
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/log"
+)
+
+// errMissingHeadState is returned if the state of the current head block is not
+// available in the database, in which case pruning would destroy the node.
+var errMissingHeadState = errors.New("head state missing")
+
+// Pruner is an offline tool to prune the stale state data of a chain database.
+// It marks all the trie nodes and contract codes reachable from a set of recent
+// state roots in a bloom filter and deletes every other trie node and contract
+// code from the key-value store.
+//
+// The pruner must only ever be run on a database that is not used by a live
+// node, since any trie node written concurrently would be deleted.
+type Pruner struct {
+	db    ccmdb.Database
+	bloom *stateBloom
+}
+
+// NewPruner creates a state pruner on top of the given database, allocating a
+// bloom filter of the given size (in megabytes) for the mark phase.
+func NewPruner(db ccmdb.Database, bloomSize uint64) (*Pruner, error) {
+	bloom, err := newStateBloom(bloomSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Pruner{db: db, bloom: bloom}, nil
+}
+
+// Prune retains the state of the latest retain blocks of the canonical chain
+// (those which are still available on disk) and that of the genesis block, and
+// deletes all other trie nodes and contract codes from the database.
+func (p *Pruner) Prune(retain uint64) error {
+	roots, err := p.retainedRoots(retain)
+	if err != nil {
+		return err
+	}
+	// Mark all the state reachable from the retained roots
+	start := time.Now()
+	for i, root := range roots {
+		if err := p.mark(root); err != nil {
+			// The head state must be complete, otherwise we can't safely prune
+			if i == 0 {
+				return fmt.Errorf("failed to mark head state %x: %v", root, err)
+			}
+			// Older states might have been partially pruned by previous runs
+			// (their root surviving as a false positive), skip them
+			log.Warn("Skipping incomplete state", "root", root, "err", err)
+		}
+	}
+	log.Info("Marked retained state", "roots", len(roots), "items", p.bloom.bloom.N(),
+		"errorrate", p.bloom.errorRate(), "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Sweep all the unmarked trie nodes and contract codes from the database
+	if err := p.sweep(); err != nil {
+		return err
+	}
+	// If the persisted state snapshot belongs to a pruned state, it cannot be
+	// loaded or resumed any more; drop its root to force a regeneration.
+	if snapRoot := rawdb.ReadSnapshotRoot(p.db); snapRoot != (common.Hash{}) {
+		retained := false
+		for _, root := range roots {
+			if root == snapRoot {
+				retained = true
+				break
+			}
+		}
+		if !retained {
+			log.Warn("Discarding pruned state snapshot", "root", snapRoot)
+			rawdb.DeleteSnapshotRoot(p.db)
+		}
+	}
+	// Compact the database to actually reclaim the disk space. The data is already
+	// pruned at this point, so a failure (e.g. unsupported by the store) is fine.
+	start = time.Now()
+	log.Info("Compacting database")
+	if err := p.db.Compact(nil, nil); err != nil {
+		log.Warn("Failed to compact database", "err", err)
+		return nil
+	}
+	log.Info("Compacted database", "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// retainedRoots collects the state roots to retain: the head state first, then
+// the available states of the preceding retain-1 canonical blocks and finally
+// the genesis state. An error is returned if the head state is missing.
+func (p *Pruner) retainedRoots(retain uint64) ([]common.Hash, error) {
+	headHash := rawdb.ReadHeadBlockHash(p.db)
+	if headHash == (common.Hash{}) {
+		return nil, errors.New("head block missing")
+	}
+	number := rawdb.ReadHeaderNumber(p.db, headHash)
+	if number == nil {
+		return nil, fmt.Errorf("head block %x number missing", headHash)
+	}
+	head := rawdb.ReadHeader(p.db, headHash, *number)
+	if head == nil {
+		return nil, fmt.Errorf("head block %x header missing", headHash)
+	}
+	sdb := state.NewDatabase(p.db)
+	if _, err := sdb.OpenTrie(head.Root); err != nil {
+		return nil, fmt.Errorf("%v: block #%d [%x], root %x", errMissingHeadState, *number, headHash, head.Root)
+	}
+	var (
+		roots = []common.Hash{head.Root}
+		seen  = map[common.Hash]bool{head.Root: true}
+	)
+	add := func(n uint64) {
+		header := rawdb.ReadHeader(p.db, rawdb.ReadCanonicalHash(p.db, n), n)
+		if header == nil || seen[header.Root] {
+			return
+		}
+		seen[header.Root] = true
+
+		if _, err := sdb.OpenTrie(header.Root); err != nil {
+			log.Debug("Skipping unavailable state", "number", n, "root", header.Root)
+			return
+		}
+		roots = append(roots, header.Root)
+	}
+	for i := uint64(1); i < retain && i <= *number; i++ {
+		add(*number - i)
+	}
+	add(0)
+	return roots, nil
+}
+
+// mark iterates the entire state belonging to the given root, including all the
+// storage tries and contract codes, and adds every hash to the bloom filter.
+func (p *Pruner) mark(root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(p.db))
+	if err != nil {
+		return err
+	}
+	var (
+		nodes  int
+		start  = time.Now()
+		logged = time.Now()
+	)
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+		// Embedded nodes don't have a hash and aren't stored standalone
+		if it.Hash == (common.Hash{}) {
+			continue
+		}
+		p.bloom.Put(it.Hash[:])
+		nodes++
+
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Marking state", "root", root, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if it.Error != nil {
+		return it.Error
+	}
+	log.Info("Marked state", "root", root, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// sweep iterates over the entire key-value store and deletes all the trie nodes
+// and contract codes not marked in the bloom filter.
+func (p *Pruner) sweep() error {
+	var (
+		count  int
+		size   common.StorageSize
+		start  = time.Now()
+		logged = time.Now()
+		batch  = p.db.NewBatch()
+	)
+	it := p.db.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		// Trie nodes and contract codes are stored keyed by their hash alone, all
+		// other data types are prefixed and thus have a different key length.
+		key := it.Key()
+		if len(key) != common.HashLength || p.bloom.Contain(key) {
+			continue
+		}
+		batch.Delete(key)
+		count++
+		size += common.StorageSize(len(key) + len(it.Value()))
+
+		if batch.ValueSize() > ccmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Pruned state data", "nodes", count, "size", size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
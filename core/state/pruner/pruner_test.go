@@ -0,0 +1,164 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+
+	// testContract stores the block number into the slot keyed by the block
+	// number, growing its storage trie with every call.
+	testContract = common.HexToAddress("0xaaaa")
+	testCode     = common.FromHex("0x43435500")
+)
+
+// newTestChain imports a chain of the given length into an archive node (all
+// states persisted) and returns the database and the imported blocks.
+func newTestChain(t *testing.T, n int) (ccmdb.Database, []*types.Block) {
+	gspec := &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc: core.GenesisAlloc{
+			testAddress:  {Balance: big.NewInt(1000000000000000000)},
+			testContract: {Code: testCode, Balance: big.NewInt(0)},
+		},
+	}
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+
+	signer := types.HomesteadSigner{}
+	blocks, _ := core.GenerateChain(gspec.Config, genesis, ccmash.NewFaker(), gendb, n, func(i int, block *core.BlockGen) {
+		for _, to := range []common.Address{{byte(i)}, testContract} {
+			tx, err := types.SignTx(types.NewTransaction(block.TxNonce(testAddress), to, big.NewInt(1), 100000, new(big.Int), nil), signer, testKey)
+			if err != nil {
+				t.Fatalf("failed to sign transaction: %v", err)
+			}
+			block.AddTx(tx)
+		}
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, &core.CacheConfig{TrieDirtyDisabled: true}, gspec.Config, ccmash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import chain: %v", err)
+	}
+	chain.Stop()
+
+	return db, blocks
+}
+
+// checkState ensures that the entire state belonging to a root is present.
+func checkState(db ccmdb.Database, root common.Hash) error {
+	statedb, err := state.New(root, state.NewDatabase(db))
+	if err != nil {
+		return err
+	}
+	it := state.NewNodeIterator(statedb)
+	for it.Next() {
+	}
+	return it.Error
+}
+
+// Tests that pruning retains the recent and genesis states intact and deletes
+// all the older ones.
+func TestPruneState(t *testing.T) {
+	db, blocks := newTestChain(t, 32)
+
+	// Pretend a state snapshot was left behind for an old block
+	rawdb.WriteSnapshotRoot(db, blocks[10].Root())
+
+	pruner, err := NewPruner(db, 1)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(4); err != nil {
+		t.Fatalf("failed to prune state: %v", err)
+	}
+	// Ensure the retained states are fully available
+	for _, block := range blocks[len(blocks)-4:] {
+		if err := checkState(db, block.Root()); err != nil {
+			t.Errorf("block #%d: retained state unavailable: %v", block.NumberU64(), err)
+		}
+	}
+	genesis := rawdb.ReadHeader(db, rawdb.ReadCanonicalHash(db, 0), 0)
+	if err := checkState(db, genesis.Root); err != nil {
+		t.Errorf("genesis state unavailable: %v", err)
+	}
+	if code, _ := db.Get(crypto.Keccak256(testCode)); len(code) == 0 {
+		t.Errorf("contract code pruned")
+	}
+	// Ensure the old states have been pruned
+	for _, block := range blocks[:len(blocks)-4] {
+		if ok, _ := db.Has(block.Root().Bytes()); ok {
+			t.Errorf("block #%d: stale state root retained", block.NumberU64())
+		}
+	}
+	// Ensure the chain data itself is untouched and the stale snapshot dropped
+	if head := rawdb.ReadHeadBlockHash(db); head != blocks[len(blocks)-1].Hash() {
+		t.Errorf("head block mismatch: have %x, want %x", head, blocks[len(blocks)-1].Hash())
+	}
+	if root := rawdb.ReadSnapshotRoot(db); root != (common.Hash{}) {
+		t.Errorf("stale snapshot root retained: %x", root)
+	}
+	// Pruning again should be a noop for the retained states
+	if err := pruner.Prune(4); err != nil {
+		t.Fatalf("failed to prune state again: %v", err)
+	}
+	if err := checkState(db, blocks[len(blocks)-1].Root()); err != nil {
+		t.Errorf("head state unavailable after second prune: %v", err)
+	}
+}
+
+// Tests that pruning is refused if the head state is missing.
+func TestPruneMissingHeadState(t *testing.T) {
+	db, blocks := newTestChain(t, 8)
+
+	root := blocks[len(blocks)-1].Root()
+	db.Delete(root.Bytes())
+
+	pruner, err := NewPruner(db, 1)
+	if err != nil {
+		t.Fatalf("failed to create pruner: %v", err)
+	}
+	if err := pruner.Prune(4); err == nil || !strings.Contains(err.Error(), errMissingHeadState.Error()) {
+		t.Fatalf("pruning error mismatch: have %v, want %v", err, errMissingHeadState)
+	}
+	// Ensure nothing was deleted
+	if err := checkState(db, blocks[len(blocks)-2].Root()); err != nil {
+		t.Errorf("state deleted on refused prune: %v", err)
+	}
+}
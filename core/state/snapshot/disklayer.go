@@ -0,0 +1,228 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/allegro/bigcache"
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/rlp"
+	"github.com/ccmchain/go-ccmchain/trie"
+)
+
+// diskLayer is a low level persistent snapshot built on top of a key-value store.
+type diskLayer struct {
+	diskdb ccmdb.KeyValueStore // Key-value store containing the base snapshot
+	triedb *trie.Database      // Trie node cache for reconstruction purposes
+	cache  *bigcache.BigCache  // Cache to avoid hitting the disk for direct access
+
+	root  common.Hash // Root hash of the base snapshot
+	stale bool        // Signals that the layer became stale (state progressed)
+
+	genMarker []byte           // Marker for the state that's indexed during initial layer generation
+	genAbort  chan chan []byte // Notification channel to abort generating the snapshot in this layer
+	genDone   chan struct{}    // Channel closed when the generator of this layer terminates
+	lock      sync.RWMutex
+}
+
+// newDiskCache creates the read cache of the disk layer with the given memory
+// allowance in megabytes, or nil if caching is disabled.
+func newDiskCache(cache int) *bigcache.BigCache {
+	if cache <= 0 {
+		return nil
+	}
+	cleans, _ := bigcache.NewBigCache(bigcache.Config{
+		Shards:             1024,
+		LifeWindow:         time.Hour,
+		MaxEntriesInWindow: cache * 1024,
+		MaxEntrySize:       512,
+		HardMaxCacheSize:   cache,
+	})
+	return cleans
+}
+
+// loadSnapshot loads a pre-existing state snapshot backed by a key-value store.
+// If the snapshot was only partially generated, generation is resumed from the
+// last persisted marker.
+func loadSnapshot(diskdb ccmdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash) (snapshot, error) {
+	// Retrieve the block number and hash of the snapshot, failing if no snapshot
+	// is present in the database (or crashed mid-update).
+	baseRoot := rawdb.ReadSnapshotRoot(diskdb)
+	if baseRoot == (common.Hash{}) {
+		return nil, fmt.Errorf("missing or corrupted snapshot")
+	}
+	if baseRoot != root {
+		return nil, fmt.Errorf("head doesn't match snapshot: have %#x, want %#x", baseRoot, root)
+	}
+	base := &diskLayer{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  newDiskCache(cache),
+		root:   baseRoot,
+	}
+	if marker := rawdb.ReadSnapshotGenerator(diskdb); marker != nil {
+		base.startGeneration(marker)
+	}
+	return base, nil
+}
+
+// Root returns root hash for which this snapshot was made.
+func (dl *diskLayer) Root() common.Hash {
+	return dl.root
+}
+
+// Parent always returns nil as there's no layer below the disk.
+func (dl *diskLayer) Parent() snapshot {
+	return nil
+}
+
+// Stale return whccmer this layer has become stale (was flattened across) or if
+// it's still live.
+func (dl *diskLayer) Stale() bool {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.stale
+}
+
+// Account directly retrieves the account associated with a particular hash in
+// the snapshot slim data format.
+func (dl *diskLayer) Account(hash common.Hash) (*Account, error) {
+	data, err := dl.AccountRLP(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 { // can be both nil and []byte{}
+		return nil, nil
+	}
+	account := new(Account)
+	if err := rlp.DecodeBytes(data, account); err != nil {
+		panic(err)
+	}
+	return account, nil
+}
+
+// AccountRLP directly retrieves the account RLP associated with a particular
+// hash in the snapshot slim data format.
+func (dl *diskLayer) AccountRLP(hash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	// If the layer is being generated, ensure the requested hash has already been
+	// covered by the generator.
+	if dl.genMarker != nil && !covered(hash[:], dl.genMarker) {
+		return nil, ErrNotCoveredYet
+	}
+	// Try to retrieve the account from the memory cache
+	if dl.cache != nil {
+		if blob, err := dl.cache.Get(string(hash[:])); err == nil {
+			snapshotCleanAccountHitMeter.Mark(1)
+			return blob, nil
+		}
+	}
+	// Cache doesn't contain account, pull from disk and cache for later
+	blob := rawdb.ReadAccountSnapshot(dl.diskdb, hash)
+	if dl.cache != nil {
+		dl.cache.Set(string(hash[:]), blob)
+	}
+	snapshotCleanAccountMissMeter.Mark(1)
+	return blob, nil
+}
+
+// Storage directly retrieves the storage data associated with a particular hash,
+// within a particular account.
+func (dl *diskLayer) Storage(accountHash, storageHash common.Hash) ([]byte, error) {
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	// If the layer was flattened into, consider it invalid (any live reference to
+	// the original should be marked as unusable).
+	if dl.stale {
+		return nil, ErrSnapshotStale
+	}
+	key := append(accountHash[:], storageHash[:]...)
+
+	// If the layer is being generated, ensure the requested hash has already been
+	// covered by the generator.
+	if dl.genMarker != nil && !covered(key, dl.genMarker) {
+		return nil, ErrNotCoveredYet
+	}
+	// Try to retrieve the storage slot from the memory cache
+	if dl.cache != nil {
+		if blob, err := dl.cache.Get(string(key)); err == nil {
+			snapshotCleanStorageHitMeter.Mark(1)
+			return blob, nil
+		}
+	}
+	// Cache doesn't contain storage slot, pull from disk and cache for later
+	blob := rawdb.ReadStorageSnapshot(dl.diskdb, accountHash, storageHash)
+	if dl.cache != nil {
+		dl.cache.Set(string(key), blob)
+	}
+	snapshotCleanStorageMissMeter.Mark(1)
+	return blob, nil
+}
+
+// Update creates a new layer on top of the existing snapshot diff tree with
+// the specified data items. Note, the maps are retained by the method to avoid
+// copying everything.
+func (dl *diskLayer) Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer {
+	return newDiffLayer(dl, blockRoot, destructs, accounts, storage)
+}
+
+// startGeneration starts a background generator filling the disk layer from
+// the state trie, continuing after the given marker.
+func (dl *diskLayer) startGeneration(marker []byte) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	dl.genMarker = marker
+	dl.genAbort = make(chan chan []byte)
+	dl.genDone = make(chan struct{})
+
+	go dl.generate(marker, dl.genAbort, dl.genDone)
+}
+
+// stopGeneration aborts the background generator of the disk layer, if any is
+// running, and returns the marker up to which the snapshot was generated. A nil
+// marker is returned if the layer is fully generated.
+func (dl *diskLayer) stopGeneration() []byte {
+	dl.lock.Lock()
+	abort := dl.genAbort
+	dl.genAbort = nil
+	dl.lock.Unlock()
+
+	if abort != nil {
+		ch := make(chan []byte)
+		abort <- ch
+		return <-ch
+	}
+	dl.lock.RLock()
+	defer dl.lock.RUnlock()
+
+	return dl.genMarker
+}
@@ -0,0 +1,296 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/rlp"
+	"github.com/ccmchain/go-ccmchain/trie"
+)
+
+// generatorStats is a collection of statistics gathered by the snapshot generator
+// for logging purposes.
+type generatorStats struct {
+	start    time.Time          // Timestamp when generation started
+	accounts uint64             // Number of accounts indexed
+	slots    uint64             // Number of storage slots indexed
+	storage  common.StorageSize // Account and storage slot size
+}
+
+// Log creates an contextual log with the given message and the context pulled
+// from the internally maintained statistics.
+func (gs *generatorStats) Log(msg string, root common.Hash, marker []byte) {
+	var ctx []interface{}
+	if root != (common.Hash{}) {
+		ctx = append(ctx, []interface{}{"root", root}...)
+	}
+	// Figure out whccmer we're after or within an account
+	switch len(marker) {
+	case common.HashLength:
+		ctx = append(ctx, []interface{}{"at", common.BytesToHash(marker)}...)
+	case 2 * common.HashLength:
+		ctx = append(ctx, []interface{}{
+			"in", common.BytesToHash(marker[:common.HashLength]),
+			"at", common.BytesToHash(marker[common.HashLength:]),
+		}...)
+	}
+	// Add the usual measurements
+	ctx = append(ctx, []interface{}{
+		"accounts", gs.accounts,
+		"slots", gs.slots,
+		"storage", gs.storage,
+		"elapsed", common.PrettyDuration(time.Since(gs.start)),
+	}...)
+	// Calculate the estimated indexing time based on current stats
+	if len(marker) > 0 {
+		if done := binary.BigEndian.Uint64(marker[:8]); done > 0 {
+			left := ^uint64(0) - binary.BigEndian.Uint64(marker[:8])
+
+			speed := done/uint64(time.Since(gs.start)/time.Millisecond+1) + 1 // +1s to avoid division by zero
+			ctx = append(ctx, []interface{}{
+				"eta", common.PrettyDuration(time.Duration(left/speed) * time.Millisecond),
+			}...)
+		}
+	}
+	log.Info(msg, ctx...)
+}
+
+// generateSnapshot regenerates a brand new snapshot based on an existing state
+// database and head block asynchronously. The snapshot is returned immediately
+// and generation is continued in the background until done.
+func generateSnapshot(diskdb ccmdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash) *diskLayer {
+	// Mark the snapshot as being generated from scratch, any leftover data will
+	// be wiped by the generator before it starts indexing.
+	batch := diskdb.NewBatch()
+
+	rawdb.WriteSnapshotRoot(batch, root)
+	rawdb.WriteSnapshotGenerator(batch, []byte{})
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write initialized state marker", "err", err)
+	}
+	base := &diskLayer{
+		diskdb: diskdb,
+		triedb: triedb,
+		root:   root,
+		cache:  newDiskCache(cache),
+	}
+	base.startGeneration([]byte{})
+	return base
+}
+
+// wipeSnapshot deletes all the account and storage snapshot entries from the
+// database. If an abort request arrives, the wipe is interrupted and the request
+// channel returned.
+func wipeSnapshot(db ccmdb.KeyValueStore, abort chan chan []byte) chan []byte {
+	batch := db.NewBatch()
+	for _, prefix := range [][]byte{rawdb.SnapshotAccountPrefix, rawdb.SnapshotStoragePrefix} {
+		// The snapshot entries share their single byte prefix with trie nodes,
+		// so filter on the exact key length too
+		keylen := len(prefix) + common.HashLength
+		if bytes.Equal(prefix, rawdb.SnapshotStoragePrefix) {
+			keylen += common.HashLength
+		}
+		it := db.NewIteratorWithPrefix(prefix)
+		for it.Next() {
+			if len(it.Key()) != keylen {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() > ccmdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					log.Crit("Failed to wipe state snapshot", "err", err)
+				}
+				batch.Reset()
+
+				select {
+				case ch := <-abort:
+					it.Release()
+					return ch
+				default:
+				}
+			}
+		}
+		it.Release()
+	}
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to wipe state snapshot", "err", err)
+	}
+	return nil
+}
+
+// generate is a background thread that iterates over the state and storage tries,
+// constructing the state snapshot. All the arguments are purely for statistics
+// gathering and logging, since the method surfs the blocks as they arrive, often
+// being restarted.
+func (dl *diskLayer) generate(marker []byte, abort chan chan []byte, done chan struct{}) {
+	var (
+		stats  = &generatorStats{start: time.Now()}
+		logged = time.Now()
+		batch  = dl.diskdb.NewBatch()
+	)
+	// A fresh generation needs to get rid of any stale snapshot data first
+	if len(marker) == 0 {
+		if ch := wipeSnapshot(dl.diskdb, abort); ch != nil {
+			close(done)
+			ch <- marker
+			return
+		}
+		stats.Log("Starting state snapshot generation", dl.root, marker)
+	} else {
+		stats.Log("Resuming state snapshot generation", dl.root, marker)
+	}
+
+	// checkAndFlush persists the generated data when the batch grows large enough
+	// or the generator is requested to stop, moving the marker forward.
+	checkAndFlush := func(current []byte) chan []byte {
+		var ch chan []byte
+		select {
+		case ch = <-abort:
+		default:
+		}
+		if batch.ValueSize() > ccmdb.IdealBatchSize || ch != nil {
+			// Persist the progress marker along the data so we can resume later
+			rawdb.WriteSnapshotGenerator(batch, current)
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write state snapshot", "err", err)
+			}
+			batch.Reset()
+
+			dl.lock.Lock()
+			dl.genMarker = current
+			dl.lock.Unlock()
+
+			if ch != nil {
+				stats.Log("Aborting state snapshot generation", dl.root, current)
+				return ch
+			}
+		}
+		if time.Since(logged) > 8*time.Second {
+			stats.Log("Generating state snapshot", dl.root, current)
+			logged = time.Now()
+		}
+		return nil
+	}
+	// failed logs a generation error and waits for the layer to be discarded,
+	// retaining the last persisted marker.
+	failed := func(msg string, err error) {
+		log.Error(msg, "root", dl.root, "err", err)
+		close(done)
+
+		dl.lock.RLock()
+		current := dl.genMarker
+		dl.lock.RUnlock()
+
+		ch := <-abort
+		ch <- current
+	}
+	accTrie, err := trie.NewSecure(dl.root, dl.triedb)
+	if err != nil {
+		failed("Generator failed to access account trie", err)
+		return
+	}
+	var accMarker []byte
+	if len(marker) > 0 {
+		accMarker = marker[:common.HashLength]
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(accMarker))
+	for accIt.Next() {
+		// Retrieve the current account and flatten it into the internal format
+		accountHash := common.BytesToHash(accIt.Key)
+
+		var acc struct {
+			Nonce    uint64
+			Balance  *big.Int
+			Root     common.Hash
+			CodeHash []byte
+		}
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			log.Crit("Invalid account encountered during snapshot creation", "err", err)
+		}
+		data := AccountRLP(acc.Nonce, acc.Balance, acc.Root, acc.CodeHash)
+
+		rawdb.WriteAccountSnapshot(batch, accountHash, data)
+		stats.storage += common.StorageSize(1 + common.HashLength + len(data))
+		stats.accounts++
+
+		if ch := checkAndFlush(accountHash[:]); ch != nil {
+			close(done)
+			ch <- accountHash[:]
+			return
+		}
+		// If the iterated account is a contract, iterate through corresponding contract
+		// storage to generate snapshot entries.
+		if acc.Root != emptyRoot {
+			var storeMarker []byte
+			if len(marker) > common.HashLength && bytes.Equal(accountHash[:], marker[:common.HashLength]) {
+				storeMarker = marker[common.HashLength:]
+			}
+			storeTrie, err := trie.NewSecure(acc.Root, dl.triedb)
+			if err != nil {
+				failed("Generator failed to access storage trie", err)
+				return
+			}
+			storeIt := trie.NewIterator(storeTrie.NodeIterator(storeMarker))
+			for storeIt.Next() {
+				rawdb.WriteStorageSnapshot(batch, accountHash, common.BytesToHash(storeIt.Key), storeIt.Value)
+				stats.storage += common.StorageSize(1 + 2*common.HashLength + len(storeIt.Value))
+				stats.slots++
+
+				current := append(accountHash[:], storeIt.Key...)
+				if ch := checkAndFlush(current); ch != nil {
+					close(done)
+					ch <- current
+					return
+				}
+			}
+			if storeIt.Err != nil {
+				failed("Generator failed to iterate storage trie", storeIt.Err)
+				return
+			}
+		}
+		// Resumption only applies to the first account, reset for the rest
+		marker = nil
+	}
+	if accIt.Err != nil {
+		failed("Generator failed to iterate account trie", accIt.Err)
+		return
+	}
+	// Snapshot fully generated, set the marker to nil
+	rawdb.DeleteSnapshotGenerator(batch)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write state snapshot", "err", err)
+	}
+	log.Info("Generated state snapshot", "accounts", stats.accounts, "slots", stats.slots,
+		"storage", stats.storage, "elapsed", common.PrettyDuration(time.Since(stats.start)))
+
+	dl.lock.Lock()
+	dl.genMarker = nil
+	dl.lock.Unlock()
+	close(done)
+
+	// Someone will be looking for us, wait it out
+	ch := <-abort
+	ch <- nil
+}
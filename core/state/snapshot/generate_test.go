@@ -0,0 +1,161 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/rlp"
+	"github.com/ccmchain/go-ccmchain/trie"
+)
+
+// makeTestState creates a state trie with a number of accounts, every third
+// one having some storage slots, and commits it into the returned database.
+func makeTestState(t *testing.T, accounts int) (ccmdb.Database, *trie.Database, common.Hash) {
+	var (
+		diskdb = rawdb.NewMemoryDatabase()
+		triedb = trie.NewDatabase(diskdb)
+	)
+	accTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+	for i := 0; i < accounts; i++ {
+		root := emptyRoot
+		if i%3 == 0 {
+			stTrie, _ := trie.NewSecure(common.Hash{}, triedb)
+			for j := 1; j <= i%7+1; j++ {
+				val, _ := rlp.EncodeToBytes(big.NewInt(int64(j)).Bytes())
+				stTrie.Update(common.BigToHash(big.NewInt(int64(j))).Bytes(), val)
+			}
+			root, _ = stTrie.Commit(nil)
+		}
+		acc := struct {
+			Nonce    uint64
+			Balance  *big.Int
+			Root     common.Hash
+			CodeHash []byte
+		}{uint64(i), big.NewInt(int64(i) * 1000), root, emptyCode[:]}
+
+		blob, _ := rlp.EncodeToBytes(acc)
+		accTrie.Update(common.BigToAddress(big.NewInt(int64(i))).Bytes(), blob)
+	}
+	root, err := accTrie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit account trie: %v", err)
+	}
+	if err := triedb.Commit(root, false); err != nil {
+		t.Fatalf("failed to flush tries: %v", err)
+	}
+	return diskdb, triedb, root
+}
+
+// checkSnapshot iterates the entire state trie and ensures all the leaves are
+// present in the given snapshot layer.
+func checkSnapshot(t *testing.T, snap Snapshot, triedb *trie.Database) {
+	accTrie, err := trie.NewSecure(snap.Root(), triedb)
+	if err != nil {
+		t.Fatalf("failed to open account trie: %v", err)
+	}
+	accIt := trie.NewIterator(accTrie.NodeIterator(nil))
+	for accIt.Next() {
+		var acc struct {
+			Nonce    uint64
+			Balance  *big.Int
+			Root     common.Hash
+			CodeHash []byte
+		}
+		if err := rlp.DecodeBytes(accIt.Value, &acc); err != nil {
+			t.Fatalf("failed to decode account: %v", err)
+		}
+		hash := common.BytesToHash(accIt.Key)
+		have, err := snap.AccountRLP(hash)
+		if err != nil {
+			t.Fatalf("account %x: failed to retrieve: %v", hash, err)
+		}
+		if want := AccountRLP(acc.Nonce, acc.Balance, acc.Root, acc.CodeHash); !bytes.Equal(have, want) {
+			t.Fatalf("account %x: mismatch: have %x, want %x", hash, have, want)
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		stTrie, _ := trie.NewSecure(acc.Root, triedb)
+		stIt := trie.NewIterator(stTrie.NodeIterator(nil))
+		for stIt.Next() {
+			have, err := snap.Storage(hash, common.BytesToHash(stIt.Key))
+			if err != nil {
+				t.Fatalf("slot %x:%x: failed to retrieve: %v", hash, stIt.Key, err)
+			}
+			if !bytes.Equal(have, stIt.Value) {
+				t.Fatalf("slot %x:%x: mismatch: have %x, want %x", hash, stIt.Key, have, stIt.Value)
+			}
+		}
+	}
+}
+
+// Tests that a snapshot generated in the background from a state trie contains
+// exactly the trie leaves, and that leftover data from before is wiped.
+func TestGeneration(t *testing.T) {
+	diskdb, triedb, root := makeTestState(t, 100)
+
+	// Inject some junk which should be wiped, but also a fake trie node sharing
+	// the snapshot prefix which must be left alone
+	junk := randomHash()
+	rawdb.WriteAccountSnapshot(diskdb, junk, randomAccount())
+	rawdb.WriteStorageSnapshot(diskdb, junk, randomHash(), []byte{0x01})
+
+	node := append(common.CopyBytes(rawdb.SnapshotAccountPrefix), make([]byte, common.HashLength-1)...)
+	diskdb.Put(node, []byte{0x01})
+
+	snaps := New(diskdb, triedb, 16, root)
+	snaps.WaitGeneration()
+
+	checkSnapshot(t, snaps.Snapshot(root), triedb)
+	if blob := rawdb.ReadAccountSnapshot(diskdb, junk); len(blob) != 0 {
+		t.Errorf("junk account not wiped")
+	}
+	if ok, _ := diskdb.Has(node); !ok {
+		t.Errorf("non-snapshot data wiped")
+	}
+	if marker := rawdb.ReadSnapshotGenerator(diskdb); marker != nil {
+		t.Errorf("generator marker not cleared: %x", marker)
+	}
+	// Reloading the snapshot should not regenerate anything
+	snaps = New(diskdb, triedb, 16, root)
+	if disk := snaps.Snapshot(root).(*diskLayer); disk.genMarker != nil {
+		t.Errorf("reloaded snapshot is regenerating")
+	}
+	checkSnapshot(t, snaps.Snapshot(root), triedb)
+}
+
+// Tests that an aborted snapshot generation can be resumed from its persisted
+// marker, ending up with the same snapshot as an uninterrupted one.
+func TestGenerationResume(t *testing.T) {
+	diskdb, triedb, root := makeTestState(t, 500)
+
+	snaps := New(diskdb, triedb, 0, root)
+	snaps.Stop()
+
+	// Reload the snapshot, which should resume generation from where it was
+	// interrupted (or not generate at all if it managed to finish)
+	snaps = New(diskdb, triedb, 0, root)
+	snaps.WaitGeneration()
+
+	checkSnapshot(t, snaps.Snapshot(root), triedb)
+}
@@ -0,0 +1,461 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package snapshot implements a layered, flat dump of the state for direct
+// account and storage access.
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ccmchain/go-ccmchain/ccmdb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/metrics"
+	"github.com/ccmchain/go-ccmchain/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256Hash(nil)
+)
+
+var (
+	snapshotCleanAccountHitMeter  = metrics.NewRegisteredMeter("state/snapshot/clean/account/hit", nil)
+	snapshotCleanAccountMissMeter = metrics.NewRegisteredMeter("state/snapshot/clean/account/miss", nil)
+	snapshotCleanStorageHitMeter  = metrics.NewRegisteredMeter("state/snapshot/clean/storage/hit", nil)
+	snapshotCleanStorageMissMeter = metrics.NewRegisteredMeter("state/snapshot/clean/storage/miss", nil)
+
+	snapshotDirtyAccountHitMeter  = metrics.NewRegisteredMeter("state/snapshot/dirty/account/hit", nil)
+	snapshotDirtyAccountMissMeter = metrics.NewRegisteredMeter("state/snapshot/dirty/account/miss", nil)
+	snapshotDirtyStorageHitMeter  = metrics.NewRegisteredMeter("state/snapshot/dirty/storage/hit", nil)
+	snapshotDirtyStorageMissMeter = metrics.NewRegisteredMeter("state/snapshot/dirty/storage/miss", nil)
+
+	snapshotFlushAccountItemMeter = metrics.NewRegisteredMeter("state/snapshot/flush/account/item", nil)
+	snapshotFlushStorageItemMeter = metrics.NewRegisteredMeter("state/snapshot/flush/storage/item", nil)
+
+	// ErrSnapshotStale is returned from data accessors if the underlying snapshot
+	// layer had been invalidated due to the chain progressing forward far enough
+	// to not maintain the layer's original state.
+	ErrSnapshotStale = errors.New("snapshot stale")
+
+	// ErrNotCoveredYet is returned from data accessors if the underlying snapshot
+	// is being generated currently and the requested data item is not yet in the
+	// range of accounts covered.
+	ErrNotCoveredYet = errors.New("not covered yet")
+
+	// errSnapshotCycle is returned if a snapshot is attempted to be inserted
+	// that forms a cycle in the snapshot tree.
+	errSnapshotCycle = errors.New("snapshot cycle")
+)
+
+// Snapshot represents the functionality supported by a snapshot storage layer.
+type Snapshot interface {
+	// Root returns the root hash for which this snapshot was made.
+	Root() common.Hash
+
+	// Account directly retrieves the account associated with a particular hash in
+	// the snapshot slim data format.
+	Account(hash common.Hash) (*Account, error)
+
+	// AccountRLP directly retrieves the account RLP associated with a particular
+	// hash in the snapshot slim data format.
+	AccountRLP(hash common.Hash) ([]byte, error)
+
+	// Storage directly retrieves the storage data associated with a particular hash,
+	// within a particular account.
+	Storage(accountHash, storageHash common.Hash) ([]byte, error)
+}
+
+// snapshot is the internal version of the snapshot data layer that supports some
+// additional methods compared to the public API.
+type snapshot interface {
+	Snapshot
+
+	// Parent returns the subsequent layer of a snapshot, or nil if the base was
+	// reached.
+	Parent() snapshot
+
+	// Update creates a new layer on top of the existing snapshot diff tree with
+	// the specified data items.
+	Update(blockRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) *diffLayer
+
+	// Stale return whccmer this layer has become stale (was flattened across) or
+	// if it's still live.
+	Stale() bool
+}
+
+// Tree is an Ccmchain state snapshot tree. It consists of one persistent base
+// layer backed by a key-value store, on top of which arbitrarily many in-memory
+// diff layers are topped. The memory diffs can form a tree with branching, but
+// the disk layer is singleton and common to all. If a reorg goes deeper than the
+// disk layer, everything needs to be deleted.
+//
+// The goal of a state snapshot is twofold: to allow direct access to account and
+// storage data to avoid expensive multi-level trie lookups; and to allow sorted,
+// cheap iteration of the account/storage tries for sync aid.
+type Tree struct {
+	diskdb ccmdb.KeyValueStore      // Persistent database to store the snapshot
+	triedb *trie.Database           // In-memory cache to access the trie through
+	cache  int                      // Megabytes permitted to use for read caches
+	layers map[common.Hash]snapshot // Collection of all known layers
+	lock   sync.RWMutex
+}
+
+// New attempts to load an already existing snapshot from a persistent key-value
+// store, ensuring that the head of the snapshot matches the expected one.
+//
+// If the snapshot is missing or inconsistent, the entirety is deleted and will
+// be reconstructed from scratch based on the tries in the key-value store, on a
+// background thread.
+func New(diskdb ccmdb.KeyValueStore, triedb *trie.Database, cache int, root common.Hash) *Tree {
+	snap := &Tree{
+		diskdb: diskdb,
+		triedb: triedb,
+		cache:  cache,
+		layers: make(map[common.Hash]snapshot),
+	}
+	// Attempt to load a previously persisted snapshot and rebuild one if failed
+	head, err := loadSnapshot(diskdb, triedb, cache, root)
+	if err != nil {
+		log.Warn("Failed to load snapshot, regenerating", "err", err)
+		head = generateSnapshot(diskdb, triedb, cache, root)
+	}
+	snap.layers[head.Root()] = head
+	return snap
+}
+
+// Snapshot retrieves a snapshot belonging to the given block root, or nil if no
+// snapshot is maintained for that block.
+func (t *Tree) Snapshot(blockRoot common.Hash) Snapshot {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	if layer, ok := t.layers[blockRoot]; ok {
+		return layer
+	}
+	return nil
+}
+
+// Update adds a new snapshot into the tree, if that can be linked to an existing
+// old parent. It is disallowed to insert a disk layer (the origin of all).
+//
+// Account entries in the accounts map are the slim RLP encoded accounts of the
+// accounts alive after the block. Accounts listed in destructs were deleted (or
+// self-destructed and recreated) during the block, wiping their storage. Empty
+// storage values mark slots that were cleared.
+func (t *Tree) Update(blockRoot common.Hash, parentRoot common.Hash, destructs map[common.Hash]struct{}, accounts map[common.Hash][]byte, storage map[common.Hash]map[common.Hash][]byte) error {
+	// Reject noop updates to avoid self-loops in the snapshot tree. This is a
+	// special case that can only happen for Clique networks where empty blocks
+	// don't modify the state (0 block subsidy).
+	if blockRoot == parentRoot {
+		return errSnapshotCycle
+	}
+	// Generate a new snapshot on top of the parent
+	parent := t.Snapshot(parentRoot)
+	if parent == nil {
+		return fmt.Errorf("parent [%#x] snapshot missing", parentRoot)
+	}
+	snap := parent.(snapshot).Update(blockRoot, destructs, accounts, storage)
+
+	// Save the new snapshot for later, unless an identical state is already
+	// tracked (e.g. two sibling blocks producing the same post-state)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, ok := t.layers[blockRoot]; !ok {
+		t.layers[blockRoot] = snap
+	}
+	return nil
+}
+
+// Cap traverses downwards the snapshot tree from a head block hash until the
+// number of allowed layers are crossed. All layers beyond the permitted number
+// are flattened downwards into the persistent disk layer.
+//
+// Note, the final diff layer count in general will be one more than the amount
+// requested. This happens because the bottom-most diff layer is the accumulator
+// which may or may not overflow and cascade to disk.
+func (t *Tree) Cap(root common.Hash, layers int) error {
+	// Retrieve the head snapshot to cap from
+	snap := t.Snapshot(root)
+	if snap == nil {
+		return fmt.Errorf("snapshot [%#x] missing", root)
+	}
+	diff, ok := snap.(*diffLayer)
+	if !ok {
+		// Nothing to flatten if the requested head is already the disk layer
+		return nil
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Gather all the diff layers from the requested head downwards
+	var diffs []*diffLayer
+	for layer := snapshot(diff); ; {
+		current, ok := layer.(*diffLayer)
+		if !ok {
+			break
+		}
+		diffs = append(diffs, current)
+		layer = current.Parent()
+	}
+	if len(diffs) <= layers {
+		return nil
+	}
+	// Flatten everything beyond the permitted layer count into the disk layer,
+	// starting with the oldest diff and moving up towards the head
+	var base *diskLayer
+	for i := len(diffs) - 1; i >= layers; i-- {
+		base = diffToDisk(diffs[i])
+		if i > 0 {
+			diffs[i-1].setParent(base)
+		}
+	}
+	// Remove any layer that does not descend from the new disk layer anymore
+	// (the flattened diffs themselves and all side branches forking below)
+	for root, layer := range t.layers {
+		if !descendsFrom(layer, base) {
+			if diff, ok := layer.(*diffLayer); ok {
+				diff.markStale()
+			}
+			delete(t.layers, root)
+		}
+	}
+	t.layers[base.root] = base
+	return nil
+}
+
+// descendsFrom checks whccmer the given layer is built on top of the specified
+// disk layer.
+func descendsFrom(layer snapshot, base *diskLayer) bool {
+	for {
+		switch current := layer.(type) {
+		case *diskLayer:
+			return current == base
+		case *diffLayer:
+			if current.Stale() {
+				return false
+			}
+			layer = current.Parent()
+		default:
+			return false
+		}
+	}
+}
+
+// Rebuild wipes all available snapshot data from the persistent database and
+// discard all caches and diff layers. Afterwards, it starts a new snapshot
+// generator with the given root hash.
+func (t *Tree) Rebuild(root common.Hash) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// Abort any running generator and invalidate all known layers
+	for _, layer := range t.layers {
+		switch layer := layer.(type) {
+		case *diskLayer:
+			// If the base layer is generating, abort it and mark it stale
+			layer.stopGeneration()
+			layer.lock.Lock()
+			layer.stale = true
+			layer.lock.Unlock()
+
+		case *diffLayer:
+			layer.markStale()
+		}
+	}
+	// Start generating a new snapshot from scratch on a background thread. The
+	// generator will wipe any leftover snapshot data first.
+	log.Info("Rebuilding state snapshot", "root", root)
+	t.layers = map[common.Hash]snapshot{
+		root: generateSnapshot(t.diskdb, t.triedb, t.cache, root),
+	}
+}
+
+// Stop aborts any background snapshot generation, persisting its progress so
+// that it can be resumed on the next startup.
+func (t *Tree) Stop() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for _, layer := range t.layers {
+		if disk, ok := layer.(*diskLayer); ok {
+			disk.stopGeneration()
+		}
+	}
+}
+
+// WaitGeneration blocks until the background generation of the disk layer is
+// finished (or aborted). It is mostly useful for tests that need to inspect a
+// fully populated snapshot.
+func (t *Tree) WaitGeneration() {
+	var prev *diskLayer
+	for {
+		// Find the current disk layer, it might have been replaced by a cap
+		t.lock.RLock()
+		var disk *diskLayer
+		for _, layer := range t.layers {
+			if layer, ok := layer.(*diskLayer); ok {
+				disk = layer
+			}
+		}
+		t.lock.RUnlock()
+
+		if disk == nil || disk == prev {
+			return
+		}
+		disk.lock.RLock()
+		done, marker := disk.genDone, disk.genMarker
+		disk.lock.RUnlock()
+
+		if marker == nil || done == nil {
+			return
+		}
+		<-done
+		prev = disk
+	}
+}
+
+// diffToDisk merges a bottom-most diff into the persistent disk layer underneath
+// it. The method will panic if called onto a non-bottom-most diff layer.
+func diffToDisk(bottom *diffLayer) *diskLayer {
+	var (
+		base  = bottom.Parent().(*diskLayer)
+		batch = base.diskdb.NewBatch()
+	)
+	// Abort any running generator, the new disk layer will continue from the
+	// position where this one stopped
+	progress := base.stopGeneration()
+
+	// Mark the original base as stale as we're going to create a new wrapper
+	base.lock.Lock()
+	if base.stale {
+		panic("parent disk layer is stale") // we've committed into the same base from two children, boo
+	}
+	base.stale = true
+	base.lock.Unlock()
+
+	// Destroy all the destructed accounts from the database
+	for hash := range bottom.destructSet {
+		// Skip any account not covered yet by the snapshot
+		if progress != nil && !covered(hash[:], progress) {
+			continue
+		}
+		rawdb.DeleteAccountSnapshot(batch, hash)
+		if base.cache != nil {
+			base.cache.Set(string(hash[:]), nil)
+		}
+		it := rawdb.IterateStorageSnapshots(base.diskdb, hash)
+		for it.Next() {
+			// Only storage snapshot entries are wiped, anything else sharing the
+			// prefix (e.g. trie nodes) is left alone
+			if key := it.Key(); len(key) == len(rawdb.SnapshotStoragePrefix)+2*common.HashLength {
+				batch.Delete(key)
+				if base.cache != nil {
+					base.cache.Delete(string(key[1:]))
+				}
+			}
+		}
+		it.Release()
+	}
+	// Push all updated accounts into the database
+	for hash, data := range bottom.accountData {
+		// Skip any account not covered yet by the snapshot
+		if progress != nil && !covered(hash[:], progress) {
+			continue
+		}
+		// Push the account to disk
+		rawdb.WriteAccountSnapshot(batch, hash, data)
+		if base.cache != nil {
+			base.cache.Set(string(hash[:]), data)
+		}
+		snapshotFlushAccountItemMeter.Mark(1)
+
+		// Ensure we don't write too much data blindly. It's ok to flush, the
+		// root will go missing in case of a crash and we'll detect and regen
+		// the snapshot.
+		if batch.ValueSize() > ccmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write account snapshot", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	// Push all the storage slots into the database
+	for accountHash, storage := range bottom.storageData {
+		// Skip any account not covered yet by the snapshot
+		if progress != nil && !covered(accountHash[:], progress) {
+			continue
+		}
+		for storageHash, data := range storage {
+			// Skip any slot not covered yet by the snapshot
+			key := append(accountHash[:], storageHash[:]...)
+			if progress != nil && !covered(key, progress) {
+				continue
+			}
+			if len(data) > 0 {
+				rawdb.WriteStorageSnapshot(batch, accountHash, storageHash, data)
+			} else {
+				rawdb.DeleteStorageSnapshot(batch, accountHash, storageHash)
+			}
+			if base.cache != nil {
+				base.cache.Set(string(key), data)
+			}
+			snapshotFlushStorageItemMeter.Mark(1)
+		}
+		if batch.ValueSize() > ccmdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				log.Crit("Failed to write storage snapshot", "err", err)
+			}
+			batch.Reset()
+		}
+	}
+	// Update the snapshot block marker and write any remainder data
+	rawdb.WriteSnapshotRoot(batch, bottom.root)
+	if err := batch.Write(); err != nil {
+		log.Crit("Failed to write leftover snapshot", "err", err)
+	}
+	bottom.markStale()
+
+	res := &diskLayer{
+		root:   bottom.root,
+		cache:  base.cache,
+		diskdb: base.diskdb,
+		triedb: base.triedb,
+	}
+	// If snapshot generation hasn't finished yet, port over all the starts and
+	// continue where the previous round left off.
+	if progress != nil {
+		res.startGeneration(progress)
+	}
+	return res
+}
+
+// covered returns whccmer the given snapshot key (account hash, or account and
+// storage hash concatenated) has already been reached by the generator whose
+// last written key is marker.
+func covered(key []byte, marker []byte) bool {
+	return bytes.Compare(key, marker) <= 0
+}
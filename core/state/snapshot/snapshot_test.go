@@ -0,0 +1,193 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+)
+
+// randomHash generates a random blob of data and returns it as a hash.
+func randomHash() common.Hash {
+	var hash common.Hash
+	if n, err := rand.Read(hash[:]); n != common.HashLength || err != nil {
+		panic(err)
+	}
+	return hash
+}
+
+// randomAccount generates a random account and returns it RLP encoded.
+func randomAccount() []byte {
+	root := randomHash()
+	return AccountRLP(rand.Uint64(), big.NewInt(rand.Int63()), root, root[:])
+}
+
+// newTestTree creates a snapshot tree with a fully generated, empty disk layer
+// at the given root.
+func newTestTree(root common.Hash) *Tree {
+	base := &diskLayer{
+		diskdb: rawdb.NewMemoryDatabase(),
+		root:   root,
+		cache:  newDiskCache(16),
+	}
+	return &Tree{
+		layers: map[common.Hash]snapshot{
+			base.root: base,
+		},
+	}
+}
+
+// Tests that account and storage lookups traverse the diff layers correctly,
+// honouring deletions and destructs along the way.
+func TestDiffLayerLookups(t *testing.T) {
+	var (
+		acc1, acc2, acc3 = randomHash(), randomHash(), randomHash()
+		slot1, slot2     = randomHash(), randomHash()
+		blob1, blob2     = randomAccount(), randomAccount()
+	)
+	snaps := newTestTree(common.HexToHash("0x01"))
+	base := snaps.layers[common.HexToHash("0x01")].(*diskLayer)
+
+	rawdb.WriteAccountSnapshot(base.diskdb, acc1, blob1)
+	rawdb.WriteStorageSnapshot(base.diskdb, acc1, slot1, []byte{0x01})
+	rawdb.WriteStorageSnapshot(base.diskdb, acc1, slot2, []byte{0x02})
+	rawdb.WriteAccountSnapshot(base.diskdb, acc3, blob1)
+
+	// Update a slot, delete a slot, destruct a whole account and add a new one
+	if err := snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"),
+		map[common.Hash]struct{}{acc3: {}},
+		map[common.Hash][]byte{acc2: blob2},
+		map[common.Hash]map[common.Hash][]byte{acc1: {slot1: []byte{0x11}, slot2: nil}},
+	); err != nil {
+		t.Fatalf("failed to create diff layer: %v", err)
+	}
+	head := snaps.Snapshot(common.HexToHash("0x02"))
+
+	if blob, err := head.AccountRLP(acc1); err != nil || !bytes.Equal(blob, blob1) {
+		t.Errorf("account 1 mismatch: have %x, want %x, err %v", blob, blob1, err)
+	}
+	if blob, err := head.AccountRLP(acc2); err != nil || !bytes.Equal(blob, blob2) {
+		t.Errorf("account 2 mismatch: have %x, want %x, err %v", blob, blob2, err)
+	}
+	if blob, err := head.AccountRLP(acc3); err != nil || len(blob) != 0 {
+		t.Errorf("account 3 not deleted: have %x, err %v", blob, err)
+	}
+	if blob, err := head.Storage(acc1, slot1); err != nil || !bytes.Equal(blob, []byte{0x11}) {
+		t.Errorf("slot 1 mismatch: have %x, want %x, err %v", blob, []byte{0x11}, err)
+	}
+	if blob, err := head.Storage(acc1, slot2); err != nil || len(blob) != 0 {
+		t.Errorf("slot 2 not deleted: have %x, err %v", blob, err)
+	}
+	// Ensure the parent is unaffected by the diff on top
+	parent := snaps.Snapshot(common.HexToHash("0x01"))
+	if blob, err := parent.Storage(acc1, slot1); err != nil || !bytes.Equal(blob, []byte{0x01}) {
+		t.Errorf("parent slot 1 mismatch: have %x, want %x, err %v", blob, []byte{0x01}, err)
+	}
+	if blob, err := parent.AccountRLP(acc3); err != nil || !bytes.Equal(blob, blob1) {
+		t.Errorf("parent account 3 mismatch: have %x, want %x, err %v", blob, blob1, err)
+	}
+}
+
+// Tests that a noop update (same state root) and updates on unknown parents
+// are rejected.
+func TestUpdateRejections(t *testing.T) {
+	snaps := newTestTree(common.HexToHash("0x01"))
+
+	if err := snaps.Update(common.HexToHash("0x01"), common.HexToHash("0x01"), nil, nil, nil); err != errSnapshotCycle {
+		t.Errorf("self-loop error mismatch: have %v, want %v", err, errSnapshotCycle)
+	}
+	if err := snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, nil, nil); err == nil {
+		t.Errorf("update on missing parent succeeded")
+	}
+}
+
+// Tests that capping the tree flattens the bottom diff layers into the disk
+// layer, marks the flattened layers stale and drops side branches forking off
+// below the new disk layer.
+func TestCapFlattening(t *testing.T) {
+	var (
+		acc1, acc2 = randomHash(), randomHash()
+		slot       = randomHash()
+		blob1      = randomAccount()
+		blob2      = randomAccount()
+	)
+	snaps := newTestTree(common.HexToHash("0x01"))
+	base := snaps.layers[common.HexToHash("0x01")].(*diskLayer)
+
+	// Create a chain 0x01 <- 0x02 <- 0x03 <- 0x04 and a side branch 0x02 <- 0xa3
+	snaps.Update(common.HexToHash("0x02"), common.HexToHash("0x01"), nil, map[common.Hash][]byte{acc1: blob1}, map[common.Hash]map[common.Hash][]byte{acc1: {slot: []byte{0x01}}})
+	snaps.Update(common.HexToHash("0x03"), common.HexToHash("0x02"), nil, map[common.Hash][]byte{acc2: blob2}, nil)
+	snaps.Update(common.HexToHash("0x04"), common.HexToHash("0x03"), map[common.Hash]struct{}{acc1: {}}, nil, nil)
+	snaps.Update(common.HexToHash("0xa3"), common.HexToHash("0x02"), nil, map[common.Hash][]byte{acc1: blob2}, nil)
+
+	flattened := snaps.Snapshot(common.HexToHash("0x02")).(*diffLayer)
+	side := snaps.Snapshot(common.HexToHash("0xa3")).(*diffLayer)
+
+	// Cap to a single diff layer, which should flatten 0x02 and 0x03 into disk
+	if err := snaps.Cap(common.HexToHash("0x04"), 1); err != nil {
+		t.Fatalf("failed to cap snapshot tree: %v", err)
+	}
+	if n := len(snaps.layers); n != 2 {
+		t.Errorf("layer count mismatch: have %d, want %d", n, 2)
+	}
+	if !base.Stale() || !flattened.Stale() || !side.Stale() {
+		t.Errorf("flattened or dropped layers not stale: base %v, diff %v, side %v", base.Stale(), flattened.Stale(), side.Stale())
+	}
+	if _, err := side.AccountRLP(acc1); err != ErrSnapshotStale {
+		t.Errorf("stale layer access error mismatch: have %v, want %v", err, ErrSnapshotStale)
+	}
+	disk, ok := snaps.Snapshot(common.HexToHash("0x03")).(*diskLayer)
+	if !ok {
+		t.Fatalf("capped layer not a disk layer")
+	}
+	if root := rawdb.ReadSnapshotRoot(disk.diskdb); root != common.HexToHash("0x03") {
+		t.Errorf("persisted root mismatch: have %x, want %x", root, common.HexToHash("0x03"))
+	}
+	if blob := rawdb.ReadAccountSnapshot(disk.diskdb, acc2); !bytes.Equal(blob, blob2) {
+		t.Errorf("flattened account mismatch: have %x, want %x", blob, blob2)
+	}
+	// The head should still see the destruct on top of the flattened data
+	head := snaps.Snapshot(common.HexToHash("0x04"))
+	if blob, err := head.AccountRLP(acc1); err != nil || len(blob) != 0 {
+		t.Errorf("destructed account accessible: have %x, err %v", blob, err)
+	}
+	if blob, err := head.Storage(acc1, slot); err != nil || len(blob) != 0 {
+		t.Errorf("destructed storage accessible: have %x, err %v", blob, err)
+	}
+	// Flatten everything and ensure the storage of the destructed account is wiped
+	if err := snaps.Cap(common.HexToHash("0x04"), 0); err != nil {
+		t.Fatalf("failed to flatten snapshot tree: %v", err)
+	}
+	if n := len(snaps.layers); n != 1 {
+		t.Errorf("layer count mismatch: have %d, want %d", n, 1)
+	}
+	disk = snaps.Snapshot(common.HexToHash("0x04")).(*diskLayer)
+	if blob := rawdb.ReadAccountSnapshot(disk.diskdb, acc1); len(blob) != 0 {
+		t.Errorf("destructed account persisted: %x", blob)
+	}
+	if blob := rawdb.ReadStorageSnapshot(disk.diskdb, acc1, slot); len(blob) != 0 {
+		t.Errorf("destructed storage persisted: %x", blob)
+	}
+	if blob, err := disk.Storage(acc1, slot); err != nil || len(blob) != 0 {
+		t.Errorf("destructed storage cached: have %x, err %v", blob, err)
+	}
+}
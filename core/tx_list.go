@@ -21,6 +21,7 @@ import (
 	"math"
 	"math/big"
 	"sort"
+	"time"
 
 	"github.com/ccmchain/go-ccmchain/common"
 	"github.com/ccmchain/go-ccmchain/core/types"
@@ -225,6 +226,7 @@ type txList struct {
 
 	costcap *big.Int // Price of the highest costing transaction (reset only if exceeds balance)
 	gascap  uint64   // Gas limit of the highest spending transaction (reset only if exceeds block limit)
+	size    uint64   // Total encoded size of the transactions in the list
 }
 
 // newTxList create a new transaction list for maintaining nonce-indexable fast,
@@ -262,6 +264,10 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
+	if old != nil {
+		l.size -= uint64(old.Size())
+	}
+	l.size += uint64(tx.Size())
 	if cost := tx.Cost(); l.costcap.Cmp(cost) < 0 {
 		l.costcap = cost
 	}
@@ -275,7 +281,7 @@ func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Tran
 // provided threshold. Every removed transaction is returned for any post-removal
 // maintenance.
 func (l *txList) Forward(threshold uint64) types.Transactions {
-	return l.txs.Forward(threshold)
+	return l.subtract(l.txs.Forward(threshold))
 }
 
 // Filter removes all transactions from the list with a cost or gas limit higher
@@ -296,7 +302,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 	l.gascap = gasLimit
 
 	// Filter out all the transactions above the account's funds
-	removed := l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas() > gasLimit })
+	removed := l.subtract(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Cost().Cmp(costLimit) > 0 || tx.Gas() > gasLimit }))
 
 	// If the list was strict, filter anything above the lowest nonce
 	var invalids types.Transactions
@@ -308,7 +314,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 				lowest = nonce
 			}
 		}
-		invalids = l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest })
+		invalids = l.subtract(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > lowest }))
 	}
 	return removed, invalids
 }
@@ -316,7 +322,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 // Cap places a hard limit on the number of items, returning all transactions
 // exceeding that limit.
 func (l *txList) Cap(threshold int) types.Transactions {
-	return l.txs.Cap(threshold)
+	return l.subtract(l.txs.Cap(threshold))
 }
 
 // Remove deletes a transaction from the maintained list, returning whccmer the
@@ -325,12 +331,15 @@ func (l *txList) Cap(threshold int) types.Transactions {
 func (l *txList) Remove(tx *types.Transaction) (bool, types.Transactions) {
 	// Remove the transaction from the set
 	nonce := tx.Nonce()
+	old := l.txs.Get(nonce)
 	if removed := l.txs.Remove(nonce); !removed {
 		return false, nil
 	}
+	l.size -= uint64(old.Size())
+
 	// In strict mode, filter out non-executable transactions
 	if l.strict {
-		return true, l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce })
+		return true, l.subtract(l.txs.Filter(func(tx *types.Transaction) bool { return tx.Nonce() > nonce }))
 	}
 	return true, nil
 }
@@ -343,7 +352,7 @@ func (l *txList) Remove(tx *types.Transaction) (bool, types.Transactions) {
 // prevent getting into and invalid state. This is not somccming that should ever
 // happen but better to be self correcting than failing!
 func (l *txList) Ready(start uint64) types.Transactions {
-	return l.txs.Ready(start)
+	return l.subtract(l.txs.Ready(start))
 }
 
 // Len returns the length of the transaction list.
@@ -351,6 +360,20 @@ func (l *txList) Len() int {
 	return l.txs.Len()
 }
 
+// Size returns the total encoded size of the transactions in the list.
+func (l *txList) Size() uint64 {
+	return l.size
+}
+
+// subtract deducts the size of the transactions removed from the list from its
+// total, returning them for chaining.
+func (l *txList) subtract(txs types.Transactions) types.Transactions {
+	for _, tx := range txs {
+		l.size -= uint64(tx.Size())
+	}
+	return txs
+}
+
 // Empty returns whccmer the list of transactions is empty or not.
 func (l *txList) Empty() bool {
 	return l.Len() == 0
@@ -363,34 +386,46 @@ func (l *txList) Flatten() types.Transactions {
 	return l.txs.Flatten()
 }
 
+// pricedTx is a transaction tracked by the priced list, along with the time it
+// was added to the pool.
+type pricedTx struct {
+	tx   *types.Transaction
+	time time.Time
+}
+
 // priceHeap is a heap.Interface implementation over transactions for retrieving
-// price-sorted transactions to discard when the pool fills up.
-type priceHeap []*types.Transaction
+// the least valuable transactions (as ranked by the pool policy) to discard when
+// the pool fills up.
+type priceHeap struct {
+	policy TxPoolPolicy
+	items  []*pricedTx
+}
 
-func (h priceHeap) Len() int      { return len(h) }
-func (h priceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *priceHeap) Len() int      { return len(h.items) }
+func (h *priceHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
 
-func (h priceHeap) Less(i, j int) bool {
-	// Sort primarily by price, returning the cheaper one
-	switch h[i].GasPrice().Cmp(h[j].GasPrice()) {
-	case -1:
+func (h *priceHeap) Less(i, j int) bool {
+	// Sort primarily by the policy, returning the cheaper one
+	a, b := h.items[i], h.items[j]
+	if h.policy.Cheaper(a.tx, a.time, b.tx, b.time) {
 		return true
-	case 1:
+	}
+	if h.policy.Cheaper(b.tx, b.time, a.tx, a.time) {
 		return false
 	}
-	// If the prices match, stabilize via nonces (high nonce is worse)
-	return h[i].Nonce() > h[j].Nonce()
+	// If the transactions are worth the same, stabilize via nonces (high nonce is worse)
+	return a.tx.Nonce() > b.tx.Nonce()
 }
 
 func (h *priceHeap) Push(x interface{}) {
-	*h = append(*h, x.(*types.Transaction))
+	h.items = append(h.items, x.(*pricedTx))
 }
 
 func (h *priceHeap) Pop() interface{} {
-	old := *h
+	old := h.items
 	n := len(old)
 	x := old[n-1]
-	*h = old[0 : n-1]
+	h.items = old[0 : n-1]
 	return x
 }
 
@@ -402,17 +437,18 @@ type txPricedList struct {
 	stales int        // Number of stale price points to (re-heap trigger)
 }
 
-// newTxPricedList creates a new price-sorted transaction heap.
-func newTxPricedList(all *txLookup) *txPricedList {
+// newTxPricedList creates a new price-sorted transaction heap, ordered by the
+// given pool policy.
+func newTxPricedList(all *txLookup, policy TxPoolPolicy) *txPricedList {
 	return &txPricedList{
 		all:   all,
-		items: new(priceHeap),
+		items: &priceHeap{policy: policy},
 	}
 }
 
 // Put inserts a new transaction into the heap.
 func (l *txPricedList) Put(tx *types.Transaction) {
-	heap.Push(l.items, tx)
+	heap.Push(l.items, &pricedTx{tx: tx, time: l.all.Time(tx.Hash())})
 }
 
 // Removed notifies the prices transaction list that an old transaction dropped
@@ -421,17 +457,19 @@ func (l *txPricedList) Put(tx *types.Transaction) {
 func (l *txPricedList) Removed(count int) {
 	// Bump the stale counter, but exit if still too low (< 25%)
 	l.stales += count
-	if l.stales <= len(*l.items)/4 {
+	if l.stales <= l.items.Len()/4 {
 		return
 	}
 	// Seems we've reached a critical number of stale transactions, reheap
-	reheap := make(priceHeap, 0, l.all.Count())
-
-	l.stales, l.items = 0, &reheap
+	txs := make([]*types.Transaction, 0, l.all.Count())
 	l.all.Range(func(hash common.Hash, tx *types.Transaction) bool {
-		*l.items = append(*l.items, tx)
+		txs = append(txs, tx)
 		return true
 	})
+	l.stales, l.items.items = 0, make([]*pricedTx, 0, len(txs))
+	for _, tx := range txs {
+		l.items.items = append(l.items.items, &pricedTx{tx: tx, time: l.all.Time(tx.Hash())})
+	}
 	heap.Init(l.items)
 }
 
@@ -439,30 +477,25 @@ func (l *txPricedList) Removed(count int) {
 // from the priced list and returns them for further removal from the entire pool.
 func (l *txPricedList) Cap(threshold *big.Int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, 128) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)  // Local underpriced transactions to keep
+	keep := make([]*pricedTx, 0, l.items.Len())
 
-	for len(*l.items) > 0 {
+	// The heap is not necessarily sorted by gas price, so check all transactions
+	for _, item := range l.items.items {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if l.all.Get(tx.Hash()) == nil {
+		if l.all.Get(item.tx.Hash()) == nil {
 			l.stales--
 			continue
 		}
-		// Stop the discards if we've reached the threshold
-		if tx.GasPrice().Cmp(threshold) >= 0 {
-			save = append(save, tx)
-			break
-		}
-		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
-		} else {
-			drop = append(drop, tx)
+		// Non stale transaction found, discard if underpriced unless local
+		if item.tx.GasPrice().Cmp(threshold) < 0 && !local.containsTx(item.tx) {
+			drop = append(drop, item.tx)
+			continue
 		}
+		keep = append(keep, item)
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
-	}
+	l.items.items = keep
+	heap.Init(l.items)
+
 	return drop
 }
 
@@ -474,9 +507,9 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		return false
 	}
 	// Discard stale price points if found at the heap start
-	for len(*l.items) > 0 {
-		head := []*types.Transaction(*l.items)[0]
-		if l.all.Get(head.Hash()) == nil {
+	for l.items.Len() > 0 {
+		head := l.items.items[0]
+		if l.all.Get(head.tx.Hash()) == nil {
 			l.stales--
 			heap.Pop(l.items)
 			continue
@@ -484,37 +517,37 @@ func (l *txPricedList) Underpriced(tx *types.Transaction, local *accountSet) boo
 		break
 	}
 	// Check if the transaction is underpriced or not
-	if len(*l.items) == 0 {
+	if l.items.Len() == 0 {
 		log.Error("Pricing query for empty pool") // This cannot happen, print to catch programming errors
 		return false
 	}
-	cheapest := []*types.Transaction(*l.items)[0]
-	return cheapest.GasPrice().Cmp(tx.GasPrice()) >= 0
+	cheapest := l.items.items[0]
+	return !l.items.policy.Cheaper(cheapest.tx, cheapest.time, tx, time.Now())
 }
 
 // Discard finds a number of most underpriced transactions, removes them from the
 // priced list and returns them for further removal from the entire pool.
 func (l *txPricedList) Discard(count int, local *accountSet) types.Transactions {
 	drop := make(types.Transactions, 0, count) // Remote underpriced transactions to drop
-	save := make(types.Transactions, 0, 64)    // Local underpriced transactions to keep
+	save := make([]*pricedTx, 0, 64)           // Local underpriced transactions to keep
 
-	for len(*l.items) > 0 && count > 0 {
+	for l.items.Len() > 0 && count > 0 {
 		// Discard stale transactions if found during cleanup
-		tx := heap.Pop(l.items).(*types.Transaction)
-		if l.all.Get(tx.Hash()) == nil {
+		item := heap.Pop(l.items).(*pricedTx)
+		if l.all.Get(item.tx.Hash()) == nil {
 			l.stales--
 			continue
 		}
 		// Non stale transaction found, discard unless local
-		if local.containsTx(tx) {
-			save = append(save, tx)
+		if local.containsTx(item.tx) {
+			save = append(save, item)
 		} else {
-			drop = append(drop, tx)
+			drop = append(drop, item.tx)
 			count--
 		}
 	}
-	for _, tx := range save {
-		heap.Push(l.items, tx)
+	for _, item := range save {
+		heap.Push(l.items, item)
 	}
 	return drop
 }
@@ -76,6 +76,11 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrAccountQuota is returned if the transactions pooled from the sender of a
+	// transaction would exceed the per-account byte quota of the pool. Similarly to
+	// ErrOversizedData, this is not a consensus error but a DOS protection.
+	ErrAccountQuota = errors.New("account quota exceeded")
 )
 
 var (
@@ -142,6 +147,12 @@ type TxPoolConfig struct {
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	TxSizeLimit   uint64        // Maximum size of a single transaction accepted into the pool
+	AccountBytes  uint64        // Maximum total size of the non-local transactions pooled per account (0 = unlimited)
+	PriceHalflife time.Duration // Time after which the eviction priority of a transaction halves (0 = price only)
+
+	Policy TxPoolPolicy `toml:"-"` // Admission and eviction policy overriding the above limits (nil = default)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -159,6 +170,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	TxSizeLimit: 32 * 1024,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -197,9 +210,97 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.TxSizeLimit < 1 {
+		log.Warn("Sanitizing invalid txpool transaction size limit", "provided", conf.TxSizeLimit, "updated", DefaultTxPoolConfig.TxSizeLimit)
+		conf.TxSizeLimit = DefaultTxPoolConfig.TxSizeLimit
+	}
+	if conf.AccountBytes != 0 && conf.AccountBytes < conf.TxSizeLimit {
+		log.Warn("Sanitizing invalid txpool account bytes", "provided", conf.AccountBytes, "updated", conf.TxSizeLimit)
+		conf.AccountBytes = conf.TxSizeLimit
+	}
+	if conf.PriceHalflife < 0 {
+		log.Warn("Sanitizing invalid txpool price halflife", "provided", conf.PriceHalflife, "updated", time.Duration(0))
+		conf.PriceHalflife = 0
+	}
+	if conf.Policy == nil {
+		conf.Policy = NewTxPoolPolicy(conf)
+	}
 	return conf
 }
 
+// TxPoolPolicy decides which transactions the pool admits and in which order it
+// evicts them when full. Local transactions are never evicted, irrespective of
+// the policy.
+type TxPoolPolicy interface {
+	// Admit checks whccmer a transaction may enter the pool, given the number and
+	// the total size of the transactions already pooled from the same sender (not
+	// counting the one it would replace, if any).
+	Admit(tx *types.Transaction, local bool, slots int, size uint64) error
+
+	// Cheaper reports whccmer transaction a, pooled at time ta, is strictly worth
+	// less than transaction b, pooled at time tb, and should be evicted first. The
+	// ordering must not change as time passes, since the pool keeps it in a heap.
+	Cheaper(a *types.Transaction, ta time.Time, b *types.Transaction, tb time.Time) bool
+}
+
+// txPoolPolicy is the built-in admission and eviction policy of the pool.
+type txPoolPolicy struct {
+	sizeLimit    uint64        // Maximum size of a single transaction
+	accountBytes uint64        // Maximum size of the remote transactions of an account
+	halflife     time.Duration // Time after which the worth of a transaction halves
+}
+
+// NewTxPoolPolicy creates the built-in admission and eviction policy, enforcing
+// the size limits of the given configuration.
+//
+// Without a price halflife, transactions are ranked by their gas price alone.
+// Otherwise they are ranked by an effective fee of their gas price decaying by
+// half for every halflife spent in the pool, so stale transactions are evicted
+// before fresh ones of similar prices.
+func NewTxPoolPolicy(config TxPoolConfig) TxPoolPolicy {
+	return &txPoolPolicy{
+		sizeLimit:    config.TxSizeLimit,
+		accountBytes: config.AccountBytes,
+		halflife:     config.PriceHalflife,
+	}
+}
+
+// Admit implements TxPoolPolicy, rejecting oversized transactions and remote
+// ones exceeding the byte quota of their sender.
+func (p *txPoolPolicy) Admit(tx *types.Transaction, local bool, slots int, size uint64) error {
+	// Heuristic limit, reject large transactions to prevent DOS attacks
+	txsize := uint64(tx.Size())
+	if txsize > p.sizeLimit {
+		return ErrOversizedData
+	}
+	if !local && p.accountBytes > 0 && size+txsize > p.accountBytes {
+		return ErrAccountQuota
+	}
+	return nil
+}
+
+// Cheaper implements TxPoolPolicy, comparing the (effective) gas prices of two
+// transactions.
+func (p *txPoolPolicy) Cheaper(a *types.Transaction, ta time.Time, b *types.Transaction, tb time.Time) bool {
+	cmp := a.GasPrice().Cmp(b.GasPrice())
+	if p.halflife == 0 {
+		return cmp < 0
+	}
+	// Zero prices remain zero after decaying, order them by age only
+	if a.GasPrice().Sign() == 0 || b.GasPrice().Sign() == 0 {
+		if cmp != 0 {
+			return cmp < 0
+		}
+		return ta.Before(tb)
+	}
+	// The effective fee of a transaction is price * 2^(-age/halflife). Compared in
+	// logarithmic space the current time cancels out, keeping the order stable.
+	pa, _ := new(big.Float).SetInt(a.GasPrice()).Float64()
+	pb, _ := new(big.Float).SetInt(b.GasPrice()).Float64()
+
+	return math.Log2(pa)-math.Log2(pb)+float64(ta.Sub(tb))/float64(p.halflife) < 0
+}
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -217,6 +318,8 @@ type TxPool struct {
 	signer      types.Signer
 	mu          sync.RWMutex
 
+	istanbul bool // Fork indicator whccmer we are in the istanbul stage.
+
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
@@ -273,7 +376,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		log.Info("Setting new local account", "address", addr)
 		pool.locals.add(addr)
 	}
-	pool.priced = newTxPricedList(pool.all)
+	pool.priced = newTxPricedList(pool.all, config.Policy)
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
@@ -507,10 +610,6 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 // validateTx checks whccmer a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
 func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
-	// Heuristic limit, reject transactions over 32KB to prevent DOS attacks
-	if tx.Size() > 32*1024 {
-		return ErrOversizedData
-	}
 	// Transactions can't be negative. This may never happen using RLP decoded
 	// transactions but may occur if you create a transaction using the RPC.
 	if tx.Value().Sign() < 0 {
@@ -530,6 +629,11 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !local && pool.gasPrice.Cmp(tx.GasPrice()) > 0 {
 		return ErrUnderpriced
 	}
+	// Ensure the transaction and its sender adhere to the size limits of the policy
+	slots, size := pool.usage(from, tx.Nonce())
+	if err := pool.config.Policy.Admit(tx, local, slots, size); err != nil {
+		return err
+	}
 	// Ensure the transaction adheres to nonce ordering
 	if pool.currentState.GetNonce(from) > tx.Nonce() {
 		return ErrNonceTooLow
@@ -540,7 +644,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 		return ErrInsufficientFunds
 	}
 	// Ensure the transaction has more gas than the basic tx fee.
-	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, true)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.To() == nil, true, pool.istanbul)
 	if err != nil {
 		return err
 	}
@@ -550,6 +654,25 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	return nil
 }
 
+// usage returns the number and the total size of the transactions pooled from
+// the given account, ignoring the one with the specified nonce.
+func (pool *TxPool) usage(addr common.Address, nonce uint64) (int, uint64) {
+	var (
+		slots int
+		size  uint64
+	)
+	for _, list := range []*txList{pool.pending[addr], pool.queue[addr]} {
+		if list == nil {
+			continue
+		}
+		slots, size = slots+list.Len(), size+list.Size()
+		if tx := list.txs.Get(nonce); tx != nil {
+			slots, size = slots-1, size-uint64(tx.Size())
+		}
+	}
+	return slots, size
+}
+
 // add validates a transaction and inserts it into the non-executable queue for later
 // pending promotion and execution. If the transaction is a replacement for an already
 // pending or queued one, it overwrites the previous transaction if its price is higher.
@@ -823,6 +946,12 @@ func (pool *TxPool) Get(hash common.Hash) *types.Transaction {
 	return pool.all.Get(hash)
 }
 
+// Has returns an indicator whccmer txpool has a transaction cached with the
+// given hash.
+func (pool *TxPool) Has(hash common.Hash) bool {
+	return pool.all.Get(hash) != nil
+}
+
 // removeTx removes a single transaction from the queue, moving all subsequent
 // transactions back to the future queue.
 func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
@@ -1114,6 +1243,10 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	// Update all fork indicator by next pending block number.
+	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
+	pool.istanbul = pool.chainconfig.IsIstanbul(next)
+
 	// Inject any transactions discarded due to reorgs
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
@@ -1461,14 +1594,16 @@ func (as *accountSet) merge(other *accountSet) {
 // peeking into the pool in TxPool.Get without having to acquire the widely scoped
 // TxPool.mu mutex.
 type txLookup struct {
-	all  map[common.Hash]*types.Transaction
-	lock sync.RWMutex
+	all   map[common.Hash]*types.Transaction
+	times map[common.Hash]time.Time
+	lock  sync.RWMutex
 }
 
 // newTxLookup returns a new txLookup structure.
 func newTxLookup() *txLookup {
 	return &txLookup{
-		all: make(map[common.Hash]*types.Transaction),
+		all:   make(map[common.Hash]*types.Transaction),
+		times: make(map[common.Hash]time.Time),
 	}
 }
 
@@ -1500,12 +1635,25 @@ func (t *txLookup) Count() int {
 	return len(t.all)
 }
 
-// Add adds a transaction to the lookup.
+// Time returns the time a transaction was added to the lookup, or the zero time
+// if not found.
+func (t *txLookup) Time(hash common.Hash) time.Time {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.times[hash]
+}
+
+// Add adds a transaction to the lookup, tracking the time it was first added.
 func (t *txLookup) Add(tx *types.Transaction) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	t.all[tx.Hash()] = tx
+	hash := tx.Hash()
+	if _, ok := t.all[hash]; !ok {
+		t.times[hash] = time.Now()
+	}
+	t.all[hash] = tx
 }
 
 // Remove removes a transaction from the lookup.
@@ -1514,4 +1662,5 @@ func (t *txLookup) Remove(hash common.Hash) {
 	defer t.lock.Unlock()
 
 	delete(t.all, hash)
+	delete(t.times, hash)
 }
@@ -77,6 +77,14 @@ func pricedTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ec
 	return tx
 }
 
+func pricedDataTransaction(nonce uint64, gaslimit uint64, gasprice *big.Int, key *ecdsa.PrivateKey, bytes uint64) *types.Transaction {
+	data := make([]byte, bytes)
+	rand.Read(data)
+
+	tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), gaslimit, gasprice, data), types.HomesteadSigner{}, key)
+	return tx
+}
+
 func setupTxPool() (*TxPool, *ecdsa.PrivateKey) {
 	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
 	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
@@ -113,6 +121,18 @@ func validateTxPoolInternals(pool *TxPool) error {
 			return fmt.Errorf("pending nonce mismatch: have %v, want %v", nonce, last+1)
 		}
 	}
+	// Ensure the tracked list sizes match their transactions
+	for _, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		for addr, list := range lists {
+			var size uint64
+			for _, tx := range list.txs.items {
+				size += uint64(tx.Size())
+			}
+			if list.Size() != size {
+				return fmt.Errorf("account %x: list size mismatch: have %d, want %d", addr, list.Size(), size)
+			}
+		}
+	}
 	return nil
 }
 
@@ -1518,6 +1538,155 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that transactions above the configured size limit are rejected, even if
+// they are local.
+func TestTransactionSizeLimiting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the size enforcement with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.TxSizeLimit = 1024
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	pool.currentState.AddBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	if err := pool.AddRemote(pricedDataTransaction(0, 200000, big.NewInt(1), key, 512)); err != nil {
+		t.Fatalf("failed to add small transaction: %v", err)
+	}
+	if err := pool.AddRemote(pricedDataTransaction(1, 200000, big.NewInt(1), key, 2048)); err != ErrOversizedData {
+		t.Fatalf("oversized remote transaction error mismatch: have %v, want %v", err, ErrOversizedData)
+	}
+	if err := pool.AddLocal(pricedDataTransaction(1, 200000, big.NewInt(1), key, 2048)); err != ErrOversizedData {
+		t.Fatalf("oversized local transaction error mismatch: have %v, want %v", err, ErrOversizedData)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the total size of the remote transactions pooled from a single
+// account is capped by the byte quota, allowing replacements and exempting locals.
+func TestTransactionAccountByteLimiting(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the quota enforcement with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.TxSizeLimit = 2048
+	config.AccountBytes = 3500
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Fill up the quota of the first account with both pending and queued transactions
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := pool.AddRemote(pricedDataTransaction(nonce, 200000, big.NewInt(1), keys[0], 1024)); err != nil {
+			t.Fatalf("failed to add transaction #%d within quota: %v", nonce, err)
+		}
+	}
+	if err := pool.AddRemote(pricedDataTransaction(2, 200000, big.NewInt(1), keys[0], 1024)); err != ErrAccountQuota {
+		t.Fatalf("transaction over quota error mismatch: have %v, want %v", err, ErrAccountQuota)
+	}
+	// Ensure replacements don't count against the quota, but new ones of other accounts do
+	if err := pool.AddRemote(pricedDataTransaction(1, 200000, big.NewInt(2), keys[0], 1024)); err != nil {
+		t.Fatalf("failed to replace transaction within quota: %v", err)
+	}
+	if err := pool.AddRemote(pricedDataTransaction(0, 200000, big.NewInt(1), keys[1], 1024)); err != nil {
+		t.Fatalf("failed to add transaction of different account: %v", err)
+	}
+	// Ensure local transactions are exempt from the quota
+	if err := pool.AddLocal(pricedDataTransaction(2, 200000, big.NewInt(1), keys[0], 1024)); err != nil {
+		t.Fatalf("failed to add local transaction over quota: %v", err)
+	}
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		if err := pool.AddLocal(pricedDataTransaction(nonce, 200000, big.NewInt(1), keys[2], 1024)); err != nil {
+			t.Fatalf("failed to add local transaction #%d over quota: %v", nonce, err)
+		}
+	}
+	pending, queued := pool.Stats()
+	if pending != 9 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 9)
+	}
+	if queued != 0 {
+		t.Fatalf("queued transactions mismatched: have %d, want %d", queued, 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that with a price halflife configured, stale transactions lose their
+// priority over time and are evicted by fresh ones paying less.
+func TestTransactionPoolAgedUnderpricing(t *testing.T) {
+	t.Parallel()
+
+	// Create the pool to test the pricing enforcement with
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	blockchain := &testBlockChain{statedb, 1000000, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.GlobalSlots = 2
+	config.GlobalQueue = 2
+	config.PriceHalflife = 200 * time.Millisecond
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	// Create a number of test accounts and fund them
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+		pool.currentState.AddBalance(crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000000))
+	}
+	// Fill up the pool with expensive transactions
+	for nonce := uint64(0); nonce < 4; nonce++ {
+		if err := pool.AddRemote(pricedTransaction(nonce, 100000, big.NewInt(4), keys[0])); err != nil {
+			t.Fatalf("failed to add expensive transaction #%d: %v", nonce, err)
+		}
+	}
+	// Ensure that cheaper transactions are underpriced while the pool is fresh
+	if err := pool.AddRemote(pricedTransaction(0, 100000, big.NewInt(2), keys[1])); err != ErrUnderpriced {
+		t.Fatalf("adding underpriced transaction error mismatch: have %v, want %v", err, ErrUnderpriced)
+	}
+	// Wait until the pooled transactions go stale and ensure fresh ones evict them
+	time.Sleep(3 * config.PriceHalflife)
+
+	fresh := types.Transactions{
+		pricedTransaction(0, 100000, big.NewInt(2), keys[1]),
+		pricedTransaction(0, 100000, big.NewInt(1), keys[2]),
+	}
+	for i, tx := range fresh {
+		if err := pool.AddRemote(tx); err != nil {
+			t.Fatalf("failed to add fresh transaction #%d: %v", i, err)
+		}
+	}
+	for i, tx := range fresh {
+		if pool.Get(tx.Hash()) == nil {
+			t.Fatalf("fresh transaction #%d evicted", i)
+		}
+	}
+	if count := pool.all.Count(); count != 4 {
+		t.Fatalf("pooled transactions mismatched: have %d, want %d", count, 4)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -27,4 +27,8 @@ var (
 	ErrInsufficientBalance      = errors.New("insufficient balance for transfer")
 	ErrContractAddressCollision = errors.New("contract address collision")
 	ErrNoCompatibleInterpreter  = errors.New("no compatible interpreter")
+
+	// ErrExecutionReverted is returned if the execution was aborted by the REVERT
+	// opcode. The remaining gas is refunded and the revert data is returned.
+	ErrExecutionReverted = errors.New("evm: execution reverted")
 )
@@ -16,7 +16,17 @@
 
 package vm
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/state"
+	"github.com/ccmchain/go-ccmchain/params"
+)
 
 func TestMemoryGasCost(t *testing.T) {
 	tests := []struct {
@@ -37,3 +47,96 @@ func TestMemoryGasCost(t *testing.T) {
 		}
 	}
 }
+
+// newIstanbulTestEVM creates an EVM running the Istanbul rules on top of a fresh
+// state, with the given code deployed and its first storage slot preset.
+func newIstanbulTestEVM(code []byte, original byte) (*EVM, common.Address) {
+	address := common.BytesToAddress([]byte("contract"))
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()))
+	statedb.CreateAccount(address)
+	statedb.SetCode(address, code)
+	statedb.SetState(address, common.Hash{}, common.BytesToHash([]byte{original}))
+	statedb.Finalise(true) // Push the state into the "original" slot
+
+	vmctx := Context{
+		CanTransfer: func(StateDB, common.Address, *big.Int) bool { return true },
+		Transfer:    func(StateDB, common.Address, common.Address, *big.Int) {},
+		BlockNumber: big.NewInt(0),
+	}
+	return NewEVM(vmctx, statedb, params.AllEthashProtocolChanges, Config{}), address
+}
+
+var eip2200Tests = []struct {
+	original byte
+	gaspool  uint64
+	input    string
+	used     uint64
+	refund   uint64
+	failure  error
+}{
+	{0, math.MaxUint64, "0x60006000556000600055", 1612, 0, nil},                // 0 -> 0 -> 0
+	{0, math.MaxUint64, "0x60006000556001600055", 20812, 0, nil},               // 0 -> 0 -> 1
+	{0, math.MaxUint64, "0x60016000556000600055", 20812, 19200, nil},           // 0 -> 1 -> 0
+	{0, math.MaxUint64, "0x60016000556002600055", 20812, 0, nil},               // 0 -> 1 -> 2
+	{0, math.MaxUint64, "0x60016000556001600055", 20812, 0, nil},               // 0 -> 1 -> 1
+	{1, math.MaxUint64, "0x60006000556000600055", 5812, 15000, nil},            // 1 -> 0 -> 0
+	{1, math.MaxUint64, "0x60006000556001600055", 5812, 4200, nil},             // 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x60006000556002600055", 5812, 0, nil},                // 1 -> 0 -> 2
+	{1, math.MaxUint64, "0x60026000556000600055", 5812, 15000, nil},            // 1 -> 2 -> 0
+	{1, math.MaxUint64, "0x60026000556003600055", 5812, 0, nil},                // 1 -> 2 -> 3
+	{1, math.MaxUint64, "0x60026000556001600055", 5812, 4200, nil},             // 1 -> 2 -> 1
+	{1, math.MaxUint64, "0x60026000556002600055", 5812, 0, nil},                // 1 -> 2 -> 2
+	{1, math.MaxUint64, "0x60016000556000600055", 5812, 15000, nil},            // 1 -> 1 -> 0
+	{1, math.MaxUint64, "0x60016000556002600055", 5812, 0, nil},                // 1 -> 1 -> 2
+	{1, math.MaxUint64, "0x60016000556001600055", 1612, 0, nil},                // 1 -> 1 -> 1
+	{0, math.MaxUint64, "0x600160005560006000556001600055", 40818, 19200, nil}, // 0 -> 1 -> 0 -> 1
+	{1, math.MaxUint64, "0x600060005560016000556000600055", 10818, 19200, nil}, // 1 -> 0 -> 1 -> 0
+	{1, 2306, "0x6001600055", 2306, 0, ErrOutOfGas},                            // 1 -> 1 (2300 sentry + 2xPUSH)
+	{1, 2307, "0x6001600055", 806, 0, nil},                                     // 1 -> 1 (2301 sentry + 2xPUSH)
+}
+
+// Tests the SSTORE gas metering of EIP-2200 against the reference cases of the
+// EIP, including the reentrancy sentry.
+func TestEIP2200(t *testing.T) {
+	for i, tt := range eip2200Tests {
+		vmenv, address := newIstanbulTestEVM(hexutil.MustDecode(tt.input), tt.original)
+
+		_, gas, err := vmenv.Call(AccountRef(common.Address{}), address, nil, tt.gaspool, new(big.Int))
+		if err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+		}
+		if used := tt.gaspool - gas; used != tt.used {
+			t.Errorf("test %d: gas used mismatch: have %v, want %v", i, used, tt.used)
+		}
+		if refund := vmenv.StateDB.GetRefund(); refund != tt.refund {
+			t.Errorf("test %d: gas refund mismatch: have %v, want %v", i, refund, tt.refund)
+		}
+	}
+}
+
+// Tests the opcodes added and repriced by EIP-1344 and EIP-1884 in Istanbul.
+func TestIstanbulGasCosts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		used  uint64
+	}{
+		{"CHAINID", "0x46", GasQuickStep},
+		{"SELFBALANCE", "0x47", GasFastStep},
+		{"SLOAD", "0x600054", GasFastestStep + params.GasTableIstanbul.SLoad},
+		{"BALANCE", "0x600031", GasFastestStep + params.GasTableIstanbul.Balance},
+		{"EXTCODEHASH", "0x60003f", GasFastestStep + params.GasTableIstanbul.ExtcodeHash},
+	}
+	for _, tt := range tests {
+		vmenv, address := newIstanbulTestEVM(hexutil.MustDecode(tt.input), 0)
+
+		_, gas, err := vmenv.Call(AccountRef(common.Address{}), address, nil, math.MaxUint64, new(big.Int))
+		if err != nil {
+			t.Errorf("%s: execution failed: %v", tt.name, err)
+		}
+		if used := math.MaxUint64 - gas; used != tt.used {
+			t.Errorf("%s: gas used mismatch: have %v, want %v", tt.name, used, tt.used)
+		}
+	}
+}
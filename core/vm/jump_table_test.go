@@ -0,0 +1,45 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package vm
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Tests that the opcodes introduced in Istanbul are only available from the
+// Istanbul instruction set onwards, and that SSTORE uses the EIP-2200 metering.
+func TestIstanbulInstructionSet(t *testing.T) {
+	for _, op := range []OpCode{CHAINID, SELFBALANCE} {
+		if !istanbulInstructionSet[op].valid {
+			t.Errorf("%v missing from the istanbul instruction set", op)
+		}
+		if istanbulInstructionSet[op].execute == nil {
+			t.Errorf("%v has no implementation in the istanbul instruction set", op)
+		}
+		if constantinopleInstructionSet[op].valid {
+			t.Errorf("%v present in the constantinople instruction set", op)
+		}
+	}
+	have := reflect.ValueOf(istanbulInstructionSet[SSTORE].dynamicGas).Pointer()
+	if want := reflect.ValueOf(gasSStoreEIP2200).Pointer(); have != want {
+		t.Errorf("istanbul SSTORE not metered by EIP-2200")
+	}
+	if have == reflect.ValueOf(constantinopleInstructionSet[SSTORE].dynamicGas).Pointer() {
+		t.Errorf("constantinople SSTORE metered by EIP-2200")
+	}
+}
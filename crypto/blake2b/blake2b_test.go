@@ -0,0 +1,88 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package blake2b
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+// sum512 computes the unkeyed BLAKE2b-512 digest of a short (at most one block)
+// message using the compression function with the standard 12 rounds.
+func sum512(msg []byte) []byte {
+	h := iv
+	h[0] ^= 0x01010040 // parameter block: digest length 64, no key, fanout 1, depth 1
+
+	var (
+		block [128]byte
+		m     [16]uint64
+	)
+	copy(block[:], msg)
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(block[i*8:])
+	}
+	F(&h, m, [2]uint64{uint64(len(msg)), 0}, true, 12)
+
+	out := make([]byte, 64)
+	for i, v := range h {
+		binary.LittleEndian.PutUint64(out[i*8:], v)
+	}
+	return out
+}
+
+// Tests that the compression function produces the RFC 7693 reference digests
+// when run with the standard number of rounds.
+func TestSum512(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want string
+	}{
+		{"", "786a02f742015903c6c6fd852552d272912f4740e15847618a86e217f71f5419d25e1031afee585313896444934eb04b903a685b1448b755d56f701afe9be2ce"},
+		{"abc", "ba80a53f981c4d0d6a2797b69f12f6e94c212f14685ac4b74b12bb6fdbffa2d17d87c5392aab792dc252d5de4533cc9518d38aa8dbf1925ab92386edd4009923"},
+	}
+	for i, tt := range tests {
+		want, _ := hex.DecodeString(tt.want)
+		if have := sum512([]byte(tt.msg)); !bytes.Equal(have, want) {
+			t.Errorf("test %d: digest mismatch: have %x, want %x", i, have, want)
+		}
+	}
+}
+
+// Tests that running zero rounds only applies the finalization, which for a
+// state equal to the initialization vector leaves it unchanged.
+func TestZeroRounds(t *testing.T) {
+	h := iv
+	F(&h, [16]uint64{}, [2]uint64{}, false, 0)
+
+	for i := range h {
+		if want := iv[i]; h[i] != want {
+			t.Errorf("word %d mismatch: have %x, want %x", i, h[i], want)
+		}
+	}
+}
+
+func BenchmarkF12Rounds(b *testing.B) {
+	var (
+		h [8]uint64
+		m [16]uint64
+	)
+	for i := 0; i < b.N; i++ {
+		F(&h, m, [2]uint64{}, true, 12)
+	}
+}
@@ -19,7 +19,9 @@ package graphql
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ccmchain/go-ccmchain"
@@ -29,7 +31,9 @@ import (
 	"github.com/ccmchain/go-ccmchain/core/state"
 	"github.com/ccmchain/go-ccmchain/core/types"
 	"github.com/ccmchain/go-ccmchain/core/vm"
+	"github.com/ccmchain/go-ccmchain/ccm"
 	"github.com/ccmchain/go-ccmchain/ccm/filters"
+	"github.com/ccmchain/go-ccmchain/ccm/tracers"
 	"github.com/ccmchain/go-ccmchain/internal/ccmapi"
 	"github.com/ccmchain/go-ccmchain/rlp"
 	"github.com/ccmchain/go-ccmchain/rpc"
@@ -91,6 +95,80 @@ func (a *Account) Storage(ctx context.Context, args struct{ Slot common.Hash })
 	return state.GetState(a.address, args.Slot), nil
 }
 
+func (a *Account) Proof(ctx context.Context, args struct{ Slots *[]common.Hash }) (*AccountProof, error) {
+	var keys []string
+	if args.Slots != nil {
+		for _, slot := range *args.Slots {
+			keys = append(keys, slot.Hex())
+		}
+	}
+	result, err := ccmapi.NewPublicBlockChainAPI(a.backend).GetProof(ctx, a.address, keys, a.blockNumber)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	return &AccountProof{result}, nil
+}
+
+// AccountProof represents the Merkle proof of an account and some of its storage
+// slots, as returned by the `proof` accessor of an account.
+type AccountProof struct {
+	result *ccmapi.AccountResult
+}
+
+// decodeProof converts the hex encoded trie nodes of a proof into binary form.
+func decodeProof(proof []string) []hexutil.Bytes {
+	nodes := make([]hexutil.Bytes, 0, len(proof))
+	for _, node := range proof {
+		nodes = append(nodes, common.FromHex(node))
+	}
+	return nodes
+}
+
+func (p *AccountProof) AccountProof() []hexutil.Bytes {
+	return decodeProof(p.result.AccountProof)
+}
+
+func (p *AccountProof) Balance() hexutil.Big {
+	return *p.result.Balance
+}
+
+func (p *AccountProof) CodeHash() common.Hash {
+	return p.result.CodeHash
+}
+
+func (p *AccountProof) Nonce() hexutil.Uint64 {
+	return p.result.Nonce
+}
+
+func (p *AccountProof) StorageHash() common.Hash {
+	return p.result.StorageHash
+}
+
+func (p *AccountProof) StorageProof() []*StorageProof {
+	ret := make([]*StorageProof, 0, len(p.result.StorageProof))
+	for _, proof := range p.result.StorageProof {
+		ret = append(ret, &StorageProof{proof})
+	}
+	return ret
+}
+
+// StorageProof represents the Merkle proof of a single storage slot.
+type StorageProof struct {
+	result ccmapi.StorageResult
+}
+
+func (p *StorageProof) Key() common.Hash {
+	return common.HexToHash(p.result.Key)
+}
+
+func (p *StorageProof) Value() hexutil.Big {
+	return *p.result.Value
+}
+
+func (p *StorageProof) Proof() []hexutil.Bytes {
+	return decodeProof(p.result.Proof)
+}
+
 // Log represents an individual log message. All arguments are mandatory.
 type Log struct {
 	backend     ccmapi.Backend
@@ -163,6 +241,14 @@ func (t *Transaction) InputData(ctx context.Context) (hexutil.Bytes, error) {
 	return hexutil.Bytes(tx.Data()), nil
 }
 
+func (t *Transaction) Raw(ctx context.Context) (hexutil.Bytes, error) {
+	tx, err := t.resolve(ctx)
+	if err != nil || tx == nil {
+		return hexutil.Bytes{}, err
+	}
+	return rlp.EncodeToBytes(tx)
+}
+
 func (t *Transaction) Gas(ctx context.Context) (hexutil.Uint64, error) {
 	tx, err := t.resolve(ctx)
 	if err != nil || tx == nil {
@@ -270,7 +356,7 @@ func (t *Transaction) getReceipt(ctx context.Context) (*types.Receipt, error) {
 
 func (t *Transaction) Status(ctx context.Context) (*hexutil.Uint64, error) {
 	receipt, err := t.getReceipt(ctx)
-	if err != nil || receipt == nil {
+	if err != nil || receipt == nil || len(receipt.PostState) > 0 {
 		return nil, err
 	}
 
@@ -278,6 +364,16 @@ func (t *Transaction) Status(ctx context.Context) (*hexutil.Uint64, error) {
 	return &ret, nil
 }
 
+func (t *Transaction) Root(ctx context.Context) (*common.Hash, error) {
+	receipt, err := t.getReceipt(ctx)
+	if err != nil || receipt == nil || len(receipt.PostState) == 0 {
+		return nil, err
+	}
+
+	ret := common.BytesToHash(receipt.PostState)
+	return &ret, nil
+}
+
 func (t *Transaction) GasUsed(ctx context.Context) (*hexutil.Uint64, error) {
 	receipt, err := t.getReceipt(ctx)
 	if err != nil || receipt == nil {
@@ -847,16 +943,19 @@ func (b *Block) Call(ctx context.Context, args struct {
 		}
 	}
 
-	result, gas, failed, err := ccmapi.DoCall(ctx, b.backend, args.Data, *b.num, vm.Config{}, 5*time.Second, b.backend.RPCGasCap())
+	result, err := ccmapi.DoCall(ctx, b.backend, args.Data, *b.num, vm.Config{}, 5*time.Second, b.backend.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
 	status := hexutil.Uint64(1)
-	if failed {
+	if result.Failed() {
 		status = 0
 	}
 	return &CallResult{
-		data:    hexutil.Bytes(result),
-		gasUsed: hexutil.Uint64(gas),
+		data:    hexutil.Bytes(result.ReturnData),
+		gasUsed: hexutil.Uint64(result.UsedGas),
 		status:  status,
-	}, err
+	}, nil
 }
 
 func (b *Block) EstimateGas(ctx context.Context, args struct {
@@ -918,16 +1017,19 @@ func (p *Pending) Account(ctx context.Context, args struct {
 func (p *Pending) Call(ctx context.Context, args struct {
 	Data ccmapi.CallArgs
 }) (*CallResult, error) {
-	result, gas, failed, err := ccmapi.DoCall(ctx, p.backend, args.Data, rpc.PendingBlockNumber, vm.Config{}, 5*time.Second, p.backend.RPCGasCap())
+	result, err := ccmapi.DoCall(ctx, p.backend, args.Data, rpc.PendingBlockNumber, vm.Config{}, 5*time.Second, p.backend.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
 	status := hexutil.Uint64(1)
-	if failed {
+	if result.Failed() {
 		status = 0
 	}
 	return &CallResult{
-		data:    hexutil.Bytes(result),
-		gasUsed: hexutil.Uint64(gas),
+		data:    hexutil.Bytes(result.ReturnData),
+		gasUsed: hexutil.Uint64(result.UsedGas),
 		status:  status,
-	}, err
+	}, nil
 }
 
 func (p *Pending) EstimateGas(ctx context.Context, args struct {
@@ -939,6 +1041,8 @@ func (p *Pending) EstimateGas(ctx context.Context, args struct {
 // Resolver is the top-level object in the GraphQL hierarchy.
 type Resolver struct {
 	backend ccmapi.Backend
+	events  *filters.EventSystem // Event system feeding the subscriptions
+	tracing bool                 // Whccmer transaction tracing is exposed
 }
 
 func (r *Resolver) Block(ctx context.Context, args struct {
@@ -1082,6 +1186,91 @@ func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria
 	return runFilter(ctx, r.backend, filter)
 }
 
+// JSON is an arbitrary JSON value, passed through to the client verbatim.
+type JSON json.RawMessage
+
+// ImplementsGraphQLType returns true if JSON implements the specified GraphQL type.
+func (j JSON) ImplementsGraphQLType(name string) bool { return name == "JSON" }
+
+// UnmarshalGraphQL unmarshals the provided GraphQL query data.
+func (j *JSON) UnmarshalGraphQL(input interface{}) error {
+	blob, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	*j = blob
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, returning the value verbatim.
+func (j JSON) MarshalJSON() ([]byte, error) {
+	return json.RawMessage(j).MarshalJSON()
+}
+
+const (
+	// maxTraceTimeout is the longest a transaction trace may run for, regardless
+	// of the timeout requested by the query.
+	maxTraceTimeout = 5 * time.Second
+
+	// maxTraceStructLogs is the maximum number of structured logs returned if no
+	// tracer is requested.
+	maxTraceStructLogs = 10000
+)
+
+// traceBackend is implemented by the backends able to re-execute historical
+// transactions, i.e. full nodes.
+type traceBackend interface {
+	TraceTransaction(ctx context.Context, hash common.Hash, config *ccm.TraceConfig) (interface{}, error)
+}
+
+func (r *Resolver) TraceTransaction(ctx context.Context, args struct {
+	Hash    common.Hash
+	Tracer  *string
+	Timeout *string
+}) (*JSON, error) {
+	if !r.tracing {
+		return nil, errors.New("transaction tracing is disabled")
+	}
+	backend, ok := r.backend.(traceBackend)
+	if !ok {
+		return nil, errors.New("transaction tracing is not supported by this node")
+	}
+	// Only allow the native tracers, arbitrary JavaScript code is not run on
+	// behalf of public queries. The structured logs are capped and omit memory.
+	config := &ccm.TraceConfig{
+		LogConfig: &vm.LogConfig{DisableMemory: true, Limit: maxTraceStructLogs},
+		Tracer:    args.Tracer,
+	}
+	if args.Tracer != nil {
+		if _, ok := tracers.NewNative(*args.Tracer); !ok {
+			return nil, fmt.Errorf("unsupported tracer %q", *args.Tracer)
+		}
+	}
+	timeout := maxTraceTimeout
+	if args.Timeout != nil {
+		requested, err := time.ParseDuration(*args.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		if requested < timeout {
+			timeout = requested
+		}
+	}
+	limit := timeout.String()
+	config.Timeout = &limit
+
+	result, err := backend.TraceTransaction(ctx, args.Hash, config)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	ret := JSON(blob)
+	return &ret, nil
+}
+
 func (r *Resolver) GasPrice(ctx context.Context) (hexutil.Big, error) {
 	price, err := r.backend.SuggestPrice(ctx)
 	return hexutil.Big(*price), err
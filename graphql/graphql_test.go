@@ -17,12 +17,578 @@
 package graphql
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/ccm"
+	"github.com/ccmchain/go-ccmchain/ccm/filters"
+	"github.com/ccmchain/go-ccmchain/ccmdb/memorydb"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/consensus/ccmash"
+	"github.com/ccmchain/go-ccmchain/core"
+	"github.com/ccmchain/go-ccmchain/core/rawdb"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/node"
+	"github.com/ccmchain/go-ccmchain/params"
+	"github.com/ccmchain/go-ccmchain/rlp"
+	"github.com/ccmchain/go-ccmchain/trie"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddr    = crypto.PubkeyToAddress(testKey.PublicKey)
+	testBalance = big.NewInt(params.Ccmchain)
+
+	// testEmitter is a contract emitting a LOG2 with the two topics it's called
+	// with, having a single storage slot set.
+	testEmitter     = common.HexToAddress("0x00000000000000000000000000000000000e1717")
+	testEmitterCode = common.FromHex("0x60203560003560006000a200")
+	testEmitterSlot = common.HexToHash("0x01")
 )
 
+// testTopics returns the topics of the log emitted by the i-th test transaction.
+func testTopics(i int) []common.Hash {
+	return []common.Hash{common.BigToHash(big.NewInt(int64(2 * i))), common.BigToHash(big.NewInt(int64(2*i + 1)))}
+}
+
+// testTransaction creates the i-th test transaction, calling the log emitter.
+func testTransaction(t *testing.T, i int) *types.Transaction {
+	var data []byte
+	for _, topic := range testTopics(i) {
+		data = append(data, topic[:]...)
+	}
+	tx, err := types.SignTx(types.NewTransaction(uint64(i), testEmitter, big.NewInt(1), 100000, big.NewInt(1), data), types.NewEIP155Signer(params.AllEthashProtocolChanges.ChainID), testKey)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+// newTestBackend creates a full node with a chain of the given length, each block
+// containing a single call to the log emitter. Byzantium is activated at block 2,
+// so the receipt of the first block has a state root instead of a status. Only
+// the first imported blocks are imported.
+func newTestBackend(t *testing.T, blocks, imported int) (*node.Node, *ccm.Ccmchain, []*types.Block, *httptest.Server) {
+	config := *params.AllEthashProtocolChanges
+	config.ByzantiumBlock = big.NewInt(2)
+	config.ConstantinopleBlock = big.NewInt(2)
+	config.PetersburgBlock = big.NewInt(2)
+
+	genesis := &core.Genesis{
+		Config: &config,
+		Alloc: core.GenesisAlloc{
+			testAddr: {Balance: testBalance},
+			testEmitter: {
+				Balance: big.NewInt(0),
+				Code:    testEmitterCode,
+				Storage: map[common.Hash]common.Hash{testEmitterSlot: common.HexToHash("0x2a")},
+			},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	chain, _ := core.GenerateChain(&config, genesis.MustCommit(db), ccmash.NewFaker(), db, blocks, func(i int, b *core.BlockGen) {
+		b.AddTx(testTransaction(t, i))
+	})
+	// Start a full node and import the requested blocks
+	var ccmservice *ccm.Ccmchain
+
+	stack, err := node.New(&node.Config{})
+	if err != nil {
+		t.Fatalf("failed to create node: %v", err)
+	}
+	stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		config := &ccm.Config{Genesis: genesis}
+		config.Ethash.PowMode = ccmash.ModeFake
+		ccmservice, err = ccm.New(ctx, config)
+		return ccmservice, err
+	})
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start node: %v", err)
+	}
+	if _, err := ccmservice.BlockChain().InsertChain(chain[:imported]); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	// Expose the GraphQL handler over HTTP
+	backend := ccmservice.APIBackend
+	handler, err := newHandler(backend, filters.NewEventSystem(backend.EventMux(), backend, false), nil, true)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	return stack, ccmservice, chain, httptest.NewServer(handler)
+}
+
+// query executes a GraphQL query over HTTP, decoding the data into result.
+func query(t *testing.T, server *httptest.Server, query string, result interface{}) {
+	data, errs := post(t, server, query)
+	if len(errs) > 0 {
+		t.Fatalf("query failed: %v", errs)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+}
+
+// post executes a GraphQL query over HTTP, returning the raw data and the error
+// messages of the reply.
+func post(t *testing.T, server *httptest.Server, query string) (json.RawMessage, []string) {
+	body, _ := json.Marshal(map[string]string{"query": query})
+
+	res, err := http.Post(server.URL+"/graphql", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to post query: %v", err)
+	}
+	defer res.Body.Close()
+
+	var reply struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.NewDecoder(res.Body).Decode(&reply); err != nil {
+		t.Fatalf("failed to decode reply: %v", err)
+	}
+	var errs []string
+	for _, err := range reply.Errors {
+		errs = append(errs, err.Message)
+	}
+	return reply.Data, errs
+}
+
 func TestBuildSchema(t *testing.T) {
 	// Make sure the schema can be parsed and matched up to the object model.
-	if _, err := newHandler(nil); err != nil {
+	if _, err := newHandler(nil, nil, nil, false); err != nil {
 		t.Errorf("Could not construct GraphQL handler: %v", err)
 	}
 }
+
+// Tests that the raw transactions, receipt roots and statuses are reported.
+func TestTransactionQueries(t *testing.T) {
+	stack, _, chain, server := newTestBackend(t, 2, 2)
+	defer stack.Stop()
+	defer server.Close()
+
+	var result struct {
+		Blocks []struct {
+			Transactions []struct {
+				Raw    hexutil.Bytes
+				Status *hexutil.Uint64
+				Root   *common.Hash
+			}
+		}
+	}
+	query(t, server, `{ blocks(from: 1, to: 2) { transactions { raw status root } } }`, &result)
+
+	for i, block := range result.Blocks {
+		tx := block.Transactions[0]
+		if want, _ := rlp.EncodeToBytes(chain[i].Transactions()[0]); !bytes.Equal(tx.Raw, want) {
+			t.Errorf("block %d: raw transaction mismatch: have %x, want %x", i+1, tx.Raw, want)
+		}
+		// The first block is before Byzantium and has a root instead of a status
+		if i == 0 {
+			if tx.Status != nil {
+				t.Errorf("block %d: status reported before Byzantium: %d", i+1, *tx.Status)
+			}
+			if tx.Root == nil || *tx.Root == (common.Hash{}) {
+				t.Errorf("block %d: root missing before Byzantium", i+1)
+			}
+		} else {
+			if tx.Status == nil || *tx.Status != 1 {
+				t.Errorf("block %d: status mismatch: have %v, want 1", i+1, tx.Status)
+			}
+			if tx.Root != nil {
+				t.Errorf("block %d: root reported after Byzantium: %x", i+1, *tx.Root)
+			}
+		}
+	}
+}
+
+// Tests that the account proofs verify against the state root of the block.
+func TestAccountProof(t *testing.T) {
+	stack, _, _, server := newTestBackend(t, 1, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	var result struct {
+		Block struct {
+			StateRoot common.Hash
+			Account   struct {
+				Proof struct {
+					AccountProof []hexutil.Bytes
+					Balance      hexutil.Big
+					Nonce        hexutil.Uint64
+					StorageHash  common.Hash
+					StorageProof []struct {
+						Key   common.Hash
+						Value hexutil.Big
+						Proof []hexutil.Bytes
+					}
+				}
+			}
+		}
+	}
+	query(t, server, fmt.Sprintf(`{ block(number: 1) { stateRoot account(address: "%s") { proof(slots: ["%s"]) {
+		accountProof balance nonce storageHash storageProof { key value proof }
+	} } } }`, testEmitter.Hex(), testEmitterSlot.Hex()), &result)
+
+	// Verify the account against the state root
+	proof := result.Block.Account.Proof
+	if proof.Balance.ToInt().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 1", proof.Balance.ToInt())
+	}
+	if _, _, err := trie.VerifyProof(result.Block.StateRoot, crypto.Keccak256(testEmitter[:]), proofDatabase(proof.AccountProof)); err != nil {
+		t.Errorf("failed to verify account proof: %v", err)
+	}
+	// Verify the storage slot against the storage root
+	if len(proof.StorageProof) != 1 {
+		t.Fatalf("storage proof count mismatch: have %d, want 1", len(proof.StorageProof))
+	}
+	slot := proof.StorageProof[0]
+	if slot.Key != testEmitterSlot || slot.Value.ToInt().Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("storage slot mismatch: have %x=%v, want %x=42", slot.Key, slot.Value.ToInt(), testEmitterSlot)
+	}
+	value, _, err := trie.VerifyProof(proof.StorageHash, crypto.Keccak256(testEmitterSlot[:]), proofDatabase(slot.Proof))
+	if err != nil {
+		t.Fatalf("failed to verify storage proof: %v", err)
+	}
+	if want, _ := rlp.EncodeToBytes([]byte{42}); !bytes.Equal(value, want) {
+		t.Errorf("proven storage mismatch: have %x, want %x", value, want)
+	}
+}
+
+// proofDatabase assembles a set of trie nodes into a database to verify against.
+func proofDatabase(nodes []hexutil.Bytes) *memorydb.Database {
+	db := memorydb.New()
+	for _, node := range nodes {
+		db.Put(crypto.Keccak256(node), node)
+	}
+	return db
+}
+
+// Tests that transactions can be traced, both with tracers and without.
+func TestTraceTransaction(t *testing.T) {
+	stack, _, chain, server := newTestBackend(t, 1, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	hash := chain[0].Transactions()[0].Hash()
+
+	var result struct {
+		Calls struct {
+			Type string
+			To   common.Address
+		}
+		Logs struct {
+			Failed      bool
+			StructLogs  []struct{ Op string }
+			ReturnValue string
+		}
+	}
+	query(t, server, fmt.Sprintf(`{
+		calls: traceTransaction(hash: "%s", tracer: "callTracer")
+		logs: traceTransaction(hash: "%s")
+	}`, hash.Hex(), hash.Hex()), &result)
+
+	if result.Calls.Type != "CALL" || result.Calls.To != testEmitter {
+		t.Errorf("call trace mismatch: have %s to %x, want CALL to %x", result.Calls.Type, result.Calls.To, testEmitter)
+	}
+	if result.Logs.Failed {
+		t.Errorf("struct log trace failed")
+	}
+	var ops []string
+	for _, log := range result.Logs.StructLogs {
+		ops = append(ops, log.Op)
+	}
+	if have, want := strings.Join(ops, ","), "PUSH1,CALLDATALOAD,PUSH1,CALLDATALOAD,PUSH1,PUSH1,LOG2,STOP"; have != want {
+		t.Errorf("struct log trace mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that tracing is refused if disabled, that JavaScript tracers are rejected
+// and that the requested timeout is capped.
+func TestTraceTransactionRestrictions(t *testing.T) {
+	stack, ccmservice, chain, server := newTestBackend(t, 1, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	hash := chain[0].Transactions()[0].Hash().Hex()
+
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{fmt.Sprintf(`{ traceTransaction(hash: "%s", tracer: "{result: function() { return 1 }, fault: function() {}}") }`, hash), "unsupported tracer"},
+		{fmt.Sprintf(`{ traceTransaction(hash: "%s", tracer: "unknownTracer") }`, hash), "unsupported tracer"},
+		{fmt.Sprintf(`{ traceTransaction(hash: "%s", tracer: "callTracer", timeout: "forever") }`, hash), "invalid duration"},
+		{fmt.Sprintf(`{ traceTransaction(hash: "%s", tracer: "callTracer", timeout: "1000h") }`, hash), ""},
+	}
+	for i, tt := range tests {
+		_, errs := post(t, server, tt.query)
+		switch {
+		case tt.err == "" && len(errs) > 0:
+			t.Errorf("test %d: unexpected errors: %v", i, errs)
+		case tt.err != "" && (len(errs) != 1 || !strings.Contains(errs[0], tt.err)):
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, errs, tt.err)
+		}
+	}
+	// Tracing is disabled unless explicitly requested
+	backend := ccmservice.APIBackend
+	handler, err := newHandler(backend, filters.NewEventSystem(backend.EventMux(), backend, false), nil, false)
+	if err != nil {
+		t.Fatalf("failed to create handler: %v", err)
+	}
+	disabled := httptest.NewServer(handler)
+	defer disabled.Close()
+
+	_, errs := post(t, disabled, fmt.Sprintf(`{ traceTransaction(hash: "%s", tracer: "callTracer") }`, hash))
+	if len(errs) != 1 || !strings.Contains(errs[0], "disabled") {
+		t.Errorf("disabled tracing error mismatch: have %v", errs)
+	}
+}
+
+// testSubscriber is a graphql-ws client used to test subscriptions.
+type testSubscriber struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+// newTestSubscriber connects to the GraphQL server over a websocket and
+// initializes the graphql-ws session.
+func newTestSubscriber(t *testing.T, server *httptest.Server) *testSubscriber {
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/graphql", nil)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	s := &testSubscriber{t: t, conn: conn}
+	s.send(wsMessage{Type: wsConnectionInit})
+	if msg := s.read(); msg.Type != wsConnectionAck {
+		t.Fatalf("connection not acknowledged: %v", msg)
+	}
+	return s
+}
+
+func (s *testSubscriber) send(msg wsMessage) {
+	if err := s.conn.WriteJSON(msg); err != nil {
+		s.t.Fatalf("failed to send message: %v", err)
+	}
+}
+
+func (s *testSubscriber) read() *wsMessage {
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	msg := new(wsMessage)
+	if err := s.conn.ReadJSON(msg); err != nil {
+		s.t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+// start starts a GraphQL operation, followed by a query which is waited for, so
+// that any subscription is live upon return.
+func (s *testSubscriber) start(id string, query string) {
+	payload, _ := json.Marshal(wsRequest{Query: query})
+	s.send(wsMessage{ID: id, Type: wsStart, Payload: payload})
+
+	payload, _ = json.Marshal(wsRequest{Query: `{ protocolVersion }`})
+	s.send(wsMessage{ID: "sync", Type: wsStart, Payload: payload})
+	for {
+		if msg := s.read(); msg.ID == "sync" && msg.Type == wsComplete {
+			return
+		} else if msg.ID != "sync" {
+			s.t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+		}
+	}
+}
+
+// data reads the next message, ensuring it's data for the given operation, and
+// decodes the data into result.
+func (s *testSubscriber) data(id string, result interface{}) {
+	s.decode(s.read(), id, result)
+}
+
+// decode ensures a message is data for the given operation, and decodes the data
+// into result.
+func (s *testSubscriber) decode(msg *wsMessage, id string, result interface{}) {
+	if msg.ID != id || msg.Type != wsData {
+		s.t.Fatalf("unexpected message: have %s %s %s, want %s %s", msg.ID, msg.Type, msg.Payload, id, wsData)
+	}
+	var payload struct {
+		Data   json.RawMessage
+		Errors []struct{ Message string }
+	}
+	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+		s.t.Fatalf("failed to decode payload: %v", err)
+	}
+	if len(payload.Errors) > 0 {
+		s.t.Fatalf("subscription failed: %v", payload.Errors)
+	}
+	if err := json.Unmarshal(payload.Data, result); err != nil {
+		s.t.Fatalf("failed to decode data: %v", err)
+	}
+}
+
+// Tests that new heads and logs are delivered to subscribers.
+func TestSubscribeChain(t *testing.T) {
+	stack, ccmservice, chain, server := newTestBackend(t, 3, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	sub := newTestSubscriber(t, server)
+	defer sub.conn.Close()
+
+	sub.start("heads", `subscription { newHeads { number hash } }`)
+	sub.start("logs", fmt.Sprintf(`subscription Logs {
+		newLogs(filter: {addresses: ["%s"], topics: [["%s"]]}) { topics transaction { hash } }
+	}`, testEmitter.Hex(), testTopics(2)[0].Hex()))
+
+	if _, err := ccmservice.BlockChain().InsertChain(chain[1:]); err != nil {
+		t.Fatalf("failed to import blocks: %v", err)
+	}
+	// The heads are delivered for both blocks, but only the second one matches
+	// the log filter. Chain and log events are delivered independently.
+	var (
+		heads []uint64
+		logs  int
+	)
+	for len(heads) < 2 || logs < 1 {
+		var result struct {
+			NewHeads *struct {
+				Number hexutil.Uint64
+				Hash   common.Hash
+			}
+			NewLogs *struct {
+				Topics      []common.Hash
+				Transaction struct{ Hash common.Hash }
+			}
+		}
+		switch msg := sub.read(); msg.ID {
+		case "heads":
+			sub.decode(msg, "heads", &result)
+			if have, want := result.NewHeads.Hash, chain[int(result.NewHeads.Number)-1].Hash(); have != want {
+				t.Errorf("head %d hash mismatch: have %x, want %x", result.NewHeads.Number, have, want)
+			}
+			heads = append(heads, uint64(result.NewHeads.Number))
+
+		case "logs":
+			sub.decode(msg, "logs", &result)
+			if have, want := result.NewLogs.Transaction.Hash, chain[2].Transactions()[0].Hash(); have != want {
+				t.Errorf("log transaction mismatch: have %x, want %x", have, want)
+			}
+			if have, want := result.NewLogs.Topics, testTopics(2); fmt.Sprint(have) != fmt.Sprint(want) {
+				t.Errorf("log topics mismatch: have %x, want %x", have, want)
+			}
+			logs++
+
+		default:
+			t.Fatalf("unexpected message: %s %s %s", msg.ID, msg.Type, msg.Payload)
+		}
+	}
+	if heads[0] != 2 || heads[1] != 3 {
+		t.Errorf("heads mismatch: have %v, want [2 3]", heads)
+	}
+}
+
+// Tests that transactions entering the pool are delivered to subscribers, and
+// that invalid subscriptions are rejected.
+func TestSubscribePendingTransactions(t *testing.T) {
+	stack, ccmservice, _, server := newTestBackend(t, 1, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	sub := newTestSubscriber(t, server)
+	defer sub.conn.Close()
+
+	// Subscriptions with multiple fields should be rejected
+	payload, _ := json.Marshal(wsRequest{Query: `subscription { newHeads { number } newPendingTransactions { hash } }`})
+	sub.send(wsMessage{ID: "invalid", Type: wsStart, Payload: payload})
+	if msg := sub.read(); msg.ID != "invalid" || msg.Type != wsData || !strings.Contains(string(msg.Payload), "at most one subscription") {
+		t.Fatalf("invalid subscription not rejected: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	if msg := sub.read(); msg.ID != "invalid" || msg.Type != wsComplete {
+		t.Fatalf("invalid subscription not completed: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	// Subscribe to pending transactions and inject one into the pool
+	sub.start("pending", `subscription { newPendingTransactions { hash nonce from { address } } }`)
+
+	tx := testTransaction(t, 1)
+	if err := ccmservice.TxPool().AddLocal(tx); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	var result struct {
+		NewPendingTransactions struct {
+			Hash  common.Hash
+			Nonce hexutil.Uint64
+			From  struct{ Address common.Address }
+		}
+	}
+	sub.data("pending", &result)
+	if pending := result.NewPendingTransactions; pending.Hash != tx.Hash() || pending.Nonce != 1 || pending.From.Address != testAddr {
+		t.Errorf("pending transaction mismatch: have %x (nonce %d, from %x), want %x (nonce 1, from %x)", pending.Hash, pending.Nonce, pending.From.Address, tx.Hash(), testAddr)
+	}
+}
+
+// Tests that subscriptions are refused over plain HTTP and that the number of
+// operations running on a websocket connection is capped.
+func TestSubscriptionLimits(t *testing.T) {
+	stack, _, _, server := newTestBackend(t, 1, 1)
+	defer stack.Stop()
+	defer server.Close()
+
+	if _, errs := post(t, server, `subscription { newHeads { number } }`); len(errs) == 0 {
+		t.Errorf("HTTP subscription not rejected")
+	}
+	sub := newTestSubscriber(t, server)
+	defer sub.conn.Close()
+
+	// Operations are set up in order, so the excess one is rejected right away
+	for i := 0; i < wsMaxOperations; i++ {
+		payload, _ := json.Marshal(wsRequest{Query: `subscription { newHeads { number } }`})
+		sub.send(wsMessage{ID: fmt.Sprint(i), Type: wsStart, Payload: payload})
+	}
+	payload, _ := json.Marshal(wsRequest{Query: `{ protocolVersion }`})
+	sub.send(wsMessage{ID: "excess", Type: wsStart, Payload: payload})
+	if msg := sub.read(); msg.ID != "excess" || msg.Type != wsError || !strings.Contains(string(msg.Payload), errTooManyOperations.Error()) {
+		t.Fatalf("excess operation not rejected: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+	// Stopping an operation makes room for a new one
+	sub.send(wsMessage{ID: "0", Type: wsStop})
+	sub.send(wsMessage{ID: "excess", Type: wsStart, Payload: payload})
+	if msg := sub.read(); msg.ID != "excess" || msg.Type != wsData {
+		t.Fatalf("operation not accepted after stop: %s %s %s", msg.ID, msg.Type, msg.Payload)
+	}
+}
+
+// Tests that websocket connections are only accepted from the allowed origins.
+func TestWebsocketOrigins(t *testing.T) {
+	tests := []struct {
+		origins []string
+		origin  string
+		host    string
+		allowed bool
+	}{
+		{nil, "", "localhost:8547", true},
+		{nil, "http://localhost:8547", "localhost:8547", true},
+		{nil, "http://evil.com", "localhost:8547", false},
+		{[]string{"http://dapp.com"}, "http://DAPP.com", "localhost:8547", true},
+		{[]string{"http://dapp.com"}, "http://evil.com", "localhost:8547", false},
+		{[]string{"*"}, "http://evil.com", "localhost:8547", true},
+	}
+	for i, tt := range tests {
+		req := httptest.NewRequest("GET", "http://"+tt.host+"/graphql", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if allowed := wsOriginValidator(tt.origins)(req); allowed != tt.allowed {
+			t.Errorf("test %d: origin %q allowance mismatch: have %v, want %v", i, tt.origin, allowed, tt.allowed)
+		}
+	}
+}
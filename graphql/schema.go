@@ -30,10 +30,14 @@ const schema string = `
     scalar BigInt
     # Long is a 64 bit unsigned integer.
     scalar Long
+    # JSON is an arbitrary JSON value, used for results whose structure is only
+    # known at runtime.
+    scalar JSON
 
     schema {
         query: Query
         mutation: Mutation
+        subscription: Subscription
     }
 
     # Account is an Ccmchain account at a particular block.
@@ -52,6 +56,37 @@ const schema string = `
         # Storage provides access to the storage of a contract account, indexed
         # by its 32 byte slot identifier.
         storage(slot: Bytes32!): Bytes32!
+        # Proof returns the Merkle proof of the account and of the requested
+        # storage slots, rooted at the state root of the account's block.
+        proof(slots: [Bytes32!]): AccountProof
+    }
+
+    # AccountProof is a Merkle proof of an account and some of its storage slots.
+    type AccountProof {
+        # AccountProof is the list of RLP-encoded trie nodes along the path from
+        # the state root to the account.
+        accountProof: [Bytes!]!
+        # Balance is the balance of the account, in wei.
+        balance: BigInt!
+        # CodeHash is the keccak256 hash of the account's code.
+        codeHash: Bytes32!
+        # Nonce is the transaction count of the account.
+        nonce: Long!
+        # StorageHash is the root hash of the account's storage trie.
+        storageHash: Bytes32!
+        # StorageProof is the list of proofs of the requested storage slots.
+        storageProof: [StorageProof!]!
+    }
+
+    # StorageProof is a Merkle proof of a single storage slot of an account.
+    type StorageProof {
+        # Key is the storage slot being proven.
+        key: Bytes32!
+        # Value is the contents of the storage slot.
+        value: BigInt!
+        # Proof is the list of RLP-encoded trie nodes along the path from the
+        # storage root to the slot.
+        proof: [Bytes!]!
     }
 
     # Log is an Ccmchain event log.
@@ -92,15 +127,22 @@ const schema string = `
         gas: Long!
         # InputData is the data supplied to the target of the transaction.
         inputData: Bytes!
+        # Raw is the canonical RLP encoding of the transaction.
+        raw: Bytes!
         # Block is the block this transaction was mined in. This will be null if
         # the transaction has not yet been mined.
         block: Block
 
         # Status is the return status of the transaction. This will be 1 if the
         # transaction succeeded, or 0 if it failed (due to a revert, or due to
-        # running out of gas). If the transaction has not yet been mined, this
-        # field will be null.
+        # running out of gas). If the transaction has not yet been mined, or it
+        # was mined before Byzantium (see root), this field will be null.
         status: Long
+        # Root is the intermediate state root after executing this transaction,
+        # reported by receipts prior to Byzantium in lieu of the status. If the
+        # transaction has not yet been mined, or it has a status, this field
+        # will be null.
+        root: Bytes32
         # GasUsed is the amount of gas that was used processing this transaction.
         # If the transaction has not yet been mined, this field will be null.
         gasUsed: Long
@@ -311,10 +353,29 @@ const schema string = `
         protocolVersion: Int!
         # Syncing returns information on the current synchronisation state.
         syncing: SyncState
+        # TraceTransaction re-executes a mined transaction and returns the output
+        # of the requested native tracer, or the structured execution logs if no
+        # tracer is specified. Timeout is a duration string such as "5s", capped
+        # by the node. Tracing must be enabled by the node operator.
+        traceTransaction(hash: Bytes32!, tracer: String, timeout: String): JSON
     }
 
     type Mutation {
         # SendRawTransaction sends an RLP-encoded transaction to the network.
         sendRawTransaction(data: Bytes!): Bytes32!
     }
+
+    # Subscriptions are only available over websocket connections, using the
+    # graphql-ws protocol. Each subscription must select exactly one field.
+    type Subscription {
+        # NewHeads is notified of every block becoming the head of the canonical
+        # chain.
+        newHeads: Block
+        # NewPendingTransactions is notified of every transaction entering the
+        # transaction pool.
+        newPendingTransactions: Transaction
+        # NewLogs is notified of every log entry matching the provided filter,
+        # emitted by blocks being added to the canonical chain.
+        newLogs(filter: BlockFilterCriteria!): Log
+    }
 `
@@ -17,37 +17,42 @@
 package graphql
 
 import (
-	"fmt"
-	"net"
 	"net/http"
 
+	"github.com/ccmchain/go-ccmchain/ccm/filters"
 	"github.com/ccmchain/go-ccmchain/internal/ccmapi"
-	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/node"
 	"github.com/ccmchain/go-ccmchain/p2p"
 	"github.com/ccmchain/go-ccmchain/rpc"
+	"github.com/gorilla/websocket"
 	"github.com/graph-gophers/graphql-go"
 	"github.com/graph-gophers/graphql-go/relay"
 )
 
 // Service encapsulates a GraphQL service.
 type Service struct {
-	endpoint string           // The host:port endpoint for this service.
-	cors     []string         // Allowed CORS domains
-	vhosts   []string         // Recognised vhosts
-	timeouts rpc.HTTPTimeouts // Timeout settings for HTTP requests.
-	backend  ccmapi.Backend   // The backend that queries will operate onn.
-	handler  http.Handler     // The `http.Handler` used to answer queries.
-	listener net.Listener     // The listening socket.
+	backend ccmapi.Backend // The backend that queries will operate onn.
+	light   bool           // Whccmer the backend is a light client.
+	handler http.Handler   // The `http.Handler` used to answer queries.
 }
 
-// New constructs a new GraphQL service instance.
-func New(backend ccmapi.Backend, endpoint string, cors, vhosts []string, timeouts rpc.HTTPTimeouts) (*Service, error) {
+// New constructs a new GraphQL service instance, registering its handler with
+// the node under the /graphql path of the given endpoint. If the endpoint is
+// empty, GraphQL is served on the HTTP RPC endpoint. Subscriptions are accepted
+// over websocket connections from the given origins. Transaction tracing is only
+// exposed if explicitly enabled.
+func New(ctx *node.ServiceContext, backend ccmapi.Backend, light bool, endpoint string, cors, vhosts, wsOrigins []string, tracing bool) (*Service, error) {
+	events := filters.NewEventSystem(backend.EventMux(), backend, light)
+	handler, err := newHandler(backend, events, wsOrigins, tracing)
+	if err != nil {
+		return nil, err
+	}
+	ctx.RegisterHTTPHandler("GraphQL", endpoint, "/graphql", rpc.NewHTTPHandlerStack(handler, cors, vhosts))
+
 	return &Service{
-		endpoint: endpoint,
-		cors:     cors,
-		vhosts:   vhosts,
-		timeouts: timeouts,
-		backend:  backend,
+		backend: backend,
+		light:   light,
+		handler: handler,
 	}, nil
 }
 
@@ -59,45 +64,42 @@ func (s *Service) APIs() []rpc.API { return nil }
 
 // Start is called after all services have been constructed and the networking
 // layer was also initialized to spawn any goroutines required by the service.
+// The GraphQL handler itself is served by the node.
 func (s *Service) Start(server *p2p.Server) error {
-	var err error
-	s.handler, err = newHandler(s.backend)
-	if err != nil {
-		return err
-	}
-	if s.listener, err = net.Listen("tcp", s.endpoint); err != nil {
-		return err
-	}
-	go rpc.NewHTTPServer(s.cors, s.vhosts, s.timeouts, s.handler).Serve(s.listener)
-	log.Info("GraphQL endpoint opened", "url", fmt.Sprintf("http://%s", s.endpoint))
 	return nil
 }
 
-// newHandler returns a new `http.Handler` that will answer GraphQL queries.
-// It additionally exports an interactive query browser on the / endpoint.
-func newHandler(backend ccmapi.Backend) (http.Handler, error) {
-	q := Resolver{backend}
+// newHandler returns a new `http.Handler` that will answer GraphQL queries, and
+// subscriptions fed by the given event system over websocket connections from
+// the allowed origins. It additionally exports an interactive query browser on
+// the /graphql/ui endpoint. Transaction tracing is only answered if enabled.
+func newHandler(backend ccmapi.Backend, events *filters.EventSystem, origins []string, tracing bool) (http.Handler, error) {
+	q := Resolver{backend: backend, events: events, tracing: tracing}
 
 	s, err := graphql.ParseSchema(schema, &q)
 	if err != nil {
 		return nil, err
 	}
-	h := &relay.Handler{Schema: s}
-
+	var (
+		rh = &relay.Handler{Schema: s}
+		wh = newWSHandler(s, origins)
+	)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			wh.ServeHTTP(w, r)
+			return
+		}
+		rh.ServeHTTP(w, r)
+	})
 	mux := http.NewServeMux()
-	mux.Handle("/", GraphiQL{})
 	mux.Handle("/graphql", h)
 	mux.Handle("/graphql/", h)
+	mux.Handle("/graphql/ui", GraphiQL{})
 	return mux, nil
 }
 
 // Stop terminates all goroutines belonging to the service, blocking until they
-// are all terminated.
+// are all terminated. The GraphQL handler is unmounted by the node.
 func (s *Service) Stop() error {
-	if s.listener != nil {
-		s.listener.Close()
-		s.listener = nil
-		log.Info("GraphQL endpoint closed", "url", fmt.Sprintf("http://%s", s.endpoint))
-	}
 	return nil
 }
@@ -0,0 +1,199 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ccmchain/go-ccmchain"
+	"github.com/ccmchain/go-ccmchain/ccm/filters"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/rpc"
+)
+
+// subscriptionQueueLimit is the maximum number of events queued up for a single
+// subscription before it is dropped for being too slow to keep up.
+const subscriptionQueueLimit = 1024
+
+// errSubscriptionOverflow is returned if a subscription is dropped for queueing up
+// too many events.
+var errSubscriptionOverflow = errors.New("subscription dropped, too many queued events")
+
+// subscriptionKey is the context key of the callback reporting the failure of a
+// subscription to the client.
+type subscriptionKey struct{}
+
+// subscription is a single subscription to the event system, feeding the events
+// to a Subscription resolver.
+type subscription struct {
+	headers chan *types.Header    // Channel receiving new chain heads
+	hashes  chan []common.Hash    // Channel receiving new pending transactions
+	logs    chan []*types.Log     // Channel receiving new logs
+	events  *filters.Subscription // Subscription to the event system
+}
+
+// start runs the subscription in the background until the context is cancelled,
+// passing every event to the deliver callback. Once the subscription terminates,
+// the done callback is invoked. A failure is reported through the context.
+func (s *subscription) start(ctx context.Context, deliver func(interface{}), done func()) {
+	go func() {
+		defer done()
+		if err := s.run(ctx, deliver); err != nil {
+			if report, ok := ctx.Value(subscriptionKey{}).(func(error)); ok {
+				report(err)
+			}
+		}
+	}()
+}
+
+func (r *Resolver) NewHeads(ctx context.Context) (<-chan *Block, error) {
+	sub := &subscription{headers: make(chan *types.Header)}
+	sub.events = r.events.SubscribeNewHeads(sub.headers)
+
+	results := make(chan *Block)
+	sub.start(ctx, func(event interface{}) {
+		header := event.(*types.Header)
+		num := rpc.BlockNumber(header.Number.Uint64())
+		block := &Block{
+			backend:   r.backend,
+			num:       &num,
+			hash:      header.Hash(),
+			header:    header,
+			canonical: unknown,
+		}
+		select {
+		case results <- block:
+		case <-ctx.Done():
+		}
+	}, func() { close(results) })
+
+	return results, nil
+}
+
+func (r *Resolver) NewPendingTransactions(ctx context.Context) (<-chan *Transaction, error) {
+	sub := &subscription{hashes: make(chan []common.Hash)}
+	sub.events = r.events.SubscribePendingTxs(sub.hashes)
+
+	results := make(chan *Transaction)
+	sub.start(ctx, func(event interface{}) {
+		tx := &Transaction{
+			backend: r.backend,
+			hash:    event.(common.Hash),
+		}
+		select {
+		case results <- tx:
+		case <-ctx.Done():
+		}
+	}, func() { close(results) })
+
+	return results, nil
+}
+
+func (r *Resolver) NewLogs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) (<-chan *Log, error) {
+	var crit ccmchain.FilterQuery
+	if args.Filter.Addresses != nil {
+		crit.Addresses = *args.Filter.Addresses
+	}
+	if args.Filter.Topics != nil {
+		crit.Topics = *args.Filter.Topics
+	}
+	sub := &subscription{logs: make(chan []*types.Log)}
+	events, err := r.events.SubscribeLogs(crit, sub.logs)
+	if err != nil {
+		return nil, err
+	}
+	sub.events = events
+
+	results := make(chan *Log)
+	sub.start(ctx, func(event interface{}) {
+		log := event.(*types.Log)
+		result := &Log{
+			backend:     r.backend,
+			transaction: &Transaction{backend: r.backend, hash: log.TxHash},
+			log:         log,
+		}
+		select {
+		case results <- result:
+		case <-ctx.Done():
+		}
+	}, func() { close(results) })
+
+	return results, nil
+}
+
+// run passes every event of the subscription to the deliver callback until the
+// context is cancelled. The subscription is torn down when the function returns.
+func (s *subscription) run(ctx context.Context, deliver func(interface{})) error {
+	defer s.events.Unsubscribe()
+
+	// Deliver the events on a separate goroutine, so slow clients don't block the
+	// event system. Events are queued up until they can be delivered.
+	var (
+		work  = make(chan interface{})
+		done  = make(chan struct{})
+		queue []interface{}
+	)
+	defer func() {
+		close(work)
+		<-done
+	}()
+	go func() {
+		defer close(done)
+		for event := range work {
+			deliver(event)
+		}
+	}()
+	for {
+		var (
+			next    interface{}
+			pending chan interface{}
+		)
+		if len(queue) > 0 {
+			next, pending = queue[0], work
+		}
+		select {
+		case header := <-s.headers:
+			queue = append(queue, header)
+
+		case batch := <-s.hashes:
+			for _, hash := range batch {
+				queue = append(queue, hash)
+			}
+		case batch := <-s.logs:
+			// Logs reverted by reorgs are also delivered, but there's no way to
+			// flag them as such to the client, so skip them.
+			for _, log := range batch {
+				if !log.Removed {
+					queue = append(queue, log)
+				}
+			}
+		case pending <- next:
+			queue = queue[1:]
+
+		case <-s.events.Err():
+			return nil
+
+		case <-ctx.Done():
+			return nil
+		}
+		if len(queue) > subscriptionQueueLimit {
+			return errSubscriptionOverflow
+		}
+	}
+}
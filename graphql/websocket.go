@@ -0,0 +1,307 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/gorilla/websocket"
+	"github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+// Message types of the graphql-ws protocol, as defined by
+// https://github.com/apollographql/subscriptions-transport-ws/blob/master/PROTOCOL.md
+const (
+	wsConnectionInit      = "connection_init"
+	wsConnectionAck       = "connection_ack"
+	wsConnectionError     = "connection_error"
+	wsConnectionKeepAlive = "ka"
+	wsConnectionTerminate = "connection_terminate"
+	wsStart               = "start"
+	wsStop                = "stop"
+	wsData                = "data"
+	wsError               = "error"
+	wsComplete            = "complete"
+)
+
+const (
+	// wsKeepAliveInterval is the time between keep-alive messages sent to clients.
+	wsKeepAliveInterval = 30 * time.Second
+
+	// wsReadLimit is the maximum size of a message accepted from clients.
+	wsReadLimit = 5 * 1024 * 1024
+
+	// wsWriteTimeout is the time allowed to send a message to a client, after
+	// which the connection is dropped.
+	wsWriteTimeout = 10 * time.Second
+
+	// wsMaxOperations is the maximum number of operations running concurrently
+	// on a single connection.
+	wsMaxOperations = 100
+)
+
+var errTooManyOperations = errors.New("too many operations on connection")
+
+// wsMessage is a single message of the graphql-ws protocol.
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsRequest is the payload of a start message.
+type wsRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// wsHandler serves GraphQL queries, mutations and subscriptions over websocket
+// connections, using the graphql-ws protocol.
+type wsHandler struct {
+	schema   *graphql.Schema // Schema to execute the operations with
+	upgrader websocket.Upgrader
+}
+
+// newWSHandler creates a websocket handler, accepting connections from the given
+// origins. Without any origins, only same-origin connections are accepted.
+func newWSHandler(schema *graphql.Schema, origins []string) *wsHandler {
+	return &wsHandler{
+		schema: schema,
+		upgrader: websocket.Upgrader{
+			CheckOrigin:  wsOriginValidator(origins),
+			Subprotocols: []string{"graphql-ws"},
+		},
+	}
+}
+
+// wsOriginValidator returns a handler that verifies the origin during the
+// websocket upgrade process.
+func wsOriginValidator(origins []string) func(*http.Request) bool {
+	allowed := make(map[string]bool)
+	for _, origin := range origins {
+		allowed[strings.ToLower(origin)] = true
+	}
+	return func(req *http.Request) bool {
+		// Non-browser clients don't set origins and don't need protection
+		origin := req.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if allowed["*"] || allowed[strings.ToLower(origin)] {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, req.Host) {
+			return true
+		}
+		log.Warn("Rejected GraphQL websocket connection", "origin", origin)
+		return false
+	}
+}
+
+// ServeHTTP implements http.Handler, upgrading the connection to a websocket and
+// serving GraphQL requests on it until it's closed.
+func (h *wsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Debug("GraphQL websocket upgrade failed", "err", err)
+		return
+	}
+	conn.SetReadLimit(wsReadLimit)
+
+	c := &wsConn{
+		handler: h,
+		conn:    conn,
+		ops:     make(map[string]*wsOperation),
+	}
+	c.serve()
+}
+
+// wsOperation is a GraphQL request running on a websocket connection.
+type wsOperation struct {
+	cancel context.CancelFunc // Terminates the operation
+}
+
+// wsConn is a single websocket connection and the operations running on it.
+type wsConn struct {
+	handler *wsHandler
+	conn    *websocket.Conn
+
+	ops     map[string]*wsOperation // Running operations, by client assigned id
+	opsLock sync.Mutex              // Lock protecting the running operations
+	opsWg   sync.WaitGroup          // Wait group to terminate the operations
+
+	writeLock sync.Mutex // Lock serializing the writes to the connection
+}
+
+// serve reads and handles the messages of the client until the connection is
+// closed or terminated.
+func (c *wsConn) serve() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		c.opsWg.Wait()
+		c.conn.Close()
+	}()
+	go c.keepAlive(ctx)
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			log.Trace("GraphQL websocket closed", "err", err)
+			return
+		}
+		switch msg.Type {
+		case wsConnectionInit:
+			c.send(wsConnectionAck, "", nil)
+
+		case wsConnectionTerminate:
+			return
+
+		case wsStart:
+			var req wsRequest
+			if err := json.Unmarshal(msg.Payload, &req); err != nil {
+				c.sendErrors(msg.ID, gqlerrors.Errorf("invalid request: %v", err))
+				continue
+			}
+			c.start(ctx, msg.ID, &req)
+
+		case wsStop:
+			c.stop(msg.ID)
+
+		default:
+			c.send(wsConnectionError, msg.ID, map[string]string{"message": "unknown message type " + msg.Type})
+		}
+	}
+}
+
+// keepAlive periodically sends keep-alive messages to the client until the
+// context is cancelled.
+func (c *wsConn) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(wsKeepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.send(wsConnectionKeepAlive, "", nil)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// start executes a GraphQL request in the background. Queries and mutations are
+// executed once, subscriptions are kept alive until stopped by the client.
+//
+// The operation is set up synchronously, so subscriptions are live once the next
+// message from the client is handled. As a consequence, queries and mutations are
+// executed in order.
+func (c *wsConn) start(ctx context.Context, id string, req *wsRequest) {
+	if errs := c.handler.schema.Validate(req.Query); len(errs) > 0 {
+		c.sendErrors(id, errs...)
+		return
+	}
+	// Replace any previous operation with the same id and start the new one
+	c.stop(id)
+
+	c.opsLock.Lock()
+	if len(c.ops) >= wsMaxOperations {
+		c.opsLock.Unlock()
+		c.sendErrors(id, gqlerrors.Errorf("%s", errTooManyOperations))
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	op := &wsOperation{cancel: cancel}
+	c.ops[id] = op
+	c.opsLock.Unlock()
+
+	ctx = context.WithValue(ctx, subscriptionKey{}, func(err error) {
+		c.sendErrors(id, gqlerrors.Errorf("%s", err))
+	})
+	results, err := c.handler.schema.Subscribe(ctx, req.Query, req.OperationName, req.Variables)
+	if err != nil {
+		c.stop(id)
+		c.sendErrors(id, gqlerrors.Errorf("%s", err))
+		return
+	}
+	c.opsWg.Add(1)
+	go func() {
+		defer c.opsWg.Done()
+
+		for res := range results {
+			c.send(wsData, id, res)
+		}
+		// Notify the client of the completion, unless it stopped the operation
+		if ctx.Err() == nil {
+			c.send(wsComplete, id, nil)
+		}
+		c.opsLock.Lock()
+		if c.ops[id] == op {
+			delete(c.ops, id)
+		}
+		c.opsLock.Unlock()
+		cancel()
+	}()
+}
+
+// stop terminates the operation with the given id, if it's running.
+func (c *wsConn) stop(id string) {
+	c.opsLock.Lock()
+	defer c.opsLock.Unlock()
+
+	if op, ok := c.ops[id]; ok {
+		op.cancel()
+		delete(c.ops, id)
+	}
+}
+
+// sendErrors sends a set of request errors to the client.
+func (c *wsConn) sendErrors(id string, errs ...*gqlerrors.QueryError) {
+	c.send(wsError, id, errs)
+}
+
+// send serializes a message with the given payload and sends it to the client.
+func (c *wsConn) send(kind string, id string, payload interface{}) {
+	msg := wsMessage{ID: id, Type: kind}
+	if payload != nil {
+		blob, err := json.Marshal(payload)
+		if err != nil {
+			log.Warn("Failed to encode GraphQL message", "type", kind, "err", err)
+			return
+		}
+		msg.Payload = blob
+	}
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if err := c.conn.WriteJSON(msg); err != nil {
+		// Drop the connection, it's unusable after a failed or timed out write
+		log.Trace("Failed to send GraphQL message", "type", kind, "err", err)
+		c.conn.Close()
+	}
+}
@@ -75,6 +75,7 @@ type Backend interface {
 
 	// Filter API
 	BloomStatus() (uint64, uint64)
+	LogIndexStatus() (uint64, uint64)
 	GetLogs(ctx context.Context, blockHash common.Hash) ([][]*types.Log, error)
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 	SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscription
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sync"
 
+	"github.com/ccmchain/go-ccmchain/log"
 	"github.com/ccmchain/go-ccmchain/metrics"
 	"github.com/ccmchain/go-ccmchain/metrics/promccmeus"
 )
@@ -46,6 +47,20 @@ func Exp(r metrics.Registry) {
 	http.Handle("/debug/metrics/promccmeus", promccmeus.Handler(r))
 }
 
+// Setup starts a dedicated metrics server at the given address, serving the
+// same endpoints as Exp, independently of the pprof server.
+func Setup(address string) {
+	m := http.NewServeMux()
+	m.Handle("/debug/metrics", ExpHandler(metrics.DefaultRegistry))
+	m.Handle("/debug/metrics/promccmeus", promccmeus.Handler(metrics.DefaultRegistry))
+	log.Info("Starting metrics server", "addr", fmt.Sprintf("http://%s/debug/metrics", address))
+	go func() {
+		if err := http.ListenAndServe(address, m); err != nil {
+			log.Error("Failure in running metrics server", "err", err)
+		}
+	}()
+}
+
 // ExpHandler will return an expvar powered metrics handler.
 func ExpHandler(r metrics.Registry) http.Handler {
 	e := exp{sync.Mutex{}, r}
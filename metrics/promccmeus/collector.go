@@ -19,97 +19,210 @@ package promccmeus
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/ccmchain/go-ccmchain/log"
 	"github.com/ccmchain/go-ccmchain/metrics"
 )
 
-var (
-	typeGaugeTpl           = "# TYPE %s gauge\n"
-	typeCounterTpl         = "# TYPE %s counter\n"
-	typeSummaryTpl         = "# TYPE %s summary\n"
-	keyValueTpl            = "%s %v\n\n"
-	keyQuantileTagValueTpl = "%s {quantile=\"%s\"} %v\n\n"
+// Promccmeus metric types emitted by the collector.
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+	typeSummary = "summary"
 )
 
-// collector is a collection of byte buffers that aggregate Promccmeus reports
-// for different metric types.
+// quantiles are the percentiles reported for timers and histograms.
+var quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+// labelEscaper escapes the characters not allowed verbatim in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// label is a single label dimension of a Promccmeus time series.
+type label struct {
+	name  string
+	value string
+}
+
+// family is a group of time series sharing the same metric name and type,
+// differing only in their labels.
+type family struct {
+	name    string   // Sanitized metric name
+	kind    string   // Promccmeus metric type
+	samples []string // Rendered time series of the family
+}
+
+// collector aggregates metrics into Promccmeus metric families, so that all the
+// time series of a family are reported together under a single type header.
 type collector struct {
-	buff *bytes.Buffer
+	families map[string]*family
+	order    []string // Metric names in order of first appearance
 }
 
 // newCollector createa a new Promccmeus metric aggregator.
 func newCollector() *collector {
 	return &collector{
-		buff: &bytes.Buffer{},
+		families: make(map[string]*family),
 	}
 }
 
 func (c *collector) addCounter(name string, m metrics.Counter) {
-	c.writeGaugeCounter(name, m.Count())
+	c.writeValue(name, typeCounter, m.Count())
 }
 
 func (c *collector) addGauge(name string, m metrics.Gauge) {
-	c.writeGaugeCounter(name, m.Value())
+	c.writeValue(name, typeGauge, m.Value())
 }
 
 func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
-	c.writeGaugeCounter(name, m.Value())
+	c.writeValue(name, typeGauge, m.Value())
 }
 
 func (c *collector) addHistogram(name string, m metrics.Histogram) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
-	}
+	c.writeSummary(name, m.Percentiles(quantiles), m.Count())
 }
 
 func (c *collector) addMeter(name string, m metrics.Meter) {
-	c.writeGaugeCounter(name, m.Count())
+	c.writeValue(name, typeCounter, m.Count())
 }
 
 func (c *collector) addTimer(name string, m metrics.Timer) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
-	}
+	c.writeSummary(name, m.Percentiles(quantiles), m.Count())
 }
 
 func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
 	if len(m.Values()) <= 0 {
 		return
 	}
+	// Resetting timers only retain the values since the last report, so there's
+	// no cumulative sum and count to report, only the quantiles.
 	ps := m.Percentiles([]float64{50, 95, 99})
-	val := m.Values()
-	c.writeSummaryCounter(name, len(val))
-	c.writeSummaryPercentile(name, "0.50", ps[0])
-	c.writeSummaryPercentile(name, "0.95", ps[1])
-	c.writeSummaryPercentile(name, "0.99", ps[2])
+
+	f, labels := c.family(name, typeSummary)
+	if f == nil {
+		return
+	}
+	for i, q := range []string{"0.5", "0.95", "0.99"} {
+		f.samples = append(f.samples, formatSample(f.name, "", append(labels, label{"quantile", q}), ps[i]))
+	}
 }
 
-func (c *collector) writeGaugeCounter(name string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+// writeValue reports a single valued metric of the given type.
+func (c *collector) writeValue(name string, kind string, value interface{}) {
+	f, labels := c.family(name, kind)
+	if f == nil {
+		return
+	}
+	f.samples = append(f.samples, formatSample(f.name, "", labels, value))
 }
 
-func (c *collector) writeSummaryCounter(name string, value interface{}) {
-	name = mutateKey(name + "_count")
-	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+// writeSummary reports a summary with its quantiles and count. The sum is omitted,
+// as only the sampled values are retained, not the cumulative sum.
+func (c *collector) writeSummary(name string, ps []float64, count int64) {
+	f, labels := c.family(name, typeSummary)
+	if f == nil {
+		return
+	}
+	for i, q := range quantiles {
+		f.samples = append(f.samples, formatSample(f.name, "", append(labels, label{"quantile", strconv.FormatFloat(q, 'f', -1, 64)}), ps[i]))
+	}
+	f.samples = append(f.samples, formatSample(f.name, "_count", labels, count))
+}
+
+// family retrieves the metric family a metric belongs to based on its name,
+// along with the labels parsed from the name. Nil is returned if the family was
+// already reported with a different type.
+func (c *collector) family(name string, kind string) (*family, []label) {
+	base, labels := parseName(name)
+
+	f, ok := c.families[base]
+	if !ok {
+		f = &family{name: base, kind: kind}
+		c.families[base] = f
+		c.order = append(c.order, base)
+	}
+	if f.kind != kind {
+		log.Warn("Conflicting Promccmeus metric types", "name", name, "have", kind, "want", f.kind)
+		return nil, nil
+	}
+	return f, labels
 }
 
-func (c *collector) writeSummaryPercentile(name, p string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, p, value))
+// bytes renders the collected metric families in the Promccmeus text format.
+func (c *collector) bytes() []byte {
+	var buff bytes.Buffer
+	for _, name := range c.order {
+		f := c.families[name]
+
+		fmt.Fprintf(&buff, "# TYPE %s %s\n", f.name, f.kind)
+		for _, sample := range f.samples {
+			buff.WriteString(sample)
+		}
+	}
+	return buff.Bytes()
 }
 
+// formatSample renders a single time series line.
+func formatSample(name string, suffix string, labels []label, value interface{}) string {
+	var buff strings.Builder
+
+	buff.WriteString(name + suffix)
+	if len(labels) > 0 {
+		buff.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				buff.WriteByte(',')
+			}
+			buff.WriteString(l.name + `="` + labelEscaper.Replace(l.value) + `"`)
+		}
+		buff.WriteByte('}')
+	}
+	fmt.Fprintf(&buff, " %v\n", value)
+	return buff.String()
+}
+
+// parseName splits a metric name into a sanitized Promccmeus metric name and
+// the label dimensions embedded in it. Labels are specified as a comma separated
+// list of key/value pairs enclosed in braces at the end of the name, e.g.
+// rpc/requests{method=ccm_call,transport="http"}. Names with malformed labels
+// are treated as plain names.
+func parseName(name string) (string, []label) {
+	start := strings.IndexByte(name, '{')
+	if start < 0 || !strings.HasSuffix(name, "}") {
+		return mutateKey(name), nil
+	}
+	var labels []label
+	for _, pair := range strings.Split(name[start+1:len(name)-1], ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return mutateKey(name), nil
+		}
+		value := strings.TrimSpace(parts[1])
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+		labels = append(labels, label{strings.Replace(mutateKey(strings.TrimSpace(parts[0])), ":", "_", -1), value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return mutateKey(name[:start]), labels
+}
+
+// mutateKey converts a metric name into a valid Promccmeus name, replacing all
+// unsupported characters with underscores.
 func mutateKey(key string) string {
-	return strings.Replace(key, "/", "_", -1)
+	key = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, key)
+	if key == "" || key[0] >= '0' && key[0] <= '9' {
+		key = "_" + key
+	}
+	return key
 }
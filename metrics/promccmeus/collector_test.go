@@ -0,0 +1,261 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package promccmeus
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/metrics"
+)
+
+// testSample is a single time series parsed from the Promccmeus text format.
+type testSample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+// testFamily is a metric family parsed from the Promccmeus text format.
+type testFamily struct {
+	kind    string
+	samples []testSample
+}
+
+var (
+	testMetricName = `[a-zA-Z_:][a-zA-Z0-9_:]*`
+	testTypeLine   = regexp.MustCompile(`^# TYPE (` + testMetricName + `) (counter|gauge|summary|histogram|untyped)$`)
+	testSampleLine = regexp.MustCompile(`^(` + testMetricName + `)(?:\{(.*)\})? (\S+)$`)
+	testLabelPair  = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\[\\"n])*)"(,|$)`)
+)
+
+// parseTestMetrics parses a Promccmeus text format exposition, enforcing that
+// every family is declared exactly once before its samples, that samples of a
+// family are contiguous and that their names match the family type.
+func parseTestMetrics(text string) (map[string]*testFamily, error) {
+	var (
+		families = make(map[string]*testFamily)
+		current  string
+	)
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			match := testTypeLine.FindStringSubmatch(line)
+			if match == nil {
+				if strings.HasPrefix(line, "# TYPE") {
+					return nil, fmt.Errorf("invalid type line: %q", line)
+				}
+				continue // Comment or help line
+			}
+			if _, ok := families[match[1]]; ok {
+				return nil, fmt.Errorf("duplicate family: %s", match[1])
+			}
+			families[match[1]] = &testFamily{kind: match[2]}
+			current = match[1]
+			continue
+		}
+		match := testSampleLine.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("invalid sample line: %q", line)
+		}
+		family := families[current]
+		if family == nil {
+			return nil, fmt.Errorf("sample without family: %q", line)
+		}
+		switch {
+		case match[1] == current:
+		case family.kind == "summary" && (match[1] == current+"_sum" || match[1] == current+"_count"):
+		case family.kind == "histogram" && (match[1] == current+"_sum" || match[1] == current+"_count" || match[1] == current+"_bucket"):
+		default:
+			return nil, fmt.Errorf("sample %s outside of its family %s", match[1], current)
+		}
+		labels := make(map[string]string)
+		for rest := match[2]; rest != ""; {
+			pair := testLabelPair.FindStringSubmatch(rest)
+			if pair == nil {
+				return nil, fmt.Errorf("invalid labels: %q", match[2])
+			}
+			if _, ok := labels[pair[1]]; ok {
+				return nil, fmt.Errorf("duplicate label %s: %q", pair[1], line)
+			}
+			labels[pair[1]] = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n").Replace(pair[2])
+			rest = rest[len(pair[0]):]
+		}
+		value, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value: %q", line)
+		}
+		family.samples = append(family.samples, testSample{name: match[1], labels: labels, value: value})
+	}
+	return families, scanner.Err()
+}
+
+// find returns the value of the sample with the given name and labels.
+func (f *testFamily) find(name string, labels map[string]string) (float64, bool) {
+	for _, sample := range f.samples {
+		if sample.name != name || len(sample.labels) != len(labels) {
+			continue
+		}
+		match := true
+		for key, value := range labels {
+			if sample.labels[key] != value {
+				match = false
+			}
+		}
+		if match {
+			return sample.value, true
+		}
+	}
+	return 0, false
+}
+
+// Tests that all metric types are exported with the proper Promccmeus types and
+// that label dimensions embedded in the metric names are grouped into families.
+func TestHandler(t *testing.T) {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	defer func() { metrics.Enabled = enabled }()
+
+	registry := metrics.NewRegistry()
+
+	metrics.NewRegisteredCounter("test/counter", registry).Inc(3)
+	metrics.NewRegisteredGauge("test/gauge", registry).Update(-5)
+	metrics.NewRegisteredGaugeFloat64("test/gauge/float", registry).Update(1.5)
+
+	meter := metrics.NewRegisteredMeter("test/meter", registry)
+	defer meter.Stop()
+	meter.Mark(7)
+
+	histogram := metrics.NewRegisteredHistogram("test/histogram", registry, metrics.NewUniformSample(100))
+	for i := int64(1); i <= 10; i++ {
+		histogram.Update(i)
+	}
+	timer := metrics.NewRegisteredTimer("test/timer", registry)
+	defer timer.Stop()
+	timer.Update(time.Second)
+	timer.Update(3 * time.Second)
+
+	metrics.NewRegisteredResettingTimer("test/resetting", registry).Update(time.Millisecond)
+	metrics.NewRegisteredResettingTimer("test/resetting/empty", registry)
+
+	metrics.NewRegisteredCounter(`test/labelled{method=ccm_call,transport="h\"ttp"}`, registry).Inc(1)
+	metrics.NewRegisteredCounter("test/labelled{method=ccm_getLogs, transport=ws}", registry).Inc(2)
+	metrics.NewRegisteredTimer("test/latency{method=ccm_call}", registry).Update(time.Millisecond)
+	metrics.NewRegisteredTimer("test/latency{method=ccm_getLogs}", registry).Update(time.Second)
+	metrics.NewRegisteredGauge("test/latency{method=conflicting}", registry).Update(1)
+	metrics.NewRegisteredCounter("test/malformed{method}", registry).Inc(4)
+
+	server := httptest.NewServer(Handler(registry))
+	defer server.Close()
+
+	res, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("failed to retrieve metrics: %v", err)
+	}
+	defer res.Body.Close()
+
+	blob, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	families, err := parseTestMetrics(string(blob))
+	if err != nil {
+		t.Fatalf("failed to parse metrics: %v\n%s", err, blob)
+	}
+	// Ensure all the families are reported with the correct types
+	types := map[string]string{
+		"test_counter":           "counter",
+		"test_gauge":             "gauge",
+		"test_gauge_float":       "gauge",
+		"test_meter":             "counter",
+		"test_histogram":         "summary",
+		"test_timer":             "summary",
+		"test_resetting":         "summary",
+		"test_labelled":          "counter",
+		"test_latency":           "summary",
+		"test_malformed_method_": "counter",
+	}
+	for name, kind := range types {
+		family, ok := families[name]
+		if !ok {
+			t.Errorf("family %s missing", name)
+			continue
+		}
+		if family.kind != kind {
+			t.Errorf("family %s: type mismatch: have %s, want %s", name, family.kind, kind)
+		}
+	}
+	if len(families) != len(types) {
+		t.Errorf("family count mismatch: have %d, want %d", len(families), len(types))
+	}
+	// Ensure the reported values are correct
+	values := []struct {
+		family string
+		name   string
+		labels map[string]string
+		value  float64
+	}{
+		{"test_counter", "test_counter", nil, 3},
+		{"test_gauge", "test_gauge", nil, -5},
+		{"test_gauge_float", "test_gauge_float", nil, 1.5},
+		{"test_meter", "test_meter", nil, 7},
+		{"test_histogram", "test_histogram", map[string]string{"quantile": "0.5"}, 5.5},
+		{"test_histogram", "test_histogram_count", nil, 10},
+		{"test_timer", "test_timer", map[string]string{"quantile": "0.99"}, float64(3 * time.Second)},
+		{"test_timer", "test_timer_count", nil, 2},
+		{"test_resetting", "test_resetting", map[string]string{"quantile": "0.5"}, float64(time.Millisecond)},
+		{"test_labelled", "test_labelled", map[string]string{"method": "ccm_call", "transport": `h"ttp`}, 1},
+		{"test_labelled", "test_labelled", map[string]string{"method": "ccm_getLogs", "transport": "ws"}, 2},
+		{"test_latency", "test_latency_count", map[string]string{"method": "ccm_call"}, 1},
+		{"test_latency", "test_latency", map[string]string{"method": "ccm_getLogs", "quantile": "0.5"}, float64(time.Second)},
+		{"test_malformed_method_", "test_malformed_method_", nil, 4},
+	}
+	for _, tt := range values {
+		family, ok := families[tt.family]
+		if !ok {
+			continue
+		}
+		value, ok := family.find(tt.name, tt.labels)
+		if !ok {
+			t.Errorf("sample %s%v missing", tt.name, tt.labels)
+			continue
+		}
+		if value != tt.value {
+			t.Errorf("sample %s%v: value mismatch: have %v, want %v", tt.name, tt.labels, value, tt.value)
+		}
+	}
+	if _, ok := families["test_latency"].find("test_latency", map[string]string{"method": "conflicting"}); ok {
+		t.Errorf("conflicting metric type reported")
+	}
+	// Ensure no approximated sums are reported for the sampled summaries
+	for _, name := range []string{"test_histogram", "test_timer"} {
+		if _, ok := families[name].find(name+"_sum", nil); ok {
+			t.Errorf("family %s: sampled sum reported", name)
+		}
+	}
+}
@@ -61,8 +61,10 @@ func Handler(reg metrics.Registry) http.Handler {
 				log.Warn("Unknown Promccmeus metric type", "type", fmt.Sprintf("%T", i))
 			}
 		}
-		w.Header().Add("Content-Type", "text/plain")
-		w.Header().Add("Content-Length", fmt.Sprint(c.buff.Len()))
-		w.Write(c.buff.Bytes())
+		blob := c.bytes()
+
+		w.Header().Add("Content-Type", "text/plain; version=0.0.4")
+		w.Header().Add("Content-Length", fmt.Sprint(len(blob)))
+		w.Write(blob)
 	})
 }
@@ -18,6 +18,8 @@ package node
 
 import (
 	"crypto/ecdsa"
+	crand "crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -45,6 +47,7 @@ const (
 	datadirStaticNodes     = "static-nodes.json"  // Path within the datadir to the static node list
 	datadirTrustedNodes    = "trusted-nodes.json" // Path within the datadir to the trusted node list
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
+	datadirJWTSecret       = "jwtsecret"          // Path within the datadir to the authenticated RPC secret
 )
 
 // Config represents a small collection of configuration values to fine tune the
@@ -82,6 +85,12 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// KeyStoreVault is the file to keep all the private keys in, instead of a key
+	// file per account within KeyStoreDir. The path can be specified as a relative
+	// path, in which case it is resolved relative to the current directory. Note,
+	// the vault stores the addresses of its accounts in cleartext.
+	KeyStoreVault string `toml:",omitempty"`
+
 	// ExternalSigner specifies an external URI for a clef-type signer
 	ExternalSigner string `toml:"omitempty"`
 
@@ -110,7 +119,8 @@ type Config struct {
 
 	// HTTPPort is the TCP port number on which to start the HTTP RPC server. The
 	// default zero value is/ valid and will pick a port number randomly (useful
-	// for ephemeral nodes).
+	// for ephemeral nodes). The websocket and GraphQL endpoints may be configured
+	// with the same host and port, in which case they share a single listener.
 	HTTPPort int `toml:",omitempty"`
 
 	// HTTPCors is the Cross-Origin Resource Sharing header to send to requesting
@@ -136,18 +146,29 @@ type Config struct {
 	// interface.
 	HTTPTimeouts rpc.HTTPTimeouts
 
+	// RPCLimits restricts the batch sizes, response sizes and execution times of
+	// the requests served over the HTTP, websocket, IPC and authenticated RPC
+	// interfaces. In-process calls are not limited.
+	RPCLimits rpc.Limits
+
+	// RPCAccessLog enables logging every method call served over the HTTP,
+	// websocket, IPC and authenticated RPC interfaces.
+	RPCAccessLog bool `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
 	// this field is empty, no websocket API endpoint will be started.
 	WSHost string `toml:",omitempty"`
 
 	// WSPort is the TCP port number on which to start the websocket RPC server. The
 	// default zero value is/ valid and will pick a port number randomly (useful for
-	// ephemeral nodes).
+	// ephemeral nodes). If it matches the HTTP endpoint, websocket upgrades are
+	// served on the HTTP RPC listener.
 	WSPort int `toml:",omitempty"`
 
 	// WSOrigins is the list of domain to accept websocket requests from. Please be
 	// aware that the server can only act upon the HTTP request the client sends and
-	// cannot verify the validity of the request header.
+	// cannot verify the validity of the request header. The list also applies to
+	// GraphQL subscriptions over websocket.
 	WSOrigins []string `toml:",omitempty"`
 
 	// WSModules is a list of API modules to expose via the websocket RPC interface.
@@ -163,12 +184,13 @@ type Config struct {
 	WSExposeAll bool `toml:",omitempty"`
 
 	// GraphQLHost is the host interface on which to start the GraphQL server. If this
-	// field is empty, no GraphQL API endpoint will be started.
+	// field is empty, GraphQL is served on the HTTP RPC endpoint.
 	GraphQLHost string `toml:",omitempty"`
 
 	// GraphQLPort is the TCP port number on which to start the GraphQL server. The
 	// default zero value is/ valid and will pick a port number randomly (useful
-	// for ephemeral nodes).
+	// for ephemeral nodes). If it matches the HTTP endpoint, GraphQL is served on
+	// the HTTP RPC listener under the /graphql path.
 	GraphQLPort int `toml:",omitempty"`
 
 	// GraphQLCors is the Cross-Origin Resource Sharing header to send to requesting
@@ -185,6 +207,35 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	GraphQLVirtualHosts []string `toml:",omitempty"`
 
+	// GraphQLTracing enables the traceTransaction query on the GraphQL endpoint.
+	// Tracing re-executes historical transactions, so it is disabled by default.
+	GraphQLTracing bool `toml:",omitempty"`
+
+	// AuthHost is the host interface on which to start the authenticated RPC server,
+	// serving both HTTP and websocket requests carrying a JWT token. If this field
+	// is empty, no authenticated API endpoint will be started.
+	AuthHost string `toml:",omitempty"`
+
+	// AuthPort is the TCP port number on which to start the authenticated RPC server.
+	AuthPort int `toml:",omitempty"`
+
+	// AuthModules is a list of API modules to expose via the authenticated RPC
+	// interface. If the module list is empty, all RPC API endpoints designated
+	// public will be exposed.
+	AuthModules []string `toml:",omitempty"`
+
+	// JWTSecret is the path to the file containing the hex encoded 32 byte secret
+	// the authenticated RPC tokens are signed with. If the file doesn't exist, a
+	// new random secret is generated into it. If the path is empty, the secret is
+	// stored in the instance directory.
+	JWTSecret string `toml:",omitempty"`
+
+	// AuthTLSCert and AuthTLSKey are the paths to the PEM encoded certificate and
+	// private key to serve the authenticated RPC endpoint with over TLS. If they
+	// are empty, the endpoint is served unencrypted.
+	AuthTLSCert string `toml:",omitempty"`
+	AuthTLSKey  string `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
@@ -271,6 +322,15 @@ func (c *Config) WSEndpoint() string {
 	return fmt.Sprintf("%s:%d", c.WSHost, c.WSPort)
 }
 
+// AuthEndpoint resolves the authenticated RPC endpoint based on the configured
+// host interface and port parameters.
+func (c *Config) AuthEndpoint() string {
+	if c.AuthHost == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d", c.AuthHost, c.AuthPort)
+}
+
 // DefaultWSEndpoint returns the websocket endpoint used by default.
 func DefaultWSEndpoint() string {
 	config := &Config{WSHost: DefaultWSHost, WSPort: DefaultWSPort}
@@ -391,6 +451,41 @@ func (c *Config) NodeKey() *ecdsa.PrivateKey {
 	return key
 }
 
+// AuthSecret retrieves the secret authenticated RPC tokens are signed with,
+// loading it from the configured file, falling back to the one found in the
+// data folder. If no secret can be found, a new one is generated.
+func (c *Config) AuthSecret() ([]byte, error) {
+	path := c.JWTSecret
+	if path == "" {
+		path = c.ResolvePath(datadirJWTSecret)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("no JWT secret configured for ephemeral node")
+	}
+	if blob, err := ioutil.ReadFile(path); err == nil {
+		secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(blob)), "0x"))
+		if err != nil || len(secret) != 32 {
+			return nil, fmt.Errorf("invalid JWT secret %s: want 32 hex encoded bytes", path)
+		}
+		return secret, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	// No persistent secret found, generate and store a new one.
+	secret := make([]byte, 32)
+	if _, err := crand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(path, []byte(hex.EncodeToString(secret)), 0600); err != nil {
+		return nil, err
+	}
+	log.Info("Generated JWT secret", "path", path)
+	return secret, nil
+}
+
 // StaticNodes returns a list of node enode URLs configured as static nodes.
 func (c *Config) StaticNodes() []*enode.Node {
 	return c.parsePersistentNodes(&c.staticNodesWarning, c.ResolvePath(datadirStaticNodes))
@@ -493,7 +588,11 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 		// If/when we implement some form of lockfile for USB and keystore wallets,
 		// we can have both, but it's very confusing for the user to see the same
 		// accounts in both externally and locally, plus very racey.
-		backends = append(backends, keystore.NewKeyStore(keydir, scryptN, scryptP))
+		if conf.KeyStoreVault != "" {
+			backends = append(backends, keystore.NewVaultKeyStore(conf.KeyStoreVault, scryptN, scryptP))
+		} else {
+			backends = append(backends, keystore.NewKeyStore(keydir, scryptN, scryptP))
+		}
 		if !conf.NoUSB {
 			// Start a USB hub for Ledger hardware wallets
 			if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/ccmchain/go-ccmchain/crypto"
@@ -160,3 +161,60 @@ func TestNodeKeyPersistency(t *testing.T) {
 		t.Fatalf("ephemeral node key persisted to disk")
 	}
 }
+
+// Tests that authenticated RPC secrets can be correctly created, persisted and
+// loaded, and that invalid ones are rejected.
+func TestAuthSecretPersistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Configure a node with no secret and ensure one is generated and persisted
+	config := &Config{Name: "unit-test", DataDir: dir}
+	secret1, err := config.AuthSecret()
+	if err != nil {
+		t.Fatalf("failed to generate secret: %v", err)
+	}
+	if len(secret1) != 32 {
+		t.Fatalf("secret length mismatch: have %d, want 32", len(secret1))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "unit-test", datadirJWTSecret)); err != nil {
+		t.Fatalf("secret not persisted to data directory: %v", err)
+	}
+	// Configure a new node and ensure the previously persisted secret is loaded
+	config = &Config{Name: "unit-test", DataDir: dir}
+	secret2, err := config.AuthSecret()
+	if err != nil {
+		t.Fatalf("failed to load secret: %v", err)
+	}
+	if !bytes.Equal(secret1, secret2) {
+		t.Fatalf("persisted secret mismatch: have %x, want %x", secret2, secret1)
+	}
+	// Configure an explicit secret file and ensure it's used
+	path := filepath.Join(dir, "custom-secret")
+	if err := ioutil.WriteFile(path, []byte("0x"+strings.Repeat("ab", 32)+"\n"), 0600); err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+	config = &Config{Name: "unit-test", DataDir: dir, JWTSecret: path}
+	if secret, err := config.AuthSecret(); err != nil {
+		t.Fatalf("failed to load custom secret: %v", err)
+	} else if !bytes.Equal(secret, bytes.Repeat([]byte{0xab}, 32)) {
+		t.Fatalf("custom secret mismatch: have %x", secret)
+	}
+	// Ensure invalid secrets are rejected
+	for _, content := range []string{"", "abcd", strings.Repeat("zz", 32)} {
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write secret: %v", err)
+		}
+		if _, err := config.AuthSecret(); err == nil {
+			t.Errorf("invalid secret %q accepted", content)
+		}
+	}
+	// Ensure ephemeral nodes can't generate a secret
+	config = &Config{Name: "unit-test"}
+	if _, err := config.AuthSecret(); err == nil {
+		t.Fatalf("ephemeral node generated a secret")
+	}
+}
@@ -59,14 +59,19 @@ type Node struct {
 	ipcListener net.Listener // IPC RPC listener socket to serve API requests
 	ipcHandler  *rpc.Server  // IPC RPC request handler to process the API requests
 
-	httpEndpoint  string       // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
-	httpWhitelist []string     // HTTP RPC modules to allow through this endpoint
-	httpListener  net.Listener // HTTP RPC listener socket to server API requests
-	httpHandler   *rpc.Server  // HTTP RPC request handler to process the API requests
+	httpEndpoint  string      // HTTP endpoint (interface + port) to listen at (empty = HTTP disabled)
+	httpWhitelist []string    // HTTP RPC modules to allow through this endpoint
+	httpHandler   *rpc.Server // HTTP RPC request handler to process the API requests
 
-	wsEndpoint string       // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
-	wsListener net.Listener // Websocket RPC listener socket to server API requests
-	wsHandler  *rpc.Server  // Websocket RPC request handler to process the API requests
+	wsEndpoint string      // Websocket endpoint (interface + port) to listen at (empty = websocket disabled)
+	wsHandler  *rpc.Server // Websocket RPC request handler to process the API requests
+
+	httpServers  map[string]*httpServer // HTTP servers shared by the endpoints, keyed by normalized endpoint
+	httpHandlers []httpHandler          // Custom HTTP handlers registered by the services
+
+	authEndpoint string       // Authenticated RPC endpoint (interface + port) to listen at (empty = disabled)
+	authListener net.Listener // Authenticated RPC listener socket to server API requests
+	authHandler  *rpc.Server  // Authenticated RPC request handler to process the API requests
 
 	stop chan struct{} // Channel to wait for termination notifications
 	lock sync.RWMutex
@@ -117,6 +122,8 @@ func New(conf *Config) (*Node, error) {
 		ipcEndpoint:       conf.IPCEndpoint(),
 		httpEndpoint:      conf.HTTPEndpoint(),
 		wsEndpoint:        conf.WSEndpoint(),
+		authEndpoint:      conf.AuthEndpoint(),
+		httpServers:       make(map[string]*httpServer),
 		eventmux:          new(event.TypeMux),
 		log:               conf.Logger,
 	}, nil
@@ -190,12 +197,16 @@ func (n *Node) Start() error {
 	n.log.Info("Starting peer-to-peer node", "instance", n.serverConfig.Name)
 
 	// Otherwise copy and specialize the P2P configuration
-	services := make(map[reflect.Type]Service)
+	var (
+		services = make(map[reflect.Type]Service)
+		handlers []httpHandler
+	)
 	for _, constructor := range n.serviceFuncs {
 		// Create a new context for the particular service
 		ctx := &ServiceContext{
 			config:         n.config,
 			services:       make(map[reflect.Type]Service),
+			handlers:       &handlers,
 			EventMux:       n.eventmux,
 			AccountManager: n.accman,
 		}
@@ -236,6 +247,7 @@ func (n *Node) Start() error {
 		started = append(started, kind)
 	}
 	// Lastly start the configured RPC interfaces
+	n.httpHandlers = handlers
 	if err := n.startRPC(services); err != nil {
 		for _, service := range services {
 			service.Stop()
@@ -302,6 +314,21 @@ func (n *Node) startRPC(services map[reflect.Type]Service) error {
 		n.stopInProc()
 		return err
 	}
+	if err := n.startHandlers(n.httpHandlers); err != nil {
+		n.stopWS()
+		n.stopHTTP()
+		n.stopIPC()
+		n.stopInProc()
+		return err
+	}
+	if err := n.startAuth(n.authEndpoint, apis, n.config.AuthModules); err != nil {
+		n.stopHandlers()
+		n.stopWS()
+		n.stopHTTP()
+		n.stopIPC()
+		n.stopInProc()
+		return err
+	}
 	// All API endpoints started successfully
 	n.rpcAPIs = apis
 	return nil
@@ -334,7 +361,7 @@ func (n *Node) startIPC(apis []rpc.API) error {
 	if n.ipcEndpoint == "" {
 		return nil // IPC disabled.
 	}
-	listener, handler, err := rpc.StartIPCEndpoint(n.ipcEndpoint, apis)
+	listener, handler, err := rpc.StartIPCEndpoint(n.ipcEndpoint, apis, n.config.RPCLimits, n.config.RPCAccessLog)
 	if err != nil {
 		return err
 	}
@@ -358,20 +385,52 @@ func (n *Node) stopIPC() {
 	}
 }
 
+// httpServer retrieves the HTTP server shared by all the handlers served on the
+// given endpoint, creating it if it doesn't exist yet.
+func (n *Node) httpServer(endpoint string) *httpServer {
+	key := httpServerKey(endpoint)
+
+	server, ok := n.httpServers[key]
+	if !ok {
+		server = newHTTPServer(endpoint, n.config.HTTPTimeouts)
+		n.httpServers[key] = server
+	}
+	return server
+}
+
+// releaseHTTPServer stops and drops the HTTP server of an endpoint if none of
+// its handlers are enabled any more.
+func (n *Node) releaseHTTPServer(endpoint string) {
+	key := httpServerKey(endpoint)
+	if server, ok := n.httpServers[key]; ok && server.idle() {
+		server.stop()
+		delete(n.httpServers, key)
+	}
+}
+
 // startHTTP initializes and starts the HTTP RPC endpoint.
 func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors []string, vhosts []string, timeouts rpc.HTTPTimeouts) error {
 	// Short circuit if the HTTP endpoint isn't being exposed
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartHTTPEndpoint(endpoint, apis, modules, cors, vhosts, timeouts)
-	if err != nil {
+	handler := rpc.NewServer()
+	handler.SetLimits(n.config.RPCLimits)
+	handler.SetAccessLog(n.config.RPCAccessLog)
+	if err := registerAPIs(handler, apis, modules, false); err != nil {
+		return err
+	}
+	server := n.httpServer(endpoint)
+	server.setRPC(rpc.NewHTTPHandlerStack(handler, cors, vhosts))
+	if err := server.start(); err != nil {
+		server.setRPC(nil)
+		n.releaseHTTPServer(endpoint)
+		handler.Stop()
 		return err
 	}
-	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", endpoint), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
+	n.log.Info("HTTP endpoint opened", "url", fmt.Sprintf("http://%s", server.addr()), "cors", strings.Join(cors, ","), "vhosts", strings.Join(vhosts, ","))
 	// All listeners booted successfully
 	n.httpEndpoint = endpoint
-	n.httpListener = listener
 	n.httpHandler = handler
 
 	return nil
@@ -379,15 +438,15 @@ func (n *Node) startHTTP(endpoint string, apis []rpc.API, modules []string, cors
 
 // stopHTTP terminates the HTTP RPC endpoint.
 func (n *Node) stopHTTP() {
-	if n.httpListener != nil {
-		n.httpListener.Close()
-		n.httpListener = nil
-
-		n.log.Info("HTTP endpoint closed", "url", fmt.Sprintf("http://%s", n.httpEndpoint))
-	}
 	if n.httpHandler != nil {
+		if server, ok := n.httpServers[httpServerKey(n.httpEndpoint)]; ok {
+			server.setRPC(nil)
+			n.releaseHTTPServer(n.httpEndpoint)
+		}
 		n.httpHandler.Stop()
 		n.httpHandler = nil
+
+		n.log.Info("HTTP endpoint closed", "url", fmt.Sprintf("http://%s", n.httpEndpoint))
 	}
 }
 
@@ -397,14 +456,23 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 	if endpoint == "" {
 		return nil
 	}
-	listener, handler, err := rpc.StartWSEndpoint(endpoint, apis, modules, wsOrigins, exposeAll)
-	if err != nil {
+	handler := rpc.NewServer()
+	handler.SetLimits(n.config.RPCLimits)
+	handler.SetAccessLog(n.config.RPCAccessLog)
+	if err := registerAPIs(handler, apis, modules, exposeAll); err != nil {
+		return err
+	}
+	server := n.httpServer(endpoint)
+	server.setWS(handler.WebsocketHandler(wsOrigins))
+	if err := server.start(); err != nil {
+		server.setWS(nil)
+		n.releaseHTTPServer(endpoint)
+		handler.Stop()
 		return err
 	}
-	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", listener.Addr()))
+	n.log.Info("WebSocket endpoint opened", "url", fmt.Sprintf("ws://%s", server.addr()))
 	// All listeners booted successfully
 	n.wsEndpoint = endpoint
-	n.wsListener = listener
 	n.wsHandler = handler
 
 	return nil
@@ -412,15 +480,95 @@ func (n *Node) startWS(endpoint string, apis []rpc.API, modules []string, wsOrig
 
 // stopWS terminates the websocket RPC endpoint.
 func (n *Node) stopWS() {
-	if n.wsListener != nil {
-		n.wsListener.Close()
-		n.wsListener = nil
-
-		n.log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
-	}
 	if n.wsHandler != nil {
+		if server, ok := n.httpServers[httpServerKey(n.wsEndpoint)]; ok {
+			server.setWS(nil)
+			n.releaseHTTPServer(n.wsEndpoint)
+		}
 		n.wsHandler.Stop()
 		n.wsHandler = nil
+
+		n.log.Info("WebSocket endpoint closed", "url", fmt.Sprintf("ws://%s", n.wsEndpoint))
+	}
+}
+
+// startHandlers starts serving the custom HTTP handlers registered by the
+// services. Handlers without an explicit endpoint share the HTTP RPC endpoint.
+func (n *Node) startHandlers(handlers []httpHandler) error {
+	for i, h := range handlers {
+		endpoint := h.endpoint
+		if endpoint == "" {
+			endpoint = n.httpEndpoint
+		}
+		if endpoint == "" {
+			n.stopHandlers()
+			return fmt.Errorf("no HTTP endpoint to serve %s on", h.name)
+		}
+		server := n.httpServer(endpoint)
+		server.setHandler(h.path, h.handler)
+		if err := server.start(); err != nil {
+			server.setHandler(h.path, nil)
+			n.releaseHTTPServer(endpoint)
+			n.stopHandlers()
+			return err
+		}
+		handlers[i].endpoint = endpoint
+		n.log.Info(fmt.Sprintf("%s endpoint opened", h.name), "url", fmt.Sprintf("http://%s%s", server.addr(), h.path))
+	}
+	return nil
+}
+
+// stopHandlers stops serving the custom HTTP handlers registered by the services.
+func (n *Node) stopHandlers() {
+	for _, h := range n.httpHandlers {
+		if server, ok := n.httpServers[httpServerKey(h.endpoint)]; ok {
+			server.setHandler(h.path, nil)
+			n.releaseHTTPServer(h.endpoint)
+
+			n.log.Info(fmt.Sprintf("%s endpoint closed", h.name), "url", fmt.Sprintf("http://%s%s", h.endpoint, h.path))
+		}
+	}
+	n.httpHandlers = nil
+}
+
+// startAuth initializes and starts the authenticated RPC endpoint.
+func (n *Node) startAuth(endpoint string, apis []rpc.API, modules []string) error {
+	// Short circuit if the authenticated endpoint isn't being exposed
+	if endpoint == "" {
+		return nil
+	}
+	secret, err := n.config.AuthSecret()
+	if err != nil {
+		return err
+	}
+	listener, handler, err := rpc.StartAuthEndpoint(endpoint, apis, modules, secret, n.config.AuthTLSCert, n.config.AuthTLSKey, n.config.HTTPTimeouts, n.config.RPCLimits, n.config.RPCAccessLog)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if n.config.AuthTLSCert != "" {
+		scheme = "https"
+	}
+	n.log.Info("Authenticated RPC endpoint opened", "url", fmt.Sprintf("%s://%s", scheme, listener.Addr()), "modules", strings.Join(modules, ","))
+	// All listeners booted successfully
+	n.authEndpoint = endpoint
+	n.authListener = listener
+	n.authHandler = handler
+
+	return nil
+}
+
+// stopAuth terminates the authenticated RPC endpoint.
+func (n *Node) stopAuth() {
+	if n.authListener != nil {
+		n.authListener.Close()
+		n.authListener = nil
+
+		n.log.Info("Authenticated RPC endpoint closed", "url", n.authEndpoint)
+	}
+	if n.authHandler != nil {
+		n.authHandler.Stop()
+		n.authHandler = nil
 	}
 }
 
@@ -436,6 +584,8 @@ func (n *Node) Stop() error {
 	}
 
 	// Terminate the API, services and the p2p server.
+	n.stopAuth()
+	n.stopHandlers()
 	n.stopWS()
 	n.stopHTTP()
 	n.stopIPC()
@@ -580,8 +730,8 @@ func (n *Node) HTTPEndpoint() string {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	if n.httpListener != nil {
-		return n.httpListener.Addr().String()
+	if server, ok := n.httpServers[httpServerKey(n.httpEndpoint)]; ok && n.httpHandler != nil {
+		return server.addr()
 	}
 	return n.httpEndpoint
 }
@@ -591,12 +741,24 @@ func (n *Node) WSEndpoint() string {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
-	if n.wsListener != nil {
-		return n.wsListener.Addr().String()
+	if server, ok := n.httpServers[httpServerKey(n.wsEndpoint)]; ok && n.wsHandler != nil {
+		return server.addr()
 	}
 	return n.wsEndpoint
 }
 
+// AuthEndpoint retrieves the current authenticated RPC endpoint used by the
+// protocol stack.
+func (n *Node) AuthEndpoint() string {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.authListener != nil {
+		return n.authListener.Addr().String()
+	}
+	return n.authEndpoint
+}
+
 // EventMux retrieves the event multiplexer used by all the network services in
 // the current protocol stack.
 func (n *Node) EventMux() *event.TypeMux {
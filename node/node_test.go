@@ -18,7 +18,10 @@ package node
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"reflect"
 	"testing"
@@ -597,3 +600,245 @@ func TestAPIGather(t *testing.T) {
 		}
 	}
 }
+
+// Tests that the authenticated RPC endpoint only serves requests carrying a valid
+// token, and only exposes the allowed modules.
+func TestAuthEndpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "node-test")
+	if err != nil {
+		t.Fatalf("failed to create temporary data directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := testNodeConfig()
+	config.DataDir = dir
+	config.AuthHost = "127.0.0.1"
+	config.AuthModules = []string{"web3"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	secret, err := stack.Config().AuthSecret()
+	if err != nil {
+		t.Fatalf("failed to load secret: %v", err)
+	}
+	dial := func(secret []byte) *rpc.Client {
+		client, err := rpc.DialHTTPWithClient("http://"+stack.AuthEndpoint(), &http.Client{
+			Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				req = req.Clone(req.Context())
+				req.Header.Set("Authorization", "Bearer "+rpc.MakeJWTToken(secret, time.Now()))
+				return http.DefaultTransport.RoundTrip(req)
+			}),
+		})
+		if err != nil {
+			t.Fatalf("failed to dial: %v", err)
+		}
+		return client
+	}
+	// Requests with a valid token must succeed for allowed modules only
+	client := dial(secret)
+	defer client.Close()
+
+	var version string
+	if err := client.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call allowed module: %v", err)
+	}
+	if err := client.Call(nil, "admin_nodeInfo"); err == nil {
+		t.Fatalf("disallowed module exposed")
+	}
+	// Requests signed with a different secret must fail
+	invalid := dial(make([]byte, 32))
+	defer invalid.Close()
+
+	if err := invalid.Call(&version, "web3_clientVersion"); err == nil {
+		t.Fatalf("unauthenticated request succeeded")
+	}
+}
+
+// Tests that the HTTP and websocket RPC APIs, as well as custom service handlers
+// can be served on a single port, each with its own host restrictions.
+func TestHTTPServerSharedPort(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := testNodeConfig()
+	config.HTTPHost, config.HTTPPort = "127.0.0.1", port
+	config.HTTPModules = []string{"web3"}
+	config.HTTPVirtualHosts = []string{"localhost"}
+	config.WSHost, config.WSPort = "127.0.0.1", port
+	config.WSModules = []string{"admin", "web3"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "graphql %s", r.URL.Path)
+	})
+	if err := stack.Register(func(ctx *ServiceContext) (Service, error) {
+		ctx.RegisterHTTPHandler("GraphQL", "", "/graphql", rpc.NewHTTPHandlerStack(handler, nil, []string{"*"}))
+		return new(NoopService), nil
+	}); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	endpoint := fmt.Sprintf("127.0.0.1:%d", port)
+	if stack.HTTPEndpoint() != endpoint || stack.WSEndpoint() != endpoint {
+		t.Fatalf("endpoint mismatch: have http %s, ws %s, want %s", stack.HTTPEndpoint(), stack.WSEndpoint(), endpoint)
+	}
+	// Ensure JSON-RPC is served over both HTTP and websocket
+	httpClient, err := rpc.Dial("http://" + endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial HTTP: %v", err)
+	}
+	defer httpClient.Close()
+
+	wsClient, err := rpc.Dial("ws://" + endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial websocket: %v", err)
+	}
+	defer wsClient.Close()
+
+	var version string
+	if err := httpClient.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call over HTTP: %v", err)
+	}
+	if err := wsClient.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call over websocket: %v", err)
+	}
+	if err := httpClient.Call(nil, "admin_nodeInfo"); err == nil {
+		t.Fatalf("websocket only module exposed over HTTP")
+	}
+	// Ensure the custom handler is served on its own path with its own vhosts
+	get := func(path, host string) (int, string) {
+		req, _ := http.NewRequest("GET", "http://"+endpoint+path, nil)
+		req.Host = host
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("failed to request %s: %v", path, err)
+		}
+		defer res.Body.Close()
+
+		body, _ := ioutil.ReadAll(res.Body)
+		return res.StatusCode, string(body)
+	}
+	for _, path := range []string{"/graphql", "/graphql/ui"} {
+		if code, body := get(path, "example.org"); code != http.StatusOK || body != "graphql "+path {
+			t.Errorf("path %s: have %d %q, want %d %q", path, code, body, http.StatusOK, "graphql "+path)
+		}
+	}
+	if code, _ := get("/", "example.org"); code != http.StatusForbidden {
+		t.Errorf("JSON-RPC vhost: have %d, want %d", code, http.StatusForbidden)
+	}
+	// Ensure the HTTP RPC API can be restarted through the admin API without
+	// disturbing the other handlers sharing the port
+	var ok bool
+	if err := wsClient.Call(&ok, "admin_stopRPC"); err != nil || !ok {
+		t.Fatalf("failed to stop HTTP RPC: %v", err)
+	}
+	if err := httpClient.Call(&version, "web3_clientVersion"); err == nil {
+		t.Fatalf("HTTP RPC served after stop")
+	}
+	if err := wsClient.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call over websocket after HTTP stop: %v", err)
+	}
+	if code, _ := get("/graphql", "localhost"); code != http.StatusOK {
+		t.Errorf("custom handler after HTTP stop: have %d, want %d", code, http.StatusOK)
+	}
+	if err := wsClient.Call(&ok, "admin_startRPC", "127.0.0.1", port); err != nil || !ok {
+		t.Fatalf("failed to restart HTTP RPC: %v", err)
+	}
+	if err := httpClient.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call over HTTP after restart: %v", err)
+	}
+	// Ensure the websocket RPC API can be restarted on the shared port too
+	if err := httpClient.Call(&ok, "admin_stopWS"); err == nil {
+		t.Fatalf("admin module exposed over HTTP")
+	}
+	wsClient.Call(&ok, "admin_stopWS") // connection is torn down before replying
+	if _, err := rpc.Dial("ws://" + endpoint); err == nil {
+		t.Fatalf("websocket RPC served after stop")
+	}
+	if err := httpClient.Call(&version, "web3_clientVersion"); err != nil {
+		t.Fatalf("failed to call over HTTP after websocket stop: %v", err)
+	}
+}
+
+// Tests that the HTTP servers are shared by endpoints spelling the same interface
+// and port differently.
+func TestHTTPServerKey(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"localhost:8545", "127.0.0.1:8545", true},
+		{"127.0.0.1:8545", "127.0.0.1:8545", true},
+		{":8545", "0.0.0.0:8545", true},
+		{":8545", "[::]:8545", true},
+		{"0.0.0.0:8545", "[::]:8545", true},
+		{":8545", "127.0.0.1:8545", false}, // Wildcard and loopback listeners conflict
+		{"127.0.0.1:8545", "127.0.0.1:8546", false},
+	}
+	for _, tt := range tests {
+		if equal := httpServerKey(tt.a) == httpServerKey(tt.b); equal != tt.equal {
+			t.Errorf("%q vs %q: key equality mismatch: have %v, want %v", tt.a, tt.b, equal, tt.equal)
+		}
+	}
+}
+
+// Tests that the HTTP and websocket RPC APIs share a single server if their hosts
+// are spelled differently but resolve to the same interface.
+func TestHTTPServerSharedPortEquivalentHosts(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free port: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	config := testNodeConfig()
+	config.HTTPHost, config.HTTPPort = "localhost", port
+	config.HTTPModules = []string{"web3"}
+	config.WSHost, config.WSPort = "127.0.0.1", port
+	config.WSModules = []string{"web3"}
+
+	stack, err := New(config)
+	if err != nil {
+		t.Fatalf("failed to create protocol stack: %v", err)
+	}
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	defer stack.Stop()
+
+	endpoint := fmt.Sprintf("127.0.0.1:%d", port)
+	for _, url := range []string{"http://" + endpoint, "ws://" + endpoint} {
+		client, err := rpc.Dial(url)
+		if err != nil {
+			t.Fatalf("failed to dial %s: %v", url, err)
+		}
+		var version string
+		if err := client.Call(&version, "web3_clientVersion"); err != nil {
+			t.Errorf("failed to call over %s: %v", url, err)
+		}
+		client.Close()
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
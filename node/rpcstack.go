@@ -0,0 +1,201 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/rpc"
+	"github.com/gorilla/websocket"
+)
+
+// httpHandler is a custom HTTP handler registered by a service, to be served by
+// the node next to the JSON-RPC APIs.
+type httpHandler struct {
+	name     string       // Name of the handler for logging purposes
+	endpoint string       // Endpoint to serve on (empty = HTTP RPC endpoint)
+	path     string       // Path prefix to serve the handler on
+	handler  http.Handler // Handler to process the requests
+}
+
+// httpServer is an HTTP server shared by all the handlers served on the same
+// endpoint. Requests are routed by path prefix to the registered handlers, all
+// others being served JSON-RPC over HTTP or websocket based on the upgrade header.
+type httpServer struct {
+	endpoint string           // Endpoint (interface + port) the server listens on
+	timeouts rpc.HTTPTimeouts // Timeouts to use for the HTTP server
+
+	rpc      http.Handler            // JSON-RPC over HTTP handler (nil = disabled)
+	ws       http.Handler            // JSON-RPC over websocket handler (nil = disabled)
+	handlers map[string]http.Handler // Custom handlers by path prefix
+
+	listener net.Listener // Listener socket accepting the requests, if running
+	lock     sync.RWMutex
+}
+
+// newHTTPServer creates an HTTP server for the given endpoint, without any
+// handlers configured.
+func newHTTPServer(endpoint string, timeouts rpc.HTTPTimeouts) *httpServer {
+	return &httpServer{
+		endpoint: endpoint,
+		timeouts: timeouts,
+		handlers: make(map[string]http.Handler),
+	}
+}
+
+// httpServerKey normalizes an endpoint into the key of the HTTP server listening
+// on it, so that different spellings of the same interface and port (e.g. host
+// names, or the various wildcard addresses) share a single server.
+func httpServerKey(endpoint string) string {
+	addr, err := net.ResolveTCPAddr("tcp", endpoint)
+	if err != nil {
+		return endpoint // Leave it to the listener to report the error
+	}
+	if addr.IP == nil || addr.IP.IsUnspecified() {
+		return net.JoinHostPort("", strconv.Itoa(addr.Port))
+	}
+	return addr.String()
+}
+
+// start starts listening on the server's endpoint, unless it's already running.
+func (s *httpServer) start() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.endpoint)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	go rpc.NewHTTPServer(nil, []string{"*"}, s.timeouts, s).Serve(listener)
+	return nil
+}
+
+// stop closes the server's listener if it's running.
+func (s *httpServer) stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+		s.listener = nil
+	}
+}
+
+// idle returns whccmer the server has no handlers configured any more.
+func (s *httpServer) idle() bool {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.rpc == nil && s.ws == nil && len(s.handlers) == 0
+}
+
+// addr returns the address the server is listening on, or the configured
+// endpoint if it's not running.
+func (s *httpServer) addr() string {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.endpoint
+}
+
+// setRPC sets the JSON-RPC over HTTP handler, nil disabling it.
+func (s *httpServer) setRPC(handler http.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rpc = handler
+}
+
+// setWS sets the JSON-RPC over websocket handler, nil disabling it.
+func (s *httpServer) setWS(handler http.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.ws = handler
+}
+
+// setHandler sets the custom handler for the given path prefix, nil removing it.
+func (s *httpServer) setHandler(path string, handler http.Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if handler == nil {
+		delete(s.handlers, path)
+		return
+	}
+	s.handlers[path] = handler
+}
+
+// ServeHTTP routes the requests to the custom handler with the matching path
+// prefix, falling back to serving JSON-RPC over websocket or HTTP.
+func (s *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.lock.RLock()
+	handler := s.route(r)
+	s.lock.RUnlock()
+
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
+
+// route finds the handler to serve a request with.
+//
+// The mccmod assumes that the server lock is held!
+func (s *httpServer) route(r *http.Request) http.Handler {
+	for path, handler := range s.handlers {
+		if r.URL.Path == path || strings.HasPrefix(r.URL.Path, path+"/") {
+			return handler
+		}
+	}
+	if websocket.IsWebSocketUpgrade(r) {
+		return s.ws
+	}
+	return s.rpc
+}
+
+// registerAPIs registers the APIs allowed by the module whitelist on an RPC
+// server. If the whitelist is empty, all public APIs are registered.
+func registerAPIs(srv *rpc.Server, apis []rpc.API, modules []string, exposeAll bool) error {
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	for _, api := range apis {
+		if exposeAll || whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := srv.RegisterName(api.Namespace, api.Service); err != nil {
+				return err
+			}
+			log.Debug("RPC registered", "namespace", api.Namespace)
+		}
+	}
+	return nil
+}
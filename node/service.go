@@ -17,8 +17,10 @@
 package node
 
 import (
+	"net/http"
 	"path/filepath"
 	"reflect"
+	"strings"
 
 	"github.com/ccmchain/go-ccmchain/accounts"
 	"github.com/ccmchain/go-ccmchain/core/rawdb"
@@ -34,10 +36,24 @@ import (
 type ServiceContext struct {
 	config         *Config
 	services       map[reflect.Type]Service // Index of the already constructed services
+	handlers       *[]httpHandler           // Custom HTTP handlers registered by the services
 	EventMux       *event.TypeMux           // Event multiplexer used for decoupled notifications
 	AccountManager *accounts.Manager        // Account manager created by the node.
 }
 
+// RegisterHTTPHandler registers a custom HTTP handler to be served by the node
+// under the given path prefix. Handlers sharing an endpoint with the HTTP or
+// websocket RPC APIs are served by the same listener, with an empty endpoint
+// selecting the HTTP RPC one.
+func (ctx *ServiceContext) RegisterHTTPHandler(name, endpoint, path string, handler http.Handler) {
+	*ctx.handlers = append(*ctx.handlers, httpHandler{
+		name:     name,
+		endpoint: endpoint,
+		path:     strings.TrimSuffix(path, "/"),
+		handler:  handler,
+	})
+}
+
 // OpenDatabase opens an existing database with the given name (or creates one
 // if no previous can be found) from within the node's data directory. If the
 // node is an ephemeral one, a memory database is returned.
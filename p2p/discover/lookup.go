@@ -0,0 +1,124 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+
+package discover
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/p2p/enode"
+)
+
+// lookupSlowdown is the delay between lookups which didn't return any nodes.
+// It prevents the iterator from spinning when the table is empty.
+const lookupSlowdown = 1 * time.Second
+
+// lookupFunc performs a single network lookup.
+type lookupFunc func() []*enode.Node
+
+// lookupIterator performs lookup operations and iterates over their results.
+// When the results of a lookup are exhausted, a new lookup is started.
+type lookupIterator struct {
+	buffer     []*enode.Node
+	nextLookup lookupFunc
+	closing    <-chan struct{} // closed when the transport shuts down
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func newLookupIterator(closing <-chan struct{}, next lookupFunc) *lookupIterator {
+	return &lookupIterator{
+		nextLookup: next,
+		closing:    closing,
+		closed:     make(chan struct{}),
+	}
+}
+
+// Node returns the current node.
+func (it *lookupIterator) Node() *enode.Node {
+	if len(it.buffer) == 0 {
+		return nil
+	}
+	return it.buffer[0]
+}
+
+// Next moves to the next node.
+func (it *lookupIterator) Next() bool {
+	// Consume next node in buffer.
+	if len(it.buffer) > 0 {
+		it.buffer = it.buffer[1:]
+	}
+	// Advance the lookup to refill the buffer.
+	for len(it.buffer) == 0 {
+		if it.isClosed() {
+			return false
+		}
+		it.buffer = it.lookup()
+		if len(it.buffer) == 0 && !it.wait(lookupSlowdown) {
+			return false
+		}
+	}
+	return true
+}
+
+// Close ends the iterator.
+func (it *lookupIterator) Close() {
+	it.closeOnce.Do(func() { close(it.closed) })
+}
+
+// lookup runs a single lookup, returning early if the iterator is closed
+// while the lookup is in progress.
+func (it *lookupIterator) lookup() []*enode.Node {
+	result := make(chan []*enode.Node, 1)
+	go func() { result <- it.nextLookup() }()
+	select {
+	case nodes := <-result:
+		return nodes
+	case <-it.closed:
+		return nil
+	case <-it.closing:
+		return nil
+	}
+}
+
+// wait sleeps for the given duration, returning false if the iterator
+// is closed in the meantime.
+func (it *lookupIterator) wait(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-it.closed:
+		return false
+	case <-it.closing:
+		return false
+	}
+}
+
+func (it *lookupIterator) isClosed() bool {
+	select {
+	case <-it.closed:
+		return true
+	case <-it.closing:
+		return true
+	default:
+		return false
+	}
+}
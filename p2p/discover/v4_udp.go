@@ -303,6 +303,11 @@ func (t *UDPv4) ReadRandomNodes(buf []*enode.Node) int {
 	return t.tab.ReadRandomNodes(buf)
 }
 
+// RandomNodes is an iterator yielding nodes from a random walk of the DHT.
+func (t *UDPv4) RandomNodes() enode.Iterator {
+	return newLookupIterator(t.closing, t.LookupRandom)
+}
+
 // LookupRandom finds random nodes in the network.
 func (t *UDPv4) LookupRandom() []*enode.Node {
 	if t.tab.len() == 0 {
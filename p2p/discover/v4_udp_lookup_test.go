@@ -23,6 +23,7 @@ import (
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/ccmchain/go-ccmchain/crypto"
 	"github.com/ccmchain/go-ccmchain/p2p/enode"
@@ -83,6 +84,78 @@ func TestUDPv4_Lookup(t *testing.T) {
 	}
 }
 
+func TestUDPv4_LookupIterator(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+	defer test.close()
+
+	// Seed table with initial node and answer lookup packets in the background.
+	fillTable(test.table, []*node{wrapNode(lookupTestnet.node(256, 0))})
+	go serveTestnet(test, lookupTestnet)
+
+	// Collect the nodes yielded by the iterator. All of them must be
+	// part of the test network.
+	known := make(map[enode.ID]bool)
+	for _, n := range lookupTestnet.closest(lookupTestnet.len()) {
+		known[n.ID()] = true
+	}
+	it := test.udp.RandomNodes()
+	seen := make(map[enode.ID]bool)
+	for len(seen) < bucketSize {
+		if !it.Next() {
+			t.Fatalf("iterator ended after %d nodes", len(seen))
+		}
+		n := it.Node()
+		if !known[n.ID()] {
+			t.Fatalf("iterator returned unknown node %v", n.ID())
+		}
+		seen[n.ID()] = true
+	}
+	// Check that Next returns false after Close.
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after Close")
+	}
+}
+
+// This test checks that shutting down the transport ends a blocked iterator.
+func TestUDPv4_LookupIteratorClose(t *testing.T) {
+	t.Parallel()
+	test := newUDPTest(t)
+
+	it := test.udp.RandomNodes()
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+	test.close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true after transport shutdown")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Next didn't unblock on transport shutdown")
+	}
+}
+
+// serveTestnet answers the packets sent by the transport under test using
+// the nodes of the given test network. It returns when the transport is closed.
+func serveTestnet(test *udpTest, testnet *preminedTestnet) {
+	for done := false; !done; {
+		done = test.waitPacketOut(func(p packetV4, to *net.UDPAddr, hash []byte) {
+			n, key := testnet.nodeByAddr(to)
+			switch p.(type) {
+			case *pingV4:
+				test.packetInFrom(nil, key, to, &pongV4{Expiration: futureExp, ReplyTok: hash})
+			case *findnodeV4:
+				dist := enode.LogDist(n.ID(), testnet.target.id())
+				nodes := testnet.nodesAtDistance(dist - 1)
+				test.packetInFrom(nil, key, to, &neighborsV4{Expiration: futureExp, Nodes: nodes})
+			}
+		})
+	}
+}
+
 // This is the test network for the Lookup test.
 // The nodes were obtained by running lookupTestnet.mine with a random NodeID as target.
 var lookupTestnet = &preminedTestnet{
@@ -148,6 +221,14 @@ type preminedTestnet struct {
 	dists  [hashBits + 1][]*ecdsa.PrivateKey
 }
 
+func (tn *preminedTestnet) len() int {
+	n := 0
+	for _, keys := range tn.dists {
+		n += len(keys)
+	}
+	return n
+}
+
 func (tn *preminedTestnet) node(dist, index int) *enode.Node {
 	key := tn.dists[dist][index]
 	ip := net.IP{127, byte(dist >> 8), byte(dist), byte(index)}
@@ -0,0 +1,311 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common/mclock"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/testlog"
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/p2p/enode"
+	"github.com/ccmchain/go-ccmchain/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+	nodesSeed3     = 0x8761543
+)
+
+// Tests that a full tree can be downloaded and verified.
+func TestClientSyncTree(t *testing.T) {
+	var (
+		nodes = testNodes(nodesSeed1, 30)
+		links = []string{"enrtree://" + newLinkEntry("other.example.org", &testKey(nodesSeed2).PublicKey).str}
+	)
+	tree, url := makeTestTree("nodes.example.org", nodes, links)
+
+	r := mapResolver(tree.ToTXT("nodes.example.org"))
+	c := NewClient(Config{Resolver: r, Logger: testlog.Logger(t, log.LvlTrace)})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if !reflect.DeepEqual(stree.Links(), links) {
+		t.Errorf("wrong links in synced tree: %v", stree.Links())
+	}
+	if stree.Seq() != tree.Seq() || stree.Signature() != tree.Signature() {
+		t.Errorf("synced tree root mismatch")
+	}
+}
+
+// Tests that trees with invalid signatures or corrupted entries are rejected.
+func TestClientSyncTreeBad(t *testing.T) {
+	tree, url := makeTestTree("nodes.example.org", testNodes(nodesSeed1, 3), nil)
+
+	// Sign the tree with a different key than the one in the URL
+	r := mapResolver(tree.ToTXT("nodes.example.org"))
+	wrongURL, _ := makeTestTreeWithKey("nodes.example.org", testNodes(nodesSeed1, 3), nil, testKey(nodesSeed2))
+	c := NewClient(Config{Resolver: r, Logger: testlog.Logger(t, log.LvlTrace)})
+
+	_, err := c.SyncTree(wrongURL.url)
+	if want := (entryError{"root", errInvalidSig}); err != want {
+		t.Errorf("wrong error for bad signature: %v", err)
+	}
+	// Corrupt one of the node records, its hash won't match the branch anymore
+	for name, txt := range r {
+		if txt[:len(enrPrefix)] == enrPrefix {
+			r[name] = (&enrEntry{testNode(nodesSeed3)}).String()
+			break
+		}
+	}
+	if _, err := c.SyncTree(url); err == nil {
+		t.Errorf("corrupted tree synced without error")
+	} else if nerr, ok := err.(nameError); !ok || nerr.err != errHashMismatch {
+		t.Errorf("wrong error for corrupted entry: %v", err)
+	}
+	// Drop the root record
+	delete(r, "nodes.example.org")
+	if _, err := c.SyncTree(url); err == nil {
+		t.Errorf("tree without root synced without error")
+	}
+}
+
+// Tests that the iterator visits all nodes of a tree and the trees linked from it.
+func TestIteratorLinks(t *testing.T) {
+	var (
+		nodes1 = testNodes(nodesSeed1, 30)
+		nodes2 = testNodes(nodesSeed2, 10)
+	)
+	tree2, url2 := makeTestTree("other.example.org", nodes2, nil)
+	tree1, url1 := makeTestTree("nodes.example.org", nodes1, []string{url2})
+
+	r := mapResolver(tree1.ToTXT("nodes.example.org"))
+	for name, txt := range tree2.ToTXT("other.example.org") {
+		r[name] = txt
+	}
+	c := NewClient(Config{Resolver: r, Logger: testlog.Logger(t, log.LvlTrace)})
+	it, err := c.NewIterator(url1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	checkIterator(t, it, append(nodes1, nodes2...))
+}
+
+// Tests that the iterator picks up new nodes when the root of the tree changes,
+// and waits for root updates while the tree is empty.
+func TestIteratorRootRecheck(t *testing.T) {
+	var (
+		clock = new(mclock.Simulated)
+		nodes = testNodes(nodesSeed1, 10)
+		r     = newSyncMapResolver()
+	)
+	empty, url := makeTestTree("nodes.example.org", nil, nil)
+	r.set(empty.ToTXT("nodes.example.org"))
+
+	c := NewClient(Config{Resolver: r, Logger: testlog.Logger(t, log.LvlTrace), RecheckInterval: 20 * time.Minute})
+	c.clock = clock
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	// Wait for the iterator to block on the empty tree, then publish some nodes
+	// and let it recheck the root
+	done := make(chan struct{})
+	go func() {
+		checkIterator(t, it, nodes)
+		close(done)
+	}()
+	clock.WaitForTimers(1)
+
+	full, _ := makeTestTree("nodes.example.org", nodes, nil)
+	r.set(full.ToTXT("nodes.example.org"))
+	clock.Run(20 * time.Minute)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("iterator didn't pick up root update")
+	}
+}
+
+// Tests that closing the iterator unblocks Next.
+func TestIteratorClose(t *testing.T) {
+	r := mapResolver(nil)
+	c := NewClient(Config{Resolver: r, Logger: testlog.Logger(t, log.LvlTrace)})
+	_, url := makeTestTree("nodes.example.org", nil, nil)
+	it, err := c.NewIterator(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan bool)
+	go func() {
+		done <- it.Next()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	it.Close()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("Next returned true after Close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Next didn't unblock on Close")
+	}
+}
+
+// checkIterator reads nodes from the iterator until all the wanted nodes were
+// seen, failing if any unexpected node is returned.
+func checkIterator(t *testing.T, it enode.Iterator, wantNodes []*enode.Node) {
+	var (
+		want = make(map[enode.ID]*enode.Node)
+		seen = make(map[enode.ID]bool)
+	)
+	for _, n := range wantNodes {
+		want[n.ID()] = n
+	}
+	for i := 0; len(seen) < len(want); i++ {
+		if i > 100*len(want) {
+			t.Errorf("iterator only returned %d of %d nodes", len(seen), len(want))
+			return
+		}
+		if !it.Next() {
+			t.Errorf("iterator ended after %d of %d nodes", len(seen), len(want))
+			return
+		}
+		n := it.Node()
+		if want[n.ID()] == nil {
+			t.Errorf("iterator returned unexpected node %v", n.ID())
+			return
+		}
+		seen[n.ID()] = true
+	}
+}
+
+// testTree is a signed test tree along with its URL.
+type testTree struct {
+	*Tree
+	url string
+}
+
+func makeTestTree(domain string, nodes []*enode.Node, links []string) (*Tree, string) {
+	tree, _ := makeTestTreeWithKey(domain, nodes, links, testKey(signingKeySeed))
+	return tree.Tree, tree.url
+}
+
+func makeTestTreeWithKey(domain string, nodes []*enode.Node, links []string, key *ecdsa.PrivateKey) (*testTree, error) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		panic(err)
+	}
+	return &testTree{tree, url}, nil
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	rand := rand.New(rand.NewSource(seed))
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := 0; i < n; i++ {
+		var seed [32]byte
+		rand.Read(seed[:])
+		key, err := crypto.ToECDSA(seed[:])
+		if err != nil {
+			panic("can't generate key: " + err.Error())
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enode.Node {
+	keys := testKeys(seed, n)
+	nodes := make([]*enode.Node, n)
+	for i, key := range keys {
+		record := new(enr.Record)
+		record.SetSeq(uint64(i))
+		enode.SignV4(record, key)
+		n, err := enode.New(enode.ValidSchemes, record)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func testNode(seed int64) *enode.Node {
+	return testNodes(seed, 1)[0]
+}
+
+// mapResolver is an in-memory resolver serving the given TXT records.
+type mapResolver map[string]string
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, nil
+}
+
+// syncMapResolver is a mapResolver safe for concurrent use and updates.
+type syncMapResolver struct {
+	records mapResolver
+	lock    sync.Mutex
+}
+
+func newSyncMapResolver() *syncMapResolver {
+	return &syncMapResolver{records: make(mapResolver)}
+}
+
+func (mr *syncMapResolver) set(records map[string]string) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+
+	mr.records = mapResolver(records)
+}
+
+func (mr *syncMapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	mr.lock.Lock()
+	defer mr.lock.Unlock()
+
+	return mr.records.LookupTXT(ctx, name)
+}
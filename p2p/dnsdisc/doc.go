@@ -0,0 +1,29 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via DNS (EIP-1459).
+//
+// Node lists are published as merkle trees of node records, stored in DNS TXT
+// records under a domain name. The root of the tree is signed by the publisher
+// and references two subtrees: one containing the node records, and one holding
+// links to other trees. Clients verify the root signature and the hash of every
+// entry they retrieve, so the DNS servers don't need to be trusted.
+//
+// Trees are referenced using enrtree:// URLs, which contain the domain name and
+// the public key of the publisher:
+//
+//	enrtree://AM5FCQLWIZX2QFPNJAP7VUERCCRNGRHWZG3YYHIUV7BVDQ5FDPRT2@nodes.example.org
+package dnsdisc
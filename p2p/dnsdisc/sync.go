@@ -0,0 +1,176 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"math/rand"
+
+	"github.com/ccmchain/go-ccmchain/common/mclock"
+)
+
+// clientTree is a full tree being synced.
+type clientTree struct {
+	c   *Client
+	loc *linkEntry // link to this tree
+
+	nextRootCheck mclock.AbsTime // time of the next root revalidation
+	root          *rootEntry
+	enrs          *subtreeSync
+	links         *subtreeSync
+}
+
+func newClientTree(c *Client, loc *linkEntry) *clientTree {
+	return &clientTree{c: c, loc: loc}
+}
+
+// syncAll retrieves all entries of the tree.
+func (ct *clientTree) syncAll(dest map[string]entry) error {
+	if err := ct.updateRoot(context.Background()); err != nil {
+		return err
+	}
+	if err := ct.links.resolveAll(dest); err != nil {
+		return err
+	}
+	if err := ct.enrs.resolveAll(dest); err != nil {
+		return err
+	}
+	return nil
+}
+
+// syncRandom retrieves a single entry of the tree. The link tree is synced
+// first, the node records are then visited in random order. Once every record
+// has been visited, the traversal starts over, hitting the cache mostly.
+func (ct *clientTree) syncRandom(ctx context.Context) (entry, error) {
+	if ct.rootUpdateDue() {
+		if err := ct.updateRoot(ctx); err != nil {
+			return nil, err
+		}
+	}
+	// Link tree sync has priority, run it to completion before syncing ENRs.
+	if !ct.links.done() {
+		return ct.links.resolveNext(ctx, ct.links.missing[0])
+	}
+	if ct.enrs.done() {
+		ct.enrs = newSubtreeSync(ct.c, ct.loc, ct.root.eroot, false)
+	}
+	return ct.enrs.resolveNext(ctx, ct.enrs.missing[rand.Intn(len(ct.enrs.missing))])
+}
+
+// canSyncRandom checks if any meaningful action can be performed by syncRandom.
+// Trees without any reachable node records are only rechecked on root updates.
+func (ct *clientTree) canSyncRandom() bool {
+	if ct.rootUpdateDue() {
+		return true
+	}
+	if ct.root == nil {
+		return false
+	}
+	return !ct.links.done() || !ct.enrs.done() || ct.enrs.leaves > 0
+}
+
+// rootUpdateDue returns true when the root of the tree needs to be revalidated.
+func (ct *clientTree) rootUpdateDue() bool {
+	return ct.c.clock.Now() >= ct.nextRootCheck
+}
+
+// updateRoot retrieves and verifies the root of the tree, restarting the syncs
+// of any subtree that changed.
+func (ct *clientTree) updateRoot(ctx context.Context) error {
+	ct.nextRootCheck = ct.c.clock.Now().Add(ct.c.cfg.RecheckInterval)
+
+	root, err := ct.c.resolveRoot(ctx, ct.loc)
+	if err != nil {
+		return err
+	}
+	ct.root = &root
+
+	// Invalidate subtrees if changed, retry the link tree if any link was lost
+	if ct.links == nil || root.lroot != ct.links.root || ct.links.failed {
+		ct.links = newSubtreeSync(ct.c, ct.loc, root.lroot, true)
+	}
+	if ct.enrs == nil || root.eroot != ct.enrs.root {
+		ct.enrs = newSubtreeSync(ct.c, ct.loc, root.eroot, false)
+	}
+	return nil
+}
+
+// subtreeSync is the sync of an ENR or link subtree.
+type subtreeSync struct {
+	c       *Client
+	loc     *linkEntry
+	root    string
+	missing []string // missing tree node hashes
+	link    bool     // true if this sync is for the link tree
+	leaves  int      // counter of synced leaves
+	failed  bool     // true if any entry failed to resolve
+}
+
+func newSubtreeSync(c *Client, loc *linkEntry, root string, link bool) *subtreeSync {
+	return &subtreeSync{c, loc, root, []string{root}, link, 0, false}
+}
+
+func (ts *subtreeSync) done() bool {
+	return len(ts.missing) == 0
+}
+
+// resolveAll resolves every entry of the subtree into the given map.
+func (ts *subtreeSync) resolveAll(dest map[string]entry) error {
+	for !ts.done() {
+		hash := ts.missing[0]
+		e, err := ts.resolveNext(context.Background(), hash)
+		if err != nil {
+			return err
+		}
+		dest[hash] = e
+	}
+	return nil
+}
+
+// resolveNext resolves the given missing entry of the subtree, scheduling the
+// children of branches for retrieval. Failed entries are not retried until the
+// sync is restarted.
+func (ts *subtreeSync) resolveNext(ctx context.Context, hash string) (entry, error) {
+	for i := range ts.missing {
+		if ts.missing[i] == hash {
+			ts.missing = append(ts.missing[:i], ts.missing[i+1:]...)
+			break
+		}
+	}
+	e, err := ts.c.resolveEntry(ctx, ts.loc.domain, hash)
+	if err != nil {
+		ts.failed = true
+		return nil, err
+	}
+	switch e := e.(type) {
+	case *enrEntry:
+		if ts.link {
+			ts.failed = true
+			return nil, errENRInLinkTree
+		}
+		ts.leaves++
+	case *linkEntry:
+		if !ts.link {
+			ts.failed = true
+			return nil, errLinkInENRTree
+		}
+		ts.leaves++
+	case *branchEntry:
+		ts.missing = append(ts.missing, e.children...)
+	}
+	return e, nil
+}
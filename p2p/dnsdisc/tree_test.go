@@ -0,0 +1,210 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/p2p/enode"
+)
+
+func TestParseRoot(t *testing.T) {
+	valid := &rootEntry{
+		eroot: "QFT4PBCRX4XQCV3VUYJ6BTCEPU",
+		lroot: "JGUFMSAGI7KZYB3P7IZW4S5Y3A",
+		seq:   3,
+	}
+	valid.sig, _ = crypto.Sign(valid.sigHash(), testKey(signingKeySeed))
+
+	tests := []struct {
+		input string
+		e     rootEntry
+		err   error
+	}{
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errSyntax},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=1 seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidChild},
+		},
+		{
+			input: "enrtree-root:v1 e=TO4Q75OQ2N7DX4EOOR7X66A6OM l=TO4Q75OQ2N7DX4EOOR7X66A6OM seq=3 sig=N-YY6UB9xD0hFx1Gmnt7v0RfSxch5tKyry2SRDoLx7B4GfPXagwLxQqyf7gAMvApFn_ORwZQekMWa_pXrcGCtw",
+			err:   entryError{"root", errInvalidSig},
+		},
+		{
+			input: valid.String(),
+			e:     *valid,
+		},
+	}
+	for i, test := range tests {
+		e, err := parseRoot(test.input)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %v, want %v", i, &e, &test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+	if !valid.verifySignature(&testKey(signingKeySeed).PublicKey) {
+		t.Errorf("root signature not verified")
+	}
+	if valid.verifySignature(&testKey(nodesSeed1).PublicKey) {
+		t.Errorf("root signature verified with wrong key")
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	var (
+		testkey = testKey(signingKeySeed)
+		testenr = &enrEntry{node: testNode(nodesSeed1)}
+		badkey  = b32format.EncodeToString(append([]byte{0x05}, make([]byte, 32)...))
+	)
+	tests := []struct {
+		input string
+		e     entry
+		err   error
+	}{
+		// Subtrees:
+		{
+			input: "enrtree-branch:1,2",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAA",
+			err:   entryError{"branch", errInvalidChild},
+		},
+		{
+			input: "enrtree-branch:",
+			e:     &branchEntry{},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA"}},
+		},
+		{
+			input: "enrtree-branch:AAAAAAAAAAAAAAAAAAAAAAAAAA,BBBBBBBBBBBBBBBBBBBBBBBBBB",
+			e:     &branchEntry{[]string{"AAAAAAAAAAAAAAAAAAAAAAAAAA", "BBBBBBBBBBBBBBBBBBBBBBBBBB"}},
+		},
+		// Links
+		{
+			input: "enrtree://" + newLinkEntry("nodes.example.org", &testkey.PublicKey).str,
+			e:     newLinkEntry("nodes.example.org", &testkey.PublicKey),
+		},
+		{
+			input: "enrtree://nodes.example.org",
+			err:   entryError{"link", errNoPubkey},
+		},
+		{
+			input: "enrtree://AP62DT7WOTEQZGQZOU474PP3KMEGVTTE7A7NPRXKX3DUD57@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		{
+			input: "enrtree://" + badkey + "@nodes.example.org",
+			err:   entryError{"link", errBadPubkey},
+		},
+		// ENRs
+		{
+			input: testenr.String(),
+			e:     testenr,
+		},
+		{
+			input: "enr:!invalid!",
+			err:   entryError{"enr", errInvalidENR},
+		},
+		// Invalid:
+		{input: "", err: errUnknownEntry},
+		{input: "foo", err: errUnknownEntry},
+		{input: "enrtree", err: errUnknownEntry},
+		{input: "enrtree-x=", err: errUnknownEntry},
+	}
+	for i, test := range tests {
+		e, err := parseEntry(test.input, enode.ValidSchemes)
+		if !reflect.DeepEqual(e, test.e) {
+			t.Errorf("test %d: wrong entry %v, want %v", i, e, test.e)
+		}
+		if err != test.err {
+			t.Errorf("test %d: wrong error %q, want %q", i, err, test.err)
+		}
+	}
+}
+
+func TestMakeTree(t *testing.T) {
+	var (
+		nodes = testNodes(nodesSeed1, 50)
+		links = []string{
+			"enrtree://" + newLinkEntry("a.example.org", &testKey(nodesSeed2).PublicKey).str,
+			"enrtree://" + newLinkEntry("b.example.org", &testKey(nodesSeed3).PublicKey).str,
+		}
+	)
+	sort.Strings(links)
+	tree, err := MakeTree(2, nodes, links)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree.Nodes(), sortByID(nodes)) {
+		t.Errorf("tree nodes mismatch")
+	}
+	if !reflect.DeepEqual(tree.Links(), links) {
+		t.Errorf("tree links mismatch: have %v, want %v", tree.Links(), links)
+	}
+	if tree.Seq() != 2 {
+		t.Errorf("tree sequence mismatch: have %d, want 2", tree.Seq())
+	}
+	// Every TXT record must fit into a single DNS string
+	for name, txt := range tree.ToTXT("nodes.example.org") {
+		if strings.HasPrefix(txt, branchPrefix) && len(txt) > 370 {
+			t.Errorf("branch at %s too long: %d bytes", name, len(txt))
+		}
+	}
+	// Sign the tree and check the signature verifies only with the right key
+	key := testKey(signingKeySeed)
+	url, err := tree.Sign(key, "nodes.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain, pubkey, err := ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if domain != "nodes.example.org" || !reflect.DeepEqual(pubkey, &key.PublicKey) {
+		t.Errorf("tree URL mismatch: %s", url)
+	}
+	signed, _ := MakeTree(2, nodes, links)
+	if err := signed.SetSignature(pubkey, tree.Signature()); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := signed.SetSignature(&testKey(nodesSeed2).PublicKey, tree.Signature()); err != errInvalidSig {
+		t.Errorf("signature of other key accepted: %v", err)
+	}
+	unsigned, _ := MakeTree(3, nodes, links)
+	if err := unsigned.SetSignature(pubkey, tree.Signature()); err != errInvalidSig {
+		t.Errorf("signature of other tree accepted: %v", err)
+	}
+}
+
+func TestMakeTreeUnsigned(t *testing.T) {
+	key := testKey(nodesSeed1)
+	if _, err := MakeTree(1, []*enode.Node{enode.NewV4(&key.PublicKey, nil, 0, 0)}, nil); err == nil {
+		t.Fatalf("unsigned node record accepted")
+	}
+}
@@ -56,6 +56,11 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// DialFilter is an optional predicate deciding whccmer a node found through
+	// discovery is suitable for the protocol. Nodes rejected by the filter of any
+	// protocol are not dialed dynamically, static nodes are always dialed.
+	DialFilter func(*enode.Node) bool
 }
 
 func (p Protocol) cap() Cap {
@@ -120,6 +120,60 @@ func (t *rlpx) close(err error) {
 	t.fd.Close()
 }
 
+// RLPXConn is an RLPx connection which is not managed by a Server. It can be used
+// to speak devp2p with a remote node directly, e.g. in protocol test tools. Unlike
+// server connections, it doesn't apply any read or write deadlines. They can be
+// set on the underlying network connection instead.
+type RLPXConn struct {
+	t *rlpx
+}
+
+// NewRLPXConn wraps the given network connection. The encryption handshake must
+// be performed before any messages can be exchanged.
+func NewRLPXConn(fd net.Conn) *RLPXConn {
+	return &RLPXConn{t: &rlpx{fd: fd}}
+}
+
+// Handshake performs the RLPx encryption handshake using the given private key. The
+// connection acts as the initiator if remote is non-nil. It returns the public key
+// of the remote node.
+func (c *RLPXConn) Handshake(prv *ecdsa.PrivateKey, remote *ecdsa.PublicKey) (*ecdsa.PublicKey, error) {
+	c.t.fd.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer c.t.fd.SetDeadline(time.Time{})
+	return c.t.doEncHandshake(prv, remote)
+}
+
+// SetSnappy enables or disables snappy compression of message payloads. It should
+// be enabled after the devp2p handshake if both sides announced base protocol
+// version 5 or higher.
+func (c *RLPXConn) SetSnappy(snappy bool) {
+	c.t.rmu.Lock()
+	c.t.wmu.Lock()
+	defer c.t.rmu.Unlock()
+	defer c.t.wmu.Unlock()
+
+	c.t.rw.snappy = snappy
+}
+
+// ReadMsg reads a message from the connection.
+func (c *RLPXConn) ReadMsg() (Msg, error) {
+	c.t.rmu.Lock()
+	defer c.t.rmu.Unlock()
+	return c.t.rw.ReadMsg()
+}
+
+// WriteMsg writes a message to the connection.
+func (c *RLPXConn) WriteMsg(msg Msg) error {
+	c.t.wmu.Lock()
+	defer c.t.wmu.Unlock()
+	return c.t.rw.WriteMsg(msg)
+}
+
+// Close closes the underlying network connection.
+func (c *RLPXConn) Close() error {
+	return c.t.fd.Close()
+}
+
 func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err error) {
 	// Writing our handshake happens concurrently, we prefer
 	// returning the handshake read error. If the remote side
@@ -221,6 +221,65 @@ func TestProtocolHandshake(t *testing.T) {
 	wg.Wait()
 }
 
+// This test checks that standalone RLPx connections can talk to each other,
+// both with and without compression.
+func TestRLPXConn(t *testing.T) {
+	var (
+		prv0, _ = crypto.GenerateKey()
+		prv1, _ = crypto.GenerateKey()
+		wg      sync.WaitGroup
+	)
+	fd0, fd1, err := pipes.TCPPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn0, conn1 := NewRLPXConn(fd0), NewRLPXConn(fd1)
+	defer conn0.Close()
+	defer conn1.Close()
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		rpubkey, err := conn0.Handshake(prv0, &prv1.PublicKey)
+		if err != nil {
+			t.Errorf("dial side handshake failed: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(rpubkey, &prv1.PublicKey) {
+			t.Errorf("dial side remote pubkey mismatch: got %v, want %v", rpubkey, &prv1.PublicKey)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		rpubkey, err := conn1.Handshake(prv1, nil)
+		if err != nil {
+			t.Errorf("listen side handshake failed: %v", err)
+			return
+		}
+		if !reflect.DeepEqual(rpubkey, &prv0.PublicKey) {
+			t.Errorf("listen side remote pubkey mismatch: got %v, want %v", rpubkey, &prv0.PublicKey)
+		}
+	}()
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	for _, snappy := range []bool{false, true} {
+		conn0.SetSnappy(snappy)
+		conn1.SetSnappy(snappy)
+
+		errc := make(chan error, 1)
+		go func() { errc <- SendItems(conn0, 0x10, "hello", uint(snappyProtocolVersion)) }()
+		if err := ExpectMsg(conn1, 0x10, []interface{}{"hello", uint(snappyProtocolVersion)}); err != nil {
+			t.Errorf("snappy=%t: %v", snappy, err)
+		}
+		if err := <-errc; err != nil {
+			t.Errorf("snappy=%t: write error: %v", snappy, err)
+		}
+	}
+}
+
 func TestProtocolHandshakeErrors(t *testing.T) {
 	tests := []struct {
 		code uint64
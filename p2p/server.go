@@ -36,6 +36,7 @@ import (
 	"github.com/ccmchain/go-ccmchain/log"
 	"github.com/ccmchain/go-ccmchain/p2p/discover"
 	"github.com/ccmchain/go-ccmchain/p2p/discv5"
+	"github.com/ccmchain/go-ccmchain/p2p/dnsdisc"
 	"github.com/ccmchain/go-ccmchain/p2p/enode"
 	"github.com/ccmchain/go-ccmchain/p2p/enr"
 	"github.com/ccmchain/go-ccmchain/p2p/nat"
@@ -45,6 +46,11 @@ import (
 const (
 	defaultDialTimeout = 15 * time.Second
 
+	// This is the fairness knob for the discovery mixer. When looking for peers, we'll
+	// wait this long for a single source of candidates before moving on and trying other
+	// sources.
+	discmixTimeout = 5 * time.Second
+
 	// Connectivity defaults.
 	maxActiveDialTasks     = 16
 	defaultMaxPendingPeers = 50
@@ -103,6 +109,16 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// DiscoverySources are additional sources of dynamic dial candidates, e.g.
+	// DNS discovery iterators. Their nodes are mixed fairly with the results of
+	// the discovery table. The server closes the iterators when it stops.
+	DiscoverySources []enode.Iterator `toml:"-"`
+
+	// DiscoveryURLs are the enrtree:// URLs of DNS discovery trees to take dial
+	// candidates from. DNS discovery doesn't need UDP, so the trees are used even
+	// if NoDiscovery is set.
+	DiscoveryURLs []string `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*enode.Node
@@ -170,6 +186,7 @@ type Server struct {
 	nodedb       *enode.DB
 	localnode    *enode.LocalNode
 	ntab         discoverTable
+	discmix      *enode.FairMix
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	DiscV5       *discv5.Network
@@ -190,7 +207,6 @@ type Server struct {
 	checkpointAddPeer       chan *conn
 
 	// State of run loop and listenLoop.
-	lastLookup     time.Time
 	inboundHistory expHeap
 }
 
@@ -470,7 +486,7 @@ func (srv *Server) Start() (err error) {
 	}
 
 	dynPeers := srv.maxDialedConns()
-	dialer := newDialState(srv.localnode.ID(), srv.ntab, dynPeers, &srv.Config)
+	dialer := newDialState(srv.localnode.ID(), dynPeers, &srv.Config)
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
@@ -521,6 +537,18 @@ func (srv *Server) setupLocalNode() error {
 }
 
 func (srv *Server) setupDiscovery() error {
+	srv.discmix = enode.NewFairMix(discmixTimeout)
+	for _, it := range srv.DiscoverySources {
+		srv.discmix.AddSource(it)
+	}
+	if len(srv.DiscoveryURLs) > 0 {
+		client := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log})
+		it, err := client.NewIterator(srv.DiscoveryURLs...)
+		if err != nil {
+			return err
+		}
+		srv.discmix.AddSource(it)
+	}
 	if srv.NoDiscovery && !srv.DiscoveryV5 {
 		return nil
 	}
@@ -562,6 +590,7 @@ func (srv *Server) setupDiscovery() error {
 			return err
 		}
 		srv.ntab = ntab
+		srv.discmix.AddSource(ntab.RandomNodes())
 	}
 	// Discovery V5
 	if srv.DiscoveryV5 {
@@ -774,6 +803,7 @@ running:
 	srv.log.Trace("P2P networking is spinning down")
 
 	// Terminate discovery. If there is a running lookup it will terminate soon.
+	srv.discmix.Close()
 	if srv.ntab != nil {
 		srv.ntab.Close()
 	}
@@ -824,7 +854,11 @@ func (srv *Server) maxInboundConns() int {
 }
 
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if srv.NoDial {
+		return 0
+	}
+	// Without discovery, only dial if there are other sources of candidates
+	if srv.NoDiscovery && len(srv.DiscoverySources) == 0 && len(srv.DiscoveryURLs) == 0 {
 		return 0
 	}
 	r := srv.DialRatio
@@ -207,6 +207,70 @@ func TestServerDial(t *testing.T) {
 
 // This test checks that tasks generated by dialstate are
 // actually executed and taskdone is called for them.
+// Tests that the server dials the candidates of its discovery sources even if the
+// discovery protocols are disabled.
+func TestServerDialDiscoverySources(t *testing.T) {
+	// run a one-shot TCP server to handle the connection.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("could not setup listener: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn
+	}()
+
+	// start the server with discovery disabled, but with a static candidate source
+	remid := &newkey().PublicKey
+	tcpAddr := listener.Addr().(*net.TCPAddr)
+	node := enode.NewV4(remid, tcpAddr.IP, tcpAddr.Port, 0)
+
+	connected := make(chan *Peer, 1)
+	srv := &Server{
+		Config: Config{
+			Name:             "test",
+			MaxPeers:         10,
+			ListenAddr:       "127.0.0.1:0",
+			PrivateKey:       newkey(),
+			NoDiscovery:      true,
+			DiscoverySources: []enode.Iterator{enode.CycleNodes([]*enode.Node{node})},
+			Logger:           testlog.Logger(t, log.LvlTrace),
+		},
+		newPeerHook: func(p *Peer) {
+			select {
+			case connected <- p:
+			default:
+			}
+		},
+		newTransport: func(fd net.Conn) transport { return newTestTransport(remid, fd) },
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Could not start server: %v", err)
+	}
+	defer srv.Stop()
+
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+
+		select {
+		case peer := <-connected:
+			if peer.ID() != node.ID() {
+				t.Errorf("peer has wrong id: have %v, want %v", peer.ID(), node.ID())
+			}
+		case <-time.After(1 * time.Second):
+			t.Error("server did not launch peer within one second")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("server did not dial discovery source candidate within five seconds")
+	}
+}
+
 func TestServerTaskScheduling(t *testing.T) {
 	var (
 		done           = make(chan *testTask)
@@ -234,7 +298,7 @@ func TestServerTaskScheduling(t *testing.T) {
 		localnode: enode.NewLocalNode(db, newkey()),
 		nodedb:    db,
 		quit:      make(chan struct{}),
-		ntab:      fakeTable{},
+		discmix:   enode.NewFairMix(0),
 		running:   true,
 		log:       log.New(),
 	}
@@ -282,7 +346,7 @@ func TestServerManyTasks(t *testing.T) {
 			quit:      make(chan struct{}),
 			localnode: enode.NewLocalNode(db, newkey()),
 			nodedb:    db,
-			ntab:      fakeTable{},
+			discmix:   enode.NewFairMix(0),
 			running:   true,
 			log:       log.New(),
 		}
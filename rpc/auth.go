@@ -0,0 +1,148 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// jwtExpiryTimeout is the maximum allowed difference between the issuance
+	// time of a token and the local time, in either direction.
+	jwtExpiryTimeout = 60 * time.Second
+
+	// jwtHeader is the encoded header of all the tokens signed by MakeJWTToken.
+	jwtHeader = `{"alg":"HS256","typ":"JWT"}`
+)
+
+var (
+	errMissingToken  = errors.New("missing bearer token")
+	errInvalidToken  = errors.New("invalid token")
+	errTokenAlgo     = errors.New("unsupported token signing algorithm")
+	errTokenSig      = errors.New("invalid token signature")
+	errTokenIssuance = errors.New("missing token issuance time")
+	errStaleToken    = errors.New("stale token")
+	errFutureToken   = errors.New("token issued in the future")
+)
+
+// jwtClaims are the token claims checked by the authentication handler.
+type jwtClaims struct {
+	IssuedAt *int64 `json:"iat"`
+}
+
+// jwtHandler is a handler which authenticates requests with HS256 signed JSON Web
+// Tokens, passed as bearer tokens in the Authorization header. Besides a valid
+// signature, the tokens must carry an issuance time close to the local time.
+type jwtHandler struct {
+	secret []byte
+	next   http.Handler
+}
+
+// NewJWTHandler creates a handler that only passes requests on to next if they
+// are authenticated by a fresh HS256 JWT token signed with the given secret.
+func NewJWTHandler(secret []byte, next http.Handler) http.Handler {
+	return &jwtHandler{secret: secret, next: next}
+}
+
+// ServeHTTP implements http.Handler.
+func (h *jwtHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		http.Error(w, errMissingToken.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := verifyJWTToken(h.secret, strings.TrimPrefix(auth, "Bearer "), time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// MakeJWTToken creates an HS256 JWT token signed with the given secret, issued
+// at the given time. The token is valid for authenticating requests until the
+// expiry timeout passes.
+func MakeJWTToken(secret []byte, issued time.Time) string {
+	claims := fmt.Sprintf(`{"iat":%d}`, issued.Unix())
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(jwtHeader)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(secret, signed))
+}
+
+// verifyJWTToken checks that the token is signed by the secret with HS256 and
+// was issued close to the given time.
+func verifyJWTToken(secret []byte, token string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errInvalidToken
+	}
+	// Ensure the token is signed with the expected algorithm
+	blob, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return errInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(blob, &header); err != nil {
+		return errInvalidToken
+	}
+	if header.Alg != "HS256" {
+		return errTokenAlgo
+	}
+	// Verify the signature before looking at any of the claims
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return errInvalidToken
+	}
+	if !hmac.Equal(sig, jwtSignature(secret, parts[0]+"."+parts[1])) {
+		return errTokenSig
+	}
+	// Signature valid, ensure the token is fresh
+	if blob, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil {
+		return errInvalidToken
+	}
+	var claims jwtClaims
+	if err := json.Unmarshal(blob, &claims); err != nil {
+		return errInvalidToken
+	}
+	if claims.IssuedAt == nil {
+		return errTokenIssuance
+	}
+	issued := time.Unix(*claims.IssuedAt, 0)
+	if now.Sub(issued) > jwtExpiryTimeout {
+		return errStaleToken
+	}
+	if issued.Sub(now) > jwtExpiryTimeout {
+		return errFutureToken
+	}
+	return nil
+}
+
+// jwtSignature calculates the HS256 signature of the signed part of a token.
+func jwtSignature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+// jwtTransport is an HTTP transport injecting bearer tokens into requests.
+type jwtTransport struct {
+	token func() string
+	base  http.RoundTripper
+}
+
+func (t *jwtTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if token := t.token(); token != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func TestJWTHandler(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv     = newTestServer()
+		httpsrv = httptest.NewServer(NewJWTHandler(testJWTSecret, srv))
+	)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	tests := []struct {
+		name  string
+		token func() string
+		err   error
+	}{
+		{"valid", func() string { return MakeJWTToken(testJWTSecret, time.Now()) }, nil},
+		{"slightly-early", func() string { return MakeJWTToken(testJWTSecret, time.Now().Add(-50*time.Second)) }, nil},
+		{"expired", func() string { return MakeJWTToken(testJWTSecret, time.Now().Add(-2*time.Minute)) }, errStaleToken},
+		{"future", func() string { return MakeJWTToken(testJWTSecret, time.Now().Add(2*time.Minute)) }, errFutureToken},
+		{"wrong-secret", func() string { return MakeJWTToken([]byte("bad"), time.Now()) }, errTokenSig},
+		{"no-iat", func() string { return signTestJWT(`{"alg":"HS256"}`, `{}`) }, errTokenIssuance},
+		{"none-alg", func() string { return signTestJWT(`{"alg":"none"}`, `{"iat":0}`) }, errTokenAlgo},
+		{"garbage", func() string { return "not.a.token" }, errInvalidToken},
+		{"missing", func() string { return "" }, errMissingToken},
+	}
+	for _, tt := range tests {
+		client, err := DialHTTPWithClient(httpsrv.URL, &http.Client{
+			Transport: &jwtTransport{token: tt.token, base: http.DefaultTransport},
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to dial: %v", tt.name, err)
+		}
+		var result Result
+		err = client.Call(&result, "test_echo", "hello", 10, &Args{"world"})
+		client.Close()
+
+		switch {
+		case tt.err == nil && err != nil:
+			t.Errorf("%s: call failed: %v", tt.name, err)
+		case tt.err == nil && result.String != "hello":
+			t.Errorf("%s: result mismatch: have %v", tt.name, result)
+		case tt.err != nil && err == nil:
+			t.Errorf("%s: call succeeded", tt.name)
+		case tt.err != nil && (!strings.HasPrefix(err.Error(), "401 Unauthorized") || !strings.Contains(err.Error(), tt.err.Error())):
+			t.Errorf("%s: error mismatch: have %q, want 401 Unauthorized with %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+// signTestJWT creates a JWT token with arbitrary header and claims, signed with
+// the test secret.
+func signTestJWT(header, claims string) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString([]byte(claims))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(jwtSignature(testJWTSecret, signed))
+}
+
+// Tests that the authenticated endpoint serves both HTTP and websocket requests
+// over TLS, only exposes the allowed modules and enforces the resource limits.
+func TestAuthEndpoint(t *testing.T) {
+	t.Parallel()
+
+	certFile, keyFile, roots := makeTestCertificate(t)
+	defer os.RemoveAll(filepath.Dir(certFile))
+
+	apis := []API{
+		{Namespace: "test", Service: new(testService)},
+		{Namespace: "nftest", Service: new(notificationTestService)},
+	}
+	listener, srv, err := StartAuthEndpoint("127.0.0.1:0", apis, []string{"test"}, testJWTSecret, certFile, keyFile, DefaultHTTPTimeouts, Limits{BatchItems: 2}, false)
+	if err != nil {
+		t.Fatalf("failed to start endpoint: %v", err)
+	}
+	defer srv.Stop()
+	defer listener.Close()
+
+	tlsConfig := &tls.Config{RootCAs: roots}
+	token := func() string { return MakeJWTToken(testJWTSecret, time.Now()) }
+
+	// Plain text requests must be rejected by the TLS listener
+	client, err := DialHTTP("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	if err := client.Call(nil, "rpc_modules"); err == nil {
+		t.Errorf("plain text request succeeded")
+	}
+	client.Close()
+
+	// Authenticated TLS requests must succeed with only the allowed modules
+	client, err = DialHTTPWithClient("https://"+listener.Addr().String(), &http.Client{
+		Transport: &jwtTransport{token: token, base: &http.Transport{TLSClientConfig: tlsConfig}},
+	})
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	var modules map[string]string
+	if err := client.Call(&modules, "rpc_modules"); err != nil {
+		t.Fatalf("failed to retrieve modules: %v", err)
+	}
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "test_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatalf("failed to send batch: %v", err)
+	}
+	for i, elem := range batch {
+		if err, ok := elem.Error.(Error); !ok || err.ErrorCode() != -32600 {
+			t.Errorf("batch element %d: error mismatch: have %v, want batch too large", i, elem.Error)
+		}
+	}
+	client.Close()
+
+	want := map[string]string{"rpc": "1.0", "test": "1.0"}
+	if !reflect.DeepEqual(modules, want) {
+		t.Errorf("modules mismatch: have %v, want %v", modules, want)
+	}
+	// Websocket upgrades must also be authenticated
+	dialer := websocket.Dialer{TLSClientConfig: tlsConfig}
+	url := "wss://" + listener.Addr().String()
+
+	if _, resp, err := dialer.Dial(url, nil); err == nil {
+		t.Errorf("unauthenticated websocket upgrade succeeded")
+	} else if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unauthenticated websocket upgrade error mismatch: %v", err)
+	}
+	conn, _, err := dialer.Dial(url, http.Header{"Authorization": {"Bearer " + token()}})
+	if err != nil {
+		t.Fatalf("authenticated websocket upgrade failed: %v", err)
+	}
+	client, _ = newClient(context.Background(), func(context.Context) (ServerCodec, error) {
+		return newWebsocketCodec(conn), nil
+	})
+	defer client.Close()
+
+	var result Result
+	if err := client.Call(&result, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatalf("websocket call failed: %v", err)
+	}
+	if result.String != "hello" {
+		t.Errorf("websocket result mismatch: have %v", result)
+	}
+}
+
+// makeTestCertificate creates a self-signed TLS certificate for 127.0.0.1, returning
+// the paths of the certificate and key files along with a pool trusting it.
+func makeTestCertificate(t *testing.T) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "rpc-tls-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return certFile, keyFile, roots
+}
@@ -49,6 +49,30 @@ func TestClientRequest(t *testing.T) {
 	}
 }
 
+func TestClientErrorData(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var resp interface{}
+	err := client.Call(&resp, "test_returnError")
+	if err == nil {
+		t.Fatal("no error returned")
+	}
+	// Check the error code and data
+	if e, ok := err.(Error); !ok {
+		t.Fatalf("client did not return rpc.Error, got %#v", err)
+	} else if e.ErrorCode() != (dataError{}.ErrorCode()) {
+		t.Fatalf("wrong error code %d, want %d", e.ErrorCode(), dataError{}.ErrorCode())
+	}
+	if de, ok := err.(DataError); !ok {
+		t.Fatalf("client did not return rpc.DataError, got %#v", err)
+	} else if de.ErrorData() != "testError data" {
+		t.Fatalf("wrong error data %#v, want %q", de.ErrorData(), "testError data")
+	}
+}
+
 func TestClientBatchRequest(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -98,6 +122,67 @@ func TestClientBatchRequest(t *testing.T) {
 	}
 }
 
+// Tests that batches rejected by the server for being too large fail every element
+// instead of leaving the client waiting for the missing responses.
+func TestClientBatchRequestLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetLimits(Limits{BatchItems: 2})
+	client := DialInProc(server)
+	defer client.Close()
+
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "test_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if err, ok := elem.Error.(Error); !ok || err.ErrorCode() != -32600 {
+			t.Errorf("element %d: error mismatch: have %v, want batch too large", i, elem.Error)
+		}
+	}
+}
+
+// Tests that the IPC endpoint enforces the resource limits it was started with.
+func TestIPCEndpointLimits(t *testing.T) {
+	endpoint := fmt.Sprintf("go-ccmchain-test-ipc-%d-%d", os.Getpid(), rand.Int63())
+	if runtime.GOOS == "windows" {
+		endpoint = `\\.\pipe\` + endpoint
+	} else {
+		endpoint = os.TempDir() + "/" + endpoint
+	}
+	listener, server, err := StartIPCEndpoint(endpoint, []API{{Namespace: "test", Service: new(testService)}}, Limits{BatchItems: 2}, false)
+	if err != nil {
+		t.Fatalf("failed to start endpoint: %v", err)
+	}
+	defer server.Stop()
+	defer listener.Close()
+
+	client, err := Dial(endpoint)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	batch := make([]BatchElem, 3)
+	for i := range batch {
+		batch[i] = BatchElem{Method: "test_echo", Args: []interface{}{"hello", i, &Args{"world"}}, Result: new(Result)}
+	}
+	if err := client.BatchCall(batch); err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range batch {
+		if err, ok := elem.Error.(Error); !ok || err.ErrorCode() != -32600 {
+			t.Errorf("element %d: error mismatch: have %v, want batch too large", i, elem.Error)
+		}
+	}
+}
+
 func TestClientNotify(t *testing.T) {
 	server := newTestServer()
 	defer server.Stop()
@@ -17,7 +17,9 @@
 package rpc
 
 import (
+	"crypto/tls"
 	"net"
+	"net/http"
 
 	"github.com/ccmchain/go-ccmchain/log"
 )
@@ -82,10 +84,64 @@ func StartWSEndpoint(endpoint string, apis []API, modules []string, wsOrigins []
 
 }
 
-// StartIPCEndpoint starts an IPC endpoint.
-func StartIPCEndpoint(ipcEndpoint string, apis []API) (net.Listener, *Server, error) {
+// StartAuthEndpoint starts an authenticated RPC endpoint, serving both HTTP and
+// websocket requests which carry a JWT token signed with the given secret. If a
+// TLS certificate and key are given, the endpoint only accepts TLS connections.
+// The resource limits and access log setting are applied before serving.
+func StartAuthEndpoint(endpoint string, apis []API, modules []string, secret []byte, tlsCert, tlsKey string, timeouts HTTPTimeouts, limits Limits, accessLog bool) (net.Listener, *Server, error) {
+	// Generate the whitelist based on the allowed modules
+	whitelist := make(map[string]bool)
+	for _, module := range modules {
+		whitelist[module] = true
+	}
+	// Register all the APIs exposed by the services
+	handler := NewServer()
+	handler.SetLimits(limits)
+	handler.SetAccessLog(accessLog)
+	for _, api := range apis {
+		if whitelist[api.Namespace] || (len(whitelist) == 0 && api.Public) {
+			if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
+				return nil, nil, err
+			}
+			log.Debug("Authenticated RPC registered", "namespace", api.Namespace)
+		}
+	}
+	// Load the TLS keypair if the endpoint is to be encrypted
+	var config *tls.Config
+	if tlsCert != "" || tlsKey != "" {
+		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	}
+	// All APIs registered, start the listener serving both HTTP and websocket
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+	ws := handler.WebsocketHandler([]string{"*"})
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebsocket(r) {
+			ws.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+	go NewHTTPServer(nil, []string{"*"}, timeouts, NewJWTHandler(secret, mux)).Serve(listener)
+	return listener, handler, nil
+}
+
+// StartIPCEndpoint starts an IPC endpoint, enforcing the given resource limits and
+// access log setting.
+func StartIPCEndpoint(ipcEndpoint string, apis []API, limits Limits, accessLog bool) (net.Listener, *Server, error) {
 	// Register all the APIs exposed by the services.
 	handler := NewServer()
+	handler.SetLimits(limits)
+	handler.SetAccessLog(accessLog)
 	for _, api := range apis {
 		if err := handler.RegisterName(api.Namespace, api.Service); err != nil {
 			return nil, nil, err
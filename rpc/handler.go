@@ -61,11 +61,18 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	config         handlerConfig
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
 
+// handlerConfig holds the settings applied by a handler to the calls it serves.
+type handlerConfig struct {
+	limits    Limits // resource limits on the served calls
+	accessLog bool   // whccmer to log every served call
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
@@ -102,6 +109,24 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 		return
 	}
 
+	// Reject batches with too many items without running any of them:
+	if limit := h.config.limits.BatchItems; limit > 0 && len(msgs) > limit {
+		h.startCallProc(func(cp *callProc) {
+			// There's no way to fail a batch as a whole, so answer each call in it
+			// with the error, clients waiting for all of them.
+			var resps []*jsonrpcMessage
+			for _, msg := range msgs {
+				if msg.isCall() {
+					resps = append(resps, msg.errorResponse(&batchTooLargeError{limit}))
+				}
+			}
+			if len(resps) == 0 {
+				resps = append(resps, errorMessage(&batchTooLargeError{limit}))
+			}
+			h.conn.Write(cp.ctx, resps)
+		})
+		return
+	}
 	// Handle non-call messages first:
 	calls := make([]*jsonrpcMessage, 0, len(msgs))
 	for _, msg := range msgs {
@@ -114,10 +139,21 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
-		answers := make([]*jsonrpcMessage, 0, len(msgs))
+		var (
+			answers = make([]*jsonrpcMessage, 0, len(msgs))
+			size    int
+		)
 		for _, msg := range calls {
+			// Stop executing calls once the response size limit is hit
+			if limit := h.config.limits.ResponseSize; limit > 0 && size > limit {
+				if msg.isCall() {
+					answers = append(answers, msg.errorResponse(&responseTooLargeError{limit}))
+				}
+				continue
+			}
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
-				answers = append(answers, answer)
+				size += len(answer.Result)
+				answers = append(answers, h.limitResponse(msg, answer, size))
 			}
 		}
 		h.addSubscriptions(cp.notifiers)
@@ -139,7 +175,7 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 		answer := h.handleCallMsg(cp, msg)
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
-			h.conn.Write(cp.ctx, answer)
+			h.conn.Write(cp.ctx, h.limitResponse(msg, answer, len(answer.Result)))
 		}
 		for _, n := range cp.notifiers {
 			n.activate()
@@ -147,6 +183,15 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 	})
 }
 
+// limitResponse replaces an answer with an error if the response it's part of
+// exceeds the size limit.
+func (h *handler) limitResponse(msg *jsonrpcMessage, answer *jsonrpcMessage, size int) *jsonrpcMessage {
+	if limit := h.config.limits.ResponseSize; limit > 0 && size > limit {
+		return msg.errorResponse(&responseTooLargeError{limit})
+	}
+	return answer
+}
+
 // close cancels all requests except for inflightReq and waits for
 // call goroutines to shut down.
 func (h *handler) close(err error, inflightReq *requestOp) {
@@ -200,6 +245,7 @@ func (h *handler) addSubscriptions(nn []*Notifier) {
 	for _, n := range nn {
 		if sub := n.takeSubscription(); sub != nil {
 			h.serverSubs[sub.ID] = sub
+			recordActiveSubscriptions(1)
 		}
 	}
 }
@@ -213,6 +259,7 @@ func (h *handler) cancelServerSubscriptions(err error) {
 		s.err <- err
 		close(s.err)
 		delete(h.serverSubs, id)
+		recordActiveSubscriptions(-1)
 	}
 }
 
@@ -309,8 +356,16 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 	}
 }
 
-// handleCall processes method calls.
+// handleCall processes method calls, recording them in the metrics.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	start := time.Now()
+	resp := h.dispatchCall(cp, msg)
+	h.recordCall(msg, resp, time.Since(start))
+	return resp
+}
+
+// dispatchCall runs the callback of a method call.
+func (h *handler) dispatchCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -327,7 +382,9 @@ func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage
 	if err != nil {
 		return msg.errorResponse(&invalidParamsError{err.Error()})
 	}
-
+	if timeout := h.config.limits.timeout(msg.Method); timeout > 0 {
+		return h.runMethodWithTimeout(cp.ctx, msg, callb, args, timeout)
+	}
 	return h.runMethod(cp.ctx, msg, callb, args)
 }
 
@@ -361,7 +418,11 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	cp.notifiers = append(cp.notifiers, n)
 	ctx := context.WithValue(cp.ctx, notifierKey{}, n)
 
-	return h.runMethod(ctx, msg, callb, args)
+	resp := h.runMethod(ctx, msg, callb, args)
+	if resp.Error == nil {
+		recordSubscription(namespace, name)
+	}
+	return resp
 }
 
 // runMethod runs the Go callback for an RPC method.
@@ -373,6 +434,31 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 	return msg.response(result)
 }
 
+// runMethodWithTimeout runs the Go callback for an RPC method with an execution
+// deadline set on its context. If the method doesn't return in time, a timeout
+// error is returned and the method is left to finish in the background.
+func (h *handler) runMethodWithTimeout(ctx context.Context, msg *jsonrpcMessage, callb *callback, args []reflect.Value, timeout time.Duration) *jsonrpcMessage {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan *jsonrpcMessage, 1)
+	go func() {
+		done <- h.runMethod(ctx, msg, callb, args)
+	}()
+	select {
+	case answer := <-done:
+		if answer.Error != nil && ctx.Err() == context.DeadlineExceeded {
+			return msg.errorResponse(&timeoutError{msg.Method})
+		}
+		return answer
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return msg.errorResponse(&timeoutError{msg.Method})
+		}
+		return msg.errorResponse(ctx.Err())
+	}
+}
+
 // unsubscribe is the callback function for all *_unsubscribe calls.
 func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	h.subLock.Lock()
@@ -384,6 +470,7 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	}
 	close(s.err)
 	delete(h.serverSubs, id)
+	recordActiveSubscriptions(-1)
 	return true, nil
 }
 
@@ -0,0 +1,101 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+// Contains the metrics collected by the RPC server.
+
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/metrics"
+)
+
+const (
+	MetricsRequests            = "rpc/requests"             // Name of the request counters
+	MetricsSuccess             = "rpc/success"              // Name of the successful request counters
+	MetricsFailure             = "rpc/failure"              // Name of the failed request counters
+	MetricsDuration            = "rpc/duration"             // Name of the request latency timers
+	MetricsSubscriptions       = "rpc/subscriptions"        // Name of the created subscription counters
+	MetricsActiveSubscriptions = "rpc/subscriptions/active" // Name of the live subscriptions gauge
+
+	methodNotFoundCode = -32601 // Error code of calls to unknown methods and subscriptions
+)
+
+// activeSubscriptions tracks the number of live subscriptions, so the gauge can be
+// updated with absolute values.
+var activeSubscriptions struct {
+	count int64
+	lock  sync.Mutex
+}
+
+// methodLabel returns the metric name suffix labelling a metric with a method.
+func methodLabel(method string) string {
+	return "{method=" + method + "}"
+}
+
+// recordCall updates the request metrics with a served method call, and writes
+// it into the access log if enabled.
+//
+// Besides the totals, metrics are collected per method, labelled with the method
+// name. Calls to unknown methods are only counted in the totals, so remote callers
+// can't blow up the registry.
+func (h *handler) recordCall(msg *jsonrpcMessage, resp *jsonrpcMessage, elapsed time.Duration) {
+	code := 0
+	if resp != nil && resp.Error != nil {
+		code = resp.Error.Code
+	}
+	if h.config.accessLog {
+		log.Info("Served RPC request", "method", msg.Method, "reqid", idForLog{msg.ID}, "addr", h.conn.RemoteAddr(), "elapsed", elapsed, "code", code)
+	}
+	if !metrics.Enabled {
+		return
+	}
+	names := []string{""}
+	if code != methodNotFoundCode {
+		names = append(names, methodLabel(msg.Method))
+	}
+	for _, name := range names {
+		metrics.GetOrRegisterCounter(MetricsRequests+name, nil).Inc(1)
+		if code == 0 {
+			metrics.GetOrRegisterCounter(MetricsSuccess+name, nil).Inc(1)
+		} else {
+			metrics.GetOrRegisterCounter(MetricsFailure+name, nil).Inc(1)
+		}
+		metrics.GetOrRegisterTimer(MetricsDuration+name, nil).Update(elapsed)
+	}
+}
+
+// recordSubscription counts a newly created subscription by its name.
+func recordSubscription(namespace, name string) {
+	if !metrics.Enabled {
+		return
+	}
+	metrics.GetOrRegisterCounter(MetricsSubscriptions+methodLabel(namespace+"_"+name), nil).Inc(1)
+}
+
+// recordActiveSubscriptions updates the number of live subscriptions by delta.
+func recordActiveSubscriptions(delta int) {
+	activeSubscriptions.lock.Lock()
+	defer activeSubscriptions.lock.Unlock()
+
+	activeSubscriptions.count += int64(delta)
+	if metrics.Enabled {
+		metrics.GetOrRegisterGauge(MetricsActiveSubscriptions, nil).Update(activeSubscriptions.count)
+	}
+}
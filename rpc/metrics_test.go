@@ -0,0 +1,211 @@
+// Copyright 2020 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/metrics"
+)
+
+// enableTestMetrics turns on metrics collection for the duration of a test.
+func enableTestMetrics() func() {
+	enabled := metrics.Enabled
+	metrics.Enabled = true
+	return func() { metrics.Enabled = enabled }
+}
+
+// counterValue returns the current value of a counter in the default registry.
+func counterValue(name string) int64 {
+	if counter, ok := metrics.DefaultRegistry.Get(name).(metrics.Counter); ok {
+		return counter.Count()
+	}
+	return 0
+}
+
+// gaugeValue returns the current value of a gauge in the default registry.
+func gaugeValue(name string) int64 {
+	if gauge, ok := metrics.DefaultRegistry.Get(name).(metrics.Gauge); ok {
+		return gauge.Value()
+	}
+	return 0
+}
+
+// timerCount returns the number of events recorded by a timer in the default
+// registry.
+func timerCount(name string) int64 {
+	if timer, ok := metrics.DefaultRegistry.Get(name).(metrics.Timer); ok {
+		return timer.Count()
+	}
+	return 0
+}
+
+// Tests that served method calls are counted and timed, both in total and per
+// method, except for calls to unknown methods.
+func TestCallMetrics(t *testing.T) {
+	defer enableTestMetrics()()
+
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	names := []string{
+		MetricsRequests, MetricsSuccess, MetricsFailure, MetricsDuration,
+		MetricsRequests + "{method=test_echo}", MetricsSuccess + "{method=test_echo}", MetricsDuration + "{method=test_echo}",
+		MetricsRequests + "{method=test_returnError}", MetricsFailure + "{method=test_returnError}",
+		MetricsRequests + "{method=test_unknown}",
+	}
+	before := make(map[string]int64)
+	for _, name := range names {
+		before[name] = counterValue(name) + timerCount(name)
+	}
+	var resp Result
+	if err := client.Call(&resp, "test_echo", "hello", 10, &Args{"world"}); err != nil {
+		t.Fatalf("failed to call test_echo: %v", err)
+	}
+	if err := client.Call(nil, "test_returnError"); err == nil {
+		t.Fatalf("test_returnError succeeded")
+	}
+	if err := client.Call(nil, "test_unknown"); err == nil {
+		t.Fatalf("test_unknown succeeded")
+	}
+	want := map[string]int64{
+		MetricsRequests:                               3,
+		MetricsSuccess:                                1,
+		MetricsFailure:                                2,
+		MetricsDuration:                               3,
+		MetricsRequests + "{method=test_echo}":        1,
+		MetricsSuccess + "{method=test_echo}":         1,
+		MetricsDuration + "{method=test_echo}":        1,
+		MetricsRequests + "{method=test_returnError}": 1,
+		MetricsFailure + "{method=test_returnError}":  1,
+		MetricsRequests + "{method=test_unknown}":     0,
+	}
+	for _, name := range names {
+		if have := counterValue(name) + timerCount(name) - before[name]; have != want[name] {
+			t.Errorf("metric %s: have %d, want %d", name, have, want[name])
+		}
+	}
+}
+
+// Tests that created subscriptions are counted by name, and that the number of
+// live subscriptions is tracked.
+func TestSubscriptionMetrics(t *testing.T) {
+	defer enableTestMetrics()()
+
+	server := newTestServer()
+	defer server.Stop()
+	client := DialInProc(server)
+	defer client.Close()
+
+	var (
+		created = MetricsSubscriptions + "{method=nftest_someSubscription}"
+
+		createdBefore = counterValue(created)
+		activeBefore  = gaugeValue(MetricsActiveSubscriptions)
+	)
+	sub, err := client.Subscribe(context.Background(), "nftest", make(chan int), "someSubscription", 0, 1)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if have := counterValue(created) - createdBefore; have != 1 {
+		t.Errorf("created subscriptions: have %d, want 1", have)
+	}
+	if have := gaugeValue(MetricsActiveSubscriptions) - activeBefore; have != 1 {
+		t.Errorf("active subscriptions: have %d, want 1", have)
+	}
+	sub.Unsubscribe()
+	if have := gaugeValue(MetricsActiveSubscriptions) - activeBefore; have != 0 {
+		t.Errorf("active subscriptions after unsubscribe: have %d, want 0", have)
+	}
+}
+
+// Tests that served method calls are written into the access log if enabled.
+func TestAccessLog(t *testing.T) {
+	var (
+		lock    sync.Mutex
+		records []*log.Record
+	)
+	handler := log.Root().GetHandler()
+	defer log.Root().SetHandler(handler)
+	log.Root().SetHandler(log.FuncHandler(func(r *log.Record) error {
+		lock.Lock()
+		defer lock.Unlock()
+		if r.Msg == "Served RPC request" {
+			records = append(records, r)
+		}
+		return nil
+	}))
+	server := newTestServer()
+	defer server.Stop()
+
+	// Ensure nothing is logged by default
+	client := DialInProc(server)
+	defer client.Close()
+
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("failed to call test_noArgsRets: %v", err)
+	}
+	lock.Lock()
+	if len(records) != 0 {
+		t.Fatalf("access log written while disabled: %d records", len(records))
+	}
+	lock.Unlock()
+
+	// Enable the access log and ensure calls are logged with their details
+	server.SetAccessLog(true)
+	logged := DialInProc(server)
+	defer logged.Close()
+
+	if err := logged.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("failed to call test_noArgsRets: %v", err)
+	}
+	if err := logged.Call(nil, "test_unknown"); err == nil {
+		t.Fatalf("test_unknown succeeded")
+	}
+	lock.Lock()
+	defer lock.Unlock()
+
+	if len(records) != 2 {
+		t.Fatalf("access log record count mismatch: have %d, want 2", len(records))
+	}
+	for i, want := range []struct {
+		method string
+		code   int
+	}{{"test_noArgsRets", 0}, {"test_unknown", methodNotFoundCode}} {
+		fields := make(map[string]interface{})
+		for j := 0; j+1 < len(records[i].Ctx); j += 2 {
+			fields[records[i].Ctx[j].(string)] = records[i].Ctx[j+1]
+		}
+		if fields["method"] != want.method {
+			t.Errorf("record %d: method mismatch: have %v, want %s", i, fields["method"], want.method)
+		}
+		if fields["code"] != want.code {
+			t.Errorf("record %d: code mismatch: have %v, want %d", i, fields["code"], want.code)
+		}
+		if _, ok := fields["addr"]; !ok {
+			t.Errorf("record %d: caller address missing", i)
+		}
+		if _, ok := fields["elapsed"]; !ok {
+			t.Errorf("record %d: duration missing", i)
+		}
+	}
+}
@@ -19,7 +19,9 @@ package rpc
 import (
 	"context"
 	"io"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ccmchain/go-ccmchain/log"
@@ -40,12 +42,43 @@ const (
 	OptionSubscriptions = 1 << iota // support pub sub
 )
 
+// Limits represents the resource limits enforced by an RPC server on the requests
+// it serves. Zero values disable the respective limit.
+type Limits struct {
+	// BatchItems is the maximum number of requests allowed in a single batch.
+	// Larger batches are rejected as a whole without executing any of them.
+	BatchItems int `toml:",omitempty"`
+
+	// ResponseSize is the maximum number of result bytes returned for a single
+	// request or a whole batch. Once the limit is hit, the remaining calls of a
+	// batch are not executed any more.
+	ResponseSize int `toml:",omitempty"`
+
+	// ExecutionTimeout is the maximum duration a method call may run for. The
+	// deadline is propagated to the method via its context.
+	ExecutionTimeout time.Duration `toml:",omitempty"`
+
+	// MethodTimeouts overrides the execution timeout of individual methods.
+	MethodTimeouts map[string]time.Duration `toml:",omitempty"`
+}
+
+// timeout returns the execution timeout to apply for the given method.
+func (l Limits) timeout(method string) time.Duration {
+	if timeout, ok := l.MethodTimeouts[method]; ok {
+		return timeout
+	}
+	return l.ExecutionTimeout
+}
+
 // Server is an RPC server.
 type Server struct {
 	services serviceRegistry
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+
+	config     handlerConfig
+	configLock sync.RWMutex
 }
 
 // NewServer creates a new server instance with no registered handlers.
@@ -66,6 +99,33 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// SetLimits sets the resource limits enforced on the requests served. The limits
+// only apply to connections established after the call.
+func (s *Server) SetLimits(limits Limits) {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+
+	s.config.limits = limits
+}
+
+// SetAccessLog enables or disables logging every served method call along with
+// its duration, caller address and error code. The setting only applies to
+// connections established after the call.
+func (s *Server) SetAccessLog(enabled bool) {
+	s.configLock.Lock()
+	defer s.configLock.Unlock()
+
+	s.config.accessLog = enabled
+}
+
+// handlerConfig returns the settings to serve a new connection with.
+func (s *Server) handlerConfig() handlerConfig {
+	s.configLock.RLock()
+	defer s.configLock.RUnlock()
+
+	return s.config
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -83,7 +143,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.handlerConfig())
 	<-codec.Closed()
 	c.Close()
 }
@@ -99,6 +159,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	h.config = s.handlerConfig()
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.Read()
@@ -45,7 +45,7 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	wantCallbacks := 7
+	wantCallbacks := 8
 	if len(svc.callbacks) != wantCallbacks {
 		t.Errorf("Expected %d callbacks for service 'service', got %d", wantCallbacks, len(svc.callbacks))
 	}
@@ -150,3 +150,116 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+// serveTestRequest sends a single line request to the server and returns the
+// response line.
+func serveTestRequest(t *testing.T, server *Server, request string) string {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go server.ServeCodec(NewJSONCodec(serverConn), 0)
+
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(clientConn, request+"\n"); err != nil {
+		t.Fatalf("write error: %v", err)
+	}
+	resp, err := bufio.NewReader(clientConn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read error: %v", err)
+	}
+	return strings.TrimRight(resp, "\r\n")
+}
+
+// Tests that batches with more items than allowed are rejected as a whole.
+func TestServerBatchLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetLimits(Limits{BatchItems: 2})
+
+	tests := []struct {
+		request, response string
+	}{
+		{
+			request:  `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]}]`,
+			response: `[{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}},{"jsonrpc":"2.0","id":2,"result":{"String":"x","Int":2,"Args":null}}]`,
+		},
+		{
+			request:  `[{"jsonrpc":"2.0","method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`,
+			response: `[{"jsonrpc":"2.0","id":2,"error":{"code":-32600,"message":"batch too large, max 2 items allowed"}},{"jsonrpc":"2.0","id":3,"error":{"code":-32600,"message":"batch too large, max 2 items allowed"}}]`,
+		},
+		{
+			request:  `[{"jsonrpc":"2.0","method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","method":"test_echo","params":["x",3]}]`,
+			response: `[{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"batch too large, max 2 items allowed"}}]`,
+		},
+	}
+	for i, tt := range tests {
+		if resp := serveTestRequest(t, server, tt.request); resp != tt.response {
+			t.Errorf("test %d: response mismatch\nhave: %s\nwant: %s", i, resp, tt.response)
+		}
+	}
+}
+
+// Tests that responses exceeding the size limit are replaced by errors, and that
+// batch calls are not executed any more once the limit is hit.
+func TestServerResponseSizeLimit(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetLimits(Limits{ResponseSize: 60})
+
+	tests := []struct {
+		request, response string
+	}{
+		{
+			request:  `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`,
+			response: `{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`,
+		},
+		{
+			request:  `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["` + strings.Repeat("x", 60) + `",1]}`,
+			response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32003,"message":"response too large, max 60 bytes allowed"}}`,
+		},
+		{
+			request:  `[{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]},{"jsonrpc":"2.0","id":2,"method":"test_echo","params":["x",2]},{"jsonrpc":"2.0","id":3,"method":"test_echo","params":["x",3]}]`,
+			response: `[{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}},{"jsonrpc":"2.0","id":2,"error":{"code":-32003,"message":"response too large, max 60 bytes allowed"}},{"jsonrpc":"2.0","id":3,"error":{"code":-32003,"message":"response too large, max 60 bytes allowed"}}]`,
+		},
+	}
+	for i, tt := range tests {
+		if resp := serveTestRequest(t, server, tt.request); resp != tt.response {
+			t.Errorf("test %d: response mismatch\nhave: %s\nwant: %s", i, resp, tt.response)
+		}
+	}
+}
+
+// Tests that method calls running past their execution deadline are failed,
+// honoring the per-method overrides.
+func TestServerExecutionTimeout(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	server.SetLimits(Limits{
+		ExecutionTimeout: 50 * time.Millisecond,
+		MethodTimeouts:   map[string]time.Duration{"test_sleep": 200 * time.Millisecond},
+	})
+	tests := []struct {
+		request, response string
+	}{
+		{
+			request:  `{"jsonrpc":"2.0","id":1,"method":"test_sleep","params":[100000000]}`,
+			response: `{"jsonrpc":"2.0","id":1,"result":null}`,
+		},
+		{
+			request:  `{"jsonrpc":"2.0","id":1,"method":"test_sleep","params":[5000000000]}`,
+			response: `{"jsonrpc":"2.0","id":1,"error":{"code":-32002,"message":"the method test_sleep timed out"}}`,
+		},
+		{
+			request:  `{"jsonrpc":"2.0","id":1,"method":"test_echoWithCtx","params":["x",1]}`,
+			response: `{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`,
+		},
+	}
+	for i, tt := range tests {
+		start := time.Now()
+		if resp := serveTestRequest(t, server, tt.request); resp != tt.response {
+			t.Errorf("test %d: response mismatch\nhave: %s\nwant: %s", i, resp, tt.response)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("test %d: response took too long: %v", i, elapsed)
+		}
+	}
+}
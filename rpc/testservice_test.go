@@ -63,6 +63,15 @@ type Result struct {
 	Args   *Args
 }
 
+type dataError struct {
+	message string
+	data    interface{}
+}
+
+func (e dataError) Error() string          { return e.message }
+func (e dataError) ErrorCode() int         { return 444 }
+func (e dataError) ErrorData() interface{} { return e.data }
+
 func (s *testService) NoArgsRets() {}
 
 func (s *testService) Echo(str string, i int, args *Args) Result {
@@ -77,6 +86,10 @@ func (s *testService) Sleep(ctx context.Context, duration time.Duration) {
 	time.Sleep(duration)
 }
 
+func (s *testService) ReturnError() error {
+	return dataError{"testError", "testError data"}
+}
+
 func (s *testService) Rets() (string, error) {
 	return "", nil
 }
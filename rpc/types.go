@@ -39,6 +39,12 @@ type Error interface {
 	ErrorCode() int // returns the code
 }
 
+// DataError contains extra data to fill in the "data" field of the JSON-RPC error.
+type DataError interface {
+	Error() string          // returns the message
+	ErrorData() interface{} // returns the error data
+}
+
 // ServerCodec implements reading, parsing and writing RPC messages for the server side of
 // a RPC session. Implementations must be go-routine safe since the codec can be called in
 // multiple go-routines concurrently.
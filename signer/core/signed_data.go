@@ -19,6 +19,7 @@ package core
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -83,16 +84,40 @@ type Type struct {
 }
 
 func (t *Type) isArray() bool {
-	return strings.HasSuffix(t.Type, "[]")
+	return strings.HasSuffix(t.Type, "]")
 }
 
-// typeName returns the canonical name of the type. If the type is 'Person[]', then
-// this method returns 'Person'
+// typeName returns the canonical name of the type. If the type is 'Person[]' or
+// 'Person[2][]', then this method returns 'Person'
 func (t *Type) typeName() string {
-	if strings.HasSuffix(t.Type, "[]") {
-		return strings.TrimSuffix(t.Type, "[]")
+	return baseTypeName(t.Type)
+}
+
+// baseTypeName strips all array dimensions from a type name.
+func baseTypeName(typ string) string {
+	if i := strings.IndexByte(typ, '['); i >= 0 {
+		return typ[:i]
 	}
-	return t.Type
+	return typ
+}
+
+// splitArrayType splits the outermost dimension off an array type. For 'uint8[2][]'
+// it returns 'uint8[2]' and -1 (dynamically sized), for 'Person[3]' it returns
+// 'Person' and 3.
+func splitArrayType(typ string) (string, int, error) {
+	open := strings.LastIndexByte(typ, '[')
+	if open < 1 || !strings.HasSuffix(typ, "]") {
+		return "", 0, fmt.Errorf("type '%s' is not an array", typ)
+	}
+	size := typ[open+1 : len(typ)-1]
+	if size == "" {
+		return typ[:open], -1, nil
+	}
+	n, err := strconv.Atoi(size)
+	if err != nil || n < 1 || strconv.Itoa(n) != size {
+		return "", 0, fmt.Errorf("invalid array size in type '%s'", typ)
+	}
+	return typ[:open], n, nil
 }
 
 func (t *Type) isReferenceType() bool {
@@ -120,7 +145,7 @@ type TypedDataDomain struct {
 	Salt              string                `json:"salt"`
 }
 
-var typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\])?$`)
+var typedDataReferenceTypeRegexp = regexp.MustCompile(`^[A-Z](\w*)(\[\d*\])*$`)
 
 // sign receives a request and produces a signature
 //
@@ -314,6 +339,9 @@ func cliqueHeaderHashAndRlp(header *types.Header) (hash, rlp []byte, err error)
 // SignTypedData signs EIP-712 conformant typed data
 // hash = keccak256("\x19${byteVersion}${domainSeparator}${hashStruct(message)}")
 func (api *SignerAPI) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, typedData TypedData) (hexutil.Bytes, error) {
+	if err := typedData.Domain.validateChainId(api.chainID); err != nil {
+		return nil, err
+	}
 	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
 	if err != nil {
 		return nil, err
@@ -365,7 +393,7 @@ func (typedData *TypedData) Dependencies(primaryType string, found []string) []s
 	}
 	found = append(found, primaryType)
 	for _, field := range typedData.Types[primaryType] {
-		for _, dep := range typedData.Dependencies(field.Type, found) {
+		for _, dep := range typedData.Dependencies(field.typeName(), found) {
 			if !includes(found, dep) {
 				found = append(found, dep)
 			}
@@ -417,69 +445,74 @@ func (typedData *TypedData) EncodeData(primaryType string, data map[string]inter
 	if err := typedData.validate(); err != nil {
 		return nil, err
 	}
-
+	fields, ok := typedData.Types[primaryType]
+	if !ok {
+		return nil, fmt.Errorf("unknown type '%s'", primaryType)
+	}
 	buffer := bytes.Buffer{}
 
 	// Verify extra data
-	if len(typedData.Types[primaryType]) < len(data) {
+	if len(fields) < len(data) {
 		return nil, errors.New("there is extra data provided in the message")
 	}
+	for name := range data {
+		if !hasField(fields, name) {
+			return nil, fmt.Errorf("there is extra data provided in the message: '%s' is not a field of '%s'", name, primaryType)
+		}
+	}
 
 	// Add typehash
 	buffer.Write(typedData.TypeHash(primaryType))
 
 	// Add field contents. Structs and arrays have special handlers.
-	for _, field := range typedData.Types[primaryType] {
-		encType := field.Type
-		encValue := data[field.Name]
-		if encType[len(encType)-1:] == "]" {
-			arrayValue, ok := encValue.([]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
-
-			arrayBuffer := bytes.Buffer{}
-			parsedType := strings.Split(encType, "[")[0]
-			for _, item := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, ok := item.(map[string]interface{})
-					if !ok {
-						return nil, dataMismatchError(parsedType, item)
-					}
-					encodedData, err := typedData.EncodeData(parsedType, mapValue, depth+1)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(encodedData)
-				} else {
-					bytesValue, err := typedData.EncodePrimitiveValue(parsedType, item, depth)
-					if err != nil {
-						return nil, err
-					}
-					arrayBuffer.Write(bytesValue)
-				}
-			}
+	for _, field := range fields {
+		encValue, err := typedData.encodeField(field.Type, data[field.Name], depth)
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(encValue)
+	}
+	return buffer.Bytes(), nil
+}
 
-			buffer.Write(crypto.Keccak256(arrayBuffer.Bytes()))
-		} else if typedData.Types[field.Type] != nil {
-			mapValue, ok := encValue.(map[string]interface{})
-			if !ok {
-				return nil, dataMismatchError(encType, encValue)
-			}
-			encodedData, err := typedData.EncodeData(field.Type, mapValue, depth+1)
-			if err != nil {
-				return nil, err
-			}
-			buffer.Write(crypto.Keccak256(encodedData))
-		} else {
-			byteValue, err := typedData.EncodePrimitiveValue(encType, encValue, depth)
+// encodeField encodes a single value of the given type as a 32 byte word. Arrays
+// are encoded as the hash of the concatenated encodings of their items, structs
+// as their hashStruct.
+func (typedData *TypedData) encodeField(encType string, encValue interface{}, depth int) ([]byte, error) {
+	if strings.HasSuffix(encType, "]") {
+		itemType, size, err := splitArrayType(encType)
+		if err != nil {
+			return nil, err
+		}
+		arrayValue, ok := encValue.([]interface{})
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		if size >= 0 && len(arrayValue) != size {
+			return nil, fmt.Errorf("provided array of length %d doesn't match type '%s'", len(arrayValue), encType)
+		}
+		arrayBuffer := bytes.Buffer{}
+		for _, item := range arrayValue {
+			encItem, err := typedData.encodeField(itemType, item, depth+1)
 			if err != nil {
 				return nil, err
 			}
-			buffer.Write(byteValue)
+			arrayBuffer.Write(encItem)
 		}
+		return crypto.Keccak256(arrayBuffer.Bytes()), nil
 	}
-	return buffer.Bytes(), nil
+	if typedData.Types[encType] != nil {
+		mapValue, ok := encValue.(map[string]interface{})
+		if !ok {
+			return nil, dataMismatchError(encType, encValue)
+		}
+		encodedData, err := typedData.EncodeData(encType, mapValue, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(encodedData), nil
+	}
+	return typedData.EncodePrimitiveValue(encType, encValue, depth)
 }
 
 func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
@@ -498,7 +531,7 @@ func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
 			lengthStr = strings.TrimPrefix(encType, "int")
 		}
 		atoiSize, err := strconv.Atoi(lengthStr)
-		if err != nil {
+		if err != nil || atoiSize < 8 || atoiSize > 256 || atoiSize%8 != 0 {
 			return nil, fmt.Errorf("invalid size on integer: %v", lengthStr)
 		}
 		length = atoiSize
@@ -524,12 +557,21 @@ func parseInteger(encType string, encValue interface{}) (*big.Int, error) {
 	if b == nil {
 		return nil, fmt.Errorf("invalid integer value %v/%v for type %v", encValue, reflect.TypeOf(encValue), encType)
 	}
-	if b.BitLen() > length {
-		return nil, fmt.Errorf("integer larger than '%v'", encType)
-	}
 	if !signed && b.Sign() == -1 {
 		return nil, fmt.Errorf("invalid negative value for unsigned type %v", encType)
 	}
+	// Signed integers have one bit less for the magnitude, the negative range
+	// reaching one further than the positive one.
+	magnitude := b
+	if signed {
+		length--
+		if b.Sign() == -1 {
+			magnitude = new(big.Int).Not(b)
+		}
+	}
+	if magnitude.BitLen() > length {
+		return nil, fmt.Errorf("integer larger than '%v'", encType)
+	}
 	return b, nil
 }
 
@@ -561,7 +603,7 @@ func (typedData *TypedData) EncodePrimitiveValue(encType string, encValue interf
 		}
 		return crypto.Keccak256([]byte(strVal)), nil
 	case "bytes":
-		bytesValue, ok := encValue.([]byte)
+		bytesValue, ok := parseBytes(encValue)
 		if !ok {
 			return nil, dataMismatchError(encType, encValue)
 		}
@@ -573,26 +615,48 @@ func (typedData *TypedData) EncodePrimitiveValue(encType string, encValue interf
 		if err != nil {
 			return nil, fmt.Errorf("invalid size on bytes: %v", lengthStr)
 		}
-		if length < 0 || length > 32 {
+		if length < 1 || length > 32 {
 			return nil, fmt.Errorf("invalid size on bytes: %d", length)
 		}
-		if byteValue, ok := encValue.(hexutil.Bytes); !ok {
+		byteValue, ok := parseBytes(encValue)
+		if !ok || len(byteValue) > length {
 			return nil, dataMismatchError(encType, encValue)
-		} else {
-			return math.PaddedBigBytes(new(big.Int).SetBytes(byteValue), 32), nil
 		}
+		// Fixed size byte arrays are left-aligned, like in the ABI
+		retval := make([]byte, 32)
+		copy(retval, byteValue)
+		return retval, nil
 	}
 	if strings.HasPrefix(encType, "int") || strings.HasPrefix(encType, "uint") {
 		b, err := parseInteger(encType, encValue)
 		if err != nil {
 			return nil, err
 		}
-		return abi.U256(b), nil
+		return abi.U256(new(big.Int).Set(b)), nil
 	}
 	return nil, fmt.Errorf("unrecognized type '%s'", encType)
 
 }
 
+// parseBytes converts a byte array value, which is hex encoded if it comes from
+// JSON, into a byte slice.
+func parseBytes(encValue interface{}) ([]byte, bool) {
+	switch v := encValue.(type) {
+	case []byte:
+		return v, true
+	case hexutil.Bytes:
+		return v, true
+	case string:
+		bytes, err := hexutil.Decode(v)
+		if err != nil {
+			return nil, false
+		}
+		return bytes, true
+	default:
+		return nil, false
+	}
+}
+
 // dataMismatchError generates an error for a mismatch between
 // the provided type and data
 func dataMismatchError(encType string, encValue interface{}) error {
@@ -672,6 +736,39 @@ func (typedData *TypedData) validate() error {
 	if err := typedData.Domain.validate(); err != nil {
 		return err
 	}
+	return typedData.validateDomainType()
+}
+
+// domainFieldTypes are the types of the fields EIP-712 allows in the domain.
+var domainFieldTypes = map[string]string{
+	"name":              "string",
+	"version":           "string",
+	"chainId":           "uint256",
+	"verifyingContract": "address",
+	"salt":              "bytes32",
+}
+
+// validateDomainType checks that the EIP712Domain type only consists of the
+// standard domain fields, and declares all fields which are set in the domain.
+func (typedData *TypedData) validateDomainType() error {
+	fields, ok := typedData.Types["EIP712Domain"]
+	if !ok {
+		return errors.New("EIP712Domain type is undefined")
+	}
+	for _, field := range fields {
+		typ, ok := domainFieldTypes[field.Name]
+		if !ok {
+			return fmt.Errorf("unknown domain field '%s'", field.Name)
+		}
+		if field.Type != typ {
+			return fmt.Errorf("domain field '%s' must be of type '%s', not '%s'", field.Name, typ, field.Type)
+		}
+	}
+	for name := range typedData.Domain.Map() {
+		if !hasField(fields, name) {
+			return fmt.Errorf("domain field '%s' is not declared in the EIP712Domain type", name)
+		}
+	}
 	return nil
 }
 
@@ -716,51 +813,55 @@ func (typedData *TypedData) formatData(primaryType string, data map[string]inter
 
 	// Add field contents. Structs and arrays have special handlers.
 	for _, field := range typedData.Types[primaryType] {
-		encName := field.Name
-		encValue := data[encName]
-		item := &NameValueType{
-			Name: encName,
-			Typ:  field.Type,
-		}
-		if field.isArray() {
-			arrayValue, _ := encValue.([]interface{})
-			parsedType := field.typeName()
-			for _, v := range arrayValue {
-				if typedData.Types[parsedType] != nil {
-					mapValue, _ := v.(map[string]interface{})
-					mapOutput, err := typedData.formatData(parsedType, mapValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = mapOutput
-				} else {
-					primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
-					if err != nil {
-						return nil, err
-					}
-					item.Value = primitiveOutput
-				}
-			}
-		} else if typedData.Types[field.Type] != nil {
-			if mapValue, ok := encValue.(map[string]interface{}); ok {
-				mapOutput, err := typedData.formatData(field.Type, mapValue)
-				if err != nil {
-					return nil, err
-				}
-				item.Value = mapOutput
-			} else {
-				item.Value = "<nil>"
+		item, err := typedData.formatField(field.Name, field.Type, data[field.Name])
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, item)
+	}
+	return output, nil
+}
+
+// formatField formats a single value of the given type. Structs and arrays are
+// formatted as nested lists, array items being named by their index.
+func (typedData *TypedData) formatField(name string, encType string, encValue interface{}) (*NameValueType, error) {
+	item := &NameValueType{
+		Name: name,
+		Typ:  encType,
+	}
+	if strings.HasSuffix(encType, "]") {
+		itemType, _, err := splitArrayType(encType)
+		if err != nil {
+			return nil, err
+		}
+		arrayValue, _ := encValue.([]interface{})
+		items := make([]*NameValueType, 0, len(arrayValue))
+		for i, v := range arrayValue {
+			arrayItem, err := typedData.formatField(fmt.Sprintf("[%d]", i), itemType, v)
+			if err != nil {
+				return nil, err
 			}
-		} else {
-			primitiveOutput, err := formatPrimitiveValue(field.Type, encValue)
+			items = append(items, arrayItem)
+		}
+		item.Value = items
+	} else if typedData.Types[encType] != nil {
+		if mapValue, ok := encValue.(map[string]interface{}); ok {
+			mapOutput, err := typedData.formatData(encType, mapValue)
 			if err != nil {
 				return nil, err
 			}
-			item.Value = primitiveOutput
+			item.Value = mapOutput
+		} else {
+			item.Value = "<nil>"
 		}
-		output = append(output, item)
+	} else {
+		primitiveOutput, err := formatPrimitiveValue(encType, encValue)
+		if err != nil {
+			return nil, err
+		}
+		item.Value = primitiveOutput
 	}
-	return output, nil
+	return item, nil
 }
 
 func formatPrimitiveValue(encType string, encValue interface{}) (string, error) {
@@ -777,18 +878,20 @@ func formatPrimitiveValue(encType string, encValue interface{}) (string, error)
 		} else {
 			return fmt.Sprintf("%t", boolValue), nil
 		}
-	case "bytes", "string":
+	case "string":
 		return fmt.Sprintf("%s", encValue), nil
 	}
 	if strings.HasPrefix(encType, "bytes") {
+		if bytesValue, ok := parseBytes(encValue); ok {
+			return hexutil.Encode(bytesValue), nil
+		}
 		return fmt.Sprintf("%s", encValue), nil
-
 	}
 	if strings.HasPrefix(encType, "uint") || strings.HasPrefix(encType, "int") {
 		if b, err := parseInteger(encType, encValue); err != nil {
 			return "", err
 		} else {
-			return fmt.Sprintf("%d (0x%x)", b, b), nil
+			return fmt.Sprintf("%d (%#x)", b, b), nil
 		}
 	}
 	return "", fmt.Errorf("unhandled type %v", encType)
@@ -850,115 +953,50 @@ func (t Types) validate() error {
 	return nil
 }
 
-// Checks if the primitive value is valid
+// hasField reports whccmer the fields of a type contain one of the given name.
+func hasField(fields []Type, name string) bool {
+	for _, field := range fields {
+		if field.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the primitive value is valid. Arrays of primitives, including fixed
+// size and nested arrays, are valid if their item type is.
 func isPrimitiveTypeValid(primitiveType string) bool {
-	if primitiveType == "address" ||
-		primitiveType == "address[]" ||
-		primitiveType == "bool" ||
-		primitiveType == "bool[]" ||
-		primitiveType == "string" ||
-		primitiveType == "string[]" {
-		return true
+	for strings.HasSuffix(primitiveType, "]") {
+		itemType, _, err := splitArrayType(primitiveType)
+		if err != nil {
+			return false
+		}
+		primitiveType = itemType
 	}
-	if primitiveType == "bytes" ||
-		primitiveType == "bytes[]" ||
-		primitiveType == "bytes1" ||
-		primitiveType == "bytes1[]" ||
-		primitiveType == "bytes2" ||
-		primitiveType == "bytes2[]" ||
-		primitiveType == "bytes3" ||
-		primitiveType == "bytes3[]" ||
-		primitiveType == "bytes4" ||
-		primitiveType == "bytes4[]" ||
-		primitiveType == "bytes5" ||
-		primitiveType == "bytes5[]" ||
-		primitiveType == "bytes6" ||
-		primitiveType == "bytes6[]" ||
-		primitiveType == "bytes7" ||
-		primitiveType == "bytes7[]" ||
-		primitiveType == "bytes8" ||
-		primitiveType == "bytes8[]" ||
-		primitiveType == "bytes9" ||
-		primitiveType == "bytes9[]" ||
-		primitiveType == "bytes10" ||
-		primitiveType == "bytes10[]" ||
-		primitiveType == "bytes11" ||
-		primitiveType == "bytes11[]" ||
-		primitiveType == "bytes12" ||
-		primitiveType == "bytes12[]" ||
-		primitiveType == "bytes13" ||
-		primitiveType == "bytes13[]" ||
-		primitiveType == "bytes14" ||
-		primitiveType == "bytes14[]" ||
-		primitiveType == "bytes15" ||
-		primitiveType == "bytes15[]" ||
-		primitiveType == "bytes16" ||
-		primitiveType == "bytes16[]" ||
-		primitiveType == "bytes17" ||
-		primitiveType == "bytes17[]" ||
-		primitiveType == "bytes18" ||
-		primitiveType == "bytes18[]" ||
-		primitiveType == "bytes19" ||
-		primitiveType == "bytes19[]" ||
-		primitiveType == "bytes20" ||
-		primitiveType == "bytes20[]" ||
-		primitiveType == "bytes21" ||
-		primitiveType == "bytes21[]" ||
-		primitiveType == "bytes22" ||
-		primitiveType == "bytes22[]" ||
-		primitiveType == "bytes23" ||
-		primitiveType == "bytes23[]" ||
-		primitiveType == "bytes24" ||
-		primitiveType == "bytes24[]" ||
-		primitiveType == "bytes25" ||
-		primitiveType == "bytes25[]" ||
-		primitiveType == "bytes26" ||
-		primitiveType == "bytes26[]" ||
-		primitiveType == "bytes27" ||
-		primitiveType == "bytes27[]" ||
-		primitiveType == "bytes28" ||
-		primitiveType == "bytes28[]" ||
-		primitiveType == "bytes29" ||
-		primitiveType == "bytes29[]" ||
-		primitiveType == "bytes30" ||
-		primitiveType == "bytes30[]" ||
-		primitiveType == "bytes31" ||
-		primitiveType == "bytes31[]" {
+	switch primitiveType {
+	case "address", "bool", "string", "bytes", "int", "uint":
 		return true
 	}
-	if primitiveType == "int" ||
-		primitiveType == "int[]" ||
-		primitiveType == "int8" ||
-		primitiveType == "int8[]" ||
-		primitiveType == "int16" ||
-		primitiveType == "int16[]" ||
-		primitiveType == "int32" ||
-		primitiveType == "int32[]" ||
-		primitiveType == "int64" ||
-		primitiveType == "int64[]" ||
-		primitiveType == "int128" ||
-		primitiveType == "int128[]" ||
-		primitiveType == "int256" ||
-		primitiveType == "int256[]" {
-		return true
+	var (
+		sizeStr  string
+		min, max int
+	)
+	switch {
+	case strings.HasPrefix(primitiveType, "bytes"):
+		sizeStr, min, max = strings.TrimPrefix(primitiveType, "bytes"), 1, 32
+	case strings.HasPrefix(primitiveType, "int"):
+		sizeStr, min, max = strings.TrimPrefix(primitiveType, "int"), 8, 256
+	case strings.HasPrefix(primitiveType, "uint"):
+		sizeStr, min, max = strings.TrimPrefix(primitiveType, "uint"), 8, 256
+	default:
+		return false
 	}
-	if primitiveType == "uint" ||
-		primitiveType == "uint[]" ||
-		primitiveType == "uint8" ||
-		primitiveType == "uint8[]" ||
-		primitiveType == "uint16" ||
-		primitiveType == "uint16[]" ||
-		primitiveType == "uint32" ||
-		primitiveType == "uint32[]" ||
-		primitiveType == "uint64" ||
-		primitiveType == "uint64[]" ||
-		primitiveType == "uint128" ||
-		primitiveType == "uint128[]" ||
-		primitiveType == "uint256" ||
-		primitiveType == "uint256[]" {
-		return true
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || strconv.Itoa(size) != sizeStr || size < min || size > max {
+		return false
 	}
-	return false
+	// Integer sizes must be a multiple of 8 bits
+	return min == 1 || size%8 == 0
 }
 
 // validate checks if the given domain is valid, i.e. contains at least
@@ -971,7 +1009,55 @@ func (domain *TypedDataDomain) validate() error {
 	if len(domain.Name) == 0 && len(domain.Version) == 0 && len(domain.VerifyingContract) == 0 && len(domain.Salt) == 0 {
 		return errors.New("domain is undefined")
 	}
+	if len(domain.VerifyingContract) > 0 && !common.IsHexAddress(domain.VerifyingContract) {
+		return fmt.Errorf("invalid verifyingContract address '%s'", domain.VerifyingContract)
+	}
+	if len(domain.Salt) > 0 {
+		if salt, err := hexutil.Decode(domain.Salt); err != nil || len(salt) != 32 {
+			return fmt.Errorf("invalid salt '%s', must be 32 bytes", domain.Salt)
+		}
+	}
+	return nil
+}
 
+// validateChainId checks that the domain is bound to the given chain, preventing
+// signatures from being replayed across networks.
+func (domain *TypedDataDomain) validateChainId(chainID *big.Int) error {
+	if domain.ChainId == nil {
+		return errors.New("chainId must be specified according to EIP-155")
+	}
+	if have := (*big.Int)(domain.ChainId); have.Cmp(chainID) != 0 {
+		return fmt.Errorf("domain chainId %v doesn't match signer chainId %v", have, chainID)
+	}
+	return nil
+}
+
+// UnmarshalJSON parses a domain. The chain ID is accepted as a JSON number as
+// well as a hex or decimal string, since dApps commonly send the former.
+func (domain *TypedDataDomain) UnmarshalJSON(input []byte) error {
+	type typedDataDomain TypedDataDomain
+	var dec struct {
+		typedDataDomain
+		ChainId json.RawMessage `json:"chainId"`
+	}
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	*domain = TypedDataDomain(dec.typedDataDomain)
+	domain.ChainId = nil
+
+	if len(dec.ChainId) == 0 || string(dec.ChainId) == "null" {
+		return nil
+	}
+	chainId := new(math.HexOrDecimal256)
+	if dec.ChainId[0] == '"' {
+		if err := json.Unmarshal(dec.ChainId, chainId); err != nil {
+			return err
+		}
+	} else if _, ok := (*big.Int)(chainId).SetString(string(dec.ChainId), 10); !ok {
+		return fmt.Errorf("invalid chainId %s", dec.ChainId)
+	}
+	domain.ChainId = chainId
 	return nil
 }
 
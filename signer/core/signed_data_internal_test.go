@@ -31,6 +31,14 @@ func TestParseInteger(t *testing.T) {
 		{"int32", "-123", big.NewInt(-123)},
 		{"uint32", "0xff", big.NewInt(0xff)},
 		{"int8", "0xffff", nil},
+		{"int8", "127", big.NewInt(127)},
+		{"int8", "128", nil},
+		{"int8", "-128", big.NewInt(-128)},
+		{"int8", "-129", nil},
+		{"uint8", "255", big.NewInt(255)},
+		{"uint8", "256", nil},
+		{"uint7", "1", nil},
+		{"uint264", "1", nil},
 	} {
 		res, err := parseInteger(tt.t, tt.v)
 		if tt.exp == nil && res == nil {
@@ -49,3 +57,22 @@ func TestParseInteger(t *testing.T) {
 		}
 	}
 }
+
+func TestIsPrimitiveTypeValid(t *testing.T) {
+	for _, typ := range []string{
+		"address", "bool", "string", "bytes", "bytes1", "bytes32", "int", "int8", "uint256",
+		"address[]", "bytes32[2]", "uint8[2][]", "string[][3]",
+	} {
+		if !isPrimitiveTypeValid(typ) {
+			t.Errorf("type %q should be valid", typ)
+		}
+	}
+	for _, typ := range []string{
+		"", "byte", "bytes0", "bytes33", "int7", "uint264", "int08", "uint+8",
+		"address[", "address]", "bool[0]", "bool[-1]", "bool[02]", "Person",
+	} {
+		if isPrimitiveTypeValid(typ) {
+			t.Errorf("type %q should be invalid", typ)
+		}
+	}
+}
@@ -221,13 +221,23 @@ func TestSignData(t *testing.T) {
 	// data/typed
 	control.approveCh <- "Y"
 	control.inputCh <- "a_long_password"
-	signature, err = api.SignTypedData(context.Background(), a, typedData)
+	localTypedData := typedData
+	localTypedData.Domain.ChainId = math.NewHexOrDecimal256(1337)
+	signature, err = api.SignTypedData(context.Background(), a, localTypedData)
 	if err != nil {
 		t.Fatal(err)
 	}
 	if signature == nil || len(signature) != 65 {
 		t.Errorf("Expected 65 byte signature (got %d bytes)", len(signature))
 	}
+	// data/typed for a different chain
+	signature, err = api.SignTypedData(context.Background(), a, typedData)
+	if err == nil || !strings.Contains(err.Error(), "doesn't match signer chainId") {
+		t.Errorf("Expected chain id mismatch error, got %v", err)
+	}
+	if signature != nil {
+		t.Errorf("Expected nil-data, got %x", signature)
+	}
 }
 
 func TestDomainChainId(t *testing.T) {
@@ -278,7 +288,7 @@ func TestHashStruct(t *testing.T) {
 		t.Error(err)
 	}
 	domainHash := fmt.Sprintf("0x%s", common.Bytes2Hex(hash))
-	if domainHash != "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+	if domainHash != "0x4ae48e9ce8259d2d065403442262ab51fe905385ecd3a79188a63520f5bbf0c0" {
 		t.Errorf("Expected different domain hashStruct result (got %s)", domainHash)
 	}
 }
@@ -328,6 +338,120 @@ func TestFormatter(t *testing.T) {
 	t.Logf("'%v'\n", string(j))
 }
 
+// TestReferenceVectors checks the encoder against the examples of the EIP-712
+// specification and its reference implementation, and against vectors covering
+// fixed size, nested and struct arrays as well as fixed size byte arrays.
+func TestReferenceVectors(t *testing.T) {
+	tests := []struct {
+		file       string
+		encodeType string
+		domain     string
+		message    string
+		sighash    string
+		signature  string // with the private key keccak256("cow")
+	}{
+		{
+			file:       "eip712_mail.json",
+			encodeType: "Mail(Person from,Person to,string contents)Person(string name,address wallet)",
+			domain:     "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f",
+			message:    "0xc52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e",
+			sighash:    "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2",
+			signature:  "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c",
+		},
+		{
+			file:       "eip712_arrays.json",
+			encodeType: "Mail(Person from,Person[] to,string contents)Person(string name,address[] wallets)",
+			domain:     "0xf2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f",
+			message:    "0xeb4221181ff3f1a83ea7313993ca9218496e424604ba9492bb4052c03d5c3df8",
+			sighash:    "0xa85c2e2b118698e88db68a8105b794a8cc7cec074e89ef991cb4f5f533819cc2",
+			signature:  "0x65cbd956f2fae28a601bebc9b906cea0191744bd4c4247bcd27cd08f8eb6b71c78efdf7a31dc9abee78f492292721f362d296cf86b4538e07b51303b67f749061b",
+		},
+		{
+			file:       "nested_arrays.json",
+			encodeType: "Order(address maker,bytes4[2] tags,int16[2][] matrix,Leg[] legs,bytes memo,bool final)Asset(string symbol,bytes32 id)Leg(Asset asset,uint256 amount,address[] hops)",
+			domain:     "0x3572c27a1cd7010b9fcafa60d558e5f0b53edd695d21f0de54d8759525029d2b",
+			message:    "0xcfea0c64d93c9dfb7ccb4a5081a9d5a065d1ff186913beabc5009c73f11f5da1",
+			sighash:    "0xd65adfd46e785255413ee008f9d8e604689fe941554a7f16f82d6485ad783ae9",
+		},
+	}
+	key := crypto.ToECDSAUnsafe(crypto.Keccak256([]byte("cow")))
+	for _, test := range tests {
+		data, err := ioutil.ReadFile(path.Join("testdata", test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var typedData core.TypedData
+		if err := json.Unmarshal(data, &typedData); err != nil {
+			t.Fatalf("%s: json unmarshalling failed: %v", test.file, err)
+		}
+		if have := string(typedData.EncodeType(typedData.PrimaryType)); have != test.encodeType {
+			t.Errorf("%s: wrong encodeType: have %s, want %s", test.file, have, test.encodeType)
+		}
+		domain, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+		if err != nil {
+			t.Fatalf("%s: domain hashStruct failed: %v", test.file, err)
+		}
+		if domain.String() != test.domain {
+			t.Errorf("%s: wrong domain separator: have %s, want %s", test.file, domain, test.domain)
+		}
+		message, sighash, err := sign(typedData)
+		if err != nil {
+			t.Fatalf("%s: message hashStruct failed: %v", test.file, err)
+		}
+		if have := hexutil.Encode(message); have != test.message {
+			t.Errorf("%s: wrong message hash: have %s, want %s", test.file, have, test.message)
+		}
+		if have := hexutil.Encode(sighash); have != test.sighash {
+			t.Errorf("%s: wrong signing hash: have %s, want %s", test.file, have, test.sighash)
+		}
+		if test.signature == "" {
+			continue
+		}
+		sig, err := crypto.Sign(sighash, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+		if have := hexutil.Encode(sig); have != test.signature {
+			t.Errorf("%s: wrong signature: have %s, want %s", test.file, have, test.signature)
+		}
+	}
+}
+
+func TestFormatArrays(t *testing.T) {
+	data, err := ioutil.ReadFile(path.Join("testdata", "nested_arrays.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var typedData core.TypedData
+	if err := json.Unmarshal(data, &typedData); err != nil {
+		t.Fatalf("json unmarshalling failed: %v", err)
+	}
+	formatted, err := typedData.Format()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var output strings.Builder
+	for _, item := range formatted {
+		output.WriteString(strings.Replace(item.Pprint(0), "\u00a0", " ", -1))
+	}
+	for _, want := range []string{
+		"tags [bytes4[2]]: \n",
+		"[1] [bytes4]: \"0x01020304\"",
+		"matrix [int16[2][]]: \n",
+		"    [0] [int16]: \"-32768 (-0x8000)\"",
+		"legs [Leg[]]: \n",
+		"asset [Asset]: \n",
+		"symbol [string]: \"SILVER\"",
+		"hops [address[]]: \n",
+		"memo [bytes]: \"0x48656c6c6f\"",
+	} {
+		if !strings.Contains(output.String(), want) {
+			t.Errorf("formatted output doesn't contain %q:\n%s", want, output.String())
+		}
+	}
+}
+
 func sign(typedData core.TypedData) ([]byte, []byte, error) {
 	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
 	if err != nil {
@@ -0,0 +1,451 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/math"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/ccmapi"
+	"github.com/ccmchain/go-ccmchain/log"
+	"github.com/ccmchain/go-ccmchain/signer/core"
+	"github.com/ccmchain/go-ccmchain/signer/fourbyte"
+	"github.com/ccmchain/go-ccmchain/signer/storage"
+	"gopkg.in/yaml.v2"
+)
+
+// Listing modes of a policy.
+const (
+	ListingManual   = ""         // Forward listing requests to the next UI
+	ListingApprove  = "approve"  // List all accounts
+	ListingRestrict = "restrict" // List only the accounts covered by the policy
+	ListingReject   = "reject"   // Reject all listing requests
+)
+
+// Policy is a declarative rule set for auto-approving requests, the audit-friendly
+// counterpart of a javascript rule file.
+//
+// Requests concerning accounts not mentioned in the policy are forwarded to the
+// next UI for manual processing. Requests from covered accounts are approved if
+// they satisfy all constraints of the account, and rejected otherwise.
+type Policy struct {
+	Listing  string                            `json:"listing" yaml:"listing"`   // Listing mode, one of the Listing* constants
+	Window   *TimeWindow                       `json:"window" yaml:"window"`     // Default time window for all accounts
+	Accounts map[common.Address]*AccountPolicy `json:"accounts" yaml:"accounts"` // Per-account constraints
+}
+
+// AccountPolicy are the constraints on the requests of a single account.
+type AccountPolicy struct {
+	Recipients  []common.Address      `json:"recipients" yaml:"recipients"`   // Allowed transaction recipients, any if empty
+	AllowCreate bool                  `json:"allowCreate" yaml:"allowCreate"` // Whccmer contract creation is allowed
+	DailyLimit  *math.HexOrDecimal256 `json:"dailyLimit" yaml:"dailyLimit"`   // Maximum value transferred per UTC day, in wei
+	Methods     []string              `json:"methods" yaml:"methods"`         // Allowed method signatures or 4byte selectors, any if empty
+	SignData    []string              `json:"signData" yaml:"signData"`       // Content types which may be signed, none if empty
+	Window      *TimeWindow           `json:"window" yaml:"window"`           // Time window overriding the policy default
+
+	selectors map[[4]byte]string // Allowed selectors, mapped to their (possibly unknown) signatures
+}
+
+// TimeWindow restricts approvals to certain hours of certain weekdays, in UTC.
+type TimeWindow struct {
+	Days []string `json:"days" yaml:"days"` // Abbreviated weekday names (Mon, Tue, ...), every day if empty
+	From string   `json:"from" yaml:"from"` // Start of the window as HH:MM, inclusive
+	To   string   `json:"to" yaml:"to"`     // End of the window as HH:MM, exclusive
+
+	days     map[time.Weekday]bool
+	from, to int // Minutes since midnight
+}
+
+// errPolicyViolation is returned internally if a request is covered by the policy
+// but does not satisfy its constraints.
+var errPolicyViolation = errors.New("policy violation")
+
+// ParsePolicy decodes a JSON or YAML policy and validates its content. Policies
+// starting with an opening brace are decoded as JSON, anything else as YAML.
+func ParsePolicy(blob []byte, db *fourbyte.Database) (*Policy, error) {
+	policy := new(Policy)
+	if bytes.HasPrefix(bytes.TrimSpace(blob), []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(blob))
+		dec.DisallowUnknownFields()
+
+		if err := dec.Decode(policy); err != nil {
+			return nil, fmt.Errorf("invalid policy: %v", err)
+		}
+	} else if err := yaml.UnmarshalStrict(blob, policy); err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+	switch policy.Listing {
+	case ListingManual, ListingApprove, ListingRestrict, ListingReject:
+	default:
+		return nil, fmt.Errorf("invalid listing mode %q", policy.Listing)
+	}
+	if policy.Window != nil {
+		if err := policy.Window.init(); err != nil {
+			return nil, fmt.Errorf("invalid default window: %v", err)
+		}
+	}
+	for addr, account := range policy.Accounts {
+		if account == nil {
+			return nil, fmt.Errorf("account %x: missing constraints", addr)
+		}
+		if account.Window != nil {
+			if err := account.Window.init(); err != nil {
+				return nil, fmt.Errorf("account %x: invalid window: %v", addr, err)
+			}
+		}
+		account.selectors = make(map[[4]byte]string)
+		for _, method := range account.Methods {
+			id, sig, err := parseMethod(method, db)
+			if err != nil {
+				return nil, fmt.Errorf("account %x: %v", addr, err)
+			}
+			account.selectors[id] = sig
+		}
+	}
+	return policy, nil
+}
+
+// parseMethod converts a method allow-list entry into a 4byte selector. Entries
+// can be raw selectors (0xa9059cbb), in which case the signature is looked up
+// in the 4byte database, or method signatures (transfer(address,uint256)).
+func parseMethod(method string, db *fourbyte.Database) ([4]byte, string, error) {
+	var id [4]byte
+	if strings.HasPrefix(method, "0x") {
+		raw, err := hex.DecodeString(method[2:])
+		if err != nil || len(raw) != 4 {
+			return id, "", fmt.Errorf("invalid method selector %q", method)
+		}
+		copy(id[:], raw)
+
+		sig := method
+		if db != nil {
+			if known, err := db.Selector(raw); err == nil {
+				sig = known
+			}
+		}
+		return id, sig, nil
+	}
+	open, close := strings.IndexByte(method, '('), strings.LastIndexByte(method, ')')
+	if open < 1 || close != len(method)-1 || strings.ContainsAny(method, " \t") {
+		return id, "", fmt.Errorf("invalid method signature %q", method)
+	}
+	copy(id[:], crypto.Keccak256([]byte(method))[:4])
+	return id, method, nil
+}
+
+// init parses the textual fields of the window.
+func (w *TimeWindow) init() error {
+	w.days = make(map[time.Weekday]bool)
+	for _, day := range w.Days {
+		wd, err := parseWeekday(day)
+		if err != nil {
+			return err
+		}
+		w.days[wd] = true
+	}
+	var err error
+	if w.from, err = parseClock(w.From, 0); err != nil {
+		return err
+	}
+	if w.to, err = parseClock(w.To, 24*60); err != nil {
+		return err
+	}
+	if w.from >= w.to {
+		return fmt.Errorf("window start %q not before end %q", w.From, w.To)
+	}
+	return nil
+}
+
+// contains reports whccmer the given time falls into the window.
+func (w *TimeWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	if len(w.days) > 0 && !w.days[t.Weekday()] {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	return minute >= w.from && minute < w.to
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		if strings.EqualFold(s, wd.String()[:3]) {
+			return wd, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+func parseClock(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		if s == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// policyUI provides an implementation of UIClientAPI that evaluates a declarative
+// policy for each approval request.
+type policyUI struct {
+	next    core.UIClientAPI // The next handler, for manual processing
+	storage storage.Storage  // Persistent spend counters
+	db      *fourbyte.Database
+	policy  *Policy
+
+	spendLock sync.Mutex // Lock serializing the check and reservation of daily spends
+
+	now func() time.Time // Clock, overridable for testing
+}
+
+// NewPolicyEvaluator creates a rule engine evaluating a declarative policy. The
+// 4byte database is used to resolve method selectors and may be nil.
+func NewPolicyEvaluator(next core.UIClientAPI, db *fourbyte.Database, backend storage.Storage) (*policyUI, error) {
+	return &policyUI{
+		next:    next,
+		storage: backend,
+		db:      db,
+		policy:  new(Policy),
+		now:     time.Now,
+	}, nil
+}
+
+func (r *policyUI) RegisterUIServer(api *core.UIServerAPI) {
+	r.next.RegisterUIServer(api)
+}
+
+// Init loads the given JSON or YAML policy.
+func (r *policyUI) Init(policy []byte) error {
+	p, err := ParsePolicy(policy, r.db)
+	if err != nil {
+		return err
+	}
+	r.policy = p
+	return nil
+}
+
+// account returns the constraints of an account, or nil if the account isn't
+// covered by the policy.
+func (r *policyUI) account(addr common.Address) *AccountPolicy {
+	return r.policy.Accounts[addr]
+}
+
+// checkWindow verifies that the current time is within the account's time window.
+func (r *policyUI) checkWindow(account *AccountPolicy) error {
+	window := r.policy.Window
+	if account.Window != nil {
+		window = account.Window
+	}
+	if window != nil && !window.contains(r.now()) {
+		return fmt.Errorf("%v: outside of time window", errPolicyViolation)
+	}
+	return nil
+}
+
+// spentKey returns the storage key of the daily spend counter of an account.
+func spentKey(addr common.Address, day time.Time) string {
+	return fmt.Sprintf("spent-%x-%s", addr, day.UTC().Format("2006-01-02"))
+}
+
+// spent returns the value transferred by the account on the given day, or nil
+// if it cannot be determined.
+func (r *policyUI) spent(addr common.Address, day time.Time) *big.Int {
+	val, err := r.storage.Get(spentKey(addr, day))
+	if err == storage.ErrNotFound {
+		return new(big.Int)
+	}
+	if err != nil {
+		log.Warn("Failed to read spend counter, assuming limit reached", "account", addr, "err", err)
+		return nil
+	}
+	spent, ok := new(big.Int).SetString(val, 10)
+	if !ok {
+		log.Warn("Corrupt spend counter, assuming limit reached", "account", addr, "value", val)
+		return nil
+	}
+	return spent
+}
+
+// checkTx verifies a transaction request against the account constraints. The
+// value of approved transactions is reserved against the daily spending cap, so
+// the caller must hold the spend lock.
+func (r *policyUI) checkTx(account *AccountPolicy, request *core.SignTxRequest) error {
+	if err := r.checkWindow(account); err != nil {
+		return err
+	}
+	tx := &request.Transaction
+	for _, info := range request.Callinfo {
+		if info.Typ == core.CRIT {
+			return fmt.Errorf("%v: %s", errPolicyViolation, info.Message)
+		}
+	}
+	// Check the recipient
+	if tx.To == nil {
+		if !account.AllowCreate {
+			return fmt.Errorf("%v: contract creation not allowed", errPolicyViolation)
+		}
+	} else if len(account.Recipients) > 0 {
+		var allowed bool
+		for _, recipient := range account.Recipients {
+			if recipient == tx.To.Address() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%v: recipient %v not allowed", errPolicyViolation, tx.To.Address())
+		}
+	}
+	// Check the called method
+	if len(account.selectors) > 0 {
+		var data []byte
+		if tx.Data != nil {
+			data = *tx.Data
+		} else if tx.Input != nil {
+			data = *tx.Input
+		}
+		if len(data) < 4 {
+			return fmt.Errorf("%v: no method called", errPolicyViolation)
+		}
+		var id [4]byte
+		copy(id[:], data[:4])
+		if _, ok := account.selectors[id]; !ok {
+			method := fmt.Sprintf("0x%x", id)
+			if r.db != nil {
+				if sig, err := r.db.Selector(id[:]); err == nil {
+					method = sig
+				}
+			}
+			return fmt.Errorf("%v: method %s not allowed", errPolicyViolation, method)
+		}
+	}
+	// Check the daily spending cap and reserve the value against it
+	if account.DailyLimit != nil {
+		now := r.now()
+		spent := r.spent(tx.From.Address(), now)
+		if spent == nil {
+			return fmt.Errorf("%v: unknown daily spend", errPolicyViolation)
+		}
+		total := new(big.Int).Add(spent, tx.Value.ToInt())
+		if total.Cmp((*big.Int)(account.DailyLimit)) > 0 {
+			return fmt.Errorf("%v: daily limit exceeded", errPolicyViolation)
+		}
+		r.storage.Put(spentKey(tx.From.Address(), now), total.String())
+	}
+	return nil
+}
+
+func (r *policyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	account := r.account(request.Transaction.From.Address())
+	if account == nil {
+		return r.next.ApproveTx(request)
+	}
+	r.spendLock.Lock()
+	err := r.checkTx(account, request)
+	r.spendLock.Unlock()
+
+	if err != nil {
+		log.Info("Op rejected", "err", err)
+		return core.SignTxResponse{Approved: false}, nil
+	}
+	log.Info("Op approved")
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+func (r *policyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	account := r.account(request.Address.Address())
+	if account == nil || len(account.SignData) == 0 {
+		return r.next.ApproveSignData(request)
+	}
+	if err := r.checkWindow(account); err != nil {
+		log.Info("Op rejected", "err", err)
+		return core.SignDataResponse{Approved: false}, nil
+	}
+	for _, contentType := range account.SignData {
+		if contentType == request.ContentType {
+			log.Info("Op approved")
+			return core.SignDataResponse{Approved: true}, nil
+		}
+	}
+	log.Info("Op rejected", "err", fmt.Errorf("%v: content type %s not allowed", errPolicyViolation, request.ContentType))
+	return core.SignDataResponse{Approved: false}, nil
+}
+
+func (r *policyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	switch r.policy.Listing {
+	case ListingApprove:
+		return core.ListResponse{Accounts: request.Accounts}, nil
+	case ListingRestrict:
+		var listed = request.Accounts[:0:0]
+		for _, acc := range request.Accounts {
+			if r.account(acc.Address) != nil {
+				listed = append(listed, acc)
+			}
+		}
+		return core.ListResponse{Accounts: listed}, nil
+	case ListingReject:
+		return core.ListResponse{}, nil
+	default:
+		return r.next.ApproveListing(request)
+	}
+}
+
+// OnInputRequired not handled by rules
+func (r *policyUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return r.next.OnInputRequired(info)
+}
+
+func (r *policyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	// This cannot be handled by rules, requires setting a password
+	// dispatch to next
+	return r.next.ApproveNewAccount(request)
+}
+
+func (r *policyUI) ShowError(message string) {
+	log.Error(message)
+	r.next.ShowError(message)
+}
+
+func (r *policyUI) ShowInfo(message string) {
+	log.Info(message)
+	r.next.ShowInfo(message)
+}
+
+func (r *policyUI) OnSignerStartup(info core.StartupInfo) {
+	r.next.OnSignerStartup(info)
+}
+
+// OnApprovedTx is not forwarded, mirroring the javascript rule engine. The value
+// of the transaction was already reserved against the daily spending cap when it
+// was approved, so concurrent approvals can't overshoot the cap. Reservations are
+// kept even if signing fails afterwards, erring on the side of caution.
+func (r *policyUI) OnApprovedTx(tx ccmapi.SignTransactionResult) {
+}
@@ -0,0 +1,406 @@
+// Copyright 2019 The go-ccmchain Authors
+// This file is part of the go-ccmchain library.
+//
+// The go-ccmchain library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ccmchain library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ccmchain library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ccmchain/go-ccmchain/accounts"
+	"github.com/ccmchain/go-ccmchain/common"
+	"github.com/ccmchain/go-ccmchain/common/hexutil"
+	"github.com/ccmchain/go-ccmchain/core/types"
+	"github.com/ccmchain/go-ccmchain/crypto"
+	"github.com/ccmchain/go-ccmchain/internal/ccmapi"
+	"github.com/ccmchain/go-ccmchain/signer/core"
+	"github.com/ccmchain/go-ccmchain/signer/storage"
+)
+
+var (
+	policyKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	policyAddr   = crypto.PubkeyToAddress(policyKey.PublicKey)
+	// A wednesday, at noon.
+	policyTime = time.Date(2019, time.November, 13, 12, 0, 0, 0, time.UTC)
+)
+
+var examplePolicy = `{
+	"listing": "restrict",
+	"window": {"days": ["Mon", "Tue", "Wed", "Thu", "Fri"], "from": "08:00", "to": "18:00"},
+	"accounts": {
+		"` + policyAddr.Hex() + `": {
+			"recipients": ["0x000000000000000000000000000000000000dead", "0x000000000000000000000000000000000000c0de"],
+			"dailyLimit": "1000000000000000000",
+			"methods": ["transfer(address,uint256)", "0x095ea7b3"],
+			"signData": ["text/plain"]
+		},
+		"0x000000000000000000000000000000000000beef": {
+			"allowCreate": true,
+			"window": {"from": "00:00", "to": "24:00"}
+		}
+	}
+}`
+
+// exampleYAMLPolicy is the YAML equivalent of examplePolicy.
+var exampleYAMLPolicy = `
+# Only list the accounts covered by the policy
+listing: restrict
+window:
+  days: [Mon, Tue, Wed, Thu, Fri]
+  from: "08:00"
+  to: "18:00"
+accounts:
+  ` + policyAddr.Hex() + `:
+    recipients:
+      - 0x000000000000000000000000000000000000dead
+      - 0x000000000000000000000000000000000000c0de
+    dailyLimit: 1000000000000000000
+    methods:
+      - transfer(address,uint256)
+      - 0x095ea7b3
+    signData: [text/plain]
+  0x000000000000000000000000000000000000beef:
+    allowCreate: true
+    window: {from: "00:00", to: "24:00"}
+`
+
+func initPolicyEngine(t *testing.T, next core.UIClientAPI, policy string) *policyUI {
+	r, err := NewPolicyEvaluator(next, nil, storage.NewEphemeralStorage())
+	if err != nil {
+		t.Fatalf("failed to create policy engine: %v", err)
+	}
+	if err := r.Init([]byte(policy)); err != nil {
+		t.Fatalf("failed to load policy: %v", err)
+	}
+	r.now = func() time.Time { return policyTime }
+	return r
+}
+
+func policyTx(from common.Address, to string, value *big.Int, data []byte) *core.SignTxRequest {
+	req := &core.SignTxRequest{
+		Transaction: core.SendTxArgs{
+			From:     common.NewMixedcaseAddress(from),
+			Value:    hexutil.Big(*value),
+			Gas:      hexutil.Uint64(50000),
+			GasPrice: hexutil.Big(*big.NewInt(2000000)),
+		},
+		Meta: core.Metadata{Remote: "remoteip", Local: "localip", Scheme: "inproc"},
+	}
+	if to != "" {
+		addr, _ := mixAddr(to)
+		req.Transaction.To = addr
+	}
+	if data != nil {
+		input := hexutil.Bytes(data)
+		req.Transaction.Data = &input
+	}
+	return req
+}
+
+func TestPolicyParse(t *testing.T) {
+	tests := []struct {
+		policy string
+		err    string
+	}{
+		{policy: examplePolicy},
+		{policy: `{}`},
+		{policy: `{"listing": "sometimes"}`, err: "invalid listing mode"},
+		{policy: `{"unknown": true}`, err: "unknown field"},
+		{policy: `{"window": {"days": ["Someday"]}}`, err: "invalid weekday"},
+		{policy: `{"window": {"from": "18:00", "to": "08:00"}}`, err: "not before end"},
+		{policy: `{"window": {"from": "8am"}}`, err: "invalid time of day"},
+		{policy: `{"accounts": {"0x000000000000000000000000000000000000dead": null}}`, err: "missing constraints"},
+		{policy: `{"accounts": {"0x000000000000000000000000000000000000dead": {"methods": ["0x1234"]}}}`, err: "invalid method selector"},
+		{policy: `{"accounts": {"0x000000000000000000000000000000000000dead": {"methods": ["transfer"]}}}`, err: "invalid method signature"},
+		{policy: exampleYAMLPolicy},
+		{policy: ``},
+		{policy: `listing: sometimes`, err: "invalid listing mode"},
+		{policy: `unknown: true`, err: "not found in type"},
+		{policy: `window: {from: "18:00", to: "08:00"}`, err: "not before end"},
+		{policy: "accounts:\n  0xdead:\n    allowCreate: true", err: "invalid policy"},
+		{policy: "accounts:\n  0x000000000000000000000000000000000000dead:\n    methods: [0x1234]", err: "invalid method selector"},
+	}
+	for i, test := range tests {
+		_, err := ParsePolicy([]byte(test.policy), nil)
+		if test.err == "" && err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("test %d: wrong error: have %v, want %q", i, err, test.err)
+		}
+	}
+}
+
+// Tests that the YAML and JSON encodings of the same policy are equivalent.
+func TestPolicyYAML(t *testing.T) {
+	want, err := ParsePolicy([]byte(examplePolicy), nil)
+	if err != nil {
+		t.Fatalf("failed to parse JSON policy: %v", err)
+	}
+	have, err := ParsePolicy([]byte(exampleYAMLPolicy), nil)
+	if err != nil {
+		t.Fatalf("failed to parse YAML policy: %v", err)
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("policy mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	for addr, account := range want.Accounts {
+		if !reflect.DeepEqual(have.Accounts[addr], account) {
+			t.Errorf("account %x: policy mismatch:\nhave %+v\nwant %+v", addr, have.Accounts[addr], account)
+		}
+	}
+}
+
+// TestPolicyForwarding tests that requests not covered by the policy are
+// dispatched to the next UI.
+func TestPolicyForwarding(t *testing.T) {
+	ui := &dummyUI{make([]string, 0)}
+	r := initPolicyEngine(t, ui, `{}`)
+
+	other := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	r.ApproveTx(policyTx(other, "0x000000000000000000000000000000000000dead", big.NewInt(1), nil))
+	r.ApproveSignData(&core.SignDataRequest{Address: common.NewMixedcaseAddress(other)})
+	r.ApproveNewAccount(nil)
+	r.ApproveListing(nil)
+	r.ShowError("test")
+	r.ShowInfo("test")
+
+	//This one is not forwarded
+	r.OnApprovedTx(ccmapi.SignTransactionResult{})
+
+	expCalls := 6
+	if len(ui.calls) != expCalls {
+		t.Errorf("Expected %d forwarded calls, got %d: %s", expCalls, len(ui.calls), strings.Join(ui.calls, ","))
+	}
+}
+
+func TestPolicyApproveTx(t *testing.T) {
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+
+	transfer := append(crypto.Keccak256([]byte("transfer(address,uint256)"))[:4], make([]byte, 64)...)
+	approve := append(common.FromHex("0x095ea7b3"), make([]byte, 64)...)
+	tests := []struct {
+		req  *core.SignTxRequest
+		want bool
+	}{
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(0), transfer), true},
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000c0de", big.NewInt(0), approve), true},
+		// Recipient not allowed
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000f00d", big.NewInt(0), transfer), false},
+		// Contract creation not allowed
+		{policyTx(policyAddr, "", big.NewInt(0), transfer), false},
+		// Method not allowed, or no method at all
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(0), []byte{1, 2, 3, 4}), false},
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(0), nil), false},
+		// Above the daily limit
+		{policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(2e18), transfer), false},
+		// Unrestricted account
+		{policyTx(common.HexToAddress("0xbeef"), "", big.NewInt(5e18), nil), true},
+		{policyTx(common.HexToAddress("0xbeef"), "0x000000000000000000000000000000000000f00d", big.NewInt(0), nil), true},
+	}
+	for i, test := range tests {
+		resp, err := r.ApproveTx(test.req)
+		if err != nil {
+			t.Errorf("test %d: unexpected error: %v", i, err)
+		}
+		if resp.Approved != test.want {
+			t.Errorf("test %d: approved = %v, want %v", i, resp.Approved, test.want)
+		}
+	}
+	// Critical validation warnings reject the transaction
+	req := tests[0].req
+	req.Callinfo = []core.ValidationInfo{{Typ: core.CRIT, Message: "transaction to the zero address"}}
+	if resp, _ := r.ApproveTx(req); resp.Approved {
+		t.Errorf("transaction with critical validation message approved")
+	}
+}
+
+func TestPolicyWindow(t *testing.T) {
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+
+	tests := []struct {
+		now        time.Time
+		want, beef bool
+	}{
+		{policyTime, true, true},
+		{time.Date(2019, time.November, 13, 8, 0, 0, 0, time.UTC), true, true},
+		{time.Date(2019, time.November, 13, 7, 59, 0, 0, time.UTC), false, true},
+		{time.Date(2019, time.November, 13, 18, 0, 0, 0, time.UTC), false, true},
+		// Saturday
+		{time.Date(2019, time.November, 16, 12, 0, 0, 0, time.UTC), false, true},
+		// Same instant in a different zone
+		{time.Date(2019, time.November, 13, 13, 0, 0, 0, time.FixedZone("CET", 3600)), true, true},
+	}
+	for i, test := range tests {
+		r.now = func() time.Time { return test.now }
+
+		resp, _ := r.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(0), common.FromHex("0x095ea7b3")))
+		if resp.Approved != test.want {
+			t.Errorf("test %d: approved = %v, want %v", i, resp.Approved, test.want)
+		}
+		resp, _ = r.ApproveTx(policyTx(common.HexToAddress("0xbeef"), "", big.NewInt(0), nil))
+		if resp.Approved != test.beef {
+			t.Errorf("test %d: approved = %v for account with own window, want %v", i, resp.Approved, test.beef)
+		}
+	}
+}
+
+func TestPolicyDailyLimit(t *testing.T) {
+	var (
+		db     = storage.NewEphemeralStorage()
+		signer = types.NewEIP155Signer(big.NewInt(1))
+		value  = big.NewInt(4e17) // 0.4 ccmer
+		data   = common.FromHex("0x095ea7b3")
+	)
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+	r.storage = db
+
+	// The first two should succeed
+	for i := 0; i < 2; i++ {
+		resp, err := r.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", value, data))
+		if err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+		if !resp.Approved {
+			t.Fatalf("Expected transaction %d to be approved", i)
+		}
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), common.HexToAddress("0xdead"), value, 50000, big.NewInt(2000000), data), signer, policyKey)
+		r.OnApprovedTx(ccmapi.SignTransactionResult{Tx: tx})
+	}
+	// Third should fail, the limit is reached
+	if resp, _ := r.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", value, data)); resp.Approved {
+		t.Fatalf("Expected transaction above daily limit to be rejected")
+	}
+	// The counter is persisted, a new engine on the same storage keeps rejecting
+	r2 := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+	r2.storage = db
+	if resp, _ := r2.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", value, data)); resp.Approved {
+		t.Fatalf("Expected transaction above persisted daily limit to be rejected")
+	}
+	// The next day, there's a fresh allowance
+	r2.now = func() time.Time { return policyTime.Add(24 * time.Hour) }
+	if resp, _ := r2.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", value, data)); !resp.Approved {
+		t.Fatalf("Expected transaction on the next day to be approved")
+	}
+}
+
+// Tests that concurrent approvals can't overshoot the daily spending cap.
+func TestPolicyDailyLimitConcurrent(t *testing.T) {
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+
+	var (
+		value    = big.NewInt(4e17) // 0.4 ccmer
+		data     = common.FromHex("0x095ea7b3")
+		lock     sync.Mutex
+		approved int
+		pend     sync.WaitGroup
+	)
+	for i := 0; i < 16; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			resp, err := r.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", value, data))
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if resp.Approved {
+				lock.Lock()
+				approved++
+				lock.Unlock()
+			}
+		}()
+	}
+	pend.Wait()
+
+	if approved != 2 {
+		t.Errorf("approved transactions mismatch: have %d, want %d", approved, 2)
+	}
+}
+
+// failingStorage is a storage which fails all reads.
+type failingStorage struct{}
+
+func (failingStorage) Put(key, value string)          {}
+func (failingStorage) Get(key string) (string, error) { return "", errors.New("decryption failed") }
+func (failingStorage) Del(key string)                 {}
+
+// Tests that transactions are rejected if the daily spend cannot be determined.
+func TestPolicyDailyLimitStorageFailure(t *testing.T) {
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+	r.storage = failingStorage{}
+
+	if resp, _ := r.ApproveTx(policyTx(policyAddr, "0x000000000000000000000000000000000000dead", big.NewInt(1), common.FromHex("0x095ea7b3"))); resp.Approved {
+		t.Fatalf("Expected transaction with unknown daily spend to be rejected")
+	}
+	// Accounts without a spending cap don't need the counters
+	if resp, _ := r.ApproveTx(policyTx(common.HexToAddress("0xbeef"), "", big.NewInt(1), nil)); !resp.Approved {
+		t.Fatalf("Expected transaction without daily limit to be approved")
+	}
+}
+
+func TestPolicySignData(t *testing.T) {
+	r := initPolicyEngine(t, &dontCallMe{t}, examplePolicy)
+
+	req := &core.SignDataRequest{ContentType: "text/plain", Address: common.NewMixedcaseAddress(policyAddr)}
+	if resp, _ := r.ApproveSignData(req); !resp.Approved {
+		t.Errorf("Expected text/plain signing to be approved")
+	}
+	req.ContentType = "data/typed"
+	if resp, _ := r.ApproveSignData(req); resp.Approved {
+		t.Errorf("Expected data/typed signing to be rejected")
+	}
+	r.now = func() time.Time { return policyTime.Add(12 * time.Hour) }
+	req.ContentType = "text/plain"
+	if resp, _ := r.ApproveSignData(req); resp.Approved {
+		t.Errorf("Expected signing outside of the window to be rejected")
+	}
+}
+
+func TestPolicyListing(t *testing.T) {
+	accs := []accounts.Account{
+		{Address: policyAddr, URL: accounts.URL{Scheme: "test", Path: "acc-0"}},
+		{Address: common.HexToAddress("0x1"), URL: accounts.URL{Scheme: "test", Path: "acc-1"}},
+		{Address: common.HexToAddress("0xbeef"), URL: accounts.URL{Scheme: "test", Path: "acc-2"}},
+	}
+	tests := []struct {
+		listing string
+		want    int
+	}{
+		{ListingApprove, 3},
+		{ListingRestrict, 2},
+		{ListingReject, 0},
+	}
+	for _, test := range tests {
+		policy := strings.Replace(examplePolicy, `"listing": "restrict"`, `"listing": "`+test.listing+`"`, 1)
+		r := initPolicyEngine(t, &dontCallMe{t}, policy)
+		resp, err := r.ApproveListing(&core.ListRequest{Accounts: accs})
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.listing, err)
+		}
+		if len(resp.Accounts) != test.want {
+			t.Errorf("%s: listed %d accounts, want %d", test.listing, len(resp.Accounts), test.want)
+		}
+	}
+	if len(accs) != 3 || accs[1].Address != common.HexToAddress("0x1") {
+		t.Errorf("listing request was modified")
+	}
+}